@@ -0,0 +1,230 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/admission"
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/manifest"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/akrzos/kubeSize/internal/source"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Rejects or warns on new workloads whose requests would push the cluster or node role commitment beyond the ceiling,
+// the capacity data is refreshed every interval instead of on every request
+type capacityGuard struct {
+	source    source.CapacitySource
+	filter    kubesize.PodFilter
+	ceiling   float64
+	warnOnly  bool
+	mutex     sync.RWMutex
+	cluster   *output.ClusterCapacityData
+	nodeRoles map[string]*output.ClusterCapacityData
+}
+
+var admissionCmd = &cobra.Command{
+	Use:   "admission",
+	Short: "Serve a validating webhook that guards cluster capacity",
+	Long:  `Serve a validating admission webhook that rejects or warns on new Deployments and Jobs whose requests would push cluster or node role commitment beyond a ceiling`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			return fmt.Errorf("interval must be greater than 0")
+		}
+
+		ceiling, _ := cmd.Flags().GetFloat64("ceiling")
+		if ceiling <= 0 {
+			return fmt.Errorf("ceiling must be greater than 0")
+		}
+
+		warnOnly, _ := cmd.Flags().GetBool("warn-only")
+
+		listenAddress, _ := cmd.Flags().GetString("listen-address")
+
+		tlsCertFile, _ := cmd.Flags().GetString("tls-cert-file")
+
+		tlsKeyFile, _ := cmd.Flags().GetString("tls-key-file")
+		if tlsCertFile == "" || tlsKeyFile == "" {
+			return fmt.Errorf("tls-cert-file and tls-key-file are required, the API server only calls webhooks over HTTPS")
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := servePprof(cmd); err != nil {
+			return err
+		}
+
+		health := newHealth(interval)
+		if err := health.serve(cmd); err != nil {
+			return err
+		}
+
+		guard := &capacityGuard{source: capacitySource, filter: newPodFilter(displayOptions), ceiling: ceiling, warnOnly: warnOnly}
+		if err := guard.refresh(); err != nil {
+			return err
+		}
+		health.collected()
+		// A failed refresh keeps the previous capacity data
+		go func() {
+			for {
+				time.Sleep(interval)
+				if err := guard.refresh(); err != nil {
+					logger.Error(err, "failed to refresh capacity")
+					continue
+				}
+				health.collected()
+				logger.V(1).Info("refreshed capacity")
+			}
+		}()
+
+		mux := http.NewServeMux()
+		mux.Handle("/validate", admission.Handler(guard.validate))
+		health.register(mux)
+		return http.ListenAndServeTLS(listenAddress, tlsCertFile, tlsKeyFile, mux)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(admissionCmd)
+	admissionCmd.Flags().Float64P("ceiling", "", 90, "Percent of allocatable pods, cpu or memory requested that new workloads may not push the cluster or their node role beyond")
+	admissionCmd.Flags().BoolP("warn-only", "", false, "Allow workloads beyond the ceiling with a warning instead of rejecting them")
+	admissionCmd.Flags().StringP("listen-address", "", ":8443", "Address to serve the webhook on")
+	admissionCmd.Flags().StringP("tls-cert-file", "", "", "File with the TLS certificate of the webhook")
+	admissionCmd.Flags().StringP("tls-key-file", "", "", "File with the TLS private key of the webhook")
+	addHealthFlag(admissionCmd)
+	addPprofFlag(admissionCmd)
+}
+
+func (g *capacityGuard) refresh() error {
+	nodes, err := g.source.Nodes()
+	if err != nil {
+		return err
+	}
+
+	pods, err := g.source.Pods("")
+	if err != nil {
+		return err
+	}
+
+	cluster := kubesize.ClusterCapacity(nodes, pods, g.filter)
+	nodeRoleCapacityData, roleNames := kubesize.NodeRoleCapacity(nodes, pods, false, false, false, g.filter)
+	nodeRoles := make(map[string]*output.ClusterCapacityData, len(roleNames))
+	for _, role := range roleNames {
+		nodeRoles[role] = nodeRoleCapacityData[role]
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.cluster, g.nodeRoles = cluster, nodeRoles
+	return nil
+}
+
+func (g *capacityGuard) validate(request *admission.Request) *admission.Response {
+	if request.Operation != "CREATE" {
+		return admission.Allow()
+	}
+	workload, err := manifest.NewWorkload(request.Kind.Group, request.Kind.Kind, request.Object.Raw)
+	if err != nil {
+		return admission.Deny(err.Error())
+	}
+	if workload == nil {
+		return admission.Allow()
+	}
+	replicas := workload.Replicas
+
+	requestsCPU, requestsMemory := kubesize.EffectivePodRequests(workload.Template.Spec)
+	requestsCPU = *resource.NewMilliQuantity(requestsCPU.MilliValue()*int64(replicas), resource.DecimalSI)
+	requestsMemory = *resource.NewQuantity(requestsMemory.Value()*int64(replicas), resource.BinarySI)
+
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	violations := make([]string, 0)
+	// Workloads selecting a node role by label are also checked against the commitment of the role
+	for _, group := range append([]string{"cluster"}, nodeSelectorRoles(workload.Template.Spec.NodeSelector)...) {
+		data := g.cluster
+		if group != "cluster" {
+			if data = g.nodeRoles[group]; data == nil {
+				continue
+			}
+		}
+		podsPercent := 0.0
+		if data.TotalAllocatablePods.Value() > 0 {
+			podsPercent = float64(data.TotalNonTermPodCount+replicas) / float64(data.TotalAllocatablePods.Value()) * 100
+		}
+		cpu := data.TotalRequestsCPU.DeepCopy()
+		cpu.Add(requestsCPU)
+		memory := data.TotalRequestsMemory.DeepCopy()
+		memory.Add(requestsMemory)
+		percents := []struct {
+			resource string
+			percent  float64
+		}{
+			{"pods", podsPercent},
+			{"cpu requests", capacity.Percent(cpu, data.TotalAllocatableCPU)},
+			{"memory requests", capacity.Percent(memory, data.TotalAllocatableMemory)},
+		}
+		for _, p := range percents {
+			if p.percent > g.ceiling {
+				violations = append(violations, fmt.Sprintf("%s %s would reach %.1f%% of allocatable", group, p.resource, p.percent))
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return admission.Allow()
+	}
+	message := fmt.Sprintf("%s %s/%s exceeds the capacity ceiling of %.0f%%: %s", request.Kind.Kind, request.Namespace, request.Name, g.ceiling, strings.Join(violations, "; "))
+	if g.warnOnly {
+		return admission.Allow(message)
+	}
+	return admission.Deny(message)
+}
+
+// Node roles selected by the node-role.kubernetes.io/<role> or kubernetes.io/role labels of a node selector
+func nodeSelectorRoles(nodeSelector map[string]string) []string {
+	roles := make([]string, 0)
+	for labelKey, labelValue := range nodeSelector {
+		switch {
+		case strings.HasPrefix(labelKey, "node-role.kubernetes.io/"):
+			if role := strings.TrimPrefix(labelKey, "node-role.kubernetes.io/"); len(role) > 0 {
+				roles = append(roles, role)
+			}
+		case labelKey == "kubernetes.io/role" && labelValue != "":
+			roles = append(roles, labelValue)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
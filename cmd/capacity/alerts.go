@@ -0,0 +1,94 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/alert"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// Set by watch mode when a summary is due, the output of the sample is then also rendered into summaryText
+var (
+	summaryRequested bool
+	summaryText      string
+)
+
+// Alert notifiers of watch mode
+func getNotifiers(cmd *cobra.Command) ([]alert.Notifier, error) {
+	notifiers := make([]alert.Notifier, 0)
+
+	webhooks, _ := cmd.Flags().GetStringSlice("alert-webhook")
+	for _, url := range webhooks {
+		notifiers = append(notifiers, alert.NewWebhook(url))
+	}
+
+	slackWebhook, _ := cmd.Flags().GetString("slack-webhook")
+	if slackWebhook != "" {
+		slackChannel, _ := cmd.Flags().GetString("slack-channel")
+		slackTemplate, _ := cmd.Flags().GetString("slack-template")
+		slack, err := alert.NewSlack(slackWebhook, slackChannel, slackTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, slack)
+	}
+
+	smtpServer, _ := cmd.Flags().GetString("smtp-server")
+	if smtpServer != "" {
+		smtpFrom, _ := cmd.Flags().GetString("smtp-from")
+		smtpTo, _ := cmd.Flags().GetStringSlice("smtp-to")
+		smtpUsername, _ := cmd.Flags().GetString("smtp-username")
+		if smtpFrom == "" || len(smtpTo) == 0 {
+			return nil, fmt.Errorf("smtp-from and smtp-to are required with smtp-server")
+		}
+		// The password is only read from the environment so it does not show up in the process list
+		notifiers = append(notifiers, &alert.Email{Server: smtpServer, From: smtpFrom, To: smtpTo, Username: smtpUsername, Password: os.Getenv("KUBESIZE_SMTP_PASSWORD")})
+	}
+
+	return notifiers, nil
+}
+
+// Notifies of the threshold breaches that changed since the previous sample, a failed notification does not stop
+// watch mode
+func notifyBreaches(notifiers []alert.Notifier, previous []output.Breach, current []output.Breach) {
+	alerts := alert.Transitions(previous, current)
+	if len(alerts) == 0 {
+		return
+	}
+	notification := alert.Notification{Timestamp: time.Now().UTC(), Alerts: alerts}
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(notification); err != nil {
+			logger.Error(err, "failed to send alert")
+		}
+	}
+}
+
+// Sends the rendered output of the sample to the notifiers that send summaries
+func sendSummary(notifiers []alert.Notifier, text string) {
+	summary := alert.Summary{Timestamp: time.Now().UTC(), Text: text}
+	for _, notifier := range notifiers {
+		if summarizer, ok := notifier.(alert.Summarizer); ok {
+			if err := summarizer.Summarize(summary); err != nil {
+				logger.Error(err, "failed to send summary")
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/metrics"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+var allCmd = &cobra.Command{
+	Use:   "all",
+	Short: "Get cluster, node-role and node capacity data at once",
+	Long:  `Get cluster, node-role and node capacity data in a single invocation, listing the nodes and pods only once`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Each list is only fetched once and shared by every section
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		displayUnassigned, _ := cmd.Flags().GetBool("unassigned")
+
+		displayTotal, _ := cmd.Flags().GetBool("display-total")
+
+		allData := output.AllData{Cluster: *kubesize.ClusterCapacity(nodes, pods, newPodFilter(displayOptions))}
+
+		var roleNames, nodeNames []string
+		allData.NodeRoles, roleNames = kubesize.NodeRoleCapacity(nodes, pods, displayUnassigned, displayTotal, false, newPodFilter(displayOptions))
+		allData.Nodes, nodeNames, _ = kubesize.NodeCapacity(nodes, pods, displayUnassigned, displayTotal, false, newPodFilter(displayOptions))
+
+		thresholdBreaches = append(clusterBreaches(displayOptions, &allData.Cluster), nodeRoleBreaches(displayOptions, allData.NodeRoles, roleNames)...)
+		thresholdBreaches = append(thresholdBreaches, nodeBreaches(displayOptions, allData.Nodes, nodeNames)...)
+		collectedMetrics = append(metrics.ClusterMetrics(&allData.Cluster, nil), metrics.NodeRoleMetrics(allData.NodeRoles, roleNames, nil)...)
+		collectedMetrics = append(collectedMetrics, metrics.NodeMetrics(allData.Nodes, nodeNames, nil)...)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayAllData(allData, roleNames, nodeNames, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(allCmd)
+	allCmd.RunE = watchRunE(allCmd.RunE)
+	allCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
+	allCmd.Flags().BoolP("unassigned", "u", false, "Include unassigned pod row, pods which do not have a node, in node-role and node table output")
+	allCmd.Flags().BoolP("display-total", "t", false, "Display sum of all capacity data in node-role and node table output")
+}
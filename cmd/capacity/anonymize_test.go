@@ -0,0 +1,213 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const snapshotFixture = "testdata/snapshot.yaml"
+
+// Runs the command line against the snapshot fixture and decodes its JSON output
+func runSnapshotJSON(t *testing.T, args ...string) (map[string]interface{}, string) {
+	setenv(t, map[string]string{"HOME": t.TempDir()})
+	var out, errOut bytes.Buffer
+	args = append(args, "--from", snapshotFixture, "-o", "json")
+	if exitCode := Run(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &out, ErrOut: &errOut}, args); exitCode != 0 {
+		t.Fatalf("%s exited with %d: %s", strings.Join(args, " "), exitCode, errOut.String())
+	}
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &data); err != nil {
+		t.Fatalf("%s output is invalid JSON: %v", strings.Join(args, " "), err)
+	}
+	return data, out.String()
+}
+
+func toJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// The node data without the names it holds, with its pods in a stable order
+func nodeNumbers(node interface{}) string {
+	data := make(map[string]interface{})
+	for key, value := range node.(map[string]interface{}) {
+		data[key] = value
+	}
+	delete(data, "InternalIP")
+	delete(data, "Labels")
+	if pods, ok := data["Pods"].([]interface{}); ok {
+		podNumbers := make([]string, 0, len(pods))
+		for _, pod := range pods {
+			podNumbers = append(podNumbers, podNumbersJSON(pod))
+		}
+		sort.Strings(podNumbers)
+		data["Pods"] = podNumbers
+	}
+	return toJSON(data)
+}
+
+func podNumbersJSON(pod interface{}) string {
+	data := make(map[string]interface{})
+	for key, value := range pod.(map[string]interface{}) {
+		data[key] = value
+	}
+	delete(data, "Namespace")
+	delete(data, "Name")
+	delete(data, "Message")
+	return toJSON(data)
+}
+
+// Maps the names of the plain output to those of the anonymized output by their data, which is unique in the fixture
+func matchNames(t *testing.T, kind string, plain map[string]interface{}, anonymized map[string]interface{}, numbers func(interface{}) string) map[string]string {
+	byNumbers := make(map[string]string)
+	for name, data := range anonymized {
+		byNumbers[numbers(data)] = name
+	}
+	names := make(map[string]string)
+	for name, data := range plain {
+		anonymizedName, ok := byNumbers[numbers(data)]
+		if !ok {
+			t.Errorf("the numbers of %s %s changed", kind, name)
+			continue
+		}
+		names[name] = anonymizedName
+	}
+	return names
+}
+
+// Records the anonymized name of a name, failing when a name maps to two names or two names to one
+func consistentName(t *testing.T, kind string, names map[string]string, name string, anonymizedName string) {
+	if previous, ok := names[name]; ok && previous != anonymizedName {
+		t.Errorf("%s %s is anonymized as both %s and %s", kind, name, previous, anonymizedName)
+	}
+	for other, otherAnonymized := range names {
+		if other != name && otherAnonymized == anonymizedName {
+			t.Errorf("%s %s and %s are both anonymized as %s", kind, name, other, anonymizedName)
+		}
+	}
+	names[name] = anonymizedName
+}
+
+func TestAnonymizeSnapshot(t *testing.T) {
+	plainNodes, _ := runSnapshotJSON(t, "node", "--show-pods", "--show-labels", "--anonymize=false")
+	anonymizedNodes, anonymizedNodesOutput := runSnapshotJSON(t, "node", "--show-pods", "--show-labels", "--anonymize")
+	plainReport, _ := runSnapshotJSON(t, "report", "--anonymize=false")
+	anonymizedReport, anonymizedReportOutput := runSnapshotJSON(t, "report", "--anonymize")
+
+	// No name, pod name or node IP of the fixture is left
+	for _, name := range []string{"master-0", "worker-0", "worker-1", "10.0.0.", "payments", "kube-system", "default", "etcd", "api-1", "api-2", "web", "batch-1"} {
+		for _, output := range []string{anonymizedNodesOutput, anonymizedReportOutput} {
+			if strings.Contains(output, name) {
+				t.Errorf("anonymized output contains %s", name)
+			}
+		}
+	}
+
+	// Nodes keep their numbers and are named after the hash of their name in every field
+	nodeNames := matchNames(t, "node", plainNodes, anonymizedNodes, nodeNumbers)
+	if len(nodeNames) != len(plainNodes) {
+		t.Fatalf("matched %d of %d nodes", len(nodeNames), len(plainNodes))
+	}
+	for _, pseudo := range []string{"*total*", "*unassigned*"} {
+		if nodeNames[pseudo] != pseudo {
+			t.Errorf("%s is anonymized as %s", pseudo, nodeNames[pseudo])
+		}
+	}
+	anonymizedNames := make(map[string]bool)
+	namespaceNames := make(map[string]string)
+	podNames := make(map[string]string)
+	for name, anonymizedName := range nodeNames {
+		anonymizedNames[anonymizedName] = true
+		plainNode := plainNodes[name].(map[string]interface{})
+		node := anonymizedNodes[anonymizedName].(map[string]interface{})
+		if labels, ok := node["Labels"].(map[string]interface{}); ok && labels["kubernetes.io/hostname"] != anonymizedName {
+			t.Errorf("node %s has hostname label %v, expected %s", anonymizedName, labels["kubernetes.io/hostname"], anonymizedName)
+		}
+		if ip := plainNode["InternalIP"]; ip != "" && node["InternalIP"] == ip {
+			t.Errorf("the IP of node %s is not anonymized", name)
+		}
+		plainPods, _ := plainNode["Pods"].([]interface{})
+		pods, _ := node["Pods"].([]interface{})
+		if len(plainPods) != len(pods) {
+			t.Errorf("node %s has %d pods, expected %d", anonymizedName, len(pods), len(plainPods))
+			continue
+		}
+		// Pods are sorted by their anonymized names, they are matched by their numbers
+		for _, plainPod := range plainPods {
+			for _, pod := range pods {
+				if podNumbersJSON(pod) != podNumbersJSON(plainPod) {
+					continue
+				}
+				plainPodData, podData := plainPod.(map[string]interface{}), pod.(map[string]interface{})
+				consistentName(t, "namespace", namespaceNames, plainPodData["Namespace"].(string), podData["Namespace"].(string))
+				consistentName(t, "pod", podNames, plainPodData["Namespace"].(string)+"/"+plainPodData["Name"].(string), podData["Name"].(string))
+			}
+		}
+	}
+	if len(podNames) != 5 || len(namespaceNames) != 3 {
+		t.Errorf("matched %d pods in %d namespaces, expected 5 pods in 3 namespaces", len(podNames), len(namespaceNames))
+	}
+
+	// The report names the same nodes, namespaces and pods the same, with the same numbers
+	if toJSON(plainReport["Cluster"]) != toJSON(anonymizedReport["Cluster"]) {
+		t.Errorf("the cluster numbers changed")
+	}
+	reportNamespaces := matchNames(t, "namespace", plainReport["Namespaces"].(map[string]interface{}), anonymizedReport["Namespaces"].(map[string]interface{}), toJSON)
+	for name, anonymizedName := range reportNamespaces {
+		if name == "*total*" {
+			continue
+		}
+		if namespaceNames[name] != anonymizedName {
+			t.Errorf("namespace %s is anonymized as %s in the report, expected %s", name, anonymizedName, namespaceNames[name])
+		}
+	}
+	for role, roleData := range anonymizedReport["NodeRoles"].(map[string]interface{}) {
+		plainMinMax, _ := plainReport["NodeRoles"].(map[string]interface{})[role].(map[string]interface{})["RequestsMinMax"].(map[string]interface{})
+		minMax, _ := roleData.(map[string]interface{})["RequestsMinMax"].(map[string]interface{})
+		for key, value := range plainMinMax {
+			if !strings.HasSuffix(key, "Node") {
+				if value != minMax[key] {
+					t.Errorf("%s of node role %s changed from %v to %v", key, role, value, minMax[key])
+				}
+			} else if minMax[key] != nodeNames[value.(string)] {
+				t.Errorf("%s of node role %s is %v, expected %s", key, role, minMax[key], nodeNames[value.(string)])
+			}
+		}
+	}
+	plainPending := plainReport["PendingPods"].([]interface{})
+	pending := anonymizedReport["PendingPods"].([]interface{})
+	if len(pending) != len(plainPending) {
+		t.Fatalf("the report has %d pending pods, expected %d", len(pending), len(plainPending))
+	}
+	for i := range pending {
+		plainPod, pod := plainPending[i].(map[string]interface{}), pending[i].(map[string]interface{})
+		if podNumbersJSON(pod) != podNumbersJSON(plainPod) {
+			t.Errorf("the numbers of pending pod %v changed", plainPod["Name"])
+		}
+		consistentName(t, "namespace", namespaceNames, plainPod["Namespace"].(string), pod["Namespace"].(string))
+		consistentName(t, "pod", podNames, plainPod["Namespace"].(string)+"/"+plainPod["Name"].(string), pod["Name"].(string))
+		if pod["Message"] != "" {
+			t.Errorf("pending pod %v keeps its scheduler message", pod["Name"])
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/manifest"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/akrzos/kubeSize/internal/simulate"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Required pod anti-affinity between the pods of the workloads and the pods already on the nodes
+type antiAffinity struct {
+	cluster    *simulate.Cluster
+	nodeLabels map[string]map[string]string
+}
+
+func newAntiAffinity(nodes *corev1.NodeList, pods *corev1.PodList, filter kubesize.PodFilter) *antiAffinity {
+	nodeLabels := make(map[string]map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		nodeLabels[node.Name] = node.Labels
+	}
+	return &antiAffinity{cluster: simulate.NewCluster(nodes, pods, filter), nodeLabels: nodeLabels}
+}
+
+// The nodes the pods of the workload can not be placed on and the topology keys its pods can hold only one pod in
+// each domain of
+func (a *antiAffinity) restrictions(workload manifest.Workload) (map[string]bool, []string) {
+	pod := newSimulatedPod(workload, 0)
+	return a.cluster.BlockedByAntiAffinity(pod), simulate.SelfAntiAffinityKeys(&pod.Pod)
+}
+
+// Pods of the workload the nodes can hold once the blocked nodes are left out and each domain of the self anti-affinity
+// keys holds one pod, and the nodes left. Nodes without a key are not limited by it, like the scheduler treats them.
+func (a *antiAffinity) slots(nodeNames []string, nodesCapacityData map[string]*output.NodeCapacityData, blocked map[string]bool, selfKeys []string, requestsCPU resource.Quantity, requestsMemory resource.Quantity) (int, []*output.NodeCapacityData) {
+	available := make([]*output.NodeCapacityData, 0)
+	availableNames := make([]string, 0)
+	slots := 0
+	for _, nodeName := range nodeNames {
+		if blocked[nodeName] {
+			continue
+		}
+		data := nodesCapacityData[nodeName]
+		available = append(available, data)
+		availableNames = append(availableNames, nodeName)
+		if data.TotalAvailablePods > 0 {
+			slots += data.TotalAvailablePods
+		}
+	}
+	for _, key := range selfKeys {
+		domains := make(map[string]bool)
+		keySlots := 0
+		for i, nodeName := range availableNames {
+			if !podFitsOnNode(available[i:i+1], requestsCPU, requestsMemory) {
+				continue
+			}
+			if value, ok := a.nodeLabels[nodeName][key]; ok {
+				domains[value] = true
+			} else {
+				keySlots += available[i].TotalAvailablePods
+			}
+		}
+		if keySlots += len(domains); keySlots < slots {
+			slots = keySlots
+		}
+	}
+	return slots, available
+}
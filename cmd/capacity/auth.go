@@ -0,0 +1,168 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// An API permission of the sub-commands, namespaced resources are checked across all namespaces
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+	required    bool
+	usedBy      string
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect authorization",
+}
+
+var authCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the permissions of the sub-commands",
+	Long:  `Review with SelfSubjectAccessReviews which of the API permissions of the sub-commands are granted to the user of the kubeconfig, before a run fails for lack of one`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		authCheckData, err := getAuthCheckData(clientset, getPermissions())
+		if err != nil {
+			return err
+		}
+
+		err = writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayAuthCheckData(authCheckData, displayOptions)
+		})
+		if err != nil {
+			return err
+		}
+		var missing []string
+		for _, permission := range authCheckData.Permissions {
+			if permission.Required && !permission.Allowed {
+				missing = append(missing, permission.Verb+" "+permission.Resource)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("required permissions are missing: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authCheckCmd)
+}
+
+// The permissions of the sub-commands, the nodes and pods every capacity sub-command reads are required, the others
+// only fail the sub-commands or features that use them
+func getPermissions() []permission {
+	permissions := []permission{
+		{verb: "list", resource: "nodes", required: true, usedBy: "capacity sub-commands"},
+		{verb: "list", resource: "pods", required: true, usedBy: "capacity sub-commands"},
+		{verb: "list", resource: "namespaces", usedBy: "namespace, report, size, usage, pods of permitted namespaces"},
+		{verb: "watch", resource: "nodes", usedBy: "streaming lists (--watch-list)"},
+		{verb: "watch", resource: "pods", usedBy: "streaming lists (--watch-list)"},
+		{verb: "list", resource: "resourcequotas", usedBy: "check, size, namespace --hierarchy"},
+		{verb: "list", group: "policy", resource: "poddisruptionbudgets", usedBy: "disruption, size"},
+		{verb: "list", group: "metrics.k8s.io", resource: "nodes", usedBy: "usage, eviction-risk"},
+		{verb: "list", group: "metrics.k8s.io", resource: "pods", usedBy: "usage"},
+		{verb: "list", group: "storage.k8s.io", resource: "csinodes", usedBy: "cluster, node --volumes"},
+		{verb: "get", resource: "nodes", subresource: "proxy", usedBy: "cpu-manager, pids, node --disk, --usage-source kubelet"},
+		{verb: "list", group: "topology.node.k8s.io", resource: "noderesourcetopologies", usedBy: "numa"},
+		{verb: "list", resource: "events", usedBy: "churn, size"},
+		{verb: "get", group: kube.ReportGroup, resource: kube.ReportResource, usedBy: "controller"},
+		{verb: "create", group: kube.ReportGroup, resource: kube.ReportResource, usedBy: "controller"},
+		{verb: "update", group: kube.ReportGroup, resource: kube.ReportResource, subresource: "status", usedBy: "controller"},
+		{verb: "create", resource: "events", usedBy: "controller"},
+	}
+	// Objects counted by the size sub-command
+	for _, resource := range []struct{ group, resource string }{
+		{"", "persistentvolumes"}, {"", "serviceaccounts"}, {"", "replicationcontrollers"}, {"", "endpoints"},
+		{"", "services"}, {"", "configmaps"}, {"", "secrets"}, {"", "persistentvolumeclaims"},
+		{"", "limitranges"}, {"rbac.authorization.k8s.io", "clusterroles"},
+		{"rbac.authorization.k8s.io", "clusterrolebindings"}, {"rbac.authorization.k8s.io", "roles"},
+		{"rbac.authorization.k8s.io", "rolebindings"}, {"networking.k8s.io", "networkpolicies"},
+		{"networking.k8s.io", "ingresses"}, {"apps", "replicasets"}, {"apps", "deployments"}, {"apps", "daemonsets"},
+		{"apps", "statefulsets"}, {"batch", "cronjobs"}, {"batch", "jobs"}, {"storage.k8s.io", "storageclasses"},
+		{"storage.k8s.io", "volumeattachments"}, {"policy", "podsecuritypolicies"},
+	} {
+		permissions = append(permissions, permission{verb: "list", group: resource.group, resource: resource.resource, usedBy: "size"})
+	}
+	return permissions
+}
+
+// Reviews each permission with a SelfSubjectAccessReview
+func getAuthCheckData(clientset *kubernetes.Clientset, permissions []permission) (output.AuthCheckData, error) {
+	authCheckData := output.AuthCheckData{}
+	for _, permission := range permissions {
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        permission.verb,
+					Group:       permission.group,
+					Resource:    permission.resource,
+					Subresource: permission.subresource,
+				},
+			},
+		})
+		if err != nil {
+			return authCheckData, errors.Wrap(err, "failed to review access")
+		}
+		resource := permission.resource
+		if permission.group != "" {
+			resource += "." + permission.group
+		}
+		if permission.subresource != "" {
+			resource += "/" + permission.subresource
+		}
+		authCheckData.Permissions = append(authCheckData.Permissions, output.PermissionData{
+			Verb:     permission.verb,
+			Resource: resource,
+			Allowed:  review.Status.Allowed,
+			Required: permission.required,
+			UsedBy:   permission.usedBy,
+			Reason:   review.Status.Reason,
+		})
+		if !review.Status.Allowed && permission.required {
+			authCheckData.MissingRequired++
+		}
+	}
+	return authCheckData, nil
+}
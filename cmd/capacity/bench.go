@@ -0,0 +1,114 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+var benchStages = []string{"node list", "pod list", "aggregation", "total"}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark the collection of capacity data",
+	Long:  `Repeatedly list the nodes and pods and aggregate the cluster capacity data, then print the latency percentiles of each stage and the objects and API requests of an iteration, to size the interval of automation or validate performance changes`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		iterations, _ := cmd.Flags().GetInt("iterations")
+		if iterations < 1 {
+			return fmt.Errorf("iterations must be greater than 0")
+		}
+
+		benchData := output.BenchData{Iterations: iterations}
+		durations := map[string][]time.Duration{}
+		apiStats = &kube.RequestStats{}
+		// Each iteration builds its own client, as separate runs of the sub-commands do
+		for i := 0; i < iterations; i++ {
+			timings = newRunTimings()
+			start := time.Now()
+			capacitySource, err := getSource(cmd)
+			if err != nil {
+				return err
+			}
+			nodes, err := capacitySource.Nodes()
+			if err != nil {
+				return err
+			}
+			pods, err := capacitySource.Pods("")
+			if err != nil {
+				return err
+			}
+			stopTiming := startStage("aggregation")
+			kubesize.ClusterCapacity(nodes, pods, newPodFilter(displayOptions))
+			stopTiming()
+			timings.durations["total"] = time.Since(start)
+			for _, stage := range benchStages {
+				durations[stage] = append(durations[stage], timings.durations[stage])
+			}
+			benchData.Nodes, benchData.Pods = len(nodes.Items), len(pods.Items)
+		}
+		benchData.APIRequests = apiStats.Requests / iterations
+		for _, stage := range benchStages {
+			benchData.Stages = append(benchData.Stages, benchStageData(stage, durations[stage]))
+		}
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayBenchData(benchData, displayOptions)
+		})
+	},
+}
+
+func benchStageData(stage string, durations []time.Duration) output.BenchStageData {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return output.BenchStageData{
+		Stage:      stage,
+		MinSeconds: durations[0].Seconds(),
+		P50Seconds: percentile(durations, 50).Seconds(),
+		P90Seconds: percentile(durations, 90).Seconds(),
+		P99Seconds: percentile(durations, 99).Seconds(),
+		MaxSeconds: durations[len(durations)-1].Seconds(),
+	}
+}
+
+// Nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	benchCmd.Flags().IntP("iterations", "", 10, "Number of times to collect the capacity data")
+}
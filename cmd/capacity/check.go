@@ -0,0 +1,357 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/manifest"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/akrzos/kubeSize/internal/simulate"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check whether workloads of manifests fit into available capacity",
+	Long:  `Compute the requests of the Deployments, StatefulSets, Jobs and CronJobs of manifest files at their desired replicas and check whether they fit into the available capacity of the cluster and of the node roles and zones they select and into the resource quotas of their namespaces`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		filenames, _ := cmd.Flags().GetStringSlice("filename")
+
+		recursive, _ := cmd.Flags().GetBool("recursive")
+
+		kustomization, _ := cmd.Flags().GetString("kustomize")
+
+		helmChart, _ := cmd.Flags().GetString("helm-chart")
+
+		helmRelease, _ := cmd.Flags().GetString("helm-release")
+
+		helmValues, _ := cmd.Flags().GetStringSlice("helm-values")
+
+		if len(filenames) == 0 && kustomization == "" && helmChart == "" {
+			return fmt.Errorf("at least one manifest file or directory is required with -f, a kustomization with -k or a chart with --helm-chart")
+		}
+
+		workloads, err := manifest.Read(filenames, recursive, cmd.InOrStdin())
+		if err != nil {
+			return err
+		}
+
+		if kustomization != "" {
+			kustomizationWorkloads, err := manifest.Kustomize(kustomization)
+			if err != nil {
+				return err
+			}
+			workloads = append(workloads, kustomizationWorkloads...)
+		}
+
+		if helmChart != "" {
+			chartWorkloads, err := manifest.Helm(helmChart, helmRelease, *KubernetesConfigFlags.Namespace, helmValues)
+			if err != nil {
+				return err
+			}
+			workloads = append(workloads, chartWorkloads...)
+		}
+
+		namespace := defaultNamespace()
+		for i := range workloads {
+			if workloads[i].Namespace == "" {
+				workloads[i].Namespace = namespace
+			}
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, newPodFilter(displayOptions))
+
+		resourceQuotas, err := capacitySource.ResourceQuotas("")
+		if err != nil {
+			return err
+		}
+
+		fitData := getFitData(workloads, nodesCapacityData, nodeNames, newAntiAffinity(nodes, pods, newPodFilter(displayOptions)))
+		fitData.Quotas = getQuotaFitData(workloads, resourceQuotas)
+		simulatePlacement, _ := cmd.Flags().GetBool("simulate")
+		unplaced := false
+		if simulatePlacement {
+			fitData.Placements = getPlacementFitData(workloads, nodes, pods, newPodFilter(displayOptions))
+			for _, placement := range fitData.Placements {
+				if !placement.Fits {
+					unplaced = true
+				}
+			}
+		}
+		fitData.Spreads = getSpreadFitData(workloads, nodes, pods, newPodFilter(displayOptions))
+		exceedsSpread := false
+		for _, spread := range fitData.Spreads {
+			if !spread.Fits {
+				exceedsSpread = true
+			}
+		}
+		blockedByQuota := false
+		for _, quota := range fitData.Quotas {
+			if !quota.Fits {
+				blockedByQuota = true
+			}
+		}
+		fitsCapacity := fitData.Fits
+		fitData.Fits = fitsCapacity && !unplaced && !exceedsSpread && !blockedByQuota
+
+		if err := writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayFitData(fitData, displayOptions)
+		}); err != nil {
+			return err
+		}
+		if !fitsCapacity {
+			return fmt.Errorf("workloads do not fit into available capacity")
+		}
+		if unplaced {
+			return fmt.Errorf("workloads can not be placed on the nodes of the cluster")
+		}
+		if exceedsSpread {
+			return fmt.Errorf("workloads exceed the replicas their topology spread constraints allow")
+		}
+		if blockedByQuota {
+			return fmt.Errorf("workloads would be rejected by resource quota")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().StringSliceP("filename", "f", []string{}, "Manifest files or directories of .yaml, .yml and .json files to check, - reads stdin, may be repeated")
+	checkCmd.Flags().BoolP("recursive", "R", false, "Read the manifests of directories recursively")
+	checkCmd.Flags().StringP("kustomize", "k", "", "Kustomization directory to build and check, such as an overlay")
+	checkCmd.Flags().BoolP("simulate", "", false, "Place the pods of the workloads one at a time with the node resources, taint, node affinity and topology spread filters of the scheduler")
+	checkCmd.Flags().StringP("helm-chart", "", "", "Chart to render with helm template and check, a chart directory, archive or repo/chart reference")
+	checkCmd.Flags().StringP("helm-release", "", "release", "Release name to render the chart with")
+	checkCmd.Flags().StringSliceP("helm-values", "", []string{}, "Values files to render the chart with, may be repeated")
+}
+
+// The namespace of the kubeconfig context, which workloads without a namespace are created in
+func defaultNamespace() string {
+	namespace, _, err := KubernetesConfigFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil || namespace == "" {
+		return "default"
+	}
+	return namespace
+}
+
+// Node zones selected by the zone labels of a node selector
+func nodeSelectorZones(nodeSelector map[string]string) []string {
+	zones := make([]string, 0)
+	for _, labelKey := range []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"} {
+		if zone := nodeSelector[labelKey]; zone != "" {
+			zones = append(zones, zone)
+			break
+		}
+	}
+	return zones
+}
+
+// Places the workloads on the cluster and on the node roles and zones they select. A group fits when the sum of the
+// requests is within the sum available on its ready, schedulable nodes and each pod fits on at least one of them.
+func getFitData(workloads []manifest.Workload, nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, antiAffinity *antiAffinity) output.FitData {
+	fitData := output.FitData{Fits: true, Groups: make([]output.GroupFitData, 0), Workloads: make([]output.WorkloadFitData, 0)}
+	groupIndex := make(map[string]int)
+	groupNodes := make(map[string][]*output.NodeCapacityData)
+	groupNodeNames := make(map[string][]string)
+
+	addGroup := func(group string, matches func(data *output.NodeCapacityData) bool) {
+		groupIndex[group] = len(fitData.Groups)
+		groupFitData := output.GroupFitData{Group: group}
+		for _, nodeName := range nodeNames {
+			data := nodesCapacityData[nodeName]
+			if !data.Ready || !data.Schedulable || !matches(data) {
+				continue
+			}
+			groupNodes[group] = append(groupNodes[group], data)
+			groupNodeNames[group] = append(groupNodeNames[group], nodeName)
+			if data.TotalAvailablePods > 0 {
+				groupFitData.AvailablePods += data.TotalAvailablePods
+			}
+			if data.TotalAvailableCPU.Sign() > 0 {
+				groupFitData.AvailableCPU.Add(data.TotalAvailableCPU)
+			}
+			if data.TotalAvailableMemory.Sign() > 0 {
+				groupFitData.AvailableMemory.Add(data.TotalAvailableMemory)
+			}
+		}
+		fitData.Groups = append(fitData.Groups, groupFitData)
+	}
+	addGroup("cluster", func(data *output.NodeCapacityData) bool { return true })
+
+	// Pods of each workload that fit on no node of a group
+	unplaceable := make(map[string][]string)
+	// Workloads with more pods than required pod anti-affinity leaves room for in a group
+	antiAffinityExceeded := make(map[string][]string)
+
+	for _, workload := range workloads {
+		podRequestsCPU, podRequestsMemory := kubesize.EffectivePodRequests(workload.Template.Spec)
+		workloadFitData := output.WorkloadFitData{
+			Kind:           workload.Kind,
+			Namespace:      workload.Namespace,
+			Name:           workload.Name,
+			PodCount:       workload.Replicas,
+			RequestsCPU:    *resource.NewMilliQuantity(podRequestsCPU.MilliValue()*int64(workload.Replicas), resource.DecimalSI),
+			RequestsMemory: *resource.NewQuantity(podRequestsMemory.Value()*int64(workload.Replicas), resource.BinarySI),
+			Groups:         []string{"cluster"},
+		}
+		workloadFitData.RequestsCPUCores = capacity.ReadableCPU(workloadFitData.RequestsCPU)
+		workloadFitData.RequestsMemoryGiB = capacity.ReadableMem(workloadFitData.RequestsMemory)
+
+		for _, role := range nodeSelectorRoles(workload.Template.Spec.NodeSelector) {
+			group := "role/" + role
+			if _, ok := groupIndex[group]; !ok {
+				addGroup(group, func(data *output.NodeCapacityData) bool { return data.Roles.Has(role) })
+			}
+			workloadFitData.Groups = append(workloadFitData.Groups, group)
+		}
+		for _, zone := range nodeSelectorZones(workload.Template.Spec.NodeSelector) {
+			group := "zone/" + zone
+			if _, ok := groupIndex[group]; !ok {
+				addGroup(group, func(data *output.NodeCapacityData) bool { return data.Zone == zone })
+			}
+			workloadFitData.Groups = append(workloadFitData.Groups, group)
+		}
+
+		blocked, selfKeys := antiAffinity.restrictions(workload)
+		for _, group := range workloadFitData.Groups {
+			groupFitData := &fitData.Groups[groupIndex[group]]
+			groupFitData.PodCount += workloadFitData.PodCount
+			groupFitData.RequestsCPU.Add(workloadFitData.RequestsCPU)
+			groupFitData.RequestsMemory.Add(workloadFitData.RequestsMemory)
+			nodes := groupNodes[group]
+			if len(blocked) > 0 || len(selfKeys) > 0 {
+				slots, available := antiAffinity.slots(groupNodeNames[group], nodesCapacityData, blocked, selfKeys, podRequestsCPU, podRequestsMemory)
+				if workloadFitData.AntiAffinityAvailablePods == nil {
+					workloadFitData.AntiAffinityAvailablePods = make(map[string]int)
+				}
+				workloadFitData.AntiAffinityAvailablePods[group] = slots
+				if workload.Replicas > slots {
+					antiAffinityExceeded[group] = append(antiAffinityExceeded[group], fmt.Sprintf("%s/%s", strings.ToLower(workload.Kind), workload.Name))
+				}
+				nodes = available
+			}
+			if workload.Replicas > 0 && !podFitsOnNode(nodes, podRequestsCPU, podRequestsMemory) {
+				unplaceable[group] = append(unplaceable[group], fmt.Sprintf("%s/%s", strings.ToLower(workload.Kind), workload.Name))
+			}
+		}
+		fitData.Workloads = append(fitData.Workloads, workloadFitData)
+	}
+
+	for i := range fitData.Groups {
+		groupFitData := &fitData.Groups[i]
+		reasons := make([]string, 0)
+		if len(groupNodes[groupFitData.Group]) == 0 {
+			reasons = append(reasons, "no ready schedulable nodes")
+		} else {
+			if groupFitData.PodCount > groupFitData.AvailablePods {
+				reasons = append(reasons, "pods exceed available pods")
+			}
+			if groupFitData.RequestsCPU.Cmp(groupFitData.AvailableCPU) > 0 {
+				reasons = append(reasons, "cpu requests exceed available cpu")
+			}
+			if groupFitData.RequestsMemory.Cmp(groupFitData.AvailableMemory) > 0 {
+				reasons = append(reasons, "memory requests exceed available memory")
+			}
+			if len(antiAffinityExceeded[groupFitData.Group]) > 0 {
+				reasons = append(reasons, "pods of "+strings.Join(antiAffinityExceeded[groupFitData.Group], ", ")+" exceed the pods required pod anti-affinity leaves room for")
+			}
+			if len(unplaceable[groupFitData.Group]) > 0 {
+				reasons = append(reasons, "a pod of "+strings.Join(unplaceable[groupFitData.Group], ", ")+" fits on no node")
+			}
+		}
+		// Groups without workload pods always fit
+		groupFitData.Fits = len(reasons) == 0 || groupFitData.PodCount == 0
+		if !groupFitData.Fits {
+			groupFitData.Reason = strings.Join(reasons, "; ")
+			fitData.Fits = false
+		}
+		groupFitData.RequestsCPUCores = capacity.ReadableCPU(groupFitData.RequestsCPU)
+		groupFitData.AvailableCPUCores = capacity.ReadableCPU(groupFitData.AvailableCPU)
+		groupFitData.RequestsMemoryGiB = capacity.ReadableMem(groupFitData.RequestsMemory)
+		groupFitData.AvailableMemoryGiB = capacity.ReadableMem(groupFitData.AvailableMemory)
+	}
+	return fitData
+}
+
+// Whether a node has a pod slot and the cpu and memory of a pod available
+func podFitsOnNode(nodes []*output.NodeCapacityData, requestsCPU resource.Quantity, requestsMemory resource.Quantity) bool {
+	for _, data := range nodes {
+		if data.TotalAvailablePods > 0 && data.TotalAvailableCPU.Cmp(requestsCPU) >= 0 && data.TotalAvailableMemory.Cmp(requestsMemory) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Places the pods of each workload, in order, on the cluster state the earlier workloads were placed on. Once a pod
+// of a workload can not be placed the following identical pods can not be either.
+func getPlacementFitData(workloads []manifest.Workload, nodes *corev1.NodeList, pods *corev1.PodList, filter kubesize.PodFilter) []output.PlacementFitData {
+	cluster := simulate.NewCluster(nodes, pods, filter)
+	placements := make([]output.PlacementFitData, 0, len(workloads))
+	for _, workload := range workloads {
+		placement := output.PlacementFitData{Kind: workload.Kind, Namespace: workload.Namespace, Name: workload.Name, PodCount: workload.Replicas, Nodes: make(map[string]int)}
+		for i := 0; i < workload.Replicas; i++ {
+			nodeName, err := cluster.Place(newSimulatedPod(workload, i))
+			if err != nil {
+				placement.Reason = err.Error()
+				break
+			}
+			placement.Nodes[nodeName]++
+			placement.PlacedCount++
+		}
+		placement.Fits = placement.PlacedCount == placement.PodCount
+		placements = append(placements, placement)
+	}
+	return placements
+}
+
+// The i-th pod of the workload, named like the pods of a StatefulSet
+func newSimulatedPod(workload manifest.Workload, i int) *simulate.Pod {
+	pod := &simulate.Pod{Pod: corev1.Pod{ObjectMeta: *workload.Template.ObjectMeta.DeepCopy(), Spec: workload.Template.Spec}, TopologySpreadConstraints: workload.TopologySpreadConstraints}
+	pod.Namespace, pod.Name = workload.Namespace, fmt.Sprintf("%s-%d", workload.Name, i)
+	return pod
+}
@@ -0,0 +1,229 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akrzos/kubeSize/internal/manifest"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const checkManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        resources:
+          requests:
+            cpu: 250m
+            memory: 512Mi
+      containers:
+      - name: web
+        resources:
+          requests:
+            cpu: 100m
+            memory: 128Mi
+      - name: proxy
+        resources:
+          requests:
+            cpu: 200m
+            memory: 128Mi
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  replicas: 2
+  template:
+    spec:
+      nodeSelector:
+        node-role.kubernetes.io/infra: ""
+      initContainers:
+      - name: restore
+        resources:
+          requests:
+            cpu: 2
+            memory: 512Mi
+      containers:
+      - name: db
+        resources:
+          requests:
+            cpu: 1
+            memory: 1Gi
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: import
+spec:
+  parallelism: 5
+  completions: 3
+  template:
+    spec:
+      containers:
+      - name: import
+        resources:
+          requests:
+            cpu: 500m
+            memory: 256Mi
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          nodeSelector:
+            topology.kubernetes.io/zone: zone-b
+          containers:
+          - name: report
+            resources:
+              requests:
+                cpu: 100m
+                memory: 64Mi
+`
+
+func newNodeCapacityData(role string, zone string, pods int, cpu string, memory string) *output.NodeCapacityData {
+	return &output.NodeCapacityData{
+		Roles:                sets.NewString(role),
+		Zone:                 zone,
+		Ready:                true,
+		Schedulable:          true,
+		TotalAvailablePods:   pods,
+		TotalAvailableCPU:    resource.MustParse(cpu),
+		TotalAvailableMemory: resource.MustParse(memory),
+	}
+}
+
+func TestGetFitData(t *testing.T) {
+	workloads, err := manifest.Decode(strings.NewReader(checkManifests), "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodesCapacityData := map[string]*output.NodeCapacityData{
+		"worker-a": newNodeCapacityData("worker", "zone-a", 110, "4", "8Gi"),
+		"infra-b":  newNodeCapacityData("infra", "zone-b", 110, "3", "4Gi"),
+	}
+	antiAffinity := newAntiAffinity(&corev1.NodeList{}, &corev1.PodList{}, kubesize.PodFilter{})
+	fitData := getFitData(workloads, nodesCapacityData, []string{"infra-b", "worker-a"}, antiAffinity)
+
+	for i, expected := range []struct {
+		kind     string
+		podCount int
+		cpu      string
+		memory   string
+		groups   []string
+	}{
+		// The migrate init container requests more memory than the sum of the containers, less cpu
+		{kind: "Deployment", podCount: 3, cpu: "900m", memory: "1536Mi", groups: []string{"cluster"}},
+		{kind: "StatefulSet", podCount: 2, cpu: "4", memory: "2Gi", groups: []string{"cluster", "role/infra"}},
+		// The smaller of parallelism and completions
+		{kind: "Job", podCount: 3, cpu: "1500m", memory: "768Mi", groups: []string{"cluster"}},
+		{kind: "CronJob", podCount: 1, cpu: "100m", memory: "64Mi", groups: []string{"cluster", "zone/zone-b"}},
+	} {
+		workload := fitData.Workloads[i]
+		if workload.Kind != expected.kind || workload.PodCount != expected.podCount {
+			t.Errorf("workload %d is a %s with %d pods, expected a %s with %d pods", i, workload.Kind, workload.PodCount, expected.kind, expected.podCount)
+		}
+		if workload.RequestsCPU.Cmp(resource.MustParse(expected.cpu)) != 0 || workload.RequestsMemory.Cmp(resource.MustParse(expected.memory)) != 0 {
+			t.Errorf("%s requests %s cpu and %s memory, expected %s and %s", workload.Kind, workload.RequestsCPU.String(), workload.RequestsMemory.String(), expected.cpu, expected.memory)
+		}
+		if strings.Join(workload.Groups, ",") != strings.Join(expected.groups, ",") {
+			t.Errorf("%s is in groups %v, expected %v", workload.Kind, workload.Groups, expected.groups)
+		}
+	}
+
+	for i, expected := range []struct {
+		group    string
+		fits     bool
+		reason   string
+		podCount int
+		cpu      string
+	}{
+		{group: "cluster", fits: true, podCount: 9, cpu: "6500m"},
+		{group: "role/infra", fits: false, reason: "cpu requests exceed available cpu", podCount: 2, cpu: "4"},
+		{group: "zone/zone-b", fits: true, podCount: 1, cpu: "100m"},
+	} {
+		group := fitData.Groups[i]
+		if group.Group != expected.group || group.Fits != expected.fits || group.Reason != expected.reason || group.PodCount != expected.podCount {
+			t.Errorf("group %d is %s fits %t (%s) with %d pods, expected %s fits %t (%s) with %d pods", i,
+				group.Group, group.Fits, group.Reason, group.PodCount, expected.group, expected.fits, expected.reason, expected.podCount)
+		}
+		if group.RequestsCPU.Cmp(resource.MustParse(expected.cpu)) != 0 {
+			t.Errorf("group %s requests %s cpu, expected %s", group.Group, group.RequestsCPU.String(), expected.cpu)
+		}
+	}
+	if fitData.Fits {
+		t.Errorf("expected the workloads not to fit")
+	}
+}
+
+// A pod larger than each node does not fit even when the sum of the nodes holds it
+func TestGetFitDataPodFitsOnNoNode(t *testing.T) {
+	workload := manifest.Workload{Kind: "Deployment", Name: "large", Replicas: 1, Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "large", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3")}}}},
+	}}}
+	nodesCapacityData := map[string]*output.NodeCapacityData{
+		"worker-a": newNodeCapacityData("worker", "zone-a", 110, "2", "8Gi"),
+		"worker-b": newNodeCapacityData("worker", "zone-b", 110, "2", "8Gi"),
+	}
+	antiAffinity := newAntiAffinity(&corev1.NodeList{}, &corev1.PodList{}, kubesize.PodFilter{})
+	fitData := getFitData([]manifest.Workload{workload}, nodesCapacityData, []string{"worker-a", "worker-b"}, antiAffinity)
+	if fitData.Fits || fitData.Groups[0].Reason != "a pod of deployment/large fits on no node" {
+		t.Errorf("expected the pod to fit on no node, got fits %t (%s)", fitData.Fits, fitData.Groups[0].Reason)
+	}
+}
+
+func TestNewSimulatedPod(t *testing.T) {
+	workload := manifest.Workload{
+		Kind:      "StatefulSet",
+		Namespace: "app",
+		Name:      "db",
+		Replicas:  3,
+		Template:  corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}}},
+		TopologySpreadConstraints: []manifest.TopologySpreadConstraint{
+			{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: "DoNotSchedule"},
+		},
+	}
+	pod := newSimulatedPod(workload, 2)
+	if pod.Namespace != "app" || pod.Name != "db-2" {
+		t.Errorf("pod is %s/%s, expected app/db-2", pod.Namespace, pod.Name)
+	}
+	if pod.Labels["app"] != "db" || len(pod.TopologySpreadConstraints) != 1 {
+		t.Errorf("expected the labels and topology spread constraints of the template")
+	}
+	pod.Labels["app"] = "changed"
+	if workload.Template.Labels["app"] != "db" {
+		t.Errorf("expected the labels of the template to be copied")
+	}
+}
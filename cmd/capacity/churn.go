@@ -0,0 +1,86 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var churnCmd = &cobra.Command{
+	Use:   "churn",
+	Short: "Report the pod creations and deletions per minute of each namespace and node role",
+	Long:  `Report the pods created and deleted per minute over a recent window of each namespace and node role from pod creation timestamps and events, since high pod churn loads the API server, scheduler, controllers and kubelets as a capacity constraint in its own right`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		window, _ := cmd.Flags().GetDuration("window")
+		if window <= 0 {
+			return fmt.Errorf("window \"%s\" is invalid, it must be positive", window)
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("node list")
+		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list nodes")
+		}
+
+		stopTiming = startStage("pod list")
+		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list pods")
+		}
+
+		stopTiming = startStage("event list")
+		events, err := clientset.CoreV1().Events("").List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list events")
+		}
+
+		churnData := kubesize.PodChurn(nodes, pods, events, window, time.Now(), displayOptions.PinnedRoles, displayOptions.NoneLast)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayChurnData(churnData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(churnCmd)
+	churnCmd.Flags().DurationP("window", "", time.Hour, "Recent window to count pod creations and deletions over, events are only kept for the event TTL of the API server")
+	churnCmd.RunE = watchRunE(churnCmd.RunE)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+	"strings"
+
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Completes the flag with the fixed values
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// Completes the namespaces of the source of the command, so --from snapshots complete as well as the cluster
+func completeNamespaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	capacitySource, err := getSource(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	namespaces, err := capacitySource.Namespaces("")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names := make([]string, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		names = append(names, namespace.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the node roles of the source of the command, after the roles already listed in the comma separated value
+func completeNodeRoles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	nodes, err := completionNodes(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	_, _, nodesByRole := kubesize.NodeCapacity(nodes, &corev1.PodList{}, false, false, false, kubesize.PodFilter{})
+	roles := make([]string, 0, len(nodesByRole))
+	for role := range nodesByRole {
+		// --none-last orders nodes without a role
+		if role != "<none>" {
+			roles = append(roles, role)
+		}
+	}
+	return completeList(roles, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the label keys of the nodes of the source of the command, after the keys already listed in the comma
+// separated value
+func completeNodeLabels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	nodes, err := completionNodes(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	labels := sets.NewString()
+	for _, node := range nodes.Items {
+		for label := range node.Labels {
+			labels.Insert(label)
+		}
+	}
+	return completeList(labels.List(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completionNodes(cmd *cobra.Command) (*corev1.NodeList, error) {
+	capacitySource, err := getSource(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return capacitySource.Nodes()
+}
+
+// Completes the contexts of the kubeconfig
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	rawConfig, err := KubernetesConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	contexts := make([]string, 0, len(rawConfig.Contexts))
+	for context := range rawConfig.Contexts {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+	return contexts, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Completes the next value of a comma separated list, leaving out the values already listed
+func completeList(values []string, toComplete string) []string {
+	listed := strings.Split(toComplete, ",")
+	prefix := strings.Join(listed[:len(listed)-1], ",")
+	if prefix != "" {
+		prefix += ","
+	}
+	skip := sets.NewString(listed...)
+	sort.Strings(values)
+	completions := make([]string, 0, len(values))
+	for _, value := range values {
+		if !skip.Has(value) {
+			completions = append(completions, prefix+value)
+		}
+	}
+	return completions
+}
@@ -0,0 +1,205 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// Prefix of the environment variables of the flags
+const envPrefix = "KUBESIZE_"
+
+// The environment variable of a flag, for example KUBESIZE_WARN_THRESHOLD for --warn-threshold
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Sets the flags that are not set on the command line from their environment variables, so flags take precedence over
+// the environment
+func bindEnvironment(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == "help" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(flag.Name))
+		if !ok {
+			return
+		}
+		if setErr := cmd.Flags().Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %v", envName(flag.Name), setErr)
+		}
+	})
+	return err
+}
+
+// Config file read when --config is not set
+const defaultConfigFile = ".kubesize.yaml"
+
+// Default flags of a config file, keyed by flag name, its named profiles of flags selected with --profile and the
+// flags of each kube context
+type config struct {
+	flags    map[string]interface{}
+	profiles map[string]map[string]interface{}
+	contexts map[string]map[string]interface{}
+}
+
+// Reads the config file of --config, the default config file in the home directory when unset. A missing default
+// config file is an empty config.
+func loadConfig(cmd *cobra.Command) (*config, error) {
+	path, _ := cmd.Flags().GetString("config")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &config{}, nil
+		}
+		path = filepath.Join(home, defaultConfigFile)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return &config{}, nil
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	// Numbers are kept as written, so large integers are not formatted as floats
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	var values struct {
+		Profiles map[string]map[string]interface{} `json:"profiles"`
+		Contexts map[string]map[string]interface{} `json:"contexts"`
+	}
+	flags := map[string]interface{}{}
+	for _, into := range []interface{}{&values, &flags} {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(into); err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+		}
+	}
+	delete(flags, "profiles")
+	delete(flags, "contexts")
+	return &config{flags: flags, profiles: values.Profiles, contexts: values.Contexts}, nil
+}
+
+// Sets the flags that are not set on the command line or in the environment from the profile of --profile, then from
+// the settings of the kube context of the run and then from the defaults of the config file. The context is resolved
+// after the profile, since a profile may select it.
+func applyConfig(cmd *cobra.Command, config *config) error {
+	known := allFlagNames(cmd.Root())
+	if profileName, _ := cmd.Flags().GetString("profile"); profileName != "" {
+		profile, ok := config.profiles[profileName]
+		if !ok {
+			return fmt.Errorf("profile \"%s\" is not in the config file", profileName)
+		}
+		if err := applyConfigFlags(cmd, profile, known); err != nil {
+			return err
+		}
+	}
+	if context := runContext(cmd); context != "" {
+		if settings, ok := config.contexts[context]; ok {
+			if _, ok := settings["context"]; ok {
+				return fmt.Errorf("config file flag \"context\" is invalid in the settings of context \"%s\"", context)
+			}
+			if err := applyConfigFlags(cmd, settings, known); err != nil {
+				return err
+			}
+		}
+	}
+	return applyConfigFlags(cmd, config.flags, known)
+}
+
+// The kube context the command runs against, the current context of the kubeconfig unless --context is set, and none
+// with --from or without a kubeconfig
+func runContext(cmd *cobra.Command) string {
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		return ""
+	}
+	if context, _ := cmd.Flags().GetString("context"); context != "" {
+		return context
+	}
+	rawConfig, err := KubernetesConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	return rawConfig.CurrentContext
+}
+
+func applyConfigFlags(cmd *cobra.Command, values map[string]interface{}, known map[string]bool) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] || name == "config" || name == "profile" {
+			return fmt.Errorf("config file flag \"%s\" is invalid", name)
+		}
+		// Flags of other sub-commands
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		if err := setConfigFlag(cmd.Flags(), name, values[name]); err != nil {
+			return fmt.Errorf("config file flag \"%s\": %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Lists are set one value at a time, which repeats flags such as --as-group and appends to list flags
+func setConfigFlag(flags *pflag.FlagSet, name string, value interface{}) error {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	for _, value := range values {
+		if err := flags.Set(name, fmt.Sprint(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flags of the command and all its sub-commands
+func allFlagNames(cmd *cobra.Command) map[string]bool {
+	names := map[string]bool{}
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		names[flag.Name] = true
+	})
+	cmd.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		names[flag.Name] = true
+	})
+	for _, subCmd := range cmd.Commands() {
+		for name := range allFlagNames(subCmd) {
+			names[name] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// Sets the environment variables for the duration of the test
+func setenv(t *testing.T, env map[string]string) {
+	for name, value := range env {
+		previous, ok := os.LookupEnv(name)
+		os.Setenv(name, value)
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+func newConfigTestCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "test", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().StringP("config", "", "", "")
+	cmd.Flags().StringP("profile", "", "", "")
+	cmd.Flags().StringP("context", "", "", "")
+	cmd.Flags().StringP("from", "", "", "")
+	cmd.Flags().IntP("warn-threshold", "", 80, "")
+	cmd.Flags().StringSliceP("as-group", "", []string{}, "")
+	return cmd
+}
+
+// Parses the args and applies the environment and the config file like the root command does
+func configureTestCommand(t *testing.T, configFile string, args ...string) (*cobra.Command, error) {
+	path := filepath.Join(t.TempDir(), "kubesize.yaml")
+	if err := ioutil.WriteFile(path, []byte(configFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newConfigTestCommand()
+	if err := cmd.ParseFlags(append([]string{"--config", path}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bindEnvironment(cmd); err != nil {
+		return nil, err
+	}
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return cmd, applyConfig(cmd, config)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		flag     string
+		env      string
+		profile  string
+		context  string
+		defaults string
+		expected int
+	}{
+		{name: "flag default", expected: 80},
+		{name: "config file default", defaults: "5", expected: 5},
+		{name: "context over default", context: "4", defaults: "5", expected: 4},
+		{name: "profile over context", profile: "3", context: "4", defaults: "5", expected: 3},
+		{name: "env over profile", env: "2", profile: "3", context: "4", defaults: "5", expected: 2},
+		{name: "flag over env", flag: "1", env: "2", profile: "3", context: "4", defaults: "5", expected: 1},
+		// Flags set to their default value are still set
+		{name: "flag set to its default", flag: "80", env: "2", profile: "3", expected: 80},
+		{name: "env set to the flag default", env: "80", profile: "3", defaults: "5", expected: 80},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			configFile := "profiles:\n  test:\n    as-group: [profile]\n"
+			if test.profile != "" {
+				configFile += "    warn-threshold: " + test.profile + "\n"
+			}
+			configFile += "contexts:\n  prod:\n    as-group: [context]\n"
+			if test.context != "" {
+				configFile += "    warn-threshold: " + test.context + "\n"
+			}
+			if test.defaults != "" {
+				configFile += "warn-threshold: " + test.defaults + "\n"
+			}
+			args := []string{"--context", "prod", "--profile", "test"}
+			if test.flag != "" {
+				args = append(args, "--warn-threshold", test.flag)
+			}
+			if test.env != "" {
+				setenv(t, map[string]string{"KUBESIZE_WARN_THRESHOLD": test.env})
+			}
+			cmd, err := configureTestCommand(t, configFile, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if threshold, _ := cmd.Flags().GetInt("warn-threshold"); threshold != test.expected {
+				t.Errorf("warn-threshold is %d, expected %d", threshold, test.expected)
+			}
+		})
+	}
+}
+
+// The context a profile selects is resolved before the context settings apply
+func TestConfigProfileSelectsContext(t *testing.T) {
+	cmd, err := configureTestCommand(t, "profiles:\n  prod:\n    context: prod\ncontexts:\n  prod:\n    warn-threshold: 90\n", "--profile", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold, _ := cmd.Flags().GetInt("warn-threshold"); threshold != 90 {
+		t.Errorf("warn-threshold is %d, expected the 90 of the context of the profile", threshold)
+	}
+}
+
+func TestConfigFromIgnoresContext(t *testing.T) {
+	cmd, err := configureTestCommand(t, "contexts:\n  prod:\n    warn-threshold: 90\n", "--context", "prod", "--from", "snapshot.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold, _ := cmd.Flags().GetInt("warn-threshold"); threshold != 80 {
+		t.Errorf("warn-threshold is %d, expected the flag default", threshold)
+	}
+}
+
+func TestConfigLists(t *testing.T) {
+	cmd, err := configureTestCommand(t, "as-group: [admins, viewers]\n", "--context", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups, _ := cmd.Flags().GetStringSlice("as-group"); !reflect.DeepEqual(groups, []string{"admins", "viewers"}) {
+		t.Errorf("as-group is %v, expected [admins viewers]", groups)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		configFile string
+		env        map[string]string
+		args       []string
+		err        string
+	}{
+		{name: "invalid env value", env: map[string]string{"KUBESIZE_WARN_THRESHOLD": "high"}, err: "KUBESIZE_WARN_THRESHOLD"},
+		{name: "missing profile", args: []string{"--profile", "prod"}, err: "profile \"prod\" is not in the config file"},
+		{name: "unknown flag", configFile: "threshold: 90\n", err: "config file flag \"threshold\" is invalid"},
+		{name: "profile in profile", configFile: "profiles:\n  prod:\n    profile: dev\n", args: []string{"--profile", "prod"}, err: "config file flag \"profile\" is invalid"},
+		{name: "context in context", configFile: "contexts:\n  prod:\n    context: dev\n", err: "config file flag \"context\" is invalid in the settings of context \"prod\""},
+		{name: "invalid value", configFile: "warn-threshold: high\n", err: "config file flag \"warn-threshold\""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, test.env)
+			_, err := configureTestCommand(t, test.configFile, append([]string{"--context", "prod"}, test.args...)...)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected an error containing %s, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/alert"
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Condition of a ClusterCapacityReport that is True while any threshold is crossed
+const conditionCapacityLow string = "CapacityLow"
+
+// Status of a ClusterCapacityReport, the capacity data of the cluster and of each node role as printed by the cluster
+// and node-role sub-commands in json output
+type clusterCapacityReportStatus struct {
+	LastUpdateTime metav1.Time                            `json:"lastUpdateTime"`
+	Cluster        *output.ClusterCapacityData            `json:"cluster"`
+	NodeRoles      map[string]*output.ClusterCapacityData `json:"nodeRoles"`
+	Conditions     []reportCondition                      `json:"conditions,omitempty"`
+}
+
+type reportCondition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime"`
+	Reason             string                 `json:"reason"`
+	Message            string                 `json:"message"`
+}
+
+// Writes the ClusterCapacityReport, the breaches and conditions of the previous update are kept to record events and
+// transition times
+type reportController struct {
+	clientset      *kubernetes.Clientset
+	reportName     string
+	displayOptions output.DisplayOptions
+	breaches       []output.Breach
+	conditions     []reportCondition
+}
+
+var controllerCmd = &cobra.Command{
+	Use:   "controller",
+	Short: "Write capacity data into a ClusterCapacityReport",
+	Long:  `Periodically write cluster and node-role capacity data, threshold conditions and events into a ClusterCapacityReport custom resource`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			return fmt.Errorf("interval must be greater than 0")
+		}
+
+		reportName, _ := cmd.Flags().GetString("report-name")
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := servePprof(cmd); err != nil {
+			return err
+		}
+
+		health := newHealth(interval)
+		if err := health.serve(cmd); err != nil {
+			return err
+		}
+
+		controller := &reportController{clientset: clientset, reportName: reportName, displayOptions: displayOptions}
+
+		// A failed update is retried on the next interval instead of stopping the controller
+		for {
+			if err := controller.update(); err != nil {
+				logger.Error(err, "failed to update report", "name", reportName)
+			} else {
+				health.collected()
+				logger.V(1).Info("updated report", "name", reportName)
+			}
+			time.Sleep(interval)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(controllerCmd)
+	controllerCmd.Flags().StringP("report-name", "", "cluster", "Name of the ClusterCapacityReport to write")
+	addHealthFlag(controllerCmd)
+	addPprofFlag(controllerCmd)
+}
+
+func (c *reportController) update() error {
+	nodes, err := c.clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+
+	pods, err := c.clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to list pods")
+	}
+
+	filter := newPodFilter(c.displayOptions)
+	nodeRoleCapacityData, roleNames := kubesize.NodeRoleCapacity(nodes, pods, false, false, false, filter)
+	status := clusterCapacityReportStatus{
+		LastUpdateTime: metav1.Now(),
+		Cluster:        kubesize.ClusterCapacity(nodes, pods, filter),
+		NodeRoles:      make(map[string]*output.ClusterCapacityData, len(roleNames)),
+	}
+	for _, role := range roleNames {
+		status.NodeRoles[role] = nodeRoleCapacityData[role]
+	}
+
+	breaches := append(clusterBreaches(c.displayOptions, status.Cluster), nodeRoleBreaches(c.displayOptions, nodeRoleCapacityData, roleNames)...)
+	status.Conditions = []reportCondition{c.capacityLowCondition(breaches, status.LastUpdateTime)}
+
+	report, err := kube.UpdateClusterCapacityReport(c.clientset, c.reportName, status)
+	if err != nil {
+		return err
+	}
+	c.conditions = status.Conditions
+
+	// Events are only recorded for breaches that started, changed level or cleared, a failed event is recorded again on
+	// the next update
+	for _, transition := range alert.Transitions(c.breaches, breaches) {
+		eventType, reason := corev1.EventTypeWarning, "ThresholdCrossed"
+		if transition.Status == alert.StatusResolved {
+			eventType, reason = corev1.EventTypeNormal, "ThresholdCleared"
+		}
+		if err := kube.RecordReportEvent(c.clientset, report, eventType, reason, breachMessage(transition.Breach)); err != nil {
+			return err
+		}
+	}
+	c.breaches = breaches
+	return nil
+}
+
+// The transition time of the previous condition is kept while its status does not change
+func (c *reportController) capacityLowCondition(breaches []output.Breach, now metav1.Time) reportCondition {
+	condition := reportCondition{Type: conditionCapacityLow, Status: corev1.ConditionFalse, LastTransitionTime: now, Reason: "WithinThresholds", Message: "No threshold is crossed"}
+	if len(breaches) > 0 {
+		messages := make([]string, 0, len(breaches))
+		for _, breach := range breaches {
+			messages = append(messages, breachMessage(breach))
+		}
+		condition.Status, condition.Reason, condition.Message = corev1.ConditionTrue, "WarningThresholdCrossed", strings.Join(messages, "; ")
+		if breachExitCode(breaches) == exitCodeCritical {
+			condition.Reason = "CriticalThresholdCrossed"
+		}
+	}
+	for _, previous := range c.conditions {
+		if previous.Type == condition.Type && previous.Status == condition.Status {
+			condition.LastTransitionTime = previous.LastTransitionTime
+		}
+	}
+	return condition
+}
+
+func breachMessage(breach output.Breach) string {
+	group := breach.Group
+	if group == "" {
+		group = "cluster"
+	}
+	return fmt.Sprintf("%s %s requests %.1f%% crossed the %s threshold %.0f%%", group, breach.Resource, breach.Percent, breach.Level, breach.Threshold)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var cpuManagerCmd = &cobra.Command{
+	Use:   "cpu-manager",
+	Short: "Split the cpu of each node into exclusive cores and the shared pool",
+	Long:  `Read the CPU manager policy of each kubelet (through the node proxy) and split the allocatable cpu of each node into the cores the static policy pins to the containers of Guaranteed pods with integral cpu requests and the shared pool, since the shared pool headroom is what burstable pods actually compete for`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		staticNodes, _ := cmd.Flags().GetStringSlice("static-nodes")
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("node list")
+		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list nodes")
+		}
+
+		stopTiming = startStage("pod list")
+		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list pods")
+		}
+
+		// Nodes whose kubelet configuration can not be read have no policy, unless assumed static with --static-nodes
+		policies := make(map[string]string, len(nodes.Items))
+		for _, nodeName := range staticNodes {
+			policies[nodeName] = kubesize.StaticCPUManagerPolicy
+		}
+		stopTiming = startStage("kubelet config fetch")
+		var unread []string
+		for _, node := range nodes.Items {
+			if _, ok := policies[node.Name]; ok {
+				continue
+			}
+			kubeletConfig, err := kube.GetKubeletConfig(clientset, node.Name)
+			if err != nil {
+				unread = append(unread, node.Name)
+				continue
+			}
+			policies[node.Name] = kubeletConfig.CPUManagerPolicy
+			if policies[node.Name] == "" {
+				policies[node.Name] = "none"
+			}
+		}
+		stopTiming()
+		if len(unread) > 0 {
+			logger.Warning("failed to read the kubelet configuration of some nodes, their cores are counted as shared", "nodes", unread, "count", len(unread))
+		}
+
+		cpuManagerData := kubesize.CPUManagerCapacity(nodes, pods, policies, newPodFilter(displayOptions))
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayCPUManagerData(cpuManagerData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cpuManagerCmd)
+	cpuManagerCmd.Flags().StringSliceP("static-nodes", "", []string{}, "Comma separated nodes assumed to use the static CPU manager policy without reading their kubelet configuration")
+	cpuManagerCmd.RunE = watchRunE(cpuManagerCmd.RunE)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akrzos/kubeSize/internal/metrics"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Grafana dashboards of the exported metrics",
+	Long:  `Generate Grafana dashboards of the exported capacity metrics`,
+}
+
+var dashboardGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a Grafana dashboard",
+	Long:  `Print a Grafana dashboard JSON wired to the names and labels of the exported capacity metrics`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		title, _ := cmd.Flags().GetString("title")
+
+		dashboard, err := metrics.GrafanaDashboard(title)
+		if err != nil {
+			return errors.Wrap(err, "failed to generate dashboard")
+		}
+
+		outputFile, _ := cmd.Flags().GetString("output-file")
+		if outputFile == "" {
+			_, err := fmt.Fprintf(cmd.OutOrStdout(), "%s\n", dashboard)
+			return err
+		}
+		err = output.WriteFileAtomic(outputFile, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%s\n", dashboard)
+			return err
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to write output file")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+	dashboardCmd.AddCommand(dashboardGenerateCmd)
+	dashboardGenerateCmd.Flags().StringP("title", "", "kubeSize capacity", "Title of the dashboard")
+}
@@ -0,0 +1,132 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var densityCmd = &cobra.Command{
+	Use:   "density",
+	Short: "Get the pod density of each node role",
+	Long:  `Get the pods per allocatable core, per GiB of allocatable memory and per node of each node role, flagging pools whose density approaches the practical limits of the kubelet to inform max-pods tuning`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		maxPodsPerCore, _ := cmd.Flags().GetFloat64("max-pods-per-core")
+		maxPodsPerNode, _ := cmd.Flags().GetInt("max-pods-per-node")
+		if maxPodsPerCore <= 0 || maxPodsPerNode <= 0 {
+			return fmt.Errorf("--max-pods-per-core and --max-pods-per-node must be greater than 0")
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		densityData := getDensityData(nodes, pods, newPodFilter(displayOptions), maxPodsPerCore, maxPodsPerNode, displayOptions.WarnThreshold)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayDensityData(densityData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(densityCmd)
+	densityCmd.RunE = watchRunE(densityCmd.RunE)
+	densityCmd.Flags().Float64P("max-pods-per-core", "", 10, "Practical limit of non-terminated pods per allocatable core, the podsPerCore of the kubelet")
+	densityCmd.Flags().IntP("max-pods-per-node", "", 110, "Practical limit of non-terminated pods per node, the default max-pods of the kubelet")
+}
+
+// Densities of each node role from the non-terminated pods on its nodes. A role is over when its pods per core or its
+// densest node pass the limits and near when either passes warnThreshold percent of its limit.
+func getDensityData(nodes *corev1.NodeList, pods *corev1.PodList, filter kubesize.PodFilter, maxPodsPerCore float64, maxPodsPerNode int, warnThreshold float64) output.DensityData {
+	nodeRoleCapacityData, roleNames := kubesize.NodeRoleCapacity(nodes, pods, false, false, false, filter)
+	nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, filter)
+
+	densityData := output.DensityData{NodeRoles: make([]output.NodeRoleDensityData, 0, len(roleNames))}
+	for _, role := range roleNames {
+		data := nodeRoleCapacityData[role]
+		density := output.NodeRoleDensityData{
+			Role:                 role,
+			Nodes:                data.TotalNodeCount,
+			Pods:                 data.TotalNonTermPodCount,
+			AllocatableCPUCores:  data.TotalAllocatableCPUCores,
+			AllocatableMemoryGiB: data.TotalAllocatableMemoryGiB,
+			Status:               "ok",
+			Reasons:              make([]string, 0),
+		}
+		if density.AllocatableCPUCores > 0 {
+			density.PodsPerCore = round(float64(density.Pods) / density.AllocatableCPUCores)
+		}
+		if density.AllocatableMemoryGiB > 0 {
+			density.PodsPerGiB = round(float64(density.Pods) / density.AllocatableMemoryGiB)
+		}
+		if density.Nodes > 0 {
+			density.PodsPerNode = round(float64(density.Pods) / float64(density.Nodes))
+		}
+		// Nodes are visited by name so ties resolve to the same node on every run
+		for _, nodeName := range nodeNames {
+			nodeData := nodesCapacityData[nodeName]
+			if nodeData.Roles.Has(role) && (density.DensestNode == "" || nodeData.TotalNonTermPodCount > density.MaxPodsPerNode) {
+				density.DensestNode = nodeName
+				density.MaxPodsPerNode = nodeData.TotalNonTermPodCount
+			}
+		}
+
+		check := func(value float64, limit float64, description string) {
+			percent := value / limit * 100
+			switch {
+			case percent > 100:
+				density.Status = "over"
+				density.Reasons = append(density.Reasons, fmt.Sprintf("%s %g exceeds the limit of %g", description, value, limit))
+			case percent > warnThreshold:
+				if density.Status == "ok" {
+					density.Status = "near"
+				}
+				density.Reasons = append(density.Reasons, fmt.Sprintf("%s %g is %.0f%% of the limit of %g", description, value, percent, limit))
+			}
+		}
+		check(density.PodsPerCore, maxPodsPerCore, "pods per core")
+		check(float64(density.MaxPodsPerNode), float64(maxPodsPerNode), "pods on "+density.DensestNode)
+
+		densityData.NodeRoles = append(densityData.NodeRoles, density)
+	}
+	return densityData
+}
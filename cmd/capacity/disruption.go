@@ -0,0 +1,231 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var disruptionCmd = &cobra.Command{
+	Use:   "disruption",
+	Short: "Show which node drains and zone losses pod disruption budgets block",
+	Long:  `Correlate pod disruption budgets with the nodes and zones their healthy pods run on, showing which node drains they would block, which zone losses would violate them and which node roles they make undrainable`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		podDisruptionBudgets, err := capacitySource.PodDisruptionBudgets("")
+		if err != nil {
+			return err
+		}
+
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, newPodFilter(displayOptions))
+
+		disruptionData := getDisruptionData(podDisruptionBudgets, pods, nodesCapacityData, nodeNames)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayDisruptionData(disruptionData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(disruptionCmd)
+	disruptionCmd.RunE = watchRunE(disruptionCmd.RunE)
+}
+
+// A drain evicts the pods of a node through the eviction API, which refuses to evict more healthy pods of a budget
+// than it allows to be disrupted. Losing a zone disrupts its pods without asking, violating the budgets of more healthy
+// pods than they allow.
+func getDisruptionData(podDisruptionBudgets *policyv1beta1.PodDisruptionBudgetList, pods *corev1.PodList, nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string) output.DisruptionData {
+	disruptionData := output.DisruptionData{Budgets: make([]output.BudgetDisruptionData, 0), Nodes: make([]output.NodeDisruptionData, 0), Zones: make([]output.ZoneDisruptionData, 0)}
+
+	nodeIndex := make(map[string]int)
+	zones := sets.NewString()
+	for _, nodeName := range nodeNames {
+		data := nodesCapacityData[nodeName]
+		nodeIndex[nodeName] = len(disruptionData.Nodes)
+		disruptionData.Nodes = append(disruptionData.Nodes, output.NodeDisruptionData{Node: nodeName, Roles: data.Roles.List(), Zone: data.Zone, Drainable: true})
+		if data.Zone != "" {
+			zones.Insert(data.Zone)
+		}
+	}
+	zoneIndex := make(map[string]int)
+	for i, zone := range zones.List() {
+		zoneIndex[zone] = i
+		disruptionData.Zones = append(disruptionData.Zones, output.ZoneDisruptionData{Zone: zone, Survivable: true})
+	}
+	for _, nodeData := range disruptionData.Nodes {
+		if i, ok := zoneIndex[nodeData.Zone]; ok {
+			disruptionData.Zones[i].NodeCount++
+		}
+	}
+	for _, pod := range pods.Items {
+		i, ok := nodeIndex[pod.Spec.NodeName]
+		if !ok || !podHealthy(pod) {
+			continue
+		}
+		disruptionData.Nodes[i].HealthyPodCount++
+		if j, ok := zoneIndex[disruptionData.Nodes[i].Zone]; ok {
+			disruptionData.Zones[j].HealthyPodCount++
+		}
+	}
+
+	for _, podDisruptionBudget := range podDisruptionBudgets.Items {
+		budgetName := podDisruptionBudget.Namespace + "/" + podDisruptionBudget.Name
+		selector := budgetSelector(podDisruptionBudget.Spec.Selector)
+		nodePods := make(map[string]int)
+		zonePods := make(map[string]int)
+		healthy, expected := 0, 0
+		for _, pod := range pods.Items {
+			if pod.Namespace != podDisruptionBudget.Namespace || !selector.Matches(labels.Set(pod.Labels)) || (pod.Status.Phase == corev1.PodSucceeded) || (pod.Status.Phase == corev1.PodFailed) {
+				continue
+			}
+			expected++
+			if !podHealthy(pod) {
+				continue
+			}
+			healthy++
+			nodePods[pod.Spec.NodeName]++
+			if i, ok := nodeIndex[pod.Spec.NodeName]; ok && disruptionData.Nodes[i].Zone != "" {
+				zonePods[disruptionData.Nodes[i].Zone]++
+			}
+		}
+
+		budgetData := output.BudgetDisruptionData{Namespace: podDisruptionBudget.Namespace, Name: podDisruptionBudget.Name, PodCount: expected, HealthyPodCount: healthy}
+		if podDisruptionBudget.Status.ObservedGeneration > 0 {
+			// The disruption controller counts the pods the controllers of the pods expect, not only those that exist
+			budgetData.DesiredHealthyPodCount = int(podDisruptionBudget.Status.DesiredHealthy)
+			budgetData.DisruptionsAllowed = int(podDisruptionBudget.Status.PodDisruptionsAllowed)
+		} else {
+			budgetData.DesiredHealthyPodCount = desiredHealthy(podDisruptionBudget.Spec, expected)
+			if allowed := healthy - budgetData.DesiredHealthyPodCount; allowed > 0 {
+				budgetData.DisruptionsAllowed = allowed
+			}
+		}
+
+		blockedRoles := sets.NewString()
+		for nodeName, count := range nodePods {
+			i, ok := nodeIndex[nodeName]
+			if !ok || count <= budgetData.DisruptionsAllowed {
+				continue
+			}
+			nodeData := &disruptionData.Nodes[i]
+			nodeData.Drainable = false
+			nodeData.BlockingBudgets = append(nodeData.BlockingBudgets, budgetName)
+			budgetData.BlockedNodes = append(budgetData.BlockedNodes, nodeName)
+			blockedRoles.Insert(nodeData.Roles...)
+		}
+		sort.Strings(budgetData.BlockedNodes)
+		budgetData.BlockedRoles = blockedRoles.List()
+		for zone, count := range zonePods {
+			if count > budgetData.DisruptionsAllowed {
+				zoneData := &disruptionData.Zones[zoneIndex[zone]]
+				zoneData.Survivable = false
+				zoneData.ViolatedBudgets = append(zoneData.ViolatedBudgets, budgetName)
+				budgetData.ViolatedZones = append(budgetData.ViolatedZones, zone)
+			}
+		}
+		sort.Strings(budgetData.ViolatedZones)
+		disruptionData.Budgets = append(disruptionData.Budgets, budgetData)
+	}
+	for i := range disruptionData.Nodes {
+		sort.Strings(disruptionData.Nodes[i].BlockingBudgets)
+	}
+	for i := range disruptionData.Zones {
+		sort.Strings(disruptionData.Zones[i].ViolatedBudgets)
+	}
+	return disruptionData
+}
+
+// Running, ready pods that are not being deleted count towards the healthy pods of a budget
+func podHealthy(pod corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// A nil selector matches no pods and an empty selector every pod of the namespace, like policy/v1 matches them
+func budgetSelector(labelSelector *metav1.LabelSelector) labels.Selector {
+	if labelSelector == nil {
+		return labels.Nothing()
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return labels.Nothing()
+	}
+	return selector
+}
+
+// Healthy pods a budget requires of the expected pods, percentages are rounded up like the disruption controller
+// rounds them
+func desiredHealthy(spec policyv1beta1.PodDisruptionBudgetSpec, expected int) int {
+	switch {
+	case spec.MinAvailable != nil:
+		minAvailable, err := intstr.GetValueFromIntOrPercent(spec.MinAvailable, expected, true)
+		if err != nil {
+			return expected
+		}
+		return minAvailable
+	case spec.MaxUnavailable != nil:
+		maxUnavailable, err := intstr.GetValueFromIntOrPercent(spec.MaxUnavailable, expected, true)
+		if err != nil {
+			return expected
+		}
+		if desired := expected - maxUnavailable; desired > 0 {
+			return desired
+		}
+		return 0
+	}
+	return expected
+}
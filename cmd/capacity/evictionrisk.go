@@ -0,0 +1,182 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var evictionRiskCmd = &cobra.Command{
+	Use:   "eviction-risk",
+	Short: "Rank nodes by their risk of memory pressure evictions and OOM kills",
+	Long:  `Rank nodes by their risk of memory pressure evictions and OOM kills from their memory usage (from metrics-server or the kubelets), the memory limits of their pods against allocatable and the memory left before the kubelet hard eviction threshold`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		evictionThresholdFlag, _ := cmd.Flags().GetString("eviction-threshold")
+		evictionThreshold, err := resource.ParseQuantity(evictionThresholdFlag)
+		if err != nil {
+			return errors.Wrapf(err, "invalid eviction-threshold \"%s\"", evictionThresholdFlag)
+		}
+
+		maxOvercommit, _ := cmd.Flags().GetFloat64("max-overcommit")
+
+		usageSource, err := getUsageSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("node list")
+		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list nodes")
+		}
+
+		stopTiming = startStage("pod list")
+		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list pods")
+		}
+
+		nodeUsage, err := getUsage(clientset, nodes, usageSource, false)
+		if err != nil {
+			return err
+		}
+
+		filter := newPodFilter(displayOptions)
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, filter)
+
+		evictionRiskData := getEvictionRiskData(nodesCapacityData, nodeNames, nodeUsage, unlimitedMemoryPodCounts(pods, filter), evictionThreshold, maxOvercommit, displayOptions)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayEvictionRiskData(evictionRiskData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evictionRiskCmd)
+	evictionRiskCmd.Flags().StringP("eviction-threshold", "", "100Mi", "Hard memory.available eviction threshold of the kubelets")
+	evictionRiskCmd.Flags().Float64P("max-overcommit", "", 1.5, "Ratio of memory limits to allocatable a node may be overcommitted by before its usage is a risk")
+	addUsageSourceFlag(evictionRiskCmd)
+	evictionRiskCmd.RunE = watchRunE(evictionRiskCmd.RunE)
+}
+
+// Ranks the nodes with metrics, highest risk first and by memory usage within a risk. A node is high risk when the
+// kubelet would already evict, or when its memory usage is past --crit-threshold percent of allocatable while its
+// limits overcommit it past the max overcommit, so its pods may still grow into eviction or OOM kills. It is medium risk
+// when only its usage is past --crit-threshold, or its usage is past --warn-threshold and it is overcommitted.
+func getEvictionRiskData(nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, nodeUsage map[string]kube.ResourceUsage, unlimitedPodCounts map[string]int, evictionThreshold resource.Quantity, maxOvercommit float64, displayOptions output.DisplayOptions) output.EvictionRiskData {
+	evictionRiskData := output.EvictionRiskData{Nodes: make([]output.NodeEvictionRiskData, 0)}
+	for _, nodeName := range nodeNames {
+		usage, ok := nodeUsage[nodeName]
+		if !ok {
+			continue
+		}
+		data := nodesCapacityData[nodeName]
+		nodeRisk := output.NodeEvictionRiskData{
+			Node:                   nodeName,
+			Roles:                  data.Roles.List(),
+			AllocatableMemory:      data.TotalAllocatableMemory.DeepCopy(),
+			LimitsMemory:           data.TotalLimitsMemory.DeepCopy(),
+			UsageMemory:            usage.Memory.DeepCopy(),
+			LimitsPercent:          capacity.Percent(data.TotalLimitsMemory, data.TotalAllocatableMemory),
+			UsagePercent:           capacity.Percent(usage.Memory, data.TotalAllocatableMemory),
+			UnlimitedMemoryPods:    unlimitedPodCounts[nodeName],
+			Reasons:                make([]string, 0),
+			EvictionHeadroomMemory: data.TotalCapacityMemory.DeepCopy(),
+		}
+		// The kubelet evicts once capacity minus the working set falls below the threshold
+		nodeRisk.EvictionHeadroomMemory.Sub(usage.Memory)
+		nodeRisk.EvictionHeadroomMemory.Sub(evictionThreshold)
+		nodeRisk.EvictionHeadroomMemoryGiB = capacity.ReadableMem(nodeRisk.EvictionHeadroomMemory)
+		nodeRisk.LimitsPercent = round(nodeRisk.LimitsPercent)
+		nodeRisk.UsagePercent = round(nodeRisk.UsagePercent)
+
+		overcommitted := nodeRisk.LimitsPercent > maxOvercommit*100
+		if overcommitted {
+			nodeRisk.Reasons = append(nodeRisk.Reasons, fmt.Sprintf("memory limits are %.1fx allocatable", nodeRisk.LimitsPercent/100))
+		}
+		if nodeRisk.UnlimitedMemoryPods > 0 {
+			nodeRisk.Reasons = append(nodeRisk.Reasons, fmt.Sprintf("%d pods have no memory limit", nodeRisk.UnlimitedMemoryPods))
+		}
+		switch {
+		case nodeRisk.EvictionHeadroomMemory.Sign() <= 0:
+			nodeRisk.Risk = "high"
+			nodeRisk.Reasons = append([]string{"memory available is below the eviction threshold"}, nodeRisk.Reasons...)
+		case nodeRisk.UsagePercent > displayOptions.CritThreshold && (overcommitted || nodeRisk.UnlimitedMemoryPods > 0):
+			nodeRisk.Risk = "high"
+		case nodeRisk.UsagePercent > displayOptions.CritThreshold, nodeRisk.UsagePercent > displayOptions.WarnThreshold && (overcommitted || nodeRisk.UnlimitedMemoryPods > 0):
+			nodeRisk.Risk = "medium"
+		default:
+			nodeRisk.Risk = "low"
+		}
+		if nodeRisk.UsagePercent > displayOptions.WarnThreshold {
+			nodeRisk.Reasons = append([]string{fmt.Sprintf("memory usage is %.1f%% of allocatable", nodeRisk.UsagePercent)}, nodeRisk.Reasons...)
+		}
+		evictionRiskData.Nodes = append(evictionRiskData.Nodes, nodeRisk)
+	}
+	riskRank := map[string]int{"high": 0, "medium": 1, "low": 2}
+	sort.SliceStable(evictionRiskData.Nodes, func(i, j int) bool {
+		if riskRank[evictionRiskData.Nodes[i].Risk] != riskRank[evictionRiskData.Nodes[j].Risk] {
+			return riskRank[evictionRiskData.Nodes[i].Risk] < riskRank[evictionRiskData.Nodes[j].Risk]
+		}
+		return evictionRiskData.Nodes[i].UsagePercent > evictionRiskData.Nodes[j].UsagePercent
+	})
+	return evictionRiskData
+}
+
+// Pods holding resources with a container without a memory limit on each node, their usage is bounded only by the node
+func unlimitedMemoryPodCounts(pods *corev1.PodList, filter kubesize.PodFilter) map[string]int {
+	counts := make(map[string]int)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || !filter.HoldsResources(pod) {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			if _, ok := container.Resources.Limits[corev1.ResourceMemory]; !ok {
+				counts[pod.Spec.NodeName]++
+				break
+			}
+		}
+	}
+	return counts
+}
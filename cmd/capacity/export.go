@@ -0,0 +1,116 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/metrics"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Metrics of the last run
+var collectedMetrics []metrics.Metric
+
+// Metric sinks that every sample is exported to
+func getSinks(cmd *cobra.Command) ([]metrics.Sink, error) {
+	sinks := make([]metrics.Sink, 0)
+
+	otlpEndpoint, _ := cmd.Flags().GetString("otlp-endpoint")
+	if otlpEndpoint != "" {
+		otlpHeaders, _ := cmd.Flags().GetStringSlice("otlp-header")
+		headers := make(map[string]string, len(otlpHeaders))
+		for _, header := range otlpHeaders {
+			keyValue := strings.SplitN(header, "=", 2)
+			if len(keyValue) != 2 {
+				return nil, fmt.Errorf("otlp-header \"%s\" must be in key=value form", header)
+			}
+			headers[keyValue[0]] = keyValue[1]
+		}
+		sinks = append(sinks, metrics.NewOTLP(otlpEndpoint, headers))
+	}
+
+	statsdAddress, _ := cmd.Flags().GetString("statsd-address")
+	if statsdAddress != "" {
+		sinks = append(sinks, &metrics.StatsD{Address: statsdAddress})
+	}
+
+	pushGateway, _ := cmd.Flags().GetString("push-gateway")
+	if pushGateway != "" {
+		sinks = append(sinks, metrics.NewPushgateway(pushGateway, "kubesize", cmd.Name()))
+	}
+
+	cloudWatchNamespace, _ := cmd.Flags().GetString("cloudwatch-namespace")
+	if cloudWatchNamespace != "" {
+		cloudWatchRegion, _ := cmd.Flags().GetString("cloudwatch-region")
+		cloudWatch, err := metrics.NewCloudWatch(cloudWatchNamespace, cloudWatchRegion)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, cloudWatch)
+	}
+
+	return sinks, nil
+}
+
+// Every metric is labeled with the cluster name, by default the cluster of the current kubeconfig context
+func clusterName(cmd *cobra.Command) string {
+	name, _ := cmd.Flags().GetString("cluster-name")
+	if name != "" {
+		return name
+	}
+	if *KubernetesConfigFlags.ClusterName != "" {
+		return *KubernetesConfigFlags.ClusterName
+	}
+	rawConfig, err := KubernetesConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	contextName := rawConfig.CurrentContext
+	if *KubernetesConfigFlags.Context != "" {
+		contextName = *KubernetesConfigFlags.Context
+	}
+	if context, ok := rawConfig.Contexts[contextName]; ok {
+		return context.Cluster
+	}
+	return ""
+}
+
+func exportMetrics(cmd *cobra.Command, sinks []metrics.Sink) error {
+	if len(collectedMetrics) == 0 {
+		return nil
+	}
+	cluster := clusterName(cmd)
+	labeled := make([]metrics.Metric, 0, len(collectedMetrics))
+	for _, metric := range collectedMetrics {
+		labels := map[string]string{"cluster": cluster}
+		for key, value := range metric.Labels {
+			labels[key] = value
+		}
+		metric.Labels = labels
+		labeled = append(labeled, metric)
+	}
+	timestamp := time.Now()
+	for _, sink := range sinks {
+		if err := sink.Export(timestamp, labeled); err != nil {
+			return errors.Wrap(err, "failed to export metrics")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var gapCmd = &cobra.Command{
+	Use:   "gap",
+	Short: "Get the capacity lost to reservations of each node role and node",
+	Long:  `Get the gap between capacity and allocatable of each node role and node, the hardware reserved for the system, the kubelet and eviction thresholds, flagging nodes with an outsized gap for their role`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		// Pods do not change capacity or allocatable
+		pods := &corev1.PodList{}
+
+		outlierPoints, _ := cmd.Flags().GetFloat64("outlier-points")
+
+		nodeRoleCapacityData, roleNames := kubesize.NodeRoleCapacity(nodes, pods, false, true, false, newPodFilter(displayOptions))
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, newPodFilter(displayOptions))
+
+		gapData := output.GapData{NodeRoles: make([]output.CapacityGapData, 0, len(roleNames)), Nodes: make([]output.CapacityGapData, 0, len(nodeNames))}
+		for _, role := range roleNames {
+			data := nodeRoleCapacityData[role]
+			roleGap := capacityGap(data.TotalCapacityCPU, data.TotalAllocatableCPU, data.TotalCapacityMemory, data.TotalAllocatableMemory, data.TotalCapacityEphemeralStorage, data.TotalAllocatableEphemeralStorage)
+			roleGap.Name = role
+			roleGap.Nodes = data.TotalNodeCount
+			gapData.NodeRoles = append(gapData.NodeRoles, roleGap)
+		}
+		for _, nodeName := range nodeNames {
+			data := nodesCapacityData[nodeName]
+			nodeGap := capacityGap(data.TotalCapacityCPU, data.TotalAllocatableCPU, data.TotalCapacityMemory, data.TotalAllocatableMemory, data.TotalCapacityEphemeralStorage, data.TotalAllocatableEphemeralStorage)
+			nodeGap.Name = nodeName
+			nodeGap.Roles = data.Roles.List()
+			gapData.Nodes = append(gapData.Nodes, nodeGap)
+		}
+		flagGapOutliers(gapData.Nodes, outlierPoints)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayGapData(gapData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gapCmd)
+	gapCmd.RunE = watchRunE(gapCmd.RunE)
+	gapCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
+	gapCmd.Flags().Float64P("outlier-points", "", 5, "Flag nodes whose cpu or memory gap percent is this many points above the median of the nodes with the same roles")
+}
+
+func capacityGap(capacityCPU, allocatableCPU, capacityMemory, allocatableMemory, capacityStorage, allocatableStorage resource.Quantity) output.CapacityGapData {
+	gapCPU, gapMemory, gapStorage := capacityCPU.DeepCopy(), capacityMemory.DeepCopy(), capacityStorage.DeepCopy()
+	gapCPU.Sub(allocatableCPU)
+	gapMemory.Sub(allocatableMemory)
+	gapStorage.Sub(allocatableStorage)
+	return output.CapacityGapData{
+		CapacityCPUCores:              capacity.ReadableCPU(capacityCPU),
+		AllocatableCPUCores:           capacity.ReadableCPU(allocatableCPU),
+		GapCPUCores:                   capacity.ReadableCPU(gapCPU),
+		GapCPUPercent:                 round(capacity.Percent(gapCPU, capacityCPU)),
+		CapacityMemoryGiB:             capacity.ReadableMem(capacityMemory),
+		AllocatableMemoryGiB:          capacity.ReadableMem(allocatableMemory),
+		GapMemoryGiB:                  capacity.ReadableMem(gapMemory),
+		GapMemoryPercent:              round(capacity.Percent(gapMemory, capacityMemory)),
+		CapacityEphemeralStorageGB:    capacity.ReadableStorage(capacityStorage),
+		AllocatableEphemeralStorageGB: capacity.ReadableStorage(allocatableStorage),
+		GapEphemeralStorageGB:         capacity.ReadableStorage(gapStorage),
+		GapEphemeralStoragePercent:    round(capacity.Percent(gapStorage, capacityStorage)),
+	}
+}
+
+// Flags the nodes whose cpu or memory gap percent is more than points above the median of the nodes with the same
+// roles, reservations are usually configured per pool so an outsized gap hints at a misconfigured node
+func flagGapOutliers(nodes []output.CapacityGapData, points float64) {
+	groups := make(map[string][]int)
+	for i, node := range nodes {
+		roles := strings.Join(node.Roles, ",")
+		groups[roles] = append(groups[roles], i)
+	}
+	for _, indexes := range groups {
+		if len(indexes) < 2 {
+			continue
+		}
+		cpuPercents := make([]float64, 0, len(indexes))
+		memoryPercents := make([]float64, 0, len(indexes))
+		for _, i := range indexes {
+			cpuPercents = append(cpuPercents, nodes[i].GapCPUPercent)
+			memoryPercents = append(memoryPercents, nodes[i].GapMemoryPercent)
+		}
+		cpuMedian, memoryMedian := median(cpuPercents), median(memoryPercents)
+		for _, i := range indexes {
+			reasons := make([]string, 0)
+			if nodes[i].GapCPUPercent > cpuMedian+points {
+				reasons = append(reasons, fmt.Sprintf("cpu gap %.1f%% is above the role median %.1f%%", nodes[i].GapCPUPercent, cpuMedian))
+			}
+			if nodes[i].GapMemoryPercent > memoryMedian+points {
+				reasons = append(reasons, fmt.Sprintf("memory gap %.1f%% is above the role median %.1f%%", nodes[i].GapMemoryPercent, memoryMedian))
+			}
+			nodes[i].Outlier = strings.Join(reasons, "; ")
+		}
+	}
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
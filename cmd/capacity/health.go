@@ -0,0 +1,99 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Intervals without a successful collection before a long running sub-command is no longer ready
+const readyIntervals = 3
+
+// Liveness and readiness of a long running sub-command, ready while its last successful collection of capacity data
+// is at most readyIntervals intervals old
+type health struct {
+	mutex       sync.Mutex
+	lastSuccess time.Time
+	maxAge      time.Duration
+}
+
+func newHealth(interval time.Duration) *health {
+	return &health{maxAge: readyIntervals * interval}
+}
+
+// Records a successful collection
+func (h *health) collected() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastSuccess = time.Now()
+}
+
+func (h *health) ready() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.lastSuccess.IsZero() {
+		return fmt.Errorf("capacity data has not been collected yet")
+	}
+	if age := time.Since(h.lastSuccess); age > h.maxAge {
+		return fmt.Errorf("capacity data was last collected %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// Adds /healthz, which succeeds while the process serves, and /readyz, which fails without a recent collection
+func (h *health) register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := h.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// Serves the health endpoints on the address of --health-address, they are off when it is empty
+func (h *health) serve(cmd *cobra.Command) error {
+	address, _ := cmd.Flags().GetString("health-address")
+	if address == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrap(err, "failed to serve health endpoints")
+	}
+	mux := http.NewServeMux()
+	h.register(mux)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logger.Error(err, "failed to serve health endpoints", "address", address)
+		}
+	}()
+	return nil
+}
+
+func addHealthFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("health-address", "", "", "Address to serve the /healthz and /readyz probe endpoints on over HTTP, for example :8081, disabled when empty")
+}
@@ -0,0 +1,44 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"io"
+
+	"github.com/akrzos/kubeSize/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+// Logger of errors and progress messages, replaced with the format and verbosity of the flags once they are parsed
+var logger *logging.Logger
+
+func newDefaultLogger(errOut io.Writer) *logging.Logger {
+	defaultLogger, _ := logging.New(errOut, logging.TextFormat, 0)
+	return defaultLogger
+}
+
+// Configures the logger from --log-format and -v and routes the client-go logs through it
+func configureLogging(cmd *cobra.Command) error {
+	logFormat, _ := cmd.Flags().GetString("log-format")
+	verbosity, _ := cmd.Flags().GetInt("v")
+	configuredLogger, err := logging.New(cmd.ErrOrStderr(), logFormat, verbosity)
+	if err != nil {
+		return err
+	}
+	logger = configuredLogger
+	logger.CaptureKlog()
+	return nil
+}
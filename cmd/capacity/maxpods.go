@@ -0,0 +1,90 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+var maxPodsCmd = &cobra.Command{
+	Use:   "max-pods",
+	Short: "Find nodes whose max-pods exceed the pod IPs of their network",
+	Long:  `Compare the pod capacity (kubelet max-pods) of each node against the addresses of its pod CIDR and, on AWS, the ENI limit of its instance type, since pods past the limit never get an IP`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		eniMaxPodsFlag, _ := cmd.Flags().GetStringSlice("eni-max-pods")
+		eniMaxPods, err := parseENIMaxPods(eniMaxPodsFlag)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		maxPodsData := kubesize.NetworkMaxPods(nodes, eniMaxPods)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayMaxPodsData(maxPodsData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(maxPodsCmd)
+	maxPodsCmd.RunE = watchRunE(maxPodsCmd.RunE)
+	maxPodsCmd.Flags().StringSliceP("eni-max-pods", "", []string{}, "Comma separated instance-type=pods ENI limits of the AWS VPC CNI overriding the built-in ones, such as with prefix delegation m5.large=110")
+}
+
+// Built-in ENI limits overridden by the instance-type=pods of --eni-max-pods
+func parseENIMaxPods(values []string) (map[string]int64, error) {
+	eniMaxPods := make(map[string]int64, len(kubesize.ENIMaxPods)+len(values))
+	for instanceType, pods := range kubesize.ENIMaxPods {
+		eniMaxPods[instanceType] = pods
+	}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("eni-max-pods \"%s\" is invalid, expected instance-type=pods", value)
+		}
+		pods, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || pods < 1 {
+			return nil, fmt.Errorf("eni-max-pods \"%s\" is invalid, pods must be a positive number", value)
+		}
+		eniMaxPods[parts[0]] = pods
+	}
+	return eniMaxPods, nil
+}
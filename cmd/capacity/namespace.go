@@ -17,17 +17,11 @@ package capacity
 
 import (
 	"fmt"
-	"os"
-	"sort"
 
-	"github.com/akrzos/kubeSize/internal/capacity"
-	"github.com/akrzos/kubeSize/internal/kube"
 	"github.com/akrzos/kubeSize/internal/output"
-	"github.com/pkg/errors"
+	"github.com/akrzos/kubeSize/internal/source"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
 	"github.com/spf13/cobra"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 )
 
 var namespaceCmd = &cobra.Command{
@@ -35,130 +29,114 @@ var namespaceCmd = &cobra.Command{
 	Aliases: []string{"ns"},
 	Short:   "Get namespace size",
 	Long:    `Get metrics related to the size of a namespace`,
-	PreRun: func(cmd *cobra.Command, args []string) {
-		if err := output.ValidateOutput(*cmd); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		clientset, err := kube.CreateClientSet(KubernetesConfigFlags)
-		if err != nil {
-			return errors.Wrap(err, "failed to create clientset")
-		}
-
-		nsFlag, _ := cmd.Flags().GetString("namespace")
-		nsListOptions := metav1.ListOptions{}
-		podListOptions := metav1.ListOptions{}
-
-		if nsFlag != "" {
-			nsFieldSelector, err := fields.ParseSelector("metadata.name=" + nsFlag)
-			if err != nil {
-				return errors.Wrap(err, "failed to create fieldSelector")
-			}
-			podNamespaceFieldSelector, err := fields.ParseSelector("metadata.namespace=" + nsFlag)
-			if err != nil {
-				return errors.Wrap(err, "failed to create fieldSelector")
-			}
-			nsListOptions = metav1.ListOptions{FieldSelector: nsFieldSelector.String()}
-			podListOptions = metav1.ListOptions{FieldSelector: podNamespaceFieldSelector.String()}
-		}
-
-		namespaces, err := clientset.CoreV1().Namespaces().List(nsListOptions)
+		displayOptions, err := getDisplayOptions(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to list namespaces")
+			return err
 		}
 
-		pods, err := clientset.CoreV1().Pods("").List(podListOptions)
+		capacitySource, err := getSource(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to list pods")
+			return err
 		}
 
-		namespaceCapacityData := make(map[string]*output.NamespaceCapacityData)
-		namespaceNames := make([]string, 0, len(namespaces.Items))
+		nsFlag, _ := cmd.Flags().GetString("namespace")
 
-		for _, namespace := range namespaces.Items {
-			namespaceNames = append(namespaceNames, namespace.Name)
-			namespaceCapacityData[namespace.Name] = new(output.NamespaceCapacityData)
+		hierarchy, _ := cmd.Flags().GetBool("hierarchy")
+		if hierarchy {
+			return displayNamespaceHierarchy(cmd, capacitySource, nsFlag, displayOptions)
 		}
 
-		for _, pod := range pods.Items {
-			if !capacity.StringInSlice(pod.Namespace, namespaceNames) {
-				namespaceNames = append(namespaceNames, pod.Namespace)
-				namespaceCapacityData[pod.Namespace] = new(output.NamespaceCapacityData)
-			}
-			if pod.Spec.NodeName == "" {
-				namespaceCapacityData[pod.Namespace].TotalUnassignedNodePodCount++
-			}
-			namespaceCapacityData[pod.Namespace].TotalPodCount++
-			if (pod.Status.Phase != corev1.PodSucceeded) && (pod.Status.Phase != corev1.PodFailed) {
-				namespaceCapacityData[pod.Namespace].TotalNonTermPodCount++
-				for _, container := range pod.Spec.Containers {
-					namespaceCapacityData[pod.Namespace].TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
-					namespaceCapacityData[pod.Namespace].TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
-					namespaceCapacityData[pod.Namespace].TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
-					namespaceCapacityData[pod.Namespace].TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
-					namespaceCapacityData[pod.Namespace].TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
-					namespaceCapacityData[pod.Namespace].TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
-				}
-			}
+		namespaces, err := capacitySource.Namespaces(nsFlag)
+		if err != nil {
+			return err
 		}
 
-		namespaceCapacityData["*total*"] = new(output.NamespaceCapacityData)
-
-		// Populate "Human" readable capacity data values and the *total* "namespace"
-		for _, namespace := range namespaceNames {
-			namespaceCapacityData[namespace].TotalRequestsCPUCores = capacity.ReadableCPU(namespaceCapacityData[namespace].TotalRequestsCPU)
-			namespaceCapacityData[namespace].TotalLimitsCPUCores = capacity.ReadableCPU(namespaceCapacityData[namespace].TotalLimitsCPU)
-			namespaceCapacityData[namespace].TotalRequestsMemoryGiB = capacity.ReadableMem(namespaceCapacityData[namespace].TotalRequestsMemory)
-			namespaceCapacityData[namespace].TotalLimitsMemoryGiB = capacity.ReadableMem(namespaceCapacityData[namespace].TotalLimitsMemory)
-			namespaceCapacityData[namespace].TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(namespaceCapacityData[namespace].TotalRequestsEphemeralStorage)
-			namespaceCapacityData[namespace].TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(namespaceCapacityData[namespace].TotalLimitsEphemeralStorage)
-			namespaceCapacityData["*total*"].TotalPodCount += namespaceCapacityData[namespace].TotalPodCount
-			namespaceCapacityData["*total*"].TotalNonTermPodCount += namespaceCapacityData[namespace].TotalNonTermPodCount
-			namespaceCapacityData["*total*"].TotalUnassignedNodePodCount += namespaceCapacityData[namespace].TotalUnassignedNodePodCount
-			namespaceCapacityData["*total*"].TotalRequestsCPU.Add(namespaceCapacityData[namespace].TotalRequestsCPU)
-			namespaceCapacityData["*total*"].TotalRequestsCPUCores += namespaceCapacityData[namespace].TotalRequestsCPUCores
-			namespaceCapacityData["*total*"].TotalLimitsCPU.Add(namespaceCapacityData[namespace].TotalLimitsCPU)
-			namespaceCapacityData["*total*"].TotalLimitsCPUCores += namespaceCapacityData[namespace].TotalLimitsCPUCores
-			namespaceCapacityData["*total*"].TotalRequestsMemory.Add(namespaceCapacityData[namespace].TotalRequestsMemory)
-			namespaceCapacityData["*total*"].TotalRequestsMemoryGiB += namespaceCapacityData[namespace].TotalRequestsMemoryGiB
-			namespaceCapacityData["*total*"].TotalLimitsMemory.Add(namespaceCapacityData[namespace].TotalLimitsMemory)
-			namespaceCapacityData["*total*"].TotalLimitsMemoryGiB += namespaceCapacityData[namespace].TotalLimitsMemoryGiB
-			namespaceCapacityData["*total*"].TotalRequestsEphemeralStorage.Add(namespaceCapacityData[namespace].TotalRequestsEphemeralStorage)
-			namespaceCapacityData["*total*"].TotalRequestsEphemeralStorageGB += namespaceCapacityData[namespace].TotalRequestsEphemeralStorageGB
-			namespaceCapacityData["*total*"].TotalLimitsEphemeralStorage.Add(namespaceCapacityData[namespace].TotalLimitsEphemeralStorage)
-			namespaceCapacityData["*total*"].TotalLimitsEphemeralStorageGB += namespaceCapacityData[namespace].TotalLimitsEphemeralStorageGB
+		pods, err := capacitySource.Pods(nsFlag)
+		if err != nil {
+			return err
 		}
 
-		sort.Strings(namespaceNames)
-
-		displayDefault, _ := cmd.Flags().GetBool("default-format")
-
-		displayEphemeralStorage, _ := cmd.Flags().GetBool("ephemeral-storage")
-
-		displayNoHeaders, _ := cmd.Flags().GetBool("no-headers")
-
-		displayFormat, _ := cmd.Flags().GetString("output")
-
-		displayAllNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
-
 		displayTotal, _ := cmd.Flags().GetBool("display-total")
 
-		if displayTotal {
-			namespaceNames = append(namespaceNames, "*total*")
+		namespaceCapacityData, namespaceNames := kubesize.NamespaceCapacity(namespaces, pods, displayTotal, newPodFilter(displayOptions))
+
+		groupByLabel, _ := cmd.Flags().GetString("group-by-label")
+		if groupByLabel != "" {
+			groupCapacityData, groupNames := kubesize.NamespaceLabelCapacity(namespaces, namespaceCapacityData, groupByLabel, displayTotal)
+			return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+				return output.DisplayNamespaceLabelData(groupCapacityData, groupNames, groupByLabel, displayOptions)
+			})
 		}
 
-		output.DisplayNamespaceData(namespaceCapacityData, namespaceNames, displayDefault, !displayNoHeaders, displayEphemeralStorage, displayFormat, displayAllNamespaces)
+		displayAllNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
 
-		return nil
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayNamespaceData(namespaceCapacityData, namespaceNames, displayOptions, displayAllNamespaces)
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(namespaceCmd)
+	namespaceCmd.RunE = watchRunE(namespaceCmd.RunE)
 	namespaceCmd.Flags().BoolP("all-namespaces", "A", false, "Include 0 pod namespaces in table output")
 	namespaceCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
 	namespaceCmd.Flags().BoolP("display-total", "t", false, "Display sum of all namespace capacity data in table output")
+	namespaceCmd.Flags().BoolP("hierarchy", "", false, "Roll up the namespaces of Hierarchical Namespace Controller (HNC) trees, each namespace shows the totals of its subtree")
+	namespaceCmd.Flags().StringP("group-by-label", "", "", "Roll up the namespaces by the value of this namespace label, such as team or tenant")
+}
+
+// Displays the subtree totals of the HNC namespace trees, of the tree below the --namespace namespace when set. The
+// whole cluster is listed since the descendants of a namespace are in other namespaces.
+func displayNamespaceHierarchy(cmd *cobra.Command, capacitySource source.CapacitySource, root string, displayOptions output.DisplayOptions) error {
+	namespaces, err := capacitySource.Namespaces("")
+	if err != nil {
+		return err
+	}
+	if !kubesize.HasNamespaceHierarchy(namespaces) {
+		return fmt.Errorf("no namespace hierarchy found, namespaces have no HNC tree labels")
+	}
+
+	pods, err := capacitySource.Pods("")
+	if err != nil {
+		return err
+	}
+
+	resourceQuotas, err := capacitySource.ResourceQuotas("")
+	if err != nil {
+		return err
+	}
+
+	namespaceCapacityData, _ := kubesize.NamespaceCapacity(namespaces, pods, false, newPodFilter(displayOptions))
+	treeData := kubesize.NamespaceHierarchyCapacity(namespaces, namespaceCapacityData, resourceQuotas)
+	if root != "" {
+		treeData = namespaceSubtree(treeData, root)
+		if len(treeData) == 0 {
+			return fmt.Errorf("namespace \"%s\" not found", root)
+		}
+	}
+
+	return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+		return output.DisplayNamespaceTreeData(treeData, displayOptions)
+	})
+}
+
+// The namespace and its descendants, which follow it in tree order until the next namespace at its depth or above
+func namespaceSubtree(treeData []output.NamespaceTreeData, root string) []output.NamespaceTreeData {
+	for i, namespace := range treeData {
+		if namespace.Namespace != root {
+			continue
+		}
+		end := i + 1
+		for end < len(treeData) && treeData[end].Depth > namespace.Depth {
+			end++
+		}
+		return treeData[i:end]
+	}
+	return nil
 }
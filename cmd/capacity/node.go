@@ -17,18 +17,16 @@ package capacity
 
 import (
 	"fmt"
-	"os"
-	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/akrzos/kubeSize/internal/capacity"
 	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/metrics"
 	"github.com/akrzos/kubeSize/internal/output"
-	"github.com/pkg/errors"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 var nodeCmd = &cobra.Command{
@@ -36,193 +34,201 @@ var nodeCmd = &cobra.Command{
 	Aliases: []string{"no"},
 	Short:   "Get individual node capacity",
 	Long:    `Get metrics and data related to node capacity`,
-	PreRun: func(cmd *cobra.Command, args []string) {
-		if err := output.ValidateOutput(*cmd); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		clientset, err := kube.CreateClientSet(KubernetesConfigFlags)
+		displayOptions, err := getDisplayOptions(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to create clientset")
+			return err
 		}
 
-		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		capacitySource, err := getSource(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to list nodes")
+			return err
 		}
 
-		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		nodes, err := capacitySource.Nodes()
 		if err != nil {
-			return errors.Wrap(err, "failed to list pods")
+			return err
 		}
 
-		nodesCapacityData := make(map[string]*output.NodeCapacityData)
-		nodeNames := make([]string, 0, len(nodes.Items))
-		nodesByRole := make(map[string][]string)
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
 
-		for _, node := range nodes.Items {
-			nodeNames = append(nodeNames, node.Name)
-			nodesCapacityData[node.Name] = new(output.NodeCapacityData)
-
-			roles := sets.NewString()
-			for labelKey, labelValue := range node.Labels {
-				switch {
-				case strings.HasPrefix(labelKey, "node-role.kubernetes.io/"):
-					if role := strings.TrimPrefix(labelKey, "node-role.kubernetes.io/"); len(role) > 0 {
-						roles.Insert(role)
-					}
-				case labelKey == "kubernetes.io/role" && labelValue != "":
-					roles.Insert(labelValue)
-				}
-			}
-			if len(roles) == 0 {
-				roles.Insert("<none>")
-			}
+		displayUnassigned, _ := cmd.Flags().GetBool("unassigned")
 
-			nodesCapacityData[node.Name].Ready = false
-			for _, condition := range node.Status.Conditions {
-				if (condition.Type == "Ready") && condition.Status == corev1.ConditionTrue {
-					nodesCapacityData[node.Name].Ready = true
-					break
-				}
-			}
+		displayTotal, _ := cmd.Flags().GetBool("display-total")
 
-			nodesCapacityData[node.Name].Schedulable = !node.Spec.Unschedulable
-			nodesCapacityData[node.Name].Roles = roles
-			nodesCapacityData[node.Name].TotalCapacityPods.Add(*node.Status.Capacity.Pods())
-			nodesCapacityData[node.Name].TotalCapacityCPU.Add(*node.Status.Capacity.Cpu())
-			nodesCapacityData[node.Name].TotalCapacityMemory.Add(*node.Status.Capacity.Memory())
-			nodesCapacityData[node.Name].TotalCapacityEphemeralStorage.Add(*node.Status.Capacity.StorageEphemeral())
-			nodesCapacityData[node.Name].TotalAllocatablePods.Add(*node.Status.Allocatable.Pods())
-			nodesCapacityData[node.Name].TotalAllocatableCPU.Add(*node.Status.Allocatable.Cpu())
-			nodesCapacityData[node.Name].TotalAllocatableMemory.Add(*node.Status.Allocatable.Memory())
-			nodesCapacityData[node.Name].TotalAllocatableEphemeralStorage.Add(*node.Status.Allocatable.StorageEphemeral())
-			rolesIndex := strings.Join(roles.List(), ",")
-			nodesByRole[rolesIndex] = append(nodesByRole[rolesIndex], node.Name)
-		}
-		nodesCapacityData["*unassigned*"] = new(output.NodeCapacityData)
-		nodesCapacityData["*total*"] = new(output.NodeCapacityData)
-
-		for _, pod := range pods.Items {
-			podNode := pod.Spec.NodeName
-			if pod.Spec.NodeName == "" {
-				podNode = "*unassigned*"
-			}
-			nodesCapacityData[podNode].TotalPodCount++
-
-			if (pod.Status.Phase != corev1.PodSucceeded) && (pod.Status.Phase != corev1.PodFailed) {
-				nodesCapacityData[podNode].TotalNonTermPodCount++
-				for _, container := range pod.Spec.Containers {
-					nodesCapacityData[podNode].TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
-					nodesCapacityData[podNode].TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
-					nodesCapacityData[podNode].TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
-					nodesCapacityData[podNode].TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
-					nodesCapacityData[podNode].TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
-					nodesCapacityData[podNode].TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
-				}
-			}
+		displayAverage, _ := cmd.Flags().GetBool("display-average")
+
+		nodesCapacityData, nodeNames, nodesByRole := kubesize.NodeCapacity(nodes, pods, displayUnassigned, displayTotal, displayAverage, newPodFilter(displayOptions))
+		thresholdBreaches = nodeBreaches(displayOptions, nodesCapacityData, nodeNames)
+		collectedMetrics = metrics.NodeMetrics(nodesCapacityData, nodeNames, nil)
+
+		displayPods, _ := cmd.Flags().GetBool("show-pods")
+		if displayPods {
+			kubesize.AddNodePods(nodesCapacityData, pods, newPodFilter(displayOptions))
 		}
 
-		for _, node := range nodeNames {
-			nodesCapacityData[node].TotalAvailablePods = int(nodesCapacityData[node].TotalAllocatablePods.Value()) - nodesCapacityData[node].TotalNonTermPodCount
-			nodesCapacityData[node].TotalAvailableCPU = nodesCapacityData[node].TotalAllocatableCPU
-			nodesCapacityData[node].TotalAvailableCPU.Sub(nodesCapacityData[node].TotalRequestsCPU)
-			nodesCapacityData[node].TotalAvailableMemory = nodesCapacityData[node].TotalAllocatableMemory
-			nodesCapacityData[node].TotalAvailableMemory.Sub(nodesCapacityData[node].TotalRequestsMemory)
-			nodesCapacityData[node].TotalAvailableEphemeralStorage = nodesCapacityData[node].TotalAllocatableEphemeralStorage
-			nodesCapacityData[node].TotalAvailableEphemeralStorage.Sub(nodesCapacityData[node].TotalRequestsEphemeralStorage)
-		}
-
-		displayDefault, _ := cmd.Flags().GetBool("default-format")
-
-		displayEphemeralStorage, _ := cmd.Flags().GetBool("ephemeral-storage")
-
-		displayNoHeaders, _ := cmd.Flags().GetBool("no-headers")
-
-		displayFormat, _ := cmd.Flags().GetString("output")
-
-		sort.Strings(nodeNames)
-		if displayUnassigned, _ := cmd.Flags().GetBool("unassigned"); displayUnassigned {
-			nodeNames = append(nodeNames, "*unassigned*")
-			nodesByRole["~"] = append(nodesByRole["~"], "*unassigned*")
-		}
-
-		// Populate "Human" readable capacity data values and the *total* "node"
-		for _, node := range nodeNames {
-			nodesCapacityData[node].TotalCapacityCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalCapacityCPU)
-			nodesCapacityData[node].TotalCapacityMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalCapacityMemory)
-			nodesCapacityData[node].TotalCapacityEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalCapacityEphemeralStorage)
-			nodesCapacityData[node].TotalAllocatableCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalAllocatableCPU)
-			nodesCapacityData[node].TotalAllocatableMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalAllocatableMemory)
-			nodesCapacityData[node].TotalAllocatableEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalAllocatableEphemeralStorage)
-			nodesCapacityData[node].TotalRequestsCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalRequestsCPU)
-			nodesCapacityData[node].TotalLimitsCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalLimitsCPU)
-			nodesCapacityData[node].TotalAvailableCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalAvailableCPU)
-			nodesCapacityData[node].TotalRequestsMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalRequestsMemory)
-			nodesCapacityData[node].TotalLimitsMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalLimitsMemory)
-			nodesCapacityData[node].TotalAvailableMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalAvailableMemory)
-			nodesCapacityData[node].TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalRequestsEphemeralStorage)
-			nodesCapacityData[node].TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalLimitsEphemeralStorage)
-			nodesCapacityData[node].TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalAvailableEphemeralStorage)
-			nodesCapacityData["*total*"].TotalPodCount += nodesCapacityData[node].TotalPodCount
-			nodesCapacityData["*total*"].TotalNonTermPodCount += nodesCapacityData[node].TotalNonTermPodCount
-			nodesCapacityData["*total*"].TotalCapacityPods.Add(nodesCapacityData[node].TotalCapacityPods)
-			nodesCapacityData["*total*"].TotalCapacityCPU.Add(nodesCapacityData[node].TotalCapacityCPU)
-			nodesCapacityData["*total*"].TotalCapacityCPUCores += nodesCapacityData[node].TotalCapacityCPUCores
-			nodesCapacityData["*total*"].TotalCapacityMemory.Add(nodesCapacityData[node].TotalCapacityMemory)
-			nodesCapacityData["*total*"].TotalCapacityMemoryGiB += nodesCapacityData[node].TotalCapacityMemoryGiB
-			nodesCapacityData["*total*"].TotalCapacityEphemeralStorage.Add(nodesCapacityData[node].TotalCapacityEphemeralStorage)
-			nodesCapacityData["*total*"].TotalCapacityEphemeralStorageGB += nodesCapacityData[node].TotalCapacityEphemeralStorageGB
-			nodesCapacityData["*total*"].TotalAllocatablePods.Add(nodesCapacityData[node].TotalAllocatablePods)
-			nodesCapacityData["*total*"].TotalAllocatableCPU.Add(nodesCapacityData[node].TotalAllocatableCPU)
-			nodesCapacityData["*total*"].TotalAllocatableCPUCores += nodesCapacityData[node].TotalAllocatableCPUCores
-			nodesCapacityData["*total*"].TotalAllocatableMemory.Add(nodesCapacityData[node].TotalAllocatableMemory)
-			nodesCapacityData["*total*"].TotalAllocatableMemoryGiB += nodesCapacityData[node].TotalAllocatableMemoryGiB
-			nodesCapacityData["*total*"].TotalAllocatableEphemeralStorage.Add(nodesCapacityData[node].TotalAllocatableEphemeralStorage)
-			nodesCapacityData["*total*"].TotalAllocatableEphemeralStorageGB += nodesCapacityData[node].TotalAllocatableEphemeralStorageGB
-			nodesCapacityData["*total*"].TotalAvailablePods += nodesCapacityData[node].TotalAvailablePods
-			nodesCapacityData["*total*"].TotalRequestsCPU.Add(nodesCapacityData[node].TotalRequestsCPU)
-			nodesCapacityData["*total*"].TotalRequestsCPUCores += nodesCapacityData[node].TotalRequestsCPUCores
-			nodesCapacityData["*total*"].TotalLimitsCPU.Add(nodesCapacityData[node].TotalLimitsCPU)
-			nodesCapacityData["*total*"].TotalLimitsCPUCores += nodesCapacityData[node].TotalLimitsCPUCores
-			nodesCapacityData["*total*"].TotalAvailableCPU.Add(nodesCapacityData[node].TotalAvailableCPU)
-			nodesCapacityData["*total*"].TotalAvailableCPUCores += nodesCapacityData[node].TotalAvailableCPUCores
-			nodesCapacityData["*total*"].TotalRequestsMemory.Add(nodesCapacityData[node].TotalRequestsMemory)
-			nodesCapacityData["*total*"].TotalRequestsMemoryGiB += nodesCapacityData[node].TotalRequestsMemoryGiB
-			nodesCapacityData["*total*"].TotalLimitsMemory.Add(nodesCapacityData[node].TotalLimitsMemory)
-			nodesCapacityData["*total*"].TotalLimitsMemoryGiB += nodesCapacityData[node].TotalLimitsMemoryGiB
-			nodesCapacityData["*total*"].TotalAvailableMemory.Add(nodesCapacityData[node].TotalAvailableMemory)
-			nodesCapacityData["*total*"].TotalAvailableMemoryGiB += nodesCapacityData[node].TotalAvailableMemoryGiB
-			nodesCapacityData["*total*"].TotalRequestsEphemeralStorage.Add(nodesCapacityData[node].TotalRequestsEphemeralStorage)
-			nodesCapacityData["*total*"].TotalRequestsEphemeralStorageGB += nodesCapacityData[node].TotalRequestsEphemeralStorageGB
-			nodesCapacityData["*total*"].TotalLimitsEphemeralStorage.Add(nodesCapacityData[node].TotalLimitsEphemeralStorage)
-			nodesCapacityData["*total*"].TotalLimitsEphemeralStorageGB += nodesCapacityData[node].TotalLimitsEphemeralStorageGB
-			nodesCapacityData["*total*"].TotalAvailableEphemeralStorage.Add(nodesCapacityData[node].TotalAvailableEphemeralStorage)
-			nodesCapacityData["*total*"].TotalAvailableEphemeralStorageGB += nodesCapacityData[node].TotalAvailableEphemeralStorageGB
+		staleAfter, _ := cmd.Flags().GetDuration("stale-after")
+		for _, node := range nodes.Items {
+			lastHeartbeatTime := nodesCapacityData[node.Name].LastHeartbeatTime
+			nodesCapacityData[node.Name].HeartbeatStale = lastHeartbeatTime == nil || displayOptions.Timestamp.Sub(*lastHeartbeatTime) > staleAfter
 		}
 
-		sortByRole, _ := cmd.Flags().GetBool("sort-by-role")
+		if displayOptions.Volumes {
+			kubesize.AddAttachedVolumes(nodesCapacityData, nodes, csiAttachLimits(cmd))
+		}
 
-		displayTotal, _ := cmd.Flags().GetBool("display-total")
+		if displayOptions.Disk {
+			if err := addNodeDisk(cmd, nodesCapacityData, nodes); err != nil {
+				return err
+			}
+		}
+
+		if displayOptions.ShowLabels || len(displayOptions.LabelColumns) > 0 {
+			for _, node := range nodes.Items {
+				nodesCapacityData[node.Name].Labels = node.Labels
+			}
+		}
 
-		if displayTotal {
-			nodeNames = append(nodeNames, "*total*")
-			nodesByRole["~"] = append(nodesByRole["~"], "*total*")
+		if below, _ := cmd.Flags().GetString("below"); below != "" {
+			belowPercent, err := parsePercent(below)
+			if err != nil {
+				return fmt.Errorf("--below %v", err)
+			}
+			nodeNames = nodesBelow(nodesCapacityData, nodeNames, nodesByRole, belowPercent)
 		}
 
-		output.DisplayNodeData(nodesCapacityData, nodeNames, displayDefault, !displayNoHeaders, displayEphemeralStorage, displayFormat, sortByRole, nodesByRole)
+		sortByRole, _ := cmd.Flags().GetBool("sort-by-role")
 
-		return nil
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayNodeData(nodesCapacityData, nodeNames, displayOptions, sortByRole, nodesByRole)
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(nodeCmd)
+	nodeCmd.RunE = watchRunE(nodeCmd.RunE)
 	nodeCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
 	nodeCmd.Flags().BoolP("sort-by-role", "r", false, "Sort output by node-role")
 	nodeCmd.Flags().BoolP("display-total", "t", false, "Display sum of all node capacity data in table output")
 	nodeCmd.Flags().BoolP("unassigned", "u", false, "Include unassigned pod row, pods which do not have a node")
+	nodeCmd.Flags().BoolP("display-average", "a", false, "Display per node average of all node capacity data in table output")
+	nodeCmd.Flags().DurationP("stale-after", "", 10*time.Minute, "Flag nodes whose last Ready heartbeat is older than this duration as stale")
+	nodeCmd.Flags().BoolP("show-labels", "", false, "Include the labels of each node as the last column")
+	nodeCmd.Flags().StringSliceP("label-columns", "L", []string{}, "Comma separated node labels to display as columns")
+	nodeCmd.RegisterFlagCompletionFunc("label-columns", completeNodeLabels)
+	nodeCmd.Flags().BoolP("available-percent", "", false, "Include the percent of allocatable pods, cpu and memory not requested in table output")
+	nodeCmd.Flags().StringP("below", "", "", "Only display the nodes with less than this percent of allocatable pods, cpu or memory not requested, such as 15%")
+	nodeCmd.Flags().BoolP("volumes", "", false, "Include the persistent volumes attached to each node against its attach limit")
+	nodeCmd.Flags().BoolP("disk", "", false, "Include the capacity and usage of the node and image filesystems from the kubelet summary API")
+	nodeCmd.Flags().BoolP("show-pods", "p", false, "List the non-terminated pods of each node with their requests and limits")
+}
+
+// Parses a percent between 0 and 100 such as 15% or 15
+func parsePercent(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("%s is not between 0 and 100 percent", value)
+	}
+	return percent, nil
+}
+
+// Leaves out the nodes with at least the percent of allocatable pods, cpu and memory available, so only the hot nodes
+// remain. The *unassigned*, *total* and *average* rows are kept.
+func nodesBelow(nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, nodesByRole map[string][]string, percent float64) []string {
+	below := func(nodeName string) bool {
+		if strings.HasPrefix(nodeName, "*") {
+			return true
+		}
+		nodeData := nodesCapacityData[nodeName]
+		return nodeData.AvailablePodsPercent < percent || nodeData.AvailableCPUPercent < percent || nodeData.AvailableMemoryPercent < percent
+	}
+	keep := func(names []string) []string {
+		kept := make([]string, 0, len(names))
+		for _, name := range names {
+			if below(name) {
+				kept = append(kept, name)
+			}
+		}
+		return kept
+	}
+	for role, roleNodeNames := range nodesByRole {
+		if nodesByRole[role] = keep(roleNodeNames); len(nodesByRole[role]) == 0 {
+			delete(nodesByRole, role)
+		}
+	}
+	kept := keep(nodeNames)
+	for _, nodeName := range nodeNames {
+		if !below(nodeName) {
+			delete(nodesCapacityData, nodeName)
+		}
+	}
+	return kept
+}
+
+// Sets the node and image filesystems of each node from the summary API of its kubelet, summed in the *total* node.
+// Nodes whose summary can not be read are warned about and displayed without.
+func addNodeDisk(cmd *cobra.Command, nodesCapacityData map[string]*output.NodeCapacityData, nodes *corev1.NodeList) error {
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		return fmt.Errorf("--disk reads the kubelet summary API, --from is not supported")
+	}
+	clientset, err := createClientSet(cmd)
+	if err != nil {
+		return err
+	}
+	summaries := nodeSummaries(clientset, nodes, "their filesystems are missing")
+	total := new(output.NodeDiskData)
+	for _, node := range nodes.Items {
+		summary, ok := summaries[node.Name]
+		if !ok {
+			continue
+		}
+		disk := &output.NodeDiskData{NodeFs: fsData(summary.Node.Fs)}
+		if summary.Node.Runtime != nil {
+			disk.ImageFs = fsData(summary.Node.Runtime.ImageFs)
+		}
+		nodesCapacityData[node.Name].Disk = disk
+		addFsData(&total.NodeFs, disk.NodeFs)
+		addFsData(&total.ImageFs, disk.ImageFs)
+	}
+	if totalData, ok := nodesCapacityData["*total*"]; ok {
+		totalData.Disk = total
+	}
+	return nil
+}
+
+func fsData(fs *kube.FsStats) output.FsData {
+	var data output.FsData
+	if fs == nil {
+		return data
+	}
+	if fs.CapacityBytes != nil {
+		data.CapacityGB = float64(*fs.CapacityBytes) / 1000 / 1000 / 1000
+	}
+	if fs.UsedBytes != nil {
+		data.UsedGB = float64(*fs.UsedBytes) / 1000 / 1000 / 1000
+	}
+	if fs.AvailableBytes != nil {
+		data.AvailableGB = float64(*fs.AvailableBytes) / 1000 / 1000 / 1000
+	}
+	if data.CapacityGB > 0 {
+		data.UsedPercent = data.UsedGB / data.CapacityGB * 100
+	}
+	return data
+}
+
+func addFsData(total *output.FsData, data output.FsData) {
+	total.CapacityGB += data.CapacityGB
+	total.UsedGB += data.UsedGB
+	total.AvailableGB += data.AvailableGB
+	if total.CapacityGB > 0 {
+		total.UsedPercent = total.UsedGB / total.CapacityGB * 100
+	}
 }
@@ -16,18 +16,14 @@ limitations under the License.
 package capacity
 
 import (
-	"fmt"
-	"os"
-	"sort"
 	"strings"
 
-	"github.com/akrzos/kubeSize/internal/capacity"
-	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/metrics"
 	"github.com/akrzos/kubeSize/internal/output"
-	"github.com/pkg/errors"
+	"github.com/akrzos/kubeSize/internal/source"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -36,149 +32,107 @@ var nodeRoleCmd = &cobra.Command{
 	Aliases: []string{"nr"},
 	Short:   "Get cluster capacity data grouped by node role",
 	Long:    `Get metrics and data related to cluster capacity grouped by node role`,
-	PreRun: func(cmd *cobra.Command, args []string) {
-		if err := output.ValidateOutput(*cmd); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		clientset, err := kube.CreateClientSet(KubernetesConfigFlags)
+		displayOptions, err := getDisplayOptions(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to create clientset")
+			return err
 		}
 
-		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		capacitySource, err := getSource(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to list nodes")
+			return err
 		}
 
-		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		nodes, err := capacitySource.Nodes()
 		if err != nil {
-			return errors.Wrap(err, "failed to list pods")
+			return err
 		}
 
-		nodeRoleCapacityData := make(map[string]*output.ClusterCapacityData)
-		nodeRoles := make(map[string][]string)
-		roleNames := make([]string, 0)
-
-		for _, node := range nodes.Items {
-			roles := sets.NewString()
-			for labelKey, labelValue := range node.Labels {
-				switch {
-				case strings.HasPrefix(labelKey, "node-role.kubernetes.io/"):
-					if role := strings.TrimPrefix(labelKey, "node-role.kubernetes.io/"); len(role) > 0 {
-						roles.Insert(role)
-					}
-				case labelKey == "kubernetes.io/role" && labelValue != "":
-					roles.Insert(labelValue)
-				}
-			}
-			if len(roles) == 0 {
-				roles.Insert("<none>")
-			}
-			for role := range roles {
-				if !capacity.StringInSlice(role, roleNames) {
-					roleNames = append(roleNames, role)
-					nodeRoleCapacityData[role] = new(output.ClusterCapacityData)
-				}
-				nodeRoleCapacityData[role].TotalNodeCount++
-				for _, condition := range node.Status.Conditions {
-					if (condition.Type == "Ready") && condition.Status == corev1.ConditionTrue {
-						nodeRoleCapacityData[role].TotalReadyNodeCount++
-					}
-				}
-				if node.Spec.Unschedulable {
-					nodeRoleCapacityData[role].TotalUnschedulableNodeCount++
-				}
-				nodeRoleCapacityData[role].TotalCapacityPods.Add(*node.Status.Capacity.Pods())
-				nodeRoleCapacityData[role].TotalCapacityCPU.Add(*node.Status.Capacity.Cpu())
-				nodeRoleCapacityData[role].TotalCapacityMemory.Add(*node.Status.Capacity.Memory())
-				nodeRoleCapacityData[role].TotalCapacityEphemeralStorage.Add(*node.Status.Capacity.StorageEphemeral())
-				nodeRoleCapacityData[role].TotalAllocatablePods.Add(*node.Status.Allocatable.Pods())
-				nodeRoleCapacityData[role].TotalAllocatableCPU.Add(*node.Status.Allocatable.Cpu())
-				nodeRoleCapacityData[role].TotalAllocatableMemory.Add(*node.Status.Allocatable.Memory())
-				nodeRoleCapacityData[role].TotalAllocatableEphemeralStorage.Add(*node.Status.Allocatable.StorageEphemeral())
-			}
-			nodeRoles[node.Name] = roles.List()
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
 		}
 
-		nodeRoleCapacityData["*unassigned*"] = new(output.ClusterCapacityData)
-		nodeRoles["*unassigned*"] = []string{"*unassigned*"}
-
-		for _, pod := range pods.Items {
-			podNode := pod.Spec.NodeName
-			if pod.Spec.NodeName == "" {
-				podNode = "*unassigned*"
-			}
-			for _, role := range nodeRoles[podNode] {
-				nodeRoleCapacityData[role].TotalPodCount++
-				if (pod.Status.Phase != corev1.PodSucceeded) && (pod.Status.Phase != corev1.PodFailed) {
-					nodeRoleCapacityData[role].TotalNonTermPodCount++
-					for _, container := range pod.Spec.Containers {
-						nodeRoleCapacityData[role].TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
-						nodeRoleCapacityData[role].TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
-						nodeRoleCapacityData[role].TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
-						nodeRoleCapacityData[role].TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
-						nodeRoleCapacityData[role].TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
-						nodeRoleCapacityData[role].TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
-					}
-				}
+		roles, _ := cmd.Flags().GetStringSlice("roles")
+		var roleFilter *source.NameFilter
+		if len(roles) > 0 {
+			if roleFilter, err = source.NewRoleFilter(roles); err != nil {
+				return err
 			}
+			nodes, pods = selectRoleNodes(nodes, pods, roleFilter)
 		}
 
-		for _, role := range roleNames {
-			nodeRoleCapacityData[role].TotalUnreadyNodeCount = nodeRoleCapacityData[role].TotalNodeCount - nodeRoleCapacityData[role].TotalReadyNodeCount
-			nodeRoleCapacityData[role].TotalAvailablePods = int(nodeRoleCapacityData[role].TotalAllocatablePods.Value()) - nodeRoleCapacityData[role].TotalNonTermPodCount
-			nodeRoleCapacityData[role].TotalAvailableCPU = nodeRoleCapacityData[role].TotalAllocatableCPU
-			nodeRoleCapacityData[role].TotalAvailableCPU.Sub(nodeRoleCapacityData[role].TotalRequestsCPU)
-			nodeRoleCapacityData[role].TotalAvailableMemory = nodeRoleCapacityData[role].TotalAllocatableMemory
-			nodeRoleCapacityData[role].TotalAvailableMemory.Sub(nodeRoleCapacityData[role].TotalRequestsMemory)
-			nodeRoleCapacityData[role].TotalAvailableEphemeralStorage = nodeRoleCapacityData[role].TotalAllocatableEphemeralStorage
-			nodeRoleCapacityData[role].TotalAvailableEphemeralStorage.Sub(nodeRoleCapacityData[role].TotalRequestsEphemeralStorage)
-		}
-
-		displayDefault, _ := cmd.Flags().GetBool("default-format")
-
-		displayEphemeralStorage, _ := cmd.Flags().GetBool("ephemeral-storage")
+		displayUnassigned, _ := cmd.Flags().GetBool("unassigned")
 
-		displayNoHeaders, _ := cmd.Flags().GetBool("no-headers")
+		displayTotal, _ := cmd.Flags().GetBool("display-total")
 
-		displayFormat, _ := cmd.Flags().GetString("output")
+		displayAverage, _ := cmd.Flags().GetBool("display-average")
 
-		sort.Strings(roleNames)
-		if displayUnassigned, _ := cmd.Flags().GetBool("unassigned"); displayUnassigned {
-			roleNames = append(roleNames, "*unassigned*")
+		nodeRoleCapacityData, roleNames := kubesize.NodeRoleCapacity(nodes, pods, displayUnassigned, displayTotal, displayAverage, newPodFilter(displayOptions))
+		if roleFilter != nil {
+			roleNames = selectRoleNames(nodeRoleCapacityData, roleNames, roleFilter)
 		}
+		thresholdBreaches = nodeRoleBreaches(displayOptions, nodeRoleCapacityData, roleNames)
+		collectedMetrics = metrics.NodeRoleMetrics(nodeRoleCapacityData, roleNames, nil)
 
-		// Populate "Human" readable capacity data values
-		for _, role := range roleNames {
-			nodeRoleCapacityData[role].TotalCapacityCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalCapacityCPU)
-			nodeRoleCapacityData[role].TotalCapacityMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalCapacityMemory)
-			nodeRoleCapacityData[role].TotalCapacityEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalCapacityEphemeralStorage)
-			nodeRoleCapacityData[role].TotalAllocatableCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalAllocatableCPU)
-			nodeRoleCapacityData[role].TotalAllocatableMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalAllocatableMemory)
-			nodeRoleCapacityData[role].TotalAllocatableEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalAllocatableEphemeralStorage)
-			nodeRoleCapacityData[role].TotalRequestsCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalRequestsCPU)
-			nodeRoleCapacityData[role].TotalLimitsCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalLimitsCPU)
-			nodeRoleCapacityData[role].TotalAvailableCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalAvailableCPU)
-			nodeRoleCapacityData[role].TotalRequestsMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalRequestsMemory)
-			nodeRoleCapacityData[role].TotalLimitsMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalLimitsMemory)
-			nodeRoleCapacityData[role].TotalAvailableMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalAvailableMemory)
-			nodeRoleCapacityData[role].TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalRequestsEphemeralStorage)
-			nodeRoleCapacityData[role].TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalLimitsEphemeralStorage)
-			nodeRoleCapacityData[role].TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalAvailableEphemeralStorage)
-		}
+		displayMinMax, _ := cmd.Flags().GetBool("min-max")
 
-		output.DisplayNodeRoleData(nodeRoleCapacityData, roleNames, displayDefault, !displayNoHeaders, displayEphemeralStorage, displayFormat)
+		displayImbalance, _ := cmd.Flags().GetBool("imbalance")
 
-		return nil
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayNodeRoleData(nodeRoleCapacityData, roleNames, displayOptions, displayMinMax, displayImbalance)
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(nodeRoleCmd)
+	nodeRoleCmd.RunE = watchRunE(nodeRoleCmd.RunE)
 	nodeRoleCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
 	nodeRoleCmd.Flags().BoolP("unassigned", "u", false, "Include unassigned pod row, pods which do not have a node")
+	nodeRoleCmd.Flags().BoolP("display-total", "t", false, "Display sum of all node capacity data in table output")
+	nodeRoleCmd.Flags().BoolP("display-average", "a", false, "Display per node average of all node capacity data in table output")
+	nodeRoleCmd.Flags().BoolP("min-max", "m", false, "Include least and most loaded node by percent of allocatable cpu and memory requested in table output")
+	nodeRoleCmd.Flags().StringSliceP("roles", "", []string{}, "Only display the node roles matching these comma separated names, globs or regular expressions enclosed in slashes, such as worker,infra")
+	nodeRoleCmd.Flags().BoolP("imbalance", "i", false, "Include the spread (max - min) and standard deviation of percent of allocatable cpu and memory requested across the nodes of each role in table output")
+}
+
+// Keeps the nodes with a role selected by the filter and the pods bound to them or not yet scheduled, so the total
+// only covers the selected roles
+func selectRoleNodes(nodes *corev1.NodeList, pods *corev1.PodList, roleFilter *source.NameFilter) (*corev1.NodeList, *corev1.PodList) {
+	selectedNodes := &corev1.NodeList{ListMeta: nodes.ListMeta}
+	nodeNames := sets.NewString()
+	for _, node := range nodes.Items {
+		for _, role := range kubesize.NodeRoles(node).List() {
+			if roleFilter.Matches(role) {
+				selectedNodes.Items = append(selectedNodes.Items, node)
+				nodeNames.Insert(node.Name)
+				break
+			}
+		}
+	}
+	selectedPods := &corev1.PodList{ListMeta: pods.ListMeta}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || nodeNames.Has(pod.Spec.NodeName) {
+			selectedPods.Items = append(selectedPods.Items, pod)
+		}
+	}
+	return selectedNodes, selectedPods
+}
+
+// Drops the other roles of nodes with several roles, the *total*, *average* and *unassigned* rows are kept
+func selectRoleNames(nodeRoleCapacityData map[string]*output.ClusterCapacityData, roleNames []string, roleFilter *source.NameFilter) []string {
+	selected := make([]string, 0, len(roleNames))
+	for _, role := range roleNames {
+		if strings.HasPrefix(role, "*") || roleFilter.Matches(role) {
+			selected = append(selected, role)
+		} else {
+			delete(nodeRoleCapacityData, role)
+		}
+	}
+	return selected
 }
@@ -0,0 +1,132 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var numaCmd = &cobra.Command{
+	Use:   "numa",
+	Short: "Report memory and hugepages available per NUMA zone of each node",
+	Long:  `Report the memory and hugepages allocatable and available in each NUMA zone of each node from the NodeResourceTopology objects of the topology updater, since with the Memory Manager or a single-numa-node Topology Manager policy the node totals can claim room that no single NUMA zone can satisfy`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("topology list")
+		nodeTopologies, err := kube.GetNodeTopologies(clientset)
+		stopTiming()
+		if err != nil {
+			return err
+		}
+
+		numaData := getNUMAData(nodeTopologies)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayNUMAData(numaData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(numaCmd)
+	numaCmd.RunE = watchRunE(numaCmd.RunE)
+}
+
+// Reports the memory and each hugepage size of the NUMA zones of each node, sorted by node with memory first. On nodes
+// whose topology manager aligns resources to one NUMA zone, the amount available beyond the largest zone is flagged
+// since no container can get it.
+func getNUMAData(nodeTopologies map[string]kube.NodeTopology) output.NUMAData {
+	numaData := output.NUMAData{Resources: make([]output.NodeNUMAData, 0)}
+	nodeNames := make([]string, 0, len(nodeTopologies))
+	for nodeName := range nodeTopologies {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		nodeTopology := nodeTopologies[nodeName]
+		resourceNames := []string{string(corev1.ResourceMemory)}
+		hugepages := make(map[string]bool)
+		for _, zone := range nodeTopology.Zones {
+			for resourceName := range zone.Resources {
+				if strings.HasPrefix(resourceName, corev1.ResourceHugePagesPrefix) && !hugepages[resourceName] {
+					hugepages[resourceName] = true
+					resourceNames = append(resourceNames, resourceName)
+				}
+			}
+		}
+		sort.Strings(resourceNames[1:])
+		for _, resourceName := range resourceNames {
+			row := output.NodeNUMAData{
+				Node:              nodeName,
+				Policy:            nodeTopology.Policy,
+				Resource:          resourceName,
+				ZonesAvailableGiB: make(map[string]float64, len(nodeTopology.Zones)),
+				Reasons:           make([]string, 0),
+			}
+			var allocatable, available, largest resource.Quantity
+			for _, zone := range nodeTopology.Zones {
+				zoneResource, ok := zone.Resources[resourceName]
+				if !ok {
+					continue
+				}
+				allocatable.Add(zoneResource.Allocatable)
+				available.Add(zoneResource.Available)
+				row.ZonesAvailableGiB[zone.Name] = capacity.ReadableMem(zoneResource.Available)
+				if row.LargestZone == "" || zoneResource.Available.Cmp(largest) > 0 {
+					row.LargestZone = zone.Name
+					largest = zoneResource.Available
+				}
+			}
+			row.AllocatableGiB = capacity.ReadableMem(allocatable)
+			row.AvailableGiB = capacity.ReadableMem(available)
+			row.LargestZoneAvailableGiB = capacity.ReadableMem(largest)
+			if alignsNUMA(nodeTopology.Policy) && available.Cmp(largest) > 0 {
+				row.Reasons = append(row.Reasons, fmt.Sprintf("only %.1f of the %.1f GiB available fits one NUMA zone", row.LargestZoneAvailableGiB, row.AvailableGiB))
+				numaData.Fragmented++
+			}
+			numaData.Resources = append(numaData.Resources, row)
+		}
+	}
+	return numaData
+}
+
+// Whether a topology manager policy admits only pods whose resources fit one NUMA zone, the v1alpha1 policies such as
+// SingleNUMANodeContainerLevel include the scope
+func alignsNUMA(policy string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.Replace(policy, "-", "", -1)), "singlenumanode")
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var pidsCmd = &cobra.Command{
+	Use:   "pids",
+	Short: "Report the process ids used of each node against its pid limit",
+	Long:  `Report the processes running on each node against its pid limit from the kubelet summary API, and the pod pid limit of the kubelet, flagging nodes near pid exhaustion which cpu and memory usage never predict`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("node list")
+		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list nodes")
+		}
+
+		stopTiming = startStage("pod list")
+		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list pods")
+		}
+
+		// Nodes without a summary are left out, those without a kubelet configuration are shown without a pod pid limit
+		summaries := nodeSummaries(clientset, nodes, "they are left out")
+		stopTiming = startStage("kubelet config fetch")
+		kubeletConfigs := make(map[string]*kube.KubeletConfig, len(summaries))
+		for nodeName := range summaries {
+			if kubeletConfig, err := kube.GetKubeletConfig(clientset, nodeName); err == nil {
+				kubeletConfigs[nodeName] = kubeletConfig
+			}
+		}
+		stopTiming()
+
+		pidData := getPIDData(nodes, pods, summaries, kubeletConfigs, newPodFilter(displayOptions), displayOptions)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayPIDData(pidData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pidsCmd)
+	pidsCmd.RunE = watchRunE(pidsCmd.RunE)
+}
+
+// Compares the processes of each node with a summary against its pid limit, sorted by node name. Nodes whose pods could
+// together reach the pid limit of the node at the pod pid limit are flagged, the pod limit does not protect them.
+func getPIDData(nodes *corev1.NodeList, pods *corev1.PodList, summaries map[string]*kube.Summary, kubeletConfigs map[string]*kube.KubeletConfig, filter kubesize.PodFilter, displayOptions output.DisplayOptions) output.PIDData {
+	podCounts := make(map[string]int)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" && filter.HoldsResources(pod) {
+			podCounts[pod.Spec.NodeName]++
+		}
+	}
+	pidData := output.PIDData{Nodes: make([]output.NodePIDData, 0, len(summaries))}
+	for _, node := range nodes.Items {
+		summary, ok := summaries[node.Name]
+		if !ok || summary.Node.Rlimit == nil || summary.Node.Rlimit.MaxPID == nil {
+			continue
+		}
+		nodePIDs := output.NodePIDData{
+			Node:    node.Name,
+			Roles:   kubesize.NodeRoles(node).List(),
+			MaxPIDs: *summary.Node.Rlimit.MaxPID,
+			Pods:    podCounts[node.Name],
+			Reasons: make([]string, 0),
+		}
+		if summary.Node.Rlimit.CurProc != nil {
+			nodePIDs.Processes = *summary.Node.Rlimit.CurProc
+		}
+		if kubeletConfig, ok := kubeletConfigs[node.Name]; ok && kubeletConfig.PodPidsLimit != nil && *kubeletConfig.PodPidsLimit > 0 {
+			nodePIDs.PodPidsLimit = *kubeletConfig.PodPidsLimit
+		}
+		if nodePIDs.MaxPIDs > 0 {
+			nodePIDs.UsedPercent = round(float64(nodePIDs.Processes) / float64(nodePIDs.MaxPIDs) * 100)
+		}
+		switch {
+		case nodePIDs.UsedPercent > displayOptions.CritThreshold:
+			nodePIDs.Status = "critical"
+		case nodePIDs.UsedPercent > displayOptions.WarnThreshold:
+			nodePIDs.Status = "near"
+		default:
+			nodePIDs.Status = "ok"
+		}
+		if nodePIDs.Status != "ok" {
+			pidData.NearNodes++
+			nodePIDs.Reasons = append(nodePIDs.Reasons, fmt.Sprintf("processes are %.1f%% of the pid limit", nodePIDs.UsedPercent))
+		}
+		if podPIDs := int64(nodePIDs.Pods) * nodePIDs.PodPidsLimit; podPIDs > nodePIDs.MaxPIDs {
+			nodePIDs.Reasons = append(nodePIDs.Reasons, fmt.Sprintf("%d pods at the pod pid limit would use %d pids, more than the node has", nodePIDs.Pods, podPIDs))
+		}
+		pidData.Nodes = append(pidData.Nodes, nodePIDs)
+	}
+	sort.Slice(pidData.Nodes, func(i, j int) bool { return pidData.Nodes[i].Node < pidData.Nodes[j].Node })
+	return pidData
+}
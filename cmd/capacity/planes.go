@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+var planesCmd = &cobra.Command{
+	Use:     "planes",
+	Aliases: []string{"pl"},
+	Short:   "Get cluster capacity data of the control plane and the workers",
+	Long:    `Get metrics and data related to cluster capacity split into control-plane nodes and all other nodes, without relying on exact role names`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		displayUnassigned, _ := cmd.Flags().GetBool("unassigned")
+
+		displayTotal, _ := cmd.Flags().GetBool("display-total")
+
+		planeCapacityData, planeNames := kubesize.PlaneCapacity(nodes, pods, displayUnassigned, displayTotal, newPodFilter(displayOptions))
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayNodeRoleData(planeCapacityData, planeNames, displayOptions, false, false)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planesCmd)
+	planesCmd.RunE = watchRunE(planesCmd.RunE)
+	planesCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
+	planesCmd.Flags().BoolP("unassigned", "u", false, "Include unassigned pod row, pods which do not have a node")
+	planesCmd.Flags().BoolP("display-total", "t", false, "Display sum of all node capacity data in table output")
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+var podIPsCmd = &cobra.Command{
+	Use:   "pod-ips",
+	Short: "Report pod IPs available per IP family of each node and cluster-wide",
+	Long:  `Report the pod IPs used and available in the pod CIDRs of each node, separately for IPv4 and IPv6 and summed per family, since on dual-stack clusters one family can exhaust while the other looks fine`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("node list")
+		nodePodCIDRs, err := kube.GetNodePodCIDRs(clientset)
+		stopTiming()
+		if err != nil {
+			return err
+		}
+
+		stopTiming = startStage("pod list")
+		nodePodIPs, err := kube.GetNodePodIPs(clientset)
+		stopTiming()
+		if err != nil {
+			return err
+		}
+
+		podIPsData := kubesize.PodIPCapacity(nodePodCIDRs, nodePodIPs, displayOptions.WarnThreshold)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayPodIPsData(podIPsData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(podIPsCmd)
+	podIPsCmd.RunE = watchRunE(podIPsCmd.RunE)
+}
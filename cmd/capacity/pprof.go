@@ -0,0 +1,55 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Serves the net/http/pprof endpoints on the address of --pprof-address, so memory and CPU profiles can be captured
+// from production deployments of the long running sub-commands. Profiles expose internals of the process, so the
+// endpoints are off by default.
+func servePprof(cmd *cobra.Command) error {
+	address, _ := cmd.Flags().GetString("pprof-address")
+	if address == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Wrap(err, "failed to serve pprof endpoints")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logger.Error(err, "failed to serve pprof endpoints", "address", address)
+		}
+	}()
+	return nil
+}
+
+func addPprofFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("pprof-address", "", "", "Address to serve the net/http/pprof profiling endpoints on, for example localhost:6060, disabled when empty")
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var preemptionCmd = &cobra.Command{
+	Use:   "preemption",
+	Short: "Show the capacity preempting lower priority pods would free",
+	Long:  `Show for a pod priority how many pods, cpu and memory the scheduler could free by preempting pods of lower priority on each ready, schedulable node and node role, and the available capacity a surge of pods at that priority would have. Without a priority the capacity available at or above each priority tier of the pods is shown.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		priority, _ := cmd.Flags().GetInt32("priority")
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		filter := newPodFilter(displayOptions)
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, filter)
+
+		if !cmd.Flags().Changed("priority") {
+			priorityTiersData := getPriorityTiersData(pods, nodesCapacityData, nodeNames, filter)
+			return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+				return output.DisplayPriorityTiersData(priorityTiersData, displayOptions)
+			})
+		}
+
+		preemptionData := getPreemptionData(pods, nodesCapacityData, nodeNames, priority, filter)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayPreemptionData(preemptionData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(preemptionCmd)
+	preemptionCmd.Flags().Int32P("priority", "", 0, "Priority of the pods that would preempt, pods of lower priority are preemptible. Without it the capacity available at or above each priority of the pods is shown.")
+	preemptionCmd.RunE = watchRunE(preemptionCmd.RunE)
+}
+
+// Pods of lower priority that are not static are preemptible, the scheduler can not remove the pods the kubelet runs
+// from manifest files. Only ready, schedulable nodes are counted, like the scheduler only preempts on those.
+func getPreemptionData(pods *corev1.PodList, nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, priority int32, filter kubesize.PodFilter) output.PreemptionData {
+	preemptionData := output.PreemptionData{Priority: priority, Roles: make([]output.PreemptionCapacityData, 0), Nodes: make([]output.PreemptionCapacityData, 0)}
+
+	nodeIndex := make(map[string]int)
+	for _, nodeName := range nodeNames {
+		data := nodesCapacityData[nodeName]
+		if !data.Ready || !data.Schedulable {
+			continue
+		}
+		nodeIndex[nodeName] = len(preemptionData.Nodes)
+		preemptionData.Nodes = append(preemptionData.Nodes, output.PreemptionCapacityData{
+			Name:            nodeName,
+			NodeCount:       1,
+			Roles:           data.Roles.List(),
+			AvailablePods:   data.TotalAvailablePods,
+			AvailableCPU:    data.TotalAvailableCPU.DeepCopy(),
+			AvailableMemory: data.TotalAvailableMemory.DeepCopy(),
+		})
+	}
+
+	for _, pod := range pods.Items {
+		i, ok := nodeIndex[pod.Spec.NodeName]
+		if !ok || !filter.HoldsResources(pod) {
+			continue
+		}
+		nodeData := &preemptionData.Nodes[i]
+		nodeData.PodCount++
+		if kubesize.PodPriority(pod) >= priority || kubesize.IsStatic(pod) {
+			continue
+		}
+		requestsCPU, requestsMemory := kubesize.PodRequests(pod)
+		nodeData.PreemptiblePodCount++
+		nodeData.FreedCPU.Add(requestsCPU)
+		nodeData.FreedMemory.Add(requestsMemory)
+	}
+
+	roles := sets.NewString()
+	for i := range preemptionData.Nodes {
+		nodeData := &preemptionData.Nodes[i]
+		nodeData.AvailablePodsAfter = nodeData.AvailablePods + nodeData.PreemptiblePodCount
+		nodeData.AvailableCPUAfter = nodeData.AvailableCPU.DeepCopy()
+		nodeData.AvailableCPUAfter.Add(nodeData.FreedCPU)
+		nodeData.AvailableMemoryAfter = nodeData.AvailableMemory.DeepCopy()
+		nodeData.AvailableMemoryAfter.Add(nodeData.FreedMemory)
+		roles.Insert(nodeData.Roles...)
+	}
+	// Every node is also part of the *total* "role"
+	roleIndex := make(map[string]int)
+	for i, role := range append(roles.List(), "*total*") {
+		roleIndex[role] = i
+		preemptionData.Roles = append(preemptionData.Roles, output.PreemptionCapacityData{Name: role})
+	}
+	for _, nodeData := range preemptionData.Nodes {
+		for _, role := range append(nodeData.Roles, "*total*") {
+			addPreemptionCapacity(&preemptionData.Roles[roleIndex[role]], nodeData)
+		}
+	}
+
+	for _, data := range [][]output.PreemptionCapacityData{preemptionData.Roles, preemptionData.Nodes} {
+		for i := range data {
+			data[i].AvailableCPUCores = capacity.ReadableCPU(data[i].AvailableCPU)
+			data[i].FreedCPUCores = capacity.ReadableCPU(data[i].FreedCPU)
+			data[i].AvailableCPUAfterCores = capacity.ReadableCPU(data[i].AvailableCPUAfter)
+			data[i].AvailableMemoryGiB = capacity.ReadableMem(data[i].AvailableMemory)
+			data[i].FreedMemoryGiB = capacity.ReadableMem(data[i].FreedMemory)
+			data[i].AvailableMemoryAfterGiB = capacity.ReadableMem(data[i].AvailableMemoryAfter)
+		}
+	}
+	return preemptionData
+}
+
+// Adds a node to its role, capacity a node is over-committed by is not available to the other nodes of the role
+func addPreemptionCapacity(roleData *output.PreemptionCapacityData, nodeData output.PreemptionCapacityData) {
+	roleData.NodeCount++
+	roleData.PodCount += nodeData.PodCount
+	roleData.PreemptiblePodCount += nodeData.PreemptiblePodCount
+	if nodeData.AvailablePods > 0 {
+		roleData.AvailablePods += nodeData.AvailablePods
+	}
+	if nodeData.AvailablePodsAfter > 0 {
+		roleData.AvailablePodsAfter += nodeData.AvailablePodsAfter
+	}
+	roleData.FreedCPU.Add(nodeData.FreedCPU)
+	roleData.FreedMemory.Add(nodeData.FreedMemory)
+	if nodeData.AvailableCPU.Sign() > 0 {
+		roleData.AvailableCPU.Add(nodeData.AvailableCPU)
+	}
+	if nodeData.AvailableCPUAfter.Sign() > 0 {
+		roleData.AvailableCPUAfter.Add(nodeData.AvailableCPUAfter)
+	}
+	if nodeData.AvailableMemory.Sign() > 0 {
+		roleData.AvailableMemory.Add(nodeData.AvailableMemory)
+	}
+	if nodeData.AvailableMemoryAfter.Sign() > 0 {
+		roleData.AvailableMemoryAfter.Add(nodeData.AvailableMemoryAfter)
+	}
+}
+
+// The capacity available at or above each priority of the pods on ready, schedulable nodes, the allocatable capacity
+// minus the requests of the pods of that priority or higher and of static pods, highest priority first
+func getPriorityTiersData(pods *corev1.PodList, nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, filter kubesize.PodFilter) output.PriorityTiersData {
+	priorities := make(map[int32]bool)
+	for _, pod := range pods.Items {
+		data, ok := nodesCapacityData[pod.Spec.NodeName]
+		if ok && data.Ready && data.Schedulable && filter.HoldsResources(pod) {
+			priorities[kubesize.PodPriority(pod)] = true
+		}
+	}
+	tiers := make([]int32, 0, len(priorities))
+	for priority := range priorities {
+		tiers = append(tiers, priority)
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i] > tiers[j] })
+
+	priorityTiersData := output.PriorityTiersData{Tiers: make([]output.PriorityTierData, 0)}
+	for _, priority := range tiers {
+		preemptionData := getPreemptionData(pods, nodesCapacityData, nodeNames, priority, filter)
+		for _, roleData := range preemptionData.Roles {
+			priorityTiersData.Tiers = append(priorityTiersData.Tiers, output.PriorityTierData{
+				Priority:           priority,
+				Role:               roleData.Name,
+				PodCount:           roleData.PodCount - roleData.PreemptiblePodCount,
+				AvailablePods:      roleData.AvailablePodsAfter,
+				AvailableCPU:       roleData.AvailableCPUAfter,
+				AvailableCPUCores:  roleData.AvailableCPUAfterCores,
+				AvailableMemory:    roleData.AvailableMemoryAfter,
+				AvailableMemoryGiB: roleData.AvailableMemoryAfterGiB,
+			})
+		}
+	}
+	return priorityTiersData
+}
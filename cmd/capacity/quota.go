@@ -0,0 +1,156 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/manifest"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// API groups of the workload kinds, object count quotas are named count/<resource>.<group>
+var workloadGroups = map[string]string{"Deployment": "apps", "StatefulSet": "apps", "Job": "batch", "CronJob": "batch"}
+
+// Simulates ResourceQuota admission of the workloads, in order, on top of the usage recorded in the quota status. Only
+// the quota resources the workloads are charged to are returned.
+func getQuotaFitData(workloads []manifest.Workload, resourceQuotas *corev1.ResourceQuotaList) []output.QuotaFitData {
+	quotas := make([]output.QuotaFitData, 0)
+	quotaIndex := make(map[string]int)
+
+	for _, workload := range workloads {
+		requestsCPU, requestsMemory := kubesize.EffectivePodRequests(workload.Template.Spec)
+		limitsCPU, limitsMemory := kubesize.EffectivePodLimits(workload.Template.Spec)
+		replicas := int64(workload.Replicas)
+		charges := map[corev1.ResourceName]resource.Quantity{
+			corev1.ResourcePods:                                *resource.NewQuantity(replicas, resource.DecimalSI),
+			"count/pods":                                       *resource.NewQuantity(replicas, resource.DecimalSI),
+			corev1.ResourceCPU:                                 *resource.NewMilliQuantity(requestsCPU.MilliValue()*replicas, resource.DecimalSI),
+			corev1.ResourceRequestsCPU:                         *resource.NewMilliQuantity(requestsCPU.MilliValue()*replicas, resource.DecimalSI),
+			corev1.ResourceMemory:                              *resource.NewQuantity(requestsMemory.Value()*replicas, resource.BinarySI),
+			corev1.ResourceRequestsMemory:                      *resource.NewQuantity(requestsMemory.Value()*replicas, resource.BinarySI),
+			corev1.ResourceLimitsCPU:                           *resource.NewMilliQuantity(limitsCPU.MilliValue()*replicas, resource.DecimalSI),
+			corev1.ResourceLimitsMemory:                        *resource.NewQuantity(limitsMemory.Value()*replicas, resource.BinarySI),
+			corev1.ResourceName(objectCountResource(workload)): *resource.NewQuantity(1, resource.DecimalSI),
+		}
+
+		for _, resourceQuota := range resourceQuotas.Items {
+			// Quotas with scopes only track pod resources
+			if resourceQuota.Namespace != workload.Namespace || !quotaMatchesScopes(resourceQuota, workload.Template.Spec) {
+				continue
+			}
+			hard := resourceQuota.Status.Hard
+			if len(hard) == 0 {
+				hard = resourceQuota.Spec.Hard
+			}
+			for resourceName, hardQuantity := range hard {
+				charge, ok := charges[resourceName]
+				if !ok || charge.IsZero() {
+					continue
+				}
+				key := resourceQuota.Namespace + "/" + resourceQuota.Name + "/" + string(resourceName)
+				i, ok := quotaIndex[key]
+				if !ok {
+					i = len(quotas)
+					quotaIndex[key] = i
+					quotas = append(quotas, output.QuotaFitData{
+						Namespace:     resourceQuota.Namespace,
+						ResourceQuota: resourceQuota.Name,
+						Resource:      string(resourceName),
+						Used:          resourceQuota.Status.Used[resourceName].DeepCopy(),
+						Hard:          hardQuantity.DeepCopy(),
+					})
+				}
+				quotas[i].Requested.Add(charge)
+				quotas[i].Workloads = append(quotas[i].Workloads, fmt.Sprintf("%s/%s", strings.ToLower(workload.Kind), workload.Name))
+			}
+		}
+	}
+
+	for i := range quotas {
+		total := quotas[i].Used.DeepCopy()
+		total.Add(quotas[i].Requested)
+		quotas[i].Fits = total.Cmp(quotas[i].Hard) <= 0
+	}
+	sort.SliceStable(quotas, func(i, j int) bool {
+		if quotas[i].Namespace != quotas[j].Namespace {
+			return quotas[i].Namespace < quotas[j].Namespace
+		}
+		if quotas[i].ResourceQuota != quotas[j].ResourceQuota {
+			return quotas[i].ResourceQuota < quotas[j].ResourceQuota
+		}
+		return quotas[i].Resource < quotas[j].Resource
+	})
+	return quotas
+}
+
+func objectCountResource(workload manifest.Workload) string {
+	return fmt.Sprintf("count/%ss.%s", strings.ToLower(workload.Kind), workloadGroups[workload.Kind])
+}
+
+// Whether the pods of the spec are tracked by a quota with scopes
+func quotaMatchesScopes(resourceQuota corev1.ResourceQuota, spec corev1.PodSpec) bool {
+	requirements := make([]corev1.ScopedResourceSelectorRequirement, 0)
+	for _, scope := range resourceQuota.Spec.Scopes {
+		requirements = append(requirements, corev1.ScopedResourceSelectorRequirement{ScopeName: scope, Operator: corev1.ScopeSelectorOpExists})
+	}
+	if resourceQuota.Spec.ScopeSelector != nil {
+		requirements = append(requirements, resourceQuota.Spec.ScopeSelector.MatchExpressions...)
+	}
+	for _, requirement := range requirements {
+		if !scopeMatches(requirement, spec) {
+			return false
+		}
+	}
+	return true
+}
+
+func scopeMatches(requirement corev1.ScopedResourceSelectorRequirement, spec corev1.PodSpec) bool {
+	if requirement.ScopeName == corev1.ResourceQuotaScopePriorityClass {
+		switch requirement.Operator {
+		case corev1.ScopeSelectorOpIn, corev1.ScopeSelectorOpNotIn:
+			in := false
+			for _, value := range requirement.Values {
+				in = in || value == spec.PriorityClassName
+			}
+			return in == (requirement.Operator == corev1.ScopeSelectorOpIn)
+		case corev1.ScopeSelectorOpDoesNotExist:
+			return spec.PriorityClassName == ""
+		}
+		return spec.PriorityClassName != ""
+	}
+
+	matches := true
+	switch requirement.ScopeName {
+	case corev1.ResourceQuotaScopeTerminating:
+		matches = spec.ActiveDeadlineSeconds != nil
+	case corev1.ResourceQuotaScopeNotTerminating:
+		matches = spec.ActiveDeadlineSeconds == nil
+	case corev1.ResourceQuotaScopeBestEffort:
+		matches = kubesize.IsBestEffort(corev1.Pod{Spec: spec})
+	case corev1.ResourceQuotaScopeNotBestEffort:
+		matches = !kubesize.IsBestEffort(corev1.Pod{Spec: spec})
+	}
+	if requirement.Operator == corev1.ScopeSelectorOpDoesNotExist {
+		return !matches
+	}
+	return matches
+}
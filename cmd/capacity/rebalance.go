@@ -0,0 +1,223 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"math"
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/akrzos/kubeSize/internal/simulate"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var rebalanceCmd = &cobra.Command{
+	Use:   "rebalance",
+	Short: "Suggest pod evictions that even out imbalanced node roles",
+	Long:  `Find node roles whose nodes are requested unevenly and suggest, as a dry run, the pods a descheduler could evict from their most requested nodes and where the scheduler would place them, with the projected request utilization spread before and after`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		maxSpread, _ := cmd.Flags().GetFloat64("max-spread")
+
+		maxEvictions, _ := cmd.Flags().GetInt("max-evictions")
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, newPodFilter(displayOptions))
+
+		rebalanceData := getRebalanceData(simulate.NewCluster(nodes, pods, newPodFilter(displayOptions)), nodesCapacityData, nodeNames, maxSpread, maxEvictions)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayRebalanceData(rebalanceData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rebalanceCmd)
+	rebalanceCmd.Flags().Float64P("max-spread", "", 20, "Percentage points the cpu or memory request utilization of the nodes of a role may spread before the role is imbalanced")
+	rebalanceCmd.Flags().IntP("max-evictions", "", 10, "Most evictions to suggest for each imbalanced role")
+	rebalanceCmd.RunE = watchRunE(rebalanceCmd.RunE)
+}
+
+// Evicts, one at a time, the pod of the most requested node of each imbalanced role whose placement on another node of
+// the role narrows the spread the most, until the role is balanced or no eviction narrows it. Roles share the cluster
+// state, so evictions of a role are seen by the roles after it.
+func getRebalanceData(cluster *simulate.Cluster, nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, maxSpread float64, maxEvictions int) output.RebalanceData {
+	rebalanceData := output.RebalanceData{Roles: make([]output.RebalanceRoleData, 0), Evictions: make([]output.RebalanceEvictionData, 0)}
+
+	nodeInfos := make(map[string]*simulate.NodeInfo, len(cluster.Nodes))
+	for _, node := range cluster.Nodes {
+		nodeInfos[node.Node.Name] = node
+	}
+	roleNodes := make(map[string][]*simulate.NodeInfo)
+	for _, nodeName := range nodeNames {
+		data := nodesCapacityData[nodeName]
+		if !data.Ready || !data.Schedulable {
+			continue
+		}
+		for _, role := range data.Roles.List() {
+			roleNodes[role] = append(roleNodes[role], nodeInfos[nodeName])
+		}
+	}
+	roles := make([]string, 0, len(roleNodes))
+	for role := range roleNodes {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		nodes := roleNodes[role]
+		roleData := output.RebalanceRoleData{Role: role, NodeCount: len(nodes), Nodes: make([]output.RebalanceNodeData, 0, len(nodes))}
+		for _, node := range nodes {
+			cpu, memory := node.Utilization()
+			roleData.Nodes = append(roleData.Nodes, output.RebalanceNodeData{Node: node.Node.Name, CPUBefore: round(cpu), MemoryBefore: round(memory)})
+		}
+		roleData.CPUSpreadBefore, roleData.MemorySpreadBefore = utilizationSpread(nodes)
+		roleData.Imbalanced = len(nodes) > 1 && math.Max(roleData.CPUSpreadBefore, roleData.MemorySpreadBefore) > maxSpread
+
+		for roleData.Imbalanced && roleData.EvictionCount < maxEvictions {
+			spread := math.Max(utilizationSpread(nodes))
+			if spread <= maxSpread {
+				break
+			}
+			source := mostUtilized(nodes)
+			targets := make([]*simulate.NodeInfo, 0, len(nodes)-1)
+			for _, node := range nodes {
+				if node != source {
+					targets = append(targets, node)
+				}
+			}
+			var bestPod *corev1.Pod
+			var bestTarget *simulate.NodeInfo
+			bestSpread := spread
+			for _, pod := range append([]*corev1.Pod(nil), source.Pods...) {
+				if !evictable(pod) {
+					continue
+				}
+				source.Remove(pod)
+				for _, target := range cluster.Feasible(&simulate.Pod{Pod: *pod}, targets) {
+					target.Add(pod)
+					if movedSpread := math.Max(utilizationSpread(nodes)); movedSpread < bestSpread {
+						bestPod, bestTarget, bestSpread = pod, target, movedSpread
+					}
+					target.Remove(pod)
+				}
+				source.Add(pod)
+			}
+			if bestPod == nil {
+				break
+			}
+			source.Remove(bestPod)
+			bestTarget.Add(bestPod)
+			requestsCPU, requestsMemory := kubesize.PodRequests(*bestPod)
+			rebalanceData.Evictions = append(rebalanceData.Evictions, output.RebalanceEvictionData{
+				Role:           role,
+				Namespace:      bestPod.Namespace,
+				Pod:            bestPod.Name,
+				From:           source.Node.Name,
+				To:             bestTarget.Node.Name,
+				RequestsCPU:    requestsCPU,
+				RequestsMemory: requestsMemory,
+			})
+			roleData.EvictionCount++
+		}
+
+		for i, node := range nodes {
+			cpu, memory := node.Utilization()
+			roleData.Nodes[i].CPUAfter, roleData.Nodes[i].MemoryAfter = round(cpu), round(memory)
+		}
+		roleData.CPUSpreadAfter, roleData.MemorySpreadAfter = utilizationSpread(nodes)
+		rebalanceData.Roles = append(rebalanceData.Roles, roleData)
+	}
+	return rebalanceData
+}
+
+// Percentage points between the most and least requested node, for cpu and for memory
+func utilizationSpread(nodes []*simulate.NodeInfo) (float64, float64) {
+	minCPU, maxCPU, minMemory, maxMemory := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, node := range nodes {
+		cpu, memory := node.Utilization()
+		minCPU, maxCPU = math.Min(minCPU, cpu), math.Max(maxCPU, cpu)
+		minMemory, maxMemory = math.Min(minMemory, memory), math.Max(maxMemory, memory)
+	}
+	if len(nodes) == 0 {
+		return 0, 0
+	}
+	return round(maxCPU - minCPU), round(maxMemory - minMemory)
+}
+
+// The node with the largest of its cpu and memory request utilization
+func mostUtilized(nodes []*simulate.NodeInfo) *simulate.NodeInfo {
+	var most *simulate.NodeInfo
+	mostUtilization := 0.0
+	for _, node := range nodes {
+		cpu, memory := node.Utilization()
+		if utilization := math.Max(cpu, memory); most == nil || utilization > mostUtilization {
+			most, mostUtilization = node, utilization
+		}
+	}
+	return most
+}
+
+// Pods the descheduler evicts by default, those recreated by a controller other than a DaemonSet, that are not
+// static, critical, terminating or using local storage
+func evictable(pod *corev1.Pod) bool {
+	if kubesize.IsStatic(*pod) || kubesize.IsTerminating(*pod) {
+		return false
+	}
+	if pod.Spec.PriorityClassName == "system-cluster-critical" || pod.Spec.PriorityClassName == "system-node-critical" {
+		return false
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return false
+		}
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller {
+			return owner.Kind != "DaemonSet"
+		}
+	}
+	return false
+}
+
+func round(value float64) float64 {
+	return math.Round(value*10) / 10
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/metrics"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var reportCmd = &cobra.Command{
+	Use:     "report",
+	Aliases: []string{"r"},
+	Short:   "Get a full capacity report",
+	Long:    `Get cluster, node-role, node, namespace and pending pod capacity data in a single report`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		// Each list is only fetched once and shared by every section of the report
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		namespaces, err := capacitySource.Namespaces("")
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		displayTotal, _ := cmd.Flags().GetBool("display-total")
+
+		reportData := output.ReportData{Cluster: *kubesize.ClusterCapacity(nodes, pods, newPodFilter(displayOptions))}
+
+		var roleNames, nodeNames, namespaceNames []string
+		reportData.NodeRoles, roleNames = kubesize.NodeRoleCapacity(nodes, pods, true, displayTotal, false, newPodFilter(displayOptions))
+		reportData.Nodes, nodeNames, _ = kubesize.NodeCapacity(nodes, pods, true, displayTotal, false, newPodFilter(displayOptions))
+		reportData.Namespaces, namespaceNames = kubesize.NamespaceCapacity(namespaces, pods, displayTotal, newPodFilter(displayOptions))
+		reportData.PendingPods = getPendingPodData(pods)
+
+		thresholdBreaches = append(clusterBreaches(displayOptions, &reportData.Cluster), nodeRoleBreaches(displayOptions, reportData.NodeRoles, roleNames)...)
+		thresholdBreaches = append(thresholdBreaches, nodeBreaches(displayOptions, reportData.Nodes, nodeNames)...)
+		collectedMetrics = append(metrics.ClusterMetrics(&reportData.Cluster, nil), metrics.NodeRoleMetrics(reportData.NodeRoles, roleNames, nil)...)
+		collectedMetrics = append(collectedMetrics, metrics.NodeMetrics(reportData.Nodes, nodeNames, nil)...)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayReportData(reportData, roleNames, nodeNames, namespaceNames, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.RunE = watchRunE(reportCmd.RunE)
+	reportCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage capacity data in table output")
+	reportCmd.Flags().BoolP("display-total", "t", false, "Display sum of all capacity data in node-role, node and namespace table output")
+}
+
+// Pending pods sorted by namespace and name with the reason they are not yet scheduled
+func getPendingPodData(pods *corev1.PodList) []output.PendingPodData {
+	pendingPods := make([]output.PendingPodData, 0)
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		pendingPod := output.PendingPodData{Namespace: pod.Namespace, Name: pod.Name, Reason: pod.Status.Reason, Message: pod.Status.Message}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled && condition.Status != corev1.ConditionTrue {
+				pendingPod.Reason = condition.Reason
+				pendingPod.Message = condition.Message
+			}
+		}
+		for _, container := range pod.Spec.Containers {
+			pendingPod.RequestsCPU.Add(*container.Resources.Requests.Cpu())
+			pendingPod.RequestsMemory.Add(*container.Resources.Requests.Memory())
+		}
+		pendingPod.RequestsCPUCores = capacity.ReadableCPU(pendingPod.RequestsCPU)
+		pendingPod.RequestsMemoryGiB = capacity.ReadableMem(pendingPod.RequestsMemory)
+		pendingPods = append(pendingPods, pendingPod)
+	}
+
+	sort.Slice(pendingPods, func(i, j int) bool {
+		if pendingPods[i].Namespace != pendingPods[j].Namespace {
+			return pendingPods[i].Namespace < pendingPods[j].Namespace
+		}
+		return pendingPods[i].Name < pendingPods[j].Name
+	})
+
+	return pendingPods
+}
@@ -16,15 +16,25 @@ limitations under the License.
 package capacity
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
+	"github.com/akrzos/kubeSize/internal/alert"
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
 )
 
 var (
 	KubernetesConfigFlags *genericclioptions.ConfigFlags
+	// Table cells of the previous sample in watch mode
+	watchDeltas *output.Deltas
 )
 
 var rootCmd = &cobra.Command{
@@ -33,19 +43,375 @@ var rootCmd = &cobra.Command{
 	Long:          `Exposes size and capacity data for Kubernetes clusters`,
 	SilenceErrors: true,
 	SilenceUsage:  true,
+	// Flags take precedence over the environment, which takes precedence over the config file
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := bindEnvironment(cmd); err != nil {
+			return err
+		}
+		config, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(cmd, config); err != nil {
+			return err
+		}
+		return configureLogging(cmd)
+	},
 }
 
 func Execute() {
+	os.Exit(Run(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}, os.Args[1:]))
+}
+
+// Runs the command line args with the IO streams and returns the exit code, so the commands can be embedded in other
+// CLIs and tested end-to-end without exiting the process
+func Run(streams genericclioptions.IOStreams, args []string) int {
+	rootCmd.SetIn(streams.In)
+	rootCmd.SetOut(streams.Out)
+	rootCmd.SetErr(streams.ErrOut)
+	rootCmd.SetArgs(args)
+	logger = newDefaultLogger(streams.ErrOut)
+	thresholdBreaches = nil
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		logger.Error(err, "")
+		return 1
 	}
+	exitCode, _ := rootCmd.PersistentFlags().GetBool("exit-code")
+	if exitCode {
+		return breachExitCode(thresholdBreaches)
+	}
+	return 0
 }
 
 func init() {
 	KubernetesConfigFlags = genericclioptions.NewConfigFlags(false)
 	KubernetesConfigFlags.AddFlags(rootCmd.PersistentFlags())
+	rootCmd.PersistentFlags().StringP("config", "", "", "Config file of default flags and profiles (default ~/.kubesize.yaml)")
+	rootCmd.PersistentFlags().StringP("profile", "", "", "Profile of the config file to apply")
+	rootCmd.PersistentFlags().StringP("as-uid", "", "", "UID to impersonate for the operation, along with the user of --as")
+	rootCmd.PersistentFlags().StringP("from", "", "", "Read capacity data from a saved snapshot (JSON or YAML file), a cluster-info dump or must-gather (directory or tar archive) or Prometheus (prometheus://host:9090) instead of the cluster")
+	rootCmd.PersistentFlags().StringP("record", "", "", "Save the raw API responses to a directory for --replay")
+	rootCmd.PersistentFlags().StringP("replay", "", "", "Serve the API responses from a directory saved with --record instead of the cluster")
+	rootCmd.PersistentFlags().IntP("retries", "", 3, "Retry API requests that failed with a connection error, were throttled (429) or hit an unavailable API server this many times")
+	rootCmd.PersistentFlags().DurationP("retry-delay", "", 500*time.Millisecond, "Delay before the first retry of an API request, doubled after each attempt")
+	rootCmd.PersistentFlags().Float32P("qps", "", rest.DefaultQPS, "Maximum queries per second to the API server, a negative value disables client-side rate limiting")
+	rootCmd.PersistentFlags().IntP("burst", "", rest.DefaultBurst, "Maximum burst of queries to the API server above --qps")
+	rootCmd.PersistentFlags().BoolP("timing", "", false, "Print the time spent listing, fetching metrics, aggregating and rendering to stderr")
+	rootCmd.PersistentFlags().BoolP("verbose", "", false, "Print the number of API requests and the time they were throttled by the client or the server to stderr")
+	rootCmd.PersistentFlags().IntP("v", "v", 0, "Log verbosity, 1 logs the progress of the long running sub-commands and 6 and above the API requests of client-go")
+	rootCmd.PersistentFlags().StringP("log-format", "", "text", "Format of the logs on stderr. One of: text|json")
+	rootCmd.PersistentFlags().DurationP("cache-ttl", "", 0, "Reuse the objects listed from the cluster by a previous run for this long, cached under ~/.kube/cache/kubesize, disabled when 0")
+	rootCmd.PersistentFlags().BoolP("watch-list", "", true, "Stream the nodes and pods from API servers that support streaming lists (WatchList) instead of listing them at once")
 	rootCmd.PersistentFlags().BoolP("default-format", "d", false, "Use default format of displaying resource quantities")
 	rootCmd.PersistentFlags().BoolP("no-headers", "", false, "No headers in table output format")
-	rootCmd.PersistentFlags().StringP("output", "o", "table", "Output format. One of: table|json|yaml")
+	rootCmd.PersistentFlags().BoolP("plain", "", false, "Separate table cells with a single space without alignment padding")
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "Output format. One of: table|wide|json|yaml|name")
+	rootCmd.PersistentFlags().StringP("schema", "", output.SchemaV2, "Schema version of the cluster, node-role, node and namespace json and yaml output. One of: v1|v2, the fields of v1 never change")
+	rootCmd.PersistentFlags().StringP("key-case", "", output.GoKeyCase, "Key casing of json and yaml output. One of: go|camel, the Go field names such as TotalCPUCores or camelCase such as totalCPUCores")
+	rootCmd.PersistentFlags().BoolP("compact", "", false, "Print json output on a single line instead of indented")
+	rootCmd.PersistentFlags().StringP("output-file", "", "", "Write output to a file, the file is only replaced once the output is complete")
+	rootCmd.PersistentFlags().BoolP("watch", "w", false, "Re-run and print the output every interval")
+	rootCmd.PersistentFlags().DurationP("interval", "", 5*time.Second, "Interval between samples in watch mode")
+	rootCmd.PersistentFlags().BoolP("anonymize", "", false, "Replace node, namespace and pod names and node IPs with consistent hashes")
+	rootCmd.PersistentFlags().BoolP("no-color", "", false, "Disable colorized table output")
+	rootCmd.PersistentFlags().StringSliceP("include-namespaces", "", []string{}, "Only count the pods and objects of namespaces matching these comma separated globs, such as prod-*, or regular expressions enclosed in slashes")
+	rootCmd.PersistentFlags().StringSliceP("exclude-namespaces", "", []string{}, "Do not count the pods and objects of namespaces matching these comma separated globs or regular expressions enclosed in slashes")
+	rootCmd.PersistentFlags().StringP("node-name-regex", "", "", "Only count the nodes whose names match this regular expression and the pods bound to them, such as ^gpu-")
+	rootCmd.PersistentFlags().BoolP("schedulable-only", "", false, "Do not count cordoned nodes and the pods bound to them, their capacity is not available to new pods")
+	rootCmd.PersistentFlags().BoolP("exclude-not-ready", "", false, "Do not count NotReady nodes and the pods bound to them")
+	rootCmd.PersistentFlags().BoolP("exclude-tainted", "", false, "Do not count nodes with a NoSchedule or NoExecute taint and the pods bound to them, dedicated pools most pods can not land on")
+	rootCmd.PersistentFlags().StringSliceP("taint-filter", "", []string{}, "Do not count nodes with a taint matching one of these comma separated key or key=value and the pods bound to them")
+	rootCmd.PersistentFlags().StringP("pod-selector", "", "", "Only count the pods matching this label selector, such as app.kubernetes.io/part-of=payments")
+	rootCmd.PersistentFlags().StringP("field-selector", "", "", "Only list the pods matching this field selector from the API server, such as spec.nodeName=worker-1 or metadata.namespace!=kube-system")
+	rootCmd.PersistentFlags().BoolP("exclude-terminating", "", false, "Do not count the requests and limits of terminating pods, their resources are freed shortly")
+	rootCmd.PersistentFlags().BoolP("exclude-static", "", false, "Do not count static (mirror) pods, such as self-hosted control-plane components, in pod counts, requests and limits")
+	rootCmd.PersistentFlags().BoolP("best-effort", "", false, "Include a column counting BestEffort pods, pods without cpu or memory requests and limits")
+	rootCmd.PersistentFlags().StringSliceP("pin-roles", "", []string{}, "Comma separated node roles to list first in table output, in the given order")
+	rootCmd.PersistentFlags().BoolP("none-last", "", false, "List nodes without a role (<none>) after all other roles in table output")
+	rootCmd.PersistentFlags().StringP("cpu-unit", "", output.CPUUnitCores, "CPU unit of table output. One of: cores|millicores")
+	rootCmd.PersistentFlags().StringP("memory-unit", "", "Gi", "Memory unit of table output. One of: Mi|Gi|Ti|MB|GB|TB")
+	rootCmd.PersistentFlags().BoolP("si", "", false, "Display memory in decimal SI units (GB instead of GiB)")
+	rootCmd.PersistentFlags().Float64P("warn-threshold", "", 80, "Utilization percent of allocatable to highlight in yellow")
+	rootCmd.PersistentFlags().Float64P("crit-threshold", "", 95, "Utilization percent of allocatable to highlight in red")
+	rootCmd.PersistentFlags().StringP("threshold-config", "", "", "YAML file of per-resource, per node role or node warning and critical thresholds")
+	rootCmd.PersistentFlags().StringSliceP("alert-webhook", "", []string{}, "URL to POST a JSON payload to when thresholds are crossed or cleared in watch mode, may be repeated")
+	rootCmd.PersistentFlags().StringP("slack-webhook", "", "", "Slack incoming webhook URL to post threshold alerts and summaries to in watch mode")
+	rootCmd.PersistentFlags().StringP("slack-channel", "", "", "Slack channel to post to, overrides the channel of the webhook")
+	rootCmd.PersistentFlags().StringP("slack-template", "", "", "File with a Go text/template of the Slack alert message")
+	rootCmd.PersistentFlags().DurationP("summary-interval", "", 0, "Interval between capacity summaries sent to notifiers in watch mode, for example 24h, 0 disables summaries")
+	rootCmd.PersistentFlags().StringP("summary-schedule", "", "", "Cron schedule of capacity summaries sent to notifiers in watch mode, for example \"0 8 * * 1-5\"")
+	rootCmd.PersistentFlags().StringP("smtp-server", "", "", "SMTP server host:port to email threshold alerts and summaries through in watch mode")
+	rootCmd.PersistentFlags().StringP("smtp-from", "", "", "Sender address of emails")
+	rootCmd.PersistentFlags().StringSliceP("smtp-to", "", []string{}, "Comma separated recipient addresses of emails")
+	rootCmd.PersistentFlags().StringP("smtp-username", "", "", "SMTP username, the password is read from the KUBESIZE_SMTP_PASSWORD environment variable")
+	rootCmd.PersistentFlags().StringP("otlp-endpoint", "", "", "OpenTelemetry collector OTLP/HTTP endpoint to push capacity metrics to, for example http://otel-collector:4318")
+	rootCmd.PersistentFlags().StringSliceP("otlp-header", "", []string{}, "Header of OTLP requests in key=value form, may be repeated")
+	rootCmd.PersistentFlags().StringP("statsd-address", "", "", "DogStatsD host:port to send capacity gauges to, for example localhost:8125")
+	rootCmd.PersistentFlags().StringP("push-gateway", "", "", "Prometheus Pushgateway URL to push capacity metrics to, for example http://pushgateway:9091")
+	rootCmd.PersistentFlags().StringP("cloudwatch-namespace", "", "", "AWS CloudWatch namespace to put capacity metrics to, for example kubeSize, with the credentials of the AWS_ACCESS_KEY_ID or IRSA AWS_ROLE_ARN environment variables")
+	rootCmd.PersistentFlags().StringP("cloudwatch-region", "", "", "AWS region of CloudWatch, defaults to AWS_REGION")
+	rootCmd.PersistentFlags().StringP("cluster-name", "", "", "Cluster label of exported metrics, defaults to the cluster of the kubeconfig context")
+	rootCmd.PersistentFlags().BoolP("exit-code", "", false, "Exit with 2 when a warning threshold and 3 when a critical threshold is crossed")
+
+	rootCmd.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	rootCmd.RegisterFlagCompletionFunc("include-namespaces", completeNamespaces)
+	rootCmd.RegisterFlagCompletionFunc("exclude-namespaces", completeNamespaces)
+	rootCmd.RegisterFlagCompletionFunc("context", completeContexts)
+	rootCmd.RegisterFlagCompletionFunc("pin-roles", completeNodeRoles)
+	rootCmd.RegisterFlagCompletionFunc("output", completeValues("table", "wide", "json", "yaml", "name"))
+	rootCmd.RegisterFlagCompletionFunc("cpu-unit", completeValues(output.CPUUnitCores, output.CPUUnitMillicores))
+	rootCmd.RegisterFlagCompletionFunc("memory-unit", completeValues("Mi", "Gi", "Ti", "MB", "GB", "TB"))
+	rootCmd.RegisterFlagCompletionFunc("key-case", completeValues(output.GoKeyCase, output.CamelKeyCase))
+	rootCmd.RegisterFlagCompletionFunc("schema", completeValues(output.SchemaV1, output.SchemaV2))
+}
+
+func getDisplayOptions(cmd *cobra.Command) (output.DisplayOptions, error) {
+	displayDefault, _ := cmd.Flags().GetBool("default-format")
+
+	displayEphemeralStorage, _ := cmd.Flags().GetBool("ephemeral-storage")
+
+	displayNoHeaders, _ := cmd.Flags().GetBool("no-headers")
+
+	displayFormat, _ := cmd.Flags().GetString("output")
+
+	displayPlain, _ := cmd.Flags().GetBool("plain")
+
+	displayNoColor, _ := cmd.Flags().GetBool("no-color")
+
+	outputFile, _ := cmd.Flags().GetString("output-file")
+
+	anonymize, _ := cmd.Flags().GetBool("anonymize")
+
+	watch, _ := cmd.Flags().GetBool("watch")
+
+	showLabels, _ := cmd.Flags().GetBool("show-labels")
+
+	labelColumns, _ := cmd.Flags().GetStringSlice("label-columns")
+
+	availablePercent, _ := cmd.Flags().GetBool("available-percent")
+	if below, _ := cmd.Flags().GetString("below"); below != "" {
+		availablePercent = true
+	}
+
+	volumes, _ := cmd.Flags().GetBool("volumes")
+
+	disk, _ := cmd.Flags().GetBool("disk")
+
+	excludeTerminating, _ := cmd.Flags().GetBool("exclude-terminating")
+
+	excludeStatic, _ := cmd.Flags().GetBool("exclude-static")
+
+	bestEffort, _ := cmd.Flags().GetBool("best-effort")
+
+	pinnedRoles, _ := cmd.Flags().GetStringSlice("pin-roles")
+
+	noneLast, _ := cmd.Flags().GetBool("none-last")
+
+	cpuUnit, _ := cmd.Flags().GetString("cpu-unit")
+
+	memoryUnit, _ := cmd.Flags().GetString("memory-unit")
+
+	efficiency, _ := cmd.Flags().GetBool("efficiency")
+
+	si, _ := cmd.Flags().GetBool("si")
+	if si {
+		memoryUnit = output.DecimalMemoryUnit(memoryUnit)
+	}
+
+	if err := output.ValidateUnits(cpuUnit, memoryUnit); err != nil {
+		return output.DisplayOptions{}, err
+	}
+
+	schema, _ := cmd.Flags().GetString("schema")
+	if err := output.ValidateSchema(schema); err != nil {
+		return output.DisplayOptions{}, err
+	}
+
+	keyCase, _ := cmd.Flags().GetString("key-case")
+	if err := output.ValidateKeyCase(keyCase); err != nil {
+		return output.DisplayOptions{}, err
+	}
+
+	compact, _ := cmd.Flags().GetBool("compact")
+
+	warnThreshold, _ := cmd.Flags().GetFloat64("warn-threshold")
+
+	critThreshold, _ := cmd.Flags().GetFloat64("crit-threshold")
+
+	var thresholds *output.Thresholds
+	thresholdConfig, _ := cmd.Flags().GetString("threshold-config")
+	if thresholdConfig != "" {
+		var err error
+		if thresholds, err = output.LoadThresholds(thresholdConfig); err != nil {
+			return output.DisplayOptions{}, errors.Wrap(err, "failed to load threshold config")
+		}
+		// The thresholds of the file replace the default thresholds unless set by flag
+		if thresholds.Warn != nil && !cmd.Flags().Changed("warn-threshold") {
+			warnThreshold = *thresholds.Warn
+		}
+		if thresholds.Crit != nil && !cmd.Flags().Changed("crit-threshold") {
+			critThreshold = *thresholds.Crit
+		}
+	}
+
+	if warnThreshold < 0 || warnThreshold > 100 || critThreshold < 0 || critThreshold > 100 {
+		return output.DisplayOptions{}, fmt.Errorf("thresholds must be between 0 and 100 percent")
+	}
+	if warnThreshold > critThreshold {
+		return output.DisplayOptions{}, fmt.Errorf("warn-threshold %.1f is greater than crit-threshold %.1f", warnThreshold, critThreshold)
+	}
+
+	_, noColorEnv := os.LookupEnv("NO_COLOR")
+
+	return output.DisplayOptions{
+		Default:            displayDefault,
+		Headers:            !displayNoHeaders,
+		EphemeralStorage:   displayEphemeralStorage,
+		Format:             displayFormat,
+		Plain:              displayPlain,
+		Color:              !displayPlain && !displayNoColor && !noColorEnv && outputFile == "" && output.IsTerminal(cmd.OutOrStdout()),
+		WarnThreshold:      warnThreshold,
+		CritThreshold:      critThreshold,
+		Anonymize:          anonymize,
+		Watch:              watch,
+		Deltas:             watchDeltas,
+		ShowLabels:         showLabels,
+		LabelColumns:       labelColumns,
+		AvailablePercent:   availablePercent,
+		Volumes:            volumes,
+		Disk:               disk,
+		ExcludeTerminating: excludeTerminating,
+		ExcludeStatic:      excludeStatic,
+		BestEffort:         bestEffort,
+		PinnedRoles:        pinnedRoles,
+		NoneLast:           noneLast,
+		CPUUnit:            cpuUnit,
+		MemoryUnit:         memoryUnit,
+		Thresholds:         thresholds,
+		Efficiency:         efficiency,
+		Schema:             schema,
+		KeyCase:            keyCase,
+		Compact:            compact,
+		Timestamp:          time.Now().UTC(),
+		Out:                cmd.OutOrStdout(),
+	}, nil
+}
+
+// Displays to --output-file when set, otherwise to stdout
+func writeOutput(cmd *cobra.Command, displayOptions output.DisplayOptions, display func(output.DisplayOptions) error) error {
+	if summaryRequested {
+		var summary bytes.Buffer
+		summaryOptions := displayOptions
+		summaryOptions.Color = false
+		summaryOptions.Deltas = nil
+		summaryOptions.Out = &summary
+		if err := display(summaryOptions); err != nil {
+			return err
+		}
+		summaryText = summary.String()
+	}
+	if displayOptions.Watch {
+		displayFunc := display
+		display = func(displayOptions output.DisplayOptions) error {
+			output.PrintWatchHeader(displayOptions)
+			return displayFunc(displayOptions)
+		}
+	}
+	defer startStage("render")()
+	outputFile, _ := cmd.Flags().GetString("output-file")
+	if outputFile == "" {
+		return display(displayOptions)
+	}
+	err := output.WriteFileAtomic(outputFile, func(w io.Writer) error {
+		displayOptions.Out = w
+		return display(displayOptions)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to write output file")
+	}
+	return nil
+}
+
+// Runs a command, with --verbose its API requests and their throttling and with --timing the time of each stage are
+// printed to stderr
+func runDiagnosed(cmd *cobra.Command, args []string, runE func(cmd *cobra.Command, args []string) error) error {
+	apiStats = &kube.RequestStats{}
+	timings = newRunTimings()
+	start := time.Now()
+	err := runE(cmd, args)
+	elapsed := time.Since(start)
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		printAPIStats(cmd.ErrOrStderr(), cmd, elapsed)
+	}
+	if timing, _ := cmd.Flags().GetBool("timing"); timing {
+		printTimings(cmd.ErrOrStderr(), elapsed)
+	}
+	return err
+}
+
+// Re-runs a command every --interval while --watch is set
+func watchRunE(runE func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		notifiers, err := getNotifiers(cmd)
+		if err != nil {
+			return err
+		}
+		sinks, err := getSinks(cmd)
+		if err != nil {
+			return err
+		}
+		watch, _ := cmd.Flags().GetBool("watch")
+		if !watch {
+			if len(notifiers) > 0 {
+				return fmt.Errorf("alerts are only sent in watch mode, set --watch")
+			}
+			if err := runDiagnosed(cmd, args, runE); err != nil {
+				return err
+			}
+			return exportMetrics(cmd, sinks)
+		}
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			return fmt.Errorf("interval must be greater than 0")
+		}
+		watchDeltas = output.NewDeltas()
+		summaryInterval, _ := cmd.Flags().GetDuration("summary-interval")
+		var summarySchedule *alert.Schedule
+		summaryScheduleSpec, _ := cmd.Flags().GetString("summary-schedule")
+		if summaryScheduleSpec != "" {
+			if summarySchedule, err = alert.ParseSchedule(summaryScheduleSpec); err != nil {
+				return err
+			}
+		}
+		var previousBreaches []output.Breach
+		var lastSummary, nextSummary time.Time
+		if summarySchedule != nil {
+			nextSummary = summarySchedule.Next(time.Now())
+		}
+		for {
+			thresholdBreaches = nil
+			collectedMetrics = nil
+			summaryRequested = summaryInterval > 0 && time.Since(lastSummary) >= summaryInterval
+			if summarySchedule != nil && !time.Now().Before(nextSummary) {
+				summaryRequested = true
+				nextSummary = summarySchedule.Next(time.Now())
+			}
+			if err := runDiagnosed(cmd, args, runE); err != nil {
+				return err
+			}
+			// A failed export does not stop watch mode
+			if err := exportMetrics(cmd, sinks); err != nil {
+				logger.Error(err, "")
+			}
+			if len(notifiers) > 0 {
+				notifyBreaches(notifiers, previousBreaches, thresholdBreaches)
+				previousBreaches = thresholdBreaches
+			}
+			if summaryRequested {
+				sendSummary(notifiers, summaryText)
+				lastSummary = time.Now()
+			}
+			time.Sleep(interval)
+		}
+	}
 }
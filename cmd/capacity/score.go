@@ -0,0 +1,165 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var scoreCmd = &cobra.Command{
+	Use:     "score",
+	Aliases: []string{"sc"},
+	Short:   "Grade cluster capacity health",
+	Long:    `Condense headroom, overcommit, unready nodes, pending pods and fragmentation into a single graded score with reasons`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		capacitySource, err := getSource(cmd)
+		if err != nil {
+			return err
+		}
+
+		nodes, err := capacitySource.Nodes()
+		if err != nil {
+			return err
+		}
+
+		pods, err := capacitySource.Pods("")
+		if err != nil {
+			return err
+		}
+
+		// Headroom and fragmentation only count capacity on nodes, so the *total* node row is used over cluster data
+		nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, newPodFilter(displayOptions))
+
+		scoreData := getScoreData(nodesCapacityData, nodeNames, len(getPendingPodData(pods)), displayOptions.CritThreshold)
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayScoreData(scoreData, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scoreCmd)
+	scoreCmd.RunE = watchRunE(scoreCmd.RunE)
+}
+
+// Starts from 100 and deducts a penalty for each unhealthy signal
+func getScoreData(nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, pendingPodCount int, critThreshold float64) output.ScoreData {
+	total := nodesCapacityData["*total*"]
+	scoreData := output.ScoreData{Score: 100}
+	addSignal := func(name string, value float64, penalty int, reason string) {
+		scoreData.Signals = append(scoreData.Signals, output.ScoreSignal{Name: name, Value: math.Round(value*10) / 10, Penalty: penalty, Reason: reason})
+		scoreData.Score -= penalty
+	}
+
+	headroomPenalty := func(headroom float64) int {
+		switch {
+		case headroom < 10:
+			return 25
+		case headroom < 20:
+			return 10
+		}
+		return 0
+	}
+	cpuHeadroom := 100 - capacity.Percent(total.TotalRequestsCPU, total.TotalAllocatableCPU)
+	addSignal("cpu-headroom", cpuHeadroom, headroomPenalty(cpuHeadroom), fmt.Sprintf("%.1f%% of allocatable cpu is not requested", cpuHeadroom))
+	memoryHeadroom := 100 - capacity.Percent(total.TotalRequestsMemory, total.TotalAllocatableMemory)
+	addSignal("memory-headroom", memoryHeadroom, headroomPenalty(memoryHeadroom), fmt.Sprintf("%.1f%% of allocatable memory is not requested", memoryHeadroom))
+
+	// Overcommit ratio of limits to allocatable, the worse of cpu and memory
+	overcommit := math.Max(capacity.Percent(total.TotalLimitsCPU, total.TotalAllocatableCPU), capacity.Percent(total.TotalLimitsMemory, total.TotalAllocatableMemory)) / 100
+	overcommitPenalty := 0
+	switch {
+	case overcommit > 2:
+		overcommitPenalty = 15
+	case overcommit > 1.5:
+		overcommitPenalty = 5
+	}
+	addSignal("overcommit", overcommit, overcommitPenalty, fmt.Sprintf("limits are %.1fx allocatable", overcommit))
+
+	unreadyNodeCount := 0
+	for _, nodeName := range nodeNames {
+		if !nodesCapacityData[nodeName].Ready {
+			unreadyNodeCount++
+		}
+	}
+	addSignal("unready-nodes", float64(unreadyNodeCount), minInt(10*unreadyNodeCount, 30), fmt.Sprintf("%d of %d nodes are not ready", unreadyNodeCount, len(nodeNames)))
+
+	addSignal("pending-pods", float64(pendingPodCount), minInt(5*pendingPodCount, 20), fmt.Sprintf("%d pods are pending", pendingPodCount))
+
+	// Available capacity is stranded on a node when its other resource is requested past the critical threshold
+	var strandedCPU, strandedMemory, availableCPU, availableMemory resource.Quantity
+	for _, nodeName := range nodeNames {
+		data := nodesCapacityData[nodeName]
+		if !data.Ready || !data.Schedulable {
+			continue
+		}
+		availableCPU.Add(data.TotalAvailableCPU)
+		availableMemory.Add(data.TotalAvailableMemory)
+		if capacity.Percent(data.TotalRequestsMemory, data.TotalAllocatableMemory) >= critThreshold && data.TotalAvailableCPU.Sign() > 0 {
+			strandedCPU.Add(data.TotalAvailableCPU)
+		}
+		if capacity.Percent(data.TotalRequestsCPU, data.TotalAllocatableCPU) >= critThreshold && data.TotalAvailableMemory.Sign() > 0 {
+			strandedMemory.Add(data.TotalAvailableMemory)
+		}
+	}
+	fragmentation := math.Max(capacity.Percent(strandedCPU, availableCPU), capacity.Percent(strandedMemory, availableMemory))
+	fragmentationPenalty := 0
+	if fragmentation > 25 {
+		fragmentationPenalty = 10
+	}
+	addSignal("fragmentation", fragmentation, fragmentationPenalty, fmt.Sprintf("%.1f%% of available capacity is stranded on nodes with another resource exhausted", fragmentation))
+
+	if scoreData.Score < 0 {
+		scoreData.Score = 0
+	}
+	switch {
+	case scoreData.Score >= 90:
+		scoreData.Grade = "A"
+	case scoreData.Score >= 80:
+		scoreData.Grade = "B"
+	case scoreData.Score >= 70:
+		scoreData.Grade = "C"
+	case scoreData.Score >= 60:
+		scoreData.Grade = "D"
+	default:
+		scoreData.Grade = "F"
+	}
+	return scoreData
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
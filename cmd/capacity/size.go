@@ -16,10 +16,6 @@ limitations under the License.
 package capacity
 
 import (
-	"fmt"
-	"os"
-
-	"github.com/akrzos/kubeSize/internal/kube"
 	"github.com/akrzos/kubeSize/internal/output"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -31,17 +27,19 @@ var sizeCmd = &cobra.Command{
 	Aliases: []string{"s"},
 	Short:   "Get cluster size data",
 	Long:    `Get counts of many Kubernetes objects in a cluster`,
-	PreRun: func(cmd *cobra.Command, args []string) {
-		if err := output.ValidateOutput(*cmd); err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
-		}
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		clientset, err := kube.CreateClientSet(KubernetesConfigFlags)
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := liveClientSet(cmd)
 		if err != nil {
-			return errors.Wrap(err, "failed to create clientset")
+			return err
 		}
 
 		clusterSizeData := new(output.ClusterSizeData)
@@ -221,16 +219,13 @@ var sizeCmd = &cobra.Command{
 		clusterSizeData.PodDisruptionBudget = len(podDisruptionBudget.Items)
 		clusterSizeData.PodSecurityPolicy = len(podSecurityPolicy.Items)
 
-		displayNoHeaders, _ := cmd.Flags().GetBool("no-headers")
-
-		displayFormat, _ := cmd.Flags().GetString("output")
-
-		output.DisplayClusterSizeData(*clusterSizeData, !displayNoHeaders, displayFormat)
-
-		return nil
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayClusterSizeData(*clusterSizeData, displayOptions)
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(sizeCmd)
+	sizeCmd.RunE = watchRunE(sizeCmd.RunE)
 }
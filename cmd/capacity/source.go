@@ -0,0 +1,243 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/source"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// API requests of the current run, printed with --verbose
+var apiStats *kube.RequestStats
+
+// Returns the source of the --from flag, the cluster of the kubeconfig when unset, with the namespaces of
+// --include-namespaces and --exclude-namespaces, the nodes of --node-name-regex, --schedulable-only,
+// --exclude-not-ready, --exclude-tainted and --taint-filter and the pods of --pod-selector and timing its reads for
+// --timing
+func getSource(cmd *cobra.Command) (source.CapacitySource, error) {
+	capacitySource, err := newSource(cmd)
+	if err != nil {
+		return nil, err
+	}
+	includeNamespaces, _ := cmd.Flags().GetStringSlice("include-namespaces")
+	excludeNamespaces, _ := cmd.Flags().GetStringSlice("exclude-namespaces")
+	nodeFilterOptions := source.NodeFilterOptions{}
+	nodeFilterOptions.NameRegex, _ = cmd.Flags().GetString("node-name-regex")
+	nodeFilterOptions.SchedulableOnly, _ = cmd.Flags().GetBool("schedulable-only")
+	nodeFilterOptions.ReadyOnly, _ = cmd.Flags().GetBool("exclude-not-ready")
+	nodeFilterOptions.ExcludeTainted, _ = cmd.Flags().GetBool("exclude-tainted")
+	nodeFilterOptions.ExcludeTaints, _ = cmd.Flags().GetStringSlice("taint-filter")
+	podSelector, _ := cmd.Flags().GetString("pod-selector")
+	if len(includeNamespaces) > 0 || len(excludeNamespaces) > 0 || nodeFiltered(nodeFilterOptions) || podSelector != "" {
+		var filter *source.NameFilter
+		if len(includeNamespaces) > 0 || len(excludeNamespaces) > 0 {
+			if filter, err = source.NewNamespaceFilter(includeNamespaces, excludeNamespaces); err != nil {
+				return nil, err
+			}
+		}
+		var nodeFilter *source.NodeFilter
+		if nodeFiltered(nodeFilterOptions) {
+			if nodeFilter, err = source.NewNodeFilter(nodeFilterOptions); err != nil {
+				return nil, err
+			}
+		}
+		var selector labels.Selector
+		if podSelector != "" {
+			if selector, err = labels.Parse(podSelector); err != nil {
+				return nil, errors.Wrap(err, "failed to parse pod selector")
+			}
+		}
+		capacitySource = source.NewFiltered(capacitySource, filter, nodeFilter, selector)
+	}
+	return timedSource{capacitySource}, nil
+}
+
+// Whether any node filter flag is set
+func nodeFiltered(options source.NodeFilterOptions) bool {
+	return options.NameRegex != "" || options.SchedulableOnly || options.ReadyOnly || options.ExcludeTainted || len(options.ExcludeTaints) > 0
+}
+
+func newSource(cmd *cobra.Command) (source.CapacitySource, error) {
+	from, _ := cmd.Flags().GetString("from")
+	fieldSelector, _ := cmd.Flags().GetString("field-selector")
+	if from != "" && fieldSelector != "" {
+		return nil, fmt.Errorf("--field-selector is applied by the API server, --from is not supported")
+	}
+	switch {
+	case from == "":
+		clientset, err := createClientSet(cmd)
+		if err != nil {
+			return nil, err
+		}
+		var podFieldSelector fields.Selector
+		if fieldSelector != "" {
+			if podFieldSelector, err = fields.ParseSelector(fieldSelector); err != nil {
+				return nil, errors.Wrap(err, "failed to parse field selector")
+			}
+		}
+		// Recordings save whole responses, so a watch stream is neither recorded nor replayed
+		watchList, _ := cmd.Flags().GetBool("watch-list")
+		recordDir, _ := cmd.Flags().GetString("record")
+		replayDir, _ := cmd.Flags().GetString("replay")
+		live := source.NewLive(clientset, watchList && recordDir == "" && replayDir == "", podFieldSelector, logger)
+		cacheTTL, _ := cmd.Flags().GetDuration("cache-ttl")
+		if cacheTTL <= 0 || recordDir != "" || replayDir != "" {
+			return live, nil
+		}
+		cacheDir, err := clusterCacheDir(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return source.NewCache(live, cacheDir, cacheTTL), nil
+	case strings.HasPrefix(from, "prometheus://"):
+		return source.NewPrometheus("http://" + strings.TrimPrefix(from, "prometheus://")), nil
+	case strings.HasPrefix(from, "prometheus+https://"):
+		return source.NewPrometheus("https://" + strings.TrimPrefix(from, "prometheus+https://")), nil
+	case source.IsDump(from):
+		return source.NewDump(from), nil
+	}
+	return source.NewSnapshot(from), nil
+}
+
+// Returns the --cache-ttl directory of the context, API server, impersonated user and pod field selector of the run
+// under ~/.kube/cache/kubesize, so the objects of one cluster, user or selection are never served for another
+func clusterCacheDir(cmd *cobra.Command) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find the cache directory")
+	}
+	impersonateUID, _ := cmd.Flags().GetString("as-uid")
+	fieldSelector, _ := cmd.Flags().GetString("field-selector")
+	identity := []string{runContext(cmd), impersonateUID, fieldSelector}
+	// The in-cluster config has no kubeconfig context and a single API server
+	if config, err := KubernetesConfigFlags.ToRESTConfig(); err == nil {
+		identity = append(identity, config.Host, config.Username, config.Impersonate.UserName, strings.Join(config.Impersonate.Groups, ","))
+	}
+	key := sha256.Sum256([]byte(strings.Join(identity, "\x00")))
+	return filepath.Join(home, ".kube", "cache", "kubesize", hex.EncodeToString(key[:8])), nil
+}
+
+// Returns the clientset of sub-commands that need more than the objects of a source
+func liveClientSet(cmd *cobra.Command) (*kubernetes.Clientset, error) {
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		return nil, fmt.Errorf("%s requires a live cluster, --from is not supported", cmd.Name())
+	}
+	return createClientSet(cmd)
+}
+
+// Returns the attach limits of the CSI drivers of each node for --volumes, only known from a live cluster. Limits that
+// can not be read are warned about, leaving those of the in-tree volume plugins in the node allocatable.
+func csiAttachLimits(cmd *cobra.Command) map[string]int64 {
+	if from, _ := cmd.Flags().GetString("from"); from != "" {
+		return nil
+	}
+	clientset, err := createClientSet(cmd)
+	if err == nil {
+		defer startStage("csi node list")()
+		var attachLimits map[string]int64
+		if attachLimits, err = kube.GetCSINodeAttachLimits(clientset); err == nil {
+			return attachLimits
+		}
+	}
+	logger.Warning("only in-tree attach limits are known", "error", err)
+	return nil
+}
+
+// Returns the summary API of the kubelet of each node by node name, read through the node proxy of the API server.
+// Nodes whose summary can not be read are logged as a warning with the consequence and left out.
+func nodeSummaries(clientset *kubernetes.Clientset, nodes *corev1.NodeList, consequence string) map[string]*kube.Summary {
+	defer startStage("kubelet summary fetch")()
+	summaries := make(map[string]*kube.Summary, len(nodes.Items))
+	var unread []string
+	for _, node := range nodes.Items {
+		summary, err := kube.GetNodeSummary(clientset, node.Name)
+		if err != nil {
+			unread = append(unread, node.Name)
+			continue
+		}
+		summaries[node.Name] = summary
+	}
+	if len(unread) > 0 {
+		logger.Warning("failed to read the kubelet stats summary of some nodes, "+consequence, "nodes", unread, "count", len(unread))
+	}
+	return summaries
+}
+
+// Creates the clientset of the kubeconfig, recording or replaying its API responses with --record or --replay and
+// retrying transient API errors with --retries
+func createClientSet(cmd *cobra.Command) (*kubernetes.Clientset, error) {
+	recordDir, _ := cmd.Flags().GetString("record")
+	replayDir, _ := cmd.Flags().GetString("replay")
+	if recordDir != "" && replayDir != "" {
+		return nil, fmt.Errorf("--record and --replay can not be combined")
+	}
+	retries, _ := cmd.Flags().GetInt("retries")
+	retryDelay, _ := cmd.Flags().GetDuration("retry-delay")
+	qps, _ := cmd.Flags().GetFloat32("qps")
+	burst, _ := cmd.Flags().GetInt("burst")
+	impersonateUID, _ := cmd.Flags().GetString("as-uid")
+	if qps > 0 && burst < 1 {
+		return nil, fmt.Errorf("--burst must be at least 1, got %d", burst)
+	}
+	clientset, err := kube.CreateClientSet(KubernetesConfigFlags, kube.ClientOptions{
+		RecordDir:      recordDir,
+		ReplayDir:      replayDir,
+		Retries:        retries,
+		RetryDelay:     retryDelay,
+		QPS:            qps,
+		Burst:          burst,
+		Stats:          apiStats,
+		ImpersonateUID: impersonateUID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create clientset")
+	}
+	return clientset, nil
+}
+
+// Prints the API requests of a run and the time they were held back, so slow collections can be tuned with --qps
+func printAPIStats(w io.Writer, cmd *cobra.Command, elapsed time.Duration) {
+	if apiStats == nil || apiStats.Requests == 0 {
+		return
+	}
+	fmt.Fprintf(w, "API requests: %d in %s\n", apiStats.Requests, elapsed.Round(time.Millisecond))
+	if apiStats.Throttled > 0 {
+		qps, _ := cmd.Flags().GetFloat32("qps")
+		burst, _ := cmd.Flags().GetInt("burst")
+		fmt.Fprintf(w, "Client-side throttling: %d requests waited %s (--qps %g --burst %d), raise --qps and --burst to collect faster\n", apiStats.Throttled, apiStats.ThrottleWait.Round(time.Millisecond), qps, burst)
+	}
+	if apiStats.TooManyRequests > 0 {
+		fmt.Fprintf(w, "Server throttling: %d responses were 429 Too Many Requests, lower --qps to be gentler on the API server\n", apiStats.TooManyRequests)
+	}
+	if apiStats.Retries > 0 {
+		fmt.Fprintf(w, "Retries: %d requests were retried after waiting %s\n", apiStats.Retries, apiStats.RetryWait.Round(time.Millisecond))
+	}
+}
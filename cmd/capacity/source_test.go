@@ -0,0 +1,116 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+const cacheTestKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+- name: staging
+  cluster:
+    server: https://staging.example.com:6443
+users:
+- name: admin
+  user:
+    token: token
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+current-context: prod
+`
+
+type cacheTestRun struct {
+	kubeconfig    string
+	context       string
+	as            string
+	asUID         string
+	fieldSelector string
+}
+
+// The cache directory of a run, with the kubeconfig flags of the run
+func testClusterCacheDir(t *testing.T, run cacheTestRun) string {
+	configFlags := KubernetesConfigFlags
+	t.Cleanup(func() { KubernetesConfigFlags = configFlags })
+	KubernetesConfigFlags = genericclioptions.NewConfigFlags(false)
+	*KubernetesConfigFlags.KubeConfig, *KubernetesConfigFlags.Context, *KubernetesConfigFlags.Impersonate = run.kubeconfig, run.context, run.as
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringP("context", "", run.context, "")
+	cmd.Flags().StringP("from", "", "", "")
+	cmd.Flags().StringP("as-uid", "", run.asUID, "")
+	cmd.Flags().StringP("field-selector", "", run.fieldSelector, "")
+	dir, err := clusterCacheDir(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestClusterCacheDir(t *testing.T) {
+	home := t.TempDir()
+	setenv(t, map[string]string{"HOME": home})
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := ioutil.WriteFile(kubeconfig, []byte(cacheTestKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The same context name pointing at another API server
+	otherKubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := ioutil.WriteFile(otherKubeconfig, []byte(strings.Replace(cacheTestKubeconfig, "prod.example.com", "prod-2.example.com", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	run := cacheTestRun{kubeconfig: kubeconfig, context: "prod"}
+	dir := testClusterCacheDir(t, run)
+	if filepath.Dir(dir) != filepath.Join(home, ".kube", "cache", "kubesize") {
+		t.Errorf("cache directory %s is not under ~/.kube/cache/kubesize", dir)
+	}
+	if again := testClusterCacheDir(t, run); again != dir {
+		t.Errorf("the cache directory of the same run changed from %s to %s", dir, again)
+	}
+	for name, other := range map[string]cacheTestRun{
+		"context":         {kubeconfig: kubeconfig, context: "staging"},
+		"API server":      {kubeconfig: otherKubeconfig, context: "prod"},
+		"impersonation":   {kubeconfig: kubeconfig, context: "prod", as: "viewer"},
+		"impersonate uid": {kubeconfig: kubeconfig, context: "prod", asUID: "1234"},
+		"field selector":  {kubeconfig: kubeconfig, context: "prod", fieldSelector: "spec.nodeName=worker-1"},
+	} {
+		if otherDir := testClusterCacheDir(t, other); otherDir == dir {
+			t.Errorf("runs with another %s share the cache directory %s", name, dir)
+		}
+	}
+	// Without --context the current context of the kubeconfig is the context of the run
+	if current := testClusterCacheDir(t, cacheTestRun{kubeconfig: kubeconfig}); current != dir {
+		t.Errorf("the current context has cache directory %s, expected the %s of the prod context", current, dir)
+	}
+}
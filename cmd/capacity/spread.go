@@ -0,0 +1,88 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/manifest"
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/akrzos/kubeSize/internal/simulate"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Finds the replicas each workload with DoNotSchedule topology spread constraints can scale to by placing its pods one
+// at a time on the current cluster state, once with and once without the PodTopologySpread filter. Each workload is
+// scaled on its own, without the pods of the other workloads.
+func getSpreadFitData(workloads []manifest.Workload, nodes *corev1.NodeList, pods *corev1.PodList, filter kubesize.PodFilter) []output.SpreadFitData {
+	spreads := make([]output.SpreadFitData, 0)
+	// Every pod takes a pod slot, so no workload scales past the slots of all nodes
+	maxPods := 0
+	nodeLabels := make(map[string]map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		maxPods += int(node.Status.Allocatable.Pods().Value())
+		nodeLabels[node.Name] = node.Labels
+	}
+	for _, workload := range workloads {
+		constraint, ok := firstRequiredConstraint(workload.TopologySpreadConstraints)
+		if !ok {
+			continue
+		}
+		spread := output.SpreadFitData{Kind: workload.Kind, Namespace: workload.Namespace, Name: workload.Name, PodCount: workload.Replicas, TopologyKey: constraint.TopologyKey, Domains: make(map[string]int)}
+
+		cluster := simulate.NewCluster(nodes, pods, filter)
+		placedNodes, err := scaleOut(cluster, workload, maxPods)
+		spread.MaxPodCount = len(placedNodes)
+		if err != nil {
+			spread.Reason = err.Error()
+		}
+		for _, nodeName := range placedNodes {
+			spread.Domains[nodeLabels[nodeName][constraint.TopologyKey]]++
+		}
+
+		headroom := simulate.NewCluster(nodes, pods, filter)
+		headroom.RemovePlugin("PodTopologySpread")
+		headroomNodes, _ := scaleOut(headroom, workload, maxPods)
+		spread.HeadroomPodCount = len(headroomNodes)
+
+		spread.Fits = spread.PodCount <= spread.MaxPodCount
+		spreads = append(spreads, spread)
+	}
+	return spreads
+}
+
+// Places pods of the workload until one can not be placed, returning the nodes of the placed pods and why the next
+// pod could not be placed
+func scaleOut(cluster *simulate.Cluster, workload manifest.Workload, maxPods int) ([]string, error) {
+	placedNodes := make([]string, 0)
+	for i := 0; i < maxPods; i++ {
+		nodeName, err := cluster.Place(newSimulatedPod(workload, i))
+		if err != nil {
+			return placedNodes, err
+		}
+		placedNodes = append(placedNodes, nodeName)
+	}
+	return placedNodes, nil
+}
+
+// ScheduleAnyway constraints only score nodes, they never limit the replicas
+func firstRequiredConstraint(constraints []manifest.TopologySpreadConstraint) (manifest.TopologySpreadConstraint, bool) {
+	for _, constraint := range constraints {
+		if constraint.WhenUnsatisfiable != "ScheduleAnyway" {
+			return constraint, true
+		}
+	}
+	return manifest.TopologySpreadConstraint{}, false
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Exit codes of --exit-code, 1 remains the exit code of errors
+const (
+	exitCodeWarning  int = 2
+	exitCodeCritical int = 3
+)
+
+// Threshold breaches of the last run
+var thresholdBreaches []output.Breach
+
+func breachExitCode(breaches []output.Breach) int {
+	exitCode := 0
+	for _, breach := range breaches {
+		if breach.Level == output.LevelCritical {
+			return exitCodeCritical
+		}
+		exitCode = exitCodeWarning
+	}
+	return exitCode
+}
+
+// Checks the percent of allocatable requested of each resource against the thresholds of the groups, ephemeral
+// storage is only checked when it is displayed
+func checkThresholds(displayOptions output.DisplayOptions, nonTermPodCount int, allocatablePods resource.Quantity, requests map[string][2]resource.Quantity, groups ...string) []output.Breach {
+	breaches := make([]output.Breach, 0)
+	percents := map[string]float64{output.ThresholdPods: 0}
+	if allocatablePods.Value() > 0 {
+		percents[output.ThresholdPods] = float64(nonTermPodCount) / float64(allocatablePods.Value()) * 100
+	}
+	for resourceName, requested := range requests {
+		if resourceName == output.ThresholdEphemeralStorage && !displayOptions.EphemeralStorage {
+			continue
+		}
+		percents[resourceName] = capacity.Percent(requested[0], requested[1])
+	}
+	for _, resourceName := range []string{output.ThresholdPods, output.ThresholdCPU, output.ThresholdMemory, output.ThresholdEphemeralStorage} {
+		percent, ok := percents[resourceName]
+		if !ok {
+			continue
+		}
+		if breach := displayOptions.CheckThreshold(resourceName, percent, groups...); breach != nil {
+			breaches = append(breaches, *breach)
+		}
+	}
+	return breaches
+}
+
+func clusterBreaches(displayOptions output.DisplayOptions, data *output.ClusterCapacityData, groups ...string) []output.Breach {
+	return checkThresholds(displayOptions, data.TotalNonTermPodCount, data.TotalAllocatablePods, map[string][2]resource.Quantity{
+		output.ThresholdCPU:              {data.TotalRequestsCPU, data.TotalAllocatableCPU},
+		output.ThresholdMemory:           {data.TotalRequestsMemory, data.TotalAllocatableMemory},
+		output.ThresholdEphemeralStorage: {data.TotalRequestsEphemeralStorage, data.TotalAllocatableEphemeralStorage},
+	}, groups...)
+}
+
+// Pseudo roles other than *total* are skipped, *total* is checked like the cluster
+func nodeRoleBreaches(displayOptions output.DisplayOptions, nodeRoleCapacityData map[string]*output.ClusterCapacityData, roleNames []string) []output.Breach {
+	breaches := make([]output.Breach, 0)
+	for _, role := range roleNames {
+		if strings.HasPrefix(role, "*") && role != "*total*" {
+			continue
+		}
+		breaches = append(breaches, clusterBreaches(displayOptions, nodeRoleCapacityData[role], role)...)
+	}
+	return breaches
+}
+
+// Pseudo nodes are skipped, nodes match rules by name or role
+func nodeBreaches(displayOptions output.DisplayOptions, nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string) []output.Breach {
+	breaches := make([]output.Breach, 0)
+	for _, nodeName := range nodeNames {
+		if strings.HasPrefix(nodeName, "*") {
+			continue
+		}
+		data := nodesCapacityData[nodeName]
+		breaches = append(breaches, checkThresholds(displayOptions, data.TotalNonTermPodCount, data.TotalAllocatablePods, map[string][2]resource.Quantity{
+			output.ThresholdCPU:              {data.TotalRequestsCPU, data.TotalAllocatableCPU},
+			output.ThresholdMemory:           {data.TotalRequestsMemory, data.TotalAllocatableMemory},
+			output.ThresholdEphemeralStorage: {data.TotalRequestsEphemeralStorage, data.TotalAllocatableEphemeralStorage},
+		}, append([]string{nodeName}, data.Roles.List()...)...)...)
+	}
+	return breaches
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/source"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+)
+
+// Time spent in each stage of the current run in the order the stages first ran, printed with --timing
+type runTimings struct {
+	stages    []string
+	durations map[string]time.Duration
+}
+
+var timings *runTimings
+
+func newRunTimings() *runTimings {
+	return &runTimings{durations: map[string]time.Duration{}}
+}
+
+// Starts timing a stage of the run, the returned func stops it. Stages that run more than once add up.
+func startStage(stage string) func() {
+	start := time.Now()
+	return func() {
+		if timings == nil {
+			return
+		}
+		if _, ok := timings.durations[stage]; !ok {
+			timings.stages = append(timings.stages, stage)
+		}
+		timings.durations[stage] += time.Since(start)
+	}
+}
+
+// Prints the time of each stage, the time not spent in a stage is the aggregation of the data
+func printTimings(w io.Writer, elapsed time.Duration) {
+	if timings == nil {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tTIME\t")
+	aggregation := elapsed
+	for _, stage := range timings.stages {
+		if stage == "render" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t\n", stage, timings.durations[stage].Round(time.Microsecond))
+		aggregation -= timings.durations[stage]
+	}
+	aggregation -= timings.durations["render"]
+	fmt.Fprintf(tw, "aggregation\t%s\t\n", aggregation.Round(time.Microsecond))
+	fmt.Fprintf(tw, "render\t%s\t\n", timings.durations["render"].Round(time.Microsecond))
+	fmt.Fprintf(tw, "total\t%s\t\n", elapsed.Round(time.Microsecond))
+	tw.Flush()
+}
+
+// Times the reads of a source
+type timedSource struct {
+	source.CapacitySource
+}
+
+func (s timedSource) Nodes() (*corev1.NodeList, error) {
+	defer startStage("node list")()
+	return s.CapacitySource.Nodes()
+}
+
+func (s timedSource) Pods(namespace string) (*corev1.PodList, error) {
+	defer startStage("pod list")()
+	return s.CapacitySource.Pods(namespace)
+}
+
+func (s timedSource) Namespaces(name string) (*corev1.NamespaceList, error) {
+	defer startStage("namespace list")()
+	return s.CapacitySource.Namespaces(name)
+}
+
+func (s timedSource) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	defer startStage("resource quota list")()
+	return s.CapacitySource.ResourceQuotas(namespace)
+}
+
+func (s timedSource) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	defer startStage("pod disruption budget list")()
+	return s.CapacitySource.PodDisruptionBudgets(namespace)
+}
@@ -0,0 +1,319 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/output"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var usageCmd = &cobra.Command{
+	Use:     "usage",
+	Aliases: []string{"u"},
+	Short:   "Compare requested, used and limit cpu and memory",
+	Long:    `Compare requested, actually used (from metrics-server or the kubelets) and limit cpu and memory grouped by cluster, node role, node or namespace`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return output.ValidateOutput(*cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		displayOptions, err := getDisplayOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		groupBy, _ := cmd.Flags().GetString("group-by")
+		if groupBy != "cluster" && groupBy != "node-role" && groupBy != "node" && groupBy != "namespace" {
+			return fmt.Errorf("group-by \"%s\" is invalid. Valid values are [cluster node-role node namespace]", groupBy)
+		}
+
+		usageSource, err := getUsageSource(cmd)
+		if err != nil {
+			return err
+		}
+		// Only the kubelets report ephemeral storage usage
+		kubeletSource := usageSource == "kubelet"
+		if displayOptions.EphemeralStorage && !kubeletSource {
+			return errors.New("ephemeral-storage usage requires --usage-source kubelet")
+		}
+
+		clientset, err := liveClientSet(cmd)
+		if err != nil {
+			return err
+		}
+
+		stopTiming := startStage("node list")
+		nodes, err := clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list nodes")
+		}
+
+		stopTiming = startStage("pod list")
+		pods, err := clientset.CoreV1().Pods("").List(metav1.ListOptions{})
+		stopTiming()
+		if err != nil {
+			return errors.Wrap(err, "failed to list pods")
+		}
+
+		filter := newPodFilter(displayOptions)
+		usageData := make(map[string]*output.UsageData)
+		var names []string
+
+		switch groupBy {
+		case "namespace":
+			stopTiming = startStage("namespace list")
+			namespaces, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+			stopTiming()
+			if err != nil {
+				return errors.Wrap(err, "failed to list namespaces")
+			}
+			podUsage, err := getUsage(clientset, nodes, usageSource, true)
+			if err != nil {
+				return err
+			}
+			namespacesUsage := make(map[string]kube.ResourceUsage)
+			for _, pod := range pods.Items {
+				usage := namespacesUsage[pod.Namespace]
+				usage.CPU.Add(podUsage[pod.Namespace+"/"+pod.Name].CPU)
+				usage.Memory.Add(podUsage[pod.Namespace+"/"+pod.Name].Memory)
+				usage.EphemeralStorage.Add(podUsage[pod.Namespace+"/"+pod.Name].EphemeralStorage)
+				namespacesUsage[pod.Namespace] = usage
+			}
+			// A namespace can use any node, so its percents are of the cluster allocatable
+			clusterCapacityData := kubesize.ClusterCapacity(nodes, pods, filter)
+			namespaceCapacityData, namespaceNames := kubesize.NamespaceCapacity(namespaces, pods, true, filter)
+			for _, namespace := range namespaceNames {
+				data := namespaceCapacityData[namespace]
+				usage := namespacesUsage[namespace]
+				if namespace == "*total*" {
+					for _, namespaceUsage := range namespacesUsage {
+						usage.CPU.Add(namespaceUsage.CPU)
+						usage.Memory.Add(namespaceUsage.Memory)
+						usage.EphemeralStorage.Add(namespaceUsage.EphemeralStorage)
+					}
+				}
+				usageData[namespace] = newUsageData(clusterCapacityData.TotalAllocatableCPU, data.TotalRequestsCPU, data.TotalLimitsCPU, clusterCapacityData.TotalAllocatableMemory, data.TotalRequestsMemory, data.TotalLimitsMemory, usage)
+				if kubeletSource {
+					usageData[namespace].EphemeralStorage = newStorageUsageData(clusterCapacityData.TotalAllocatableEphemeralStorage, data.TotalRequestsEphemeralStorage, data.TotalLimitsEphemeralStorage, usage.EphemeralStorage)
+				}
+			}
+			names = namespaceNames
+		default:
+			nodeUsage, err := getUsage(clientset, nodes, usageSource, false)
+			if err != nil {
+				return err
+			}
+			nodesCapacityData, nodeNames, _ := kubesize.NodeCapacity(nodes, pods, false, false, false, filter)
+			switch groupBy {
+			case "node":
+				for _, nodeName := range nodeNames {
+					data := nodesCapacityData[nodeName]
+					usageData[nodeName] = newUsageData(data.TotalAllocatableCPU, data.TotalRequestsCPU, data.TotalLimitsCPU, data.TotalAllocatableMemory, data.TotalRequestsMemory, data.TotalLimitsMemory, nodeUsage[nodeName])
+					if kubeletSource {
+						usageData[nodeName].EphemeralStorage = newStorageUsageData(data.TotalAllocatableEphemeralStorage, data.TotalRequestsEphemeralStorage, data.TotalLimitsEphemeralStorage, nodeUsage[nodeName].EphemeralStorage)
+					}
+				}
+				names = nodeNames
+			case "node-role":
+				rolesUsage := make(map[string]kube.ResourceUsage)
+				for _, nodeName := range nodeNames {
+					for _, role := range append(nodesCapacityData[nodeName].Roles.List(), "*total*") {
+						usage := rolesUsage[role]
+						usage.CPU.Add(nodeUsage[nodeName].CPU)
+						usage.Memory.Add(nodeUsage[nodeName].Memory)
+						usage.EphemeralStorage.Add(nodeUsage[nodeName].EphemeralStorage)
+						rolesUsage[role] = usage
+					}
+				}
+				nodeRoleCapacityData, roleNames := kubesize.NodeRoleCapacity(nodes, pods, false, true, false, filter)
+				for _, role := range roleNames {
+					data := nodeRoleCapacityData[role]
+					usageData[role] = newUsageData(data.TotalAllocatableCPU, data.TotalRequestsCPU, data.TotalLimitsCPU, data.TotalAllocatableMemory, data.TotalRequestsMemory, data.TotalLimitsMemory, rolesUsage[role])
+					if kubeletSource {
+						usageData[role].EphemeralStorage = newStorageUsageData(data.TotalAllocatableEphemeralStorage, data.TotalRequestsEphemeralStorage, data.TotalLimitsEphemeralStorage, rolesUsage[role].EphemeralStorage)
+					}
+				}
+				capacity.SortRoleNames(roleNames, displayOptions.PinnedRoles, displayOptions.NoneLast)
+				names = roleNames
+			case "cluster":
+				var usage kube.ResourceUsage
+				for _, nodeName := range nodeNames {
+					usage.CPU.Add(nodeUsage[nodeName].CPU)
+					usage.Memory.Add(nodeUsage[nodeName].Memory)
+					usage.EphemeralStorage.Add(nodeUsage[nodeName].EphemeralStorage)
+				}
+				data := kubesize.ClusterCapacity(nodes, pods, filter)
+				usageData["*total*"] = newUsageData(data.TotalAllocatableCPU, data.TotalRequestsCPU, data.TotalLimitsCPU, data.TotalAllocatableMemory, data.TotalRequestsMemory, data.TotalLimitsMemory, usage)
+				if kubeletSource {
+					usageData["*total*"].EphemeralStorage = newStorageUsageData(data.TotalAllocatableEphemeralStorage, data.TotalRequestsEphemeralStorage, data.TotalLimitsEphemeralStorage, usage.EphemeralStorage)
+				}
+				names = []string{"*total*"}
+			}
+		}
+
+		if displayOptions.Efficiency {
+			sortByEfficiency(usageData, names)
+		}
+
+		return writeOutput(cmd, displayOptions, func(displayOptions output.DisplayOptions) error {
+			return output.DisplayUsageData(usageData, names, groupBy, displayOptions)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.RunE = watchRunE(usageCmd.RunE)
+	usageCmd.Flags().StringP("group-by", "g", "node-role", "Group usage by one of: cluster|node-role|node|namespace")
+	usageCmd.RegisterFlagCompletionFunc("group-by", completeValues("cluster", "node-role", "node", "namespace"))
+	usageCmd.Flags().BoolP("efficiency", "", false, "Include used / requests efficiency columns and rank the least efficient groups first")
+	usageCmd.Flags().BoolP("ephemeral-storage", "e", false, "Include ephemeral storage usage data in table output, requires --usage-source kubelet")
+	addUsageSourceFlag(usageCmd)
+}
+
+func addUsageSourceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("usage-source", "", "metrics", "Read usage from one of: metrics|kubelet")
+	cmd.RegisterFlagCompletionFunc("usage-source", completeValues("metrics", "kubelet"))
+}
+
+func getUsageSource(cmd *cobra.Command) (string, error) {
+	usageSource, _ := cmd.Flags().GetString("usage-source")
+	if usageSource != "metrics" && usageSource != "kubelet" {
+		return "", fmt.Errorf("usage-source \"%s\" is invalid. Valid values are [metrics kubelet]", usageSource)
+	}
+	return usageSource, nil
+}
+
+// Returns the usage of each node, or with pods of each pod by "namespace/name", from the metrics API or from the summary
+// API of the kubelet of each node for clusters without metrics-server. Nodes whose summary can not be read have no
+// usage.
+func getUsage(clientset *kubernetes.Clientset, nodes *corev1.NodeList, usageSource string, pods bool) (map[string]kube.ResourceUsage, error) {
+	if usageSource == "metrics" {
+		defer startStage("metrics fetch")()
+		if pods {
+			return kube.GetPodMetrics(clientset)
+		}
+		return kube.GetNodeMetrics(clientset)
+	}
+	usage := make(map[string]kube.ResourceUsage)
+	for nodeName, summary := range nodeSummaries(clientset, nodes, "their usage is left out") {
+		if !pods {
+			usage[nodeName] = summary.NodeUsage()
+			continue
+		}
+		for name, podUsage := range summary.PodUsage() {
+			usage[name] = podUsage
+		}
+	}
+	return usage, nil
+}
+
+// Populates "Human" readable values and percents of allocatable
+func newUsageData(allocatableCPU, requestsCPU, limitsCPU, allocatableMemory, requestsMemory, limitsMemory resource.Quantity, usage kube.ResourceUsage) *output.UsageData {
+	return &output.UsageData{
+		AllocatableCPU:          allocatableCPU,
+		AllocatableCPUCores:     capacity.ReadableCPU(allocatableCPU),
+		RequestsCPU:             requestsCPU,
+		RequestsCPUCores:        capacity.ReadableCPU(requestsCPU),
+		RequestsCPUPercent:      capacity.Percent(requestsCPU, allocatableCPU),
+		UsedCPU:                 usage.CPU,
+		UsedCPUCores:            capacity.ReadableCPU(usage.CPU),
+		UsedCPUPercent:          capacity.Percent(usage.CPU, allocatableCPU),
+		LimitsCPU:               limitsCPU,
+		LimitsCPUCores:          capacity.ReadableCPU(limitsCPU),
+		LimitsCPUPercent:        capacity.Percent(limitsCPU, allocatableCPU),
+		AllocatableMemory:       allocatableMemory,
+		AllocatableMemoryGiB:    capacity.ReadableMem(allocatableMemory),
+		RequestsMemory:          requestsMemory,
+		RequestsMemoryGiB:       capacity.ReadableMem(requestsMemory),
+		RequestsMemoryPercent:   capacity.Percent(requestsMemory, allocatableMemory),
+		UsedMemory:              usage.Memory,
+		UsedMemoryGiB:           capacity.ReadableMem(usage.Memory),
+		UsedMemoryPercent:       capacity.Percent(usage.Memory, allocatableMemory),
+		LimitsMemory:            limitsMemory,
+		LimitsMemoryGiB:         capacity.ReadableMem(limitsMemory),
+		LimitsMemoryPercent:     capacity.Percent(limitsMemory, allocatableMemory),
+		CPUEfficiencyPercent:    capacity.Percent(usage.CPU, requestsCPU),
+		MemoryEfficiencyPercent: capacity.Percent(usage.Memory, requestsMemory),
+	}
+}
+
+// Ephemeral storage percents are of allocatable
+func newStorageUsageData(allocatable, requests, limits, used resource.Quantity) *output.StorageUsageData {
+	return &output.StorageUsageData{
+		Allocatable:     allocatable,
+		AllocatableGB:   capacity.ReadableStorage(allocatable),
+		Requests:        requests,
+		RequestsGB:      capacity.ReadableStorage(requests),
+		RequestsPercent: capacity.Percent(requests, allocatable),
+		Used:            used,
+		UsedGB:          capacity.ReadableStorage(used),
+		UsedPercent:     capacity.Percent(used, allocatable),
+		Limits:          limits,
+		LimitsGB:        capacity.ReadableStorage(limits),
+		LimitsPercent:   capacity.Percent(limits, allocatable),
+	}
+}
+
+// Sorts names in place by the average efficiency of the requested resources, worst offenders first. Groups without
+// requests have no efficiency and follow, pseudo entries such as *total* stay last.
+func sortByEfficiency(usageData map[string]*output.UsageData, names []string) {
+	efficiency := func(name string) (float64, bool) {
+		data := usageData[name]
+		var sum float64
+		var count int
+		if !data.RequestsCPU.IsZero() {
+			sum += data.CPUEfficiencyPercent
+			count++
+		}
+		if !data.RequestsMemory.IsZero() {
+			sum += data.MemoryEfficiencyPercent
+			count++
+		}
+		if count == 0 {
+			return 0, false
+		}
+		return sum / float64(count), true
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		iPseudo, jPseudo := strings.HasPrefix(names[i], "*"), strings.HasPrefix(names[j], "*")
+		if iPseudo != jPseudo {
+			return jPseudo
+		}
+		if iPseudo {
+			return false
+		}
+		iEfficiency, iOk := efficiency(names[i])
+		jEfficiency, jOk := efficiency(names[j])
+		if iOk != jOk {
+			return iOk
+		}
+		return iEfficiency < jEfficiency
+	})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Only the fields of the admission.k8s.io AdmissionReview that are used. The vendored v1beta1 types lack the warnings
+// of v1, the review is answered in the apiVersion it was sent in
+type Review struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Request    *Request  `json:"request,omitempty"`
+	Response   *Response `json:"response,omitempty"`
+}
+
+type Request struct {
+	UID       types.UID               `json:"uid"`
+	Kind      metav1.GroupVersionKind `json:"kind"`
+	Namespace string                  `json:"namespace"`
+	Name      string                  `json:"name"`
+	Operation string                  `json:"operation"`
+	Object    runtime.RawExtension    `json:"object"`
+}
+
+type Response struct {
+	UID      types.UID      `json:"uid"`
+	Allowed  bool           `json:"allowed"`
+	Result   *metav1.Status `json:"status,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// Allows the request, with warnings if any
+func Allow(warnings ...string) *Response {
+	return &Response{Allowed: true, Warnings: warnings}
+}
+
+// Rejects the request with a 403 Forbidden message
+func Deny(message string) *Response {
+	return &Response{Allowed: false, Result: &metav1.Status{Status: metav1.StatusFailure, Message: message, Reason: metav1.StatusReasonForbidden, Code: http.StatusForbidden}}
+}
+
+// Serves AdmissionReview requests with the response of validate
+func Handler(validate func(request *Request) *Response) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		review := new(Review)
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+			http.Error(w, fmt.Sprintf("failed to decode AdmissionReview: %v", err), http.StatusBadRequest)
+			return
+		}
+		response := validate(review.Request)
+		response.UID = review.Request.UID
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Review{APIVersion: review.APIVersion, Kind: review.Kind, Response: response})
+	})
+}
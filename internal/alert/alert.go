@@ -0,0 +1,77 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package alert
+
+import (
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/output"
+)
+
+// Alert statuses
+const (
+	StatusFiring   string = "firing"
+	StatusResolved string = "resolved"
+)
+
+// Threshold breaches that started, changed level or cleared since the previous sample
+type Notification struct {
+	Timestamp time.Time
+	Alerts    []Alert
+}
+
+type Alert struct {
+	Status string
+	output.Breach
+}
+
+type Notifier interface {
+	Notify(notification Notification) error
+}
+
+// Rendered output of a sample sent on a schedule
+type Summary struct {
+	Timestamp time.Time
+	Text      string
+}
+
+// Notifiers that also send scheduled summaries
+type Summarizer interface {
+	Summarize(summary Summary) error
+}
+
+// Returns the alerts between the breaches of two samples, a breach that changes level fires again at the new level
+func Transitions(previous []output.Breach, current []output.Breach) []Alert {
+	alerts := make([]Alert, 0)
+	previousLevels := make(map[string]string, len(previous))
+	for _, breach := range previous {
+		previousLevels[breach.Group+"/"+breach.Resource] = breach.Level
+	}
+	currentKeys := make(map[string]bool, len(current))
+	for _, breach := range current {
+		key := breach.Group + "/" + breach.Resource
+		currentKeys[key] = true
+		if previousLevels[key] != breach.Level {
+			alerts = append(alerts, Alert{Status: StatusFiring, Breach: breach})
+		}
+	}
+	for _, breach := range previous {
+		if !currentKeys[breach.Group+"/"+breach.Resource] {
+			alerts = append(alerts, Alert{Status: StatusResolved, Breach: breach})
+		}
+	}
+	return alerts
+}
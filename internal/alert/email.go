@@ -0,0 +1,75 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Sends alerts and summaries as HTML email over SMTP, with PLAIN auth when a username is set
+type Email struct {
+	Server   string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (e *Email) Notify(notification Notification) error {
+	var body bytes.Buffer
+	body.WriteString("<ul>\n")
+	for _, alert := range notification.Alerts {
+		group := alert.Group
+		if group == "" {
+			group = "cluster"
+		}
+		fmt.Fprintf(&body, "<li>%s %s requests %.1f%% %s (%s threshold %.0f%%)</li>\n", html.EscapeString(group), alert.Resource, alert.Percent, alert.Status, alert.Level, alert.Threshold)
+	}
+	body.WriteString("</ul>\n")
+	return e.send("kubeSize capacity alert "+notification.Timestamp.Format(time.RFC3339), body.String())
+}
+
+// The summary is sent as a preformatted capacity report
+func (e *Email) Summarize(summary Summary) error {
+	return e.send("kubeSize capacity report "+summary.Timestamp.Format(time.RFC3339), "<pre>\n"+html.EscapeString(summary.Text)+"</pre>\n")
+}
+
+func (e *Email) send(subject string, htmlBody string) error {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", e.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	message.WriteString("<html><body>\n" + htmlBody + "</body></html>\r\n")
+
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Server)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	return smtp.SendMail(e.Server, auth, e.From, e.To, message.Bytes())
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron schedule of minute, hour, day of month, month and day of week fields. Fields support *, lists, ranges and
+// steps such as "0 8 * * 1-5" or "*/30 * * * *". Day of month and day of week both have to match.
+type Schedule struct {
+	fields [5]map[int]bool
+}
+
+var scheduleBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+func ParseSchedule(spec string) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("schedule \"%s\" must have 5 fields: minute hour day-of-month month day-of-week", spec)
+	}
+	schedule := new(Schedule)
+	for i, part := range parts {
+		values, err := parseScheduleField(part, scheduleBounds[i][0], scheduleBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("schedule \"%s\": %v", spec, err)
+		}
+		schedule.fields[i] = values
+	}
+	return schedule, nil
+}
+
+func parseScheduleField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in \"%s\"", item)
+			}
+			item = item[:i]
+		}
+		low, high := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value \"%s\"", item)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value \"%s\"", item)
+				}
+			}
+			if low < min || high > max || low > high {
+				return nil, fmt.Errorf("\"%s\" is out of range %d-%d", item, min, max)
+			}
+		}
+		for value := low; value <= high; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// Returns the first minute matching the schedule after t
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	// Every schedule matches at least once within 4 years (Feb 29)
+	for limit := next.AddDate(4, 0, 1); next.Before(limit); next = next.Add(time.Minute) {
+		if s.fields[0][next.Minute()] && s.fields[1][next.Hour()] && s.fields[2][next.Day()] && s.fields[3][int(next.Month())] && s.fields[4][int(next.Weekday())] {
+			return next
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultSlackTemplate = `{{range .Alerts}}{{if eq .Status "firing"}}:red_circle:{{else}}:large_green_circle:{{end}} {{with .Group}}{{.}} {{end}}{{.Resource}} requests {{printf "%.1f" .Percent}}% {{.Status}} ({{.Level}} threshold {{.Threshold}}%)
+{{end}}`
+
+// Posts messages to a Slack incoming webhook, alerts are rendered with a text/template of the Notification
+type Slack struct {
+	URL      string
+	Channel  string
+	Template *template.Template
+	Client   *http.Client
+}
+
+// The template is read from templateFile, the default template is used when templateFile is empty
+func NewSlack(url string, channel string, templateFile string) (*Slack, error) {
+	text := defaultSlackTemplate
+	if templateFile != "" {
+		data, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read slack template")
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("slack").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse slack template")
+	}
+	return &Slack{URL: url, Channel: channel, Template: tmpl, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (s *Slack) Notify(notification Notification) error {
+	var text bytes.Buffer
+	if err := s.Template.Execute(&text, notification); err != nil {
+		return err
+	}
+	return s.post(text.String())
+}
+
+// Posts a periodic capacity summary as a preformatted block
+func (s *Slack) Summarize(summary Summary) error {
+	return s.post("Capacity summary " + summary.Timestamp.Format(time.RFC3339) + "\n```\n" + summary.Text + "```")
+}
+
+func (s *Slack) post(text string) error {
+	message := struct {
+		Channel string `json:"channel,omitempty"`
+		Text    string `json:"text"`
+	}{Channel: s.Channel, Text: text}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return postJSON(s.Client, s.URL, body)
+}
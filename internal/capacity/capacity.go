@@ -15,7 +15,13 @@ limitations under the License.
 */
 package capacity
 
-import "k8s.io/apimachinery/pkg/api/resource"
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
 
 func StringInSlice(a string, list []string) bool {
 	for _, b := range list {
@@ -40,3 +46,76 @@ func ReadableStorage(storage resource.Quantity) float64 {
 	// Convert from KiB to GB (Gigabyte)
 	return float64(storage.Value()) / 1000 / 1000 / 1000
 }
+
+func AverageQuantity(quantity resource.Quantity, count int) resource.Quantity {
+	// Pods, memory and storage are averaged in whole units
+	if count == 0 {
+		return *resource.NewQuantity(0, quantity.Format)
+	}
+	return *resource.NewQuantity(quantity.Value()/int64(count), quantity.Format)
+}
+
+func AverageCPU(cpu resource.Quantity, count int) resource.Quantity {
+	// CPU is averaged in millicores
+	if count == 0 {
+		return *resource.NewMilliQuantity(0, cpu.Format)
+	}
+	return *resource.NewMilliQuantity(cpu.MilliValue()/int64(count), cpu.Format)
+}
+
+func Percent(used resource.Quantity, total resource.Quantity) float64 {
+	if total.IsZero() {
+		return 0
+	}
+	return float64(used.MilliValue()) / float64(total.MilliValue()) * 100
+}
+
+// Population standard deviation
+func StdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(squares / float64(len(values)))
+}
+
+// Orders role names in place: pinned roles first in the pinned order, then the remaining roles by name with
+// <none> last when noneLast is set. Pseudo entries (*total* etc.) keep their position at the end.
+// Comma separated role groups such as "master,worker" are ranked by their highest pinned role.
+func SortRoleNames(roleNames []string, pinnedRoles []string, noneLast bool) {
+	rank := func(roleName string) int {
+		if strings.HasPrefix(roleName, "*") || strings.HasPrefix(roleName, "~") {
+			return len(pinnedRoles) + 2
+		}
+		if noneLast && roleName == "<none>" {
+			return len(pinnedRoles) + 1
+		}
+		best := len(pinnedRoles)
+		for _, role := range strings.Split(roleName, ",") {
+			for i, pinnedRole := range pinnedRoles {
+				if role == pinnedRole && i < best {
+					best = i
+				}
+			}
+		}
+		return best
+	}
+	sort.SliceStable(roleNames, func(i, j int) bool {
+		rankI, rankJ := rank(roleNames[i]), rank(roleNames[j])
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		if rankI == len(pinnedRoles)+2 {
+			return false
+		}
+		return roleNames[i] < roleNames[j]
+	})
+}
@@ -16,15 +16,61 @@ limitations under the License.
 package kube
 
 import (
+	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/pkg/errors"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
-func CreateClientSet(kubernetesConfigFlags *genericclioptions.ConfigFlags) (*kubernetes.Clientset, error) {
-	config, err := kubernetesConfigFlags.ToRESTConfig()
+// Options of the clientset of the kubeconfig
+type ClientOptions struct {
+	// The API responses are saved to RecordDir when set, with ReplayDir set they are served from that recording
+	// instead of the cluster
+	RecordDir string
+	ReplayDir string
+	// Transient API errors are retried Retries times, RetryDelay is doubled after each attempt
+	Retries    int
+	RetryDelay time.Duration
+	// Client-side rate limit of the requests, the client-go defaults when zero and unlimited with a negative QPS
+	QPS   float32
+	Burst int
+	// Counts the requests and their throttling when set
+	Stats *RequestStats
+	// UID of the user impersonated with --as, which client-go has no setting for
+	ImpersonateUID string
+}
+
+func CreateClientSet(kubernetesConfigFlags *genericclioptions.ConfigFlags, options ClientOptions) (*kubernetes.Clientset, error) {
+	var config *rest.Config
+	var err error
+	if options.ReplayDir != "" {
+		config, err = replayConfig(options.ReplayDir)
+	} else {
+		config, err = restConfig(kubernetesConfigFlags)
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read kubeconfig")
+		return nil, err
+	}
+	if options.ImpersonateUID != "" && options.ReplayDir == "" {
+		if config.Impersonate.UserName == "" {
+			return nil, fmt.Errorf("impersonating a UID requires a user to impersonate, set --as")
+		}
+		impersonateUIDConfig(config, options.ImpersonateUID)
+	}
+	config.QPS = options.QPS
+	config.Burst = options.Burst
+	if options.Stats != nil {
+		statsConfig(config, options.Stats)
+	}
+	if options.RecordDir != "" {
+		recordConfig(config, options.RecordDir)
+	}
+	if options.ReplayDir == "" && options.Retries > 0 {
+		retryConfig(config, options.Retries, options.RetryDelay, options.Stats)
 	}
 
 	clientset, err := kubernetes.NewForConfig(config)
@@ -34,3 +80,55 @@ func CreateClientSet(kubernetesConfigFlags *genericclioptions.ConfigFlags) (*kub
 
 	return clientset, nil
 }
+
+// Without a kubeconfig or --server the service account of the pod is used, so the long running modes can run as a
+// Deployment. The kubeconfig loader would otherwise fall back to localhost:8080.
+func restConfig(kubernetesConfigFlags *genericclioptions.ConfigFlags) (*rest.Config, error) {
+	rawConfig, err := kubernetesConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read kubeconfig")
+	}
+	if len(rawConfig.Clusters) == 0 && *kubernetesConfigFlags.APIServer == "" {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "no kubeconfig found and failed to read in-cluster config")
+		}
+		// The kubeconfig overrides of --as and --as-group do not apply to the in-cluster config
+		if kubernetesConfigFlags.Impersonate != nil {
+			config.Impersonate.UserName = *kubernetesConfigFlags.Impersonate
+		}
+		if kubernetesConfigFlags.ImpersonateGroup != nil {
+			config.Impersonate.Groups = *kubernetesConfigFlags.ImpersonateGroup
+		}
+		return config, nil
+	}
+
+	config, err := kubernetesConfigFlags.ToRESTConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read kubeconfig")
+	}
+	return config, nil
+}
+
+// Impersonates the UID along with the user of the config
+func impersonateUIDConfig(config *rest.Config, uid string) {
+	wrapTransport := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrapTransport != nil {
+			rt = wrapTransport(rt)
+		}
+		return &uidImpersonator{uid: uid, next: rt}
+	}
+}
+
+type uidImpersonator struct {
+	uid  string
+	next http.RoundTripper
+}
+
+func (i *uidImpersonator) RoundTrip(request *http.Request) (*http.Response, error) {
+	// Round trippers must not modify the request
+	request = request.Clone(request.Context())
+	request.Header.Set("Impersonate-Uid", i.uid)
+	return i.next.RoundTrip(request)
+}
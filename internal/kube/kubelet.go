@@ -0,0 +1,130 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Only the fields of the kubelet configuration served by its configz endpoint that are used
+type KubeletConfig struct {
+	CPUManagerPolicy string `json:"cpuManagerPolicy"`
+	// Most pids of a pod, unlimited when unset or negative
+	PodPidsLimit *int64 `json:"podPidsLimit"`
+}
+
+// Only the fields of the kubelet summary API that are used
+type Summary struct {
+	Node struct {
+		CPU     *CPUStats    `json:"cpu"`
+		Memory  *MemoryStats `json:"memory"`
+		Fs      *FsStats     `json:"fs"`
+		Runtime *struct {
+			ImageFs *FsStats `json:"imageFs"`
+		} `json:"runtime"`
+		Rlimit *struct {
+			MaxPID  *int64 `json:"maxpid"`
+			CurProc *int64 `json:"curproc"`
+		} `json:"rlimit"`
+	} `json:"node"`
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		CPU              *CPUStats    `json:"cpu"`
+		Memory           *MemoryStats `json:"memory"`
+		EphemeralStorage *FsStats     `json:"ephemeral-storage"`
+	} `json:"pods"`
+}
+
+type CPUStats struct {
+	UsageNanoCores *uint64 `json:"usageNanoCores"`
+}
+
+// The working set is the memory the kubelet evicts on and the metrics API reports
+type MemoryStats struct {
+	WorkingSetBytes *uint64 `json:"workingSetBytes"`
+}
+
+// Bytes of a filesystem of the node, the kubelet omits those it can not read
+type FsStats struct {
+	AvailableBytes *uint64 `json:"availableBytes"`
+	CapacityBytes  *uint64 `json:"capacityBytes"`
+	UsedBytes      *uint64 `json:"usedBytes"`
+}
+
+// Returns the running configuration of the kubelet of a node, read through the node proxy of the API server
+func GetKubeletConfig(clientset *kubernetes.Clientset, nodeName string) (*KubeletConfig, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("configz").DoRaw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the kubelet configuration of node %s", nodeName)
+	}
+	configz := struct {
+		KubeletConfig KubeletConfig `json:"kubeletconfig"`
+	}{}
+	if err := json.Unmarshal(data, &configz); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the kubelet configuration of node %s", nodeName)
+	}
+	return &configz.KubeletConfig, nil
+}
+
+// Returns the resource usage of a node and its pods from the summary API of its kubelet, read through the node proxy of
+// the API server
+func GetNodeSummary(clientset *kubernetes.Clientset, nodeName string) (*Summary, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("stats", "summary").DoRaw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the kubelet stats summary of node %s", nodeName)
+	}
+	summary := new(Summary)
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the kubelet stats summary of node %s", nodeName)
+	}
+	return summary, nil
+}
+
+// Returns the usage of the node, its ephemeral storage usage is the used bytes of the node filesystem
+func (summary *Summary) NodeUsage() ResourceUsage {
+	return summaryUsage(summary.Node.CPU, summary.Node.Memory, summary.Node.Fs)
+}
+
+// Returns the usage of each pod of the node by "namespace/name"
+func (summary *Summary) PodUsage() map[string]ResourceUsage {
+	podUsage := make(map[string]ResourceUsage, len(summary.Pods))
+	for _, pod := range summary.Pods {
+		podUsage[pod.PodRef.Namespace+"/"+pod.PodRef.Name] = summaryUsage(pod.CPU, pod.Memory, pod.EphemeralStorage)
+	}
+	return podUsage
+}
+
+// Stats the kubelet could not read are left zero
+func summaryUsage(cpu *CPUStats, memory *MemoryStats, fs *FsStats) ResourceUsage {
+	var usage ResourceUsage
+	if cpu != nil && cpu.UsageNanoCores != nil {
+		usage.CPU = *resource.NewMilliQuantity(int64(*cpu.UsageNanoCores/1000000), resource.DecimalSI)
+	}
+	if memory != nil && memory.WorkingSetBytes != nil {
+		usage.Memory = *resource.NewQuantity(int64(*memory.WorkingSetBytes), resource.BinarySI)
+	}
+	if fs != nil && fs.UsedBytes != nil {
+		usage.EphemeralStorage = *resource.NewQuantity(int64(*fs.UsedBytes), resource.DecimalSI)
+	}
+	return usage
+}
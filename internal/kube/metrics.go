@@ -0,0 +1,89 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Resource usage reported by the metrics API (metrics-server) or the summary API of the kubelets
+type ResourceUsage struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+	// Only reported by the summary API of the kubelets
+	EphemeralStorage resource.Quantity
+}
+
+// Only the fields of the metrics.k8s.io/v1beta1 NodeMetrics and PodMetrics lists that are used, the metrics API is read
+// with the discovery REST client so the metrics clientset is not needed as a dependency
+type metricsList struct {
+	Items []struct {
+		metav1.ObjectMeta `json:"metadata"`
+		Usage             corev1.ResourceList `json:"usage"`
+		Containers        []struct {
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+func getMetrics(clientset *kubernetes.Clientset, resourceName string) (*metricsList, error) {
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1", resourceName).DoRaw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s metrics, is metrics-server installed", resourceName)
+	}
+	metrics := new(metricsList)
+	if err := json.Unmarshal(data, metrics); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s metrics", resourceName)
+	}
+	return metrics, nil
+}
+
+// Returns the usage of each node by node name
+func GetNodeMetrics(clientset *kubernetes.Clientset) (map[string]ResourceUsage, error) {
+	metrics, err := getMetrics(clientset, "nodes")
+	if err != nil {
+		return nil, err
+	}
+	nodeUsage := make(map[string]ResourceUsage, len(metrics.Items))
+	for _, item := range metrics.Items {
+		nodeUsage[item.Name] = ResourceUsage{CPU: *item.Usage.Cpu(), Memory: *item.Usage.Memory()}
+	}
+	return nodeUsage, nil
+}
+
+// Returns the summed container usage of each pod by "namespace/name"
+func GetPodMetrics(clientset *kubernetes.Clientset) (map[string]ResourceUsage, error) {
+	metrics, err := getMetrics(clientset, "pods")
+	if err != nil {
+		return nil, err
+	}
+	podUsage := make(map[string]ResourceUsage, len(metrics.Items))
+	for _, item := range metrics.Items {
+		var usage ResourceUsage
+		for _, container := range item.Containers {
+			usage.CPU.Add(*container.Usage.Cpu())
+			usage.Memory.Add(*container.Usage.Memory())
+		}
+		podUsage[item.Namespace+"/"+item.Name] = usage
+	}
+	return podUsage, nil
+}
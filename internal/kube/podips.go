@@ -0,0 +1,101 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Only the fields of the node and pod lists that are used, spec.podCIDRs and status.podIPs of dual-stack clusters are
+// newer than the types of the client so the lists are decoded into these
+type nodePodCIDRsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			PodCIDR  string   `json:"podCIDR"`
+			PodCIDRs []string `json:"podCIDRs"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type podIPsList struct {
+	Items []struct {
+		Spec struct {
+			NodeName    string `json:"nodeName"`
+			HostNetwork bool   `json:"hostNetwork"`
+		} `json:"spec"`
+		Status struct {
+			Phase  corev1.PodPhase `json:"phase"`
+			PodIP  string          `json:"podIP"`
+			PodIPs []struct {
+				IP string `json:"ip"`
+			} `json:"podIPs"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// Returns the pod CIDRs of each node by node name, one per IP family on dual-stack clusters
+func GetNodePodCIDRs(clientset *kubernetes.Clientset) (map[string][]string, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().Resource("nodes").DoRaw()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+	nodes := new(nodePodCIDRsList)
+	if err := json.Unmarshal(data, nodes); err != nil {
+		return nil, errors.Wrap(err, "failed to decode nodes")
+	}
+	nodePodCIDRs := make(map[string][]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		podCIDRs := node.Spec.PodCIDRs
+		if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+			podCIDRs = []string{node.Spec.PodCIDR}
+		}
+		nodePodCIDRs[node.Metadata.Name] = podCIDRs
+	}
+	return nodePodCIDRs, nil
+}
+
+// Returns the IPs of the pods of each node by node name, one per IP family on dual-stack clusters. Host network pods use
+// the IPs of the node and succeeded and failed pods have released theirs, so neither is included.
+func GetNodePodIPs(clientset *kubernetes.Clientset) (map[string][]string, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().Resource("pods").DoRaw()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pods")
+	}
+	pods := new(podIPsList)
+	if err := json.Unmarshal(data, pods); err != nil {
+		return nil, errors.Wrap(err, "failed to decode pods")
+	}
+	nodePodIPs := make(map[string][]string)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if len(pod.Status.PodIPs) == 0 && pod.Status.PodIP != "" {
+			nodePodIPs[pod.Spec.NodeName] = append(nodePodIPs[pod.Spec.NodeName], pod.Status.PodIP)
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			nodePodIPs[pod.Spec.NodeName] = append(nodePodIPs[pod.Spec.NodeName], podIP.IP)
+		}
+	}
+	return nodePodIPs, nil
+}
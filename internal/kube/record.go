@@ -0,0 +1,126 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Saves the body of each successful GET response to the directory, so the recording can be replayed without cluster
+// access
+type recorder struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := r.next.RoundTrip(request)
+	if err != nil || request.Method != http.MethodGet || response.StatusCode != http.StatusOK {
+		return response, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	path := recordingPath(r.dir, request.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to record response")
+	}
+	if err := ioutil.WriteFile(path, body, 0644); err != nil {
+		return nil, errors.Wrap(err, "failed to record response")
+	}
+	return response, nil
+}
+
+// Serves the responses of a recording, requests that were not recorded are answered with NotFound
+type replayer struct {
+	dir string
+}
+
+func (r *replayer) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet {
+		return nil, fmt.Errorf("%s %s is not supported when replaying a recording", request.Method, request.URL.Path)
+	}
+	statusCode := http.StatusOK
+	body, err := ioutil.ReadFile(recordingPath(r.dir, request.URL))
+	if os.IsNotExist(err) {
+		statusCode = http.StatusNotFound
+		body, err = json.Marshal(metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Message:  fmt.Sprintf("%s was not recorded", request.URL.RequestURI()),
+			Reason:   metav1.StatusReasonNotFound,
+			Code:     int32(statusCode),
+		})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read recording")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
+
+// The file of a request within a recording, named after its path and query, for example api/v1/nodes.json
+func recordingPath(dir string, requestURL *url.URL) string {
+	name := strings.Trim(requestURL.Path, "/")
+	if requestURL.RawQuery != "" {
+		name += "_" + url.QueryEscape(requestURL.RawQuery)
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)+".json")
+}
+
+// Records the responses of the config to the directory
+func recordConfig(config *rest.Config, dir string) {
+	wrapTransport := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrapTransport != nil {
+			rt = wrapTransport(rt)
+		}
+		return &recorder{dir: dir, next: rt}
+	}
+}
+
+// A config that serves the responses of the recording in the directory instead of contacting a cluster
+func replayConfig(dir string) (*rest.Config, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, errors.Wrap(err, "failed to read recording")
+	}
+	return &rest.Config{Host: "http://replay", Transport: &replayer{dir: dir}}, nil
+}
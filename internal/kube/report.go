@@ -0,0 +1,101 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ReportGroup        = "kubesize.akrzos.github.io"
+	ReportGroupVersion = ReportGroup + "/v1alpha1"
+	ReportKind         = "ClusterCapacityReport"
+	ReportResource     = "clustercapacityreports"
+	reportsPath        = "/apis/" + ReportGroupVersion + "/" + ReportResource
+	// Events of cluster scoped objects are recorded in the default namespace
+	reportEventsNamespace = "default"
+)
+
+// Replaces the status of the named ClusterCapacityReport, creating the report first if it does not exist, and returns
+// a reference to the report. The custom resource is written with the discovery REST client like the metrics API so no
+// generated clientset is needed
+func UpdateClusterCapacityReport(clientset *kubernetes.Clientset, name string, status interface{}) (*corev1.ObjectReference, error) {
+	restClient := clientset.Discovery().RESTClient()
+
+	var statusCode int
+	data, err := restClient.Get().AbsPath(reportsPath, name).Do().StatusCode(&statusCode).Raw()
+	if statusCode == http.StatusNotFound {
+		report, _ := json.Marshal(map[string]interface{}{
+			"apiVersion": ReportGroupVersion,
+			"kind":       ReportKind,
+			"metadata":   map[string]string{"name": name},
+		})
+		data, err = restClient.Post().AbsPath(reportsPath).SetHeader("Content-Type", "application/json").Body(report).DoRaw()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create %s %s, is the CRD installed", ReportKind, name)
+		}
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s %s, is the CRD installed", ReportKind, name)
+	}
+
+	report := make(map[string]interface{})
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s %s", ReportKind, name)
+	}
+	var metadata struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s %s", ReportKind, name)
+	}
+	report["status"] = status
+	data, err = json.Marshal(report)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode %s %s", ReportKind, name)
+	}
+	// The resourceVersion of the read report makes a concurrent update fail instead of being overwritten
+	_, err = restClient.Put().AbsPath(reportsPath, name, "status").SetHeader("Content-Type", "application/json").Body(data).DoRaw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to update status of %s %s", ReportKind, name)
+	}
+	return &corev1.ObjectReference{APIVersion: ReportGroupVersion, Kind: ReportKind, Name: name, UID: metadata.Metadata.UID}, nil
+}
+
+// Records an event of type Normal or Warning about a ClusterCapacityReport
+func RecordReportEvent(clientset *kubernetes.Clientset, report *corev1.ObjectReference, eventType string, reason string, message string) error {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{GenerateName: report.Name + ".", Namespace: reportEventsNamespace},
+		InvolvedObject: *report,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "kubesize"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := clientset.CoreV1().Events(reportEventsNamespace).Create(event); err != nil {
+		return errors.Wrapf(err, "failed to record %s event", reason)
+	}
+	return nil
+}
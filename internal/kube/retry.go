@@ -0,0 +1,90 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// Longest delay between two attempts of a request
+const maxRetryDelay = 30 * time.Second
+
+// Retries GET requests that failed with a connection error, were throttled (429) or hit an unavailable API server, so
+// a single blip does not fail a whole collection. The delay doubles after each attempt, a longer Retry-After of the
+// response is waited instead.
+type retrier struct {
+	attempts int
+	delay    time.Duration
+	stats    *RequestStats
+	next     http.RoundTripper
+}
+
+func (r *retrier) RoundTrip(request *http.Request) (*http.Response, error) {
+	delay := r.delay
+	for attempt := 0; ; attempt++ {
+		response, err := r.next.RoundTrip(request)
+		if attempt >= r.attempts || request.Method != http.MethodGet || !retriable(response, err) || request.Context().Err() != nil {
+			return response, err
+		}
+		wait := delay
+		if response != nil {
+			if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && time.Duration(seconds)*time.Second > wait {
+				wait = time.Duration(seconds) * time.Second
+			}
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+		r.stats.addRetry(wait)
+		select {
+		case <-time.After(wait):
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// Connection errors, throttled requests and unavailable API servers or load balancers are transient
+func retriable(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retries the transient errors of the requests of the config
+func retryConfig(config *rest.Config, attempts int, delay time.Duration, stats *RequestStats) {
+	wrapTransport := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrapTransport != nil {
+			rt = wrapTransport(rt)
+		}
+		return &retrier{attempts: attempts, delay: delay, stats: stats, next: rt}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Counts the API requests of the clientsets of a collection and the time they were held back by client-side throttling
+// or retries, so slow collections can be explained
+type RequestStats struct {
+	mutex sync.Mutex
+	// Requests sent to the API server, each retry included
+	Requests int
+	// Responses of a server throttling the client (429)
+	TooManyRequests int
+	Retries         int
+	RetryWait       time.Duration
+	// Requests delayed by the client-side rate limit and the time they waited
+	Throttled    int
+	ThrottleWait time.Duration
+}
+
+// Waits of the rate limiter shorter than this are not counted as throttled, the same threshold client-go logs
+const throttledThreshold = 50 * time.Millisecond
+
+func (s *RequestStats) addRequest(statusCode int) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Requests++
+	if statusCode == http.StatusTooManyRequests {
+		s.TooManyRequests++
+	}
+}
+
+func (s *RequestStats) addRetry(wait time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Retries++
+	s.RetryWait += wait
+}
+
+func (s *RequestStats) addThrottle(wait time.Duration) {
+	if s == nil || wait < throttledThreshold {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Throttled++
+	s.ThrottleWait += wait
+}
+
+// Counts each request sent to the API server and its 429 responses
+type requestCounter struct {
+	stats *RequestStats
+	next  http.RoundTripper
+}
+
+func (c *requestCounter) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := c.next.RoundTrip(request)
+	statusCode := 0
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	c.stats.addRequest(statusCode)
+	return response, err
+}
+
+// Measures how long the rate limiter holds back each request
+type throttleRecorder struct {
+	flowcontrol.RateLimiter
+	stats *RequestStats
+}
+
+func (t *throttleRecorder) Accept() {
+	start := time.Now()
+	t.RateLimiter.Accept()
+	t.stats.addThrottle(time.Since(start))
+}
+
+// Counts the requests of the config and their throttling in the stats
+func statsConfig(config *rest.Config, stats *RequestStats) {
+	wrapTransport := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrapTransport != nil {
+			rt = wrapTransport(rt)
+		}
+		return &requestCounter{stats: stats, next: rt}
+	}
+	qps, burst := config.QPS, config.Burst
+	if qps == 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst == 0 {
+		burst = rest.DefaultBurst
+	}
+	if qps > 0 {
+		config.RateLimiter = &throttleRecorder{RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst), stats: stats}
+	}
+}
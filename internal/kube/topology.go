@@ -0,0 +1,103 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Resources of the NUMA zones of a node and the topology manager policy of its kubelet
+type NodeTopology struct {
+	Policy string
+	Zones  []NUMAZone
+}
+
+type NUMAZone struct {
+	Name      string
+	Resources map[string]ZoneResource
+}
+
+type ZoneResource struct {
+	Allocatable resource.Quantity
+	Available   resource.Quantity
+}
+
+// Only the fields of the topology.node.k8s.io NodeResourceTopology list that are used, v1alpha1 has the policy in
+// topologyPolicies and v1alpha2 in the topologyManagerPolicy attribute
+type nodeResourceTopologyList struct {
+	Items []struct {
+		metav1.ObjectMeta `json:"metadata"`
+		TopologyPolicies  []string `json:"topologyPolicies"`
+		Attributes        []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"attributes"`
+		Zones []struct {
+			Name      string `json:"name"`
+			Type      string `json:"type"`
+			Resources []struct {
+				Name        string            `json:"name"`
+				Allocatable resource.Quantity `json:"allocatable"`
+				Available   resource.Quantity `json:"available"`
+			} `json:"resources"`
+		} `json:"zones"`
+	} `json:"items"`
+}
+
+// Returns the NUMA zones of each node by node name from the NodeResourceTopology objects exported by the topology
+// updater of node-feature-discovery or the resource topology exporter. Lists v1alpha2 first, falling back to v1alpha1.
+func GetNodeTopologies(clientset *kubernetes.Clientset) (map[string]NodeTopology, error) {
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/topology.node.k8s.io/v1alpha2", "noderesourcetopologies").DoRaw()
+	if apierrors.IsNotFound(err) {
+		data, err = clientset.Discovery().RESTClient().Get().AbsPath("/apis/topology.node.k8s.io/v1alpha1", "noderesourcetopologies").DoRaw()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list node resource topologies, is the topology updater of node-feature-discovery installed")
+	}
+	topologies := new(nodeResourceTopologyList)
+	if err := json.Unmarshal(data, topologies); err != nil {
+		return nil, errors.Wrap(err, "failed to decode node resource topologies")
+	}
+	nodeTopologies := make(map[string]NodeTopology, len(topologies.Items))
+	for _, item := range topologies.Items {
+		nodeTopology := NodeTopology{Policy: strings.Join(item.TopologyPolicies, ",")}
+		for _, attribute := range item.Attributes {
+			if attribute.Name == "topologyManagerPolicy" {
+				nodeTopology.Policy = attribute.Value
+			}
+		}
+		for _, zone := range item.Zones {
+			// Other zone types, such as sockets or cores, are not NUMA nodes
+			if zone.Type != "Node" {
+				continue
+			}
+			numaZone := NUMAZone{Name: zone.Name, Resources: make(map[string]ZoneResource, len(zone.Resources))}
+			for _, zoneResource := range zone.Resources {
+				numaZone.Resources[zoneResource.Name] = ZoneResource{Allocatable: zoneResource.Allocatable, Available: zoneResource.Available}
+			}
+			nodeTopology.Zones = append(nodeTopology.Zones, numaZone)
+		}
+		nodeTopologies[item.Name] = nodeTopology
+	}
+	return nodeTopologies, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Only the fields of the storage.k8s.io/v1 CSINode list that are used, the allocatable count of the drivers is newer
+// than the types of the client
+type csiNodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Drivers []struct {
+				Allocatable *struct {
+					Count *int64 `json:"count"`
+				} `json:"allocatable"`
+			} `json:"drivers"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// Returns the volumes the CSI drivers of each node may attach by node name, summed over the drivers reporting a limit.
+// Servers without storage.k8s.io/v1 CSINodes (before 1.17) return no limits.
+func GetCSINodeAttachLimits(clientset *kubernetes.Clientset) (map[string]int64, error) {
+	attachLimits := make(map[string]int64)
+	data, err := clientset.Discovery().RESTClient().Get().AbsPath("/apis/storage.k8s.io/v1", "csinodes").DoRaw()
+	if apierrors.IsNotFound(err) {
+		return attachLimits, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list csi nodes")
+	}
+	csiNodes := new(csiNodeList)
+	if err := json.Unmarshal(data, csiNodes); err != nil {
+		return nil, errors.Wrap(err, "failed to decode csi nodes")
+	}
+	for _, csiNode := range csiNodes.Items {
+		for _, driver := range csiNode.Spec.Drivers {
+			if driver.Allocatable != nil && driver.Allocatable.Count != nil {
+				attachLimits[csiNode.Metadata.Name] += *driver.Allocatable.Count
+			}
+		}
+	}
+	return attachLimits, nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// Annotation of the bookmark event that ends the initial events of a streaming list
+const initialEventsEndAnnotation = "k8s.io/initial-events-end"
+
+// Servers before 1.27 ignore sendInitialEvents and would never send the bookmark that ends the list
+const watchListMinMinor = 27
+
+// Whether the API server accepts streaming lists. Servers with the WatchList feature disabled still reject the
+// request, WatchList falls back on that error.
+func SupportsWatchList(client discovery.DiscoveryInterface) bool {
+	version, err := client.ServerVersion()
+	if err != nil {
+		return false
+	}
+	major, err := strconv.Atoi(version.Major)
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(strings.TrimSuffix(version.Minor, "+"))
+	if err != nil {
+		return false
+	}
+	return major > 1 || (major == 1 && minor >= watchListMinMinor)
+}
+
+// Lists the resource as a watch with sendInitialEvents, the API server streams the objects one event at a time instead
+// of serializing one large list, which lowers the peak memory of the API server and of kubeSize on large clusters. Each
+// object is passed to add as it arrives. Only the objects matching fieldSelector are sent, all when empty.
+func WatchList(client rest.Interface, resource string, namespace string, fieldSelector string, timeoutSeconds int, add func(object []byte) error) error {
+	request := client.Get().
+		Namespace(namespace).
+		Resource(resource)
+	if fieldSelector != "" {
+		request = request.Param("fieldSelector", fieldSelector)
+	}
+	stream, err := request.
+		Param("watch", "true").
+		Param("sendInitialEvents", "true").
+		Param("resourceVersionMatch", "NotOlderThan").
+		Param("allowWatchBookmarks", "true").
+		Param("timeoutSeconds", strconv.Itoa(timeoutSeconds)).
+		Stream()
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	decoder := json.NewDecoder(stream)
+	for {
+		event := struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}{}
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("watch of %s ended before its initial events", resource)
+			}
+			return err
+		}
+		switch event.Type {
+		case "ADDED":
+			if err := add(event.Object); err != nil {
+				return err
+			}
+		case "BOOKMARK":
+			bookmark := struct {
+				metav1.ObjectMeta `json:"metadata"`
+			}{}
+			if err := json.Unmarshal(event.Object, &bookmark); err != nil {
+				return err
+			}
+			if bookmark.Annotations[initialEventsEndAnnotation] == "true" {
+				return nil
+			}
+		case "ERROR":
+			status := metav1.Status{}
+			if err := json.Unmarshal(event.Object, &status); err != nil {
+				return err
+			}
+			return &apierrors.StatusError{ErrStatus: status}
+		default:
+			// A server that answers with a list instead of watch events
+			return fmt.Errorf("unexpected watch event %q listing %s", event.Type, resource)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logging
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+// Writes leveled messages with key/value pairs as text lines or JSON objects. Messages logged with V(level) are only
+// written when the verbosity is at least the level.
+type Logger struct {
+	mutex     *sync.Mutex
+	out       io.Writer
+	format    string
+	verbosity int
+}
+
+func New(out io.Writer, format string, verbosity int) (*Logger, error) {
+	if format != TextFormat && format != JSONFormat {
+		return nil, fmt.Errorf("invalid log format %q, use %s or %s", format, TextFormat, JSONFormat)
+	}
+	if verbosity < 0 {
+		return nil, fmt.Errorf("log verbosity must not be negative")
+	}
+	return &Logger{mutex: &sync.Mutex{}, out: out, format: format, verbosity: verbosity}, nil
+}
+
+// Returns the logger when the verbosity is at least the level and nil, which discards messages, otherwise
+func (l *Logger) V(level int) *Logger {
+	if l == nil || level > l.verbosity {
+		return nil
+	}
+	return l
+}
+
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.log("info", msg, keysAndValues)
+}
+
+func (l *Logger) Warning(msg string, keysAndValues ...interface{}) {
+	l.log("warning", msg, keysAndValues)
+}
+
+// Logs the error, with the message as context when it is set
+func (l *Logger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.log("error", msg, append([]interface{}{"error", err}, keysAndValues...))
+}
+
+func (l *Logger) log(level string, msg string, keysAndValues []interface{}) {
+	if l == nil {
+		return
+	}
+	// A key without a value is kept, like klog does
+	if len(keysAndValues)%2 == 1 {
+		keysAndValues = append(keysAndValues[:len(keysAndValues):len(keysAndValues)], "(MISSING)")
+	}
+	var line []byte
+	if l.format == JSONFormat {
+		entry := map[string]interface{}{"ts": time.Now().UTC().Format(time.RFC3339Nano), "level": level}
+		if msg != "" {
+			entry["msg"] = msg
+		}
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			value := keysAndValues[i+1]
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			entry[fmt.Sprint(keysAndValues[i])] = value
+		}
+		line, _ = json.Marshal(entry)
+	} else {
+		line = []byte(textLine(level, msg, keysAndValues))
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	fmt.Fprintf(l.out, "%s\n", line)
+}
+
+// Formats as "error: <msg>: <error> key=value", which keeps the text format of the errors the CLI always printed
+func textLine(level string, msg string, keysAndValues []interface{}) string {
+	var parts []string
+	if level != "info" {
+		parts = append(parts, level)
+	}
+	if msg != "" {
+		parts = append(parts, msg)
+	}
+	var fields []string
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "error" {
+			parts = append(parts, fmt.Sprint(keysAndValues[i+1]))
+			continue
+		}
+		value := fmt.Sprint(keysAndValues[i+1])
+		if strings.ContainsAny(value, " \t\"=") {
+			value = strconv.Quote(value)
+		}
+		fields = append(fields, fmt.Sprintf("%v=%s", keysAndValues[i], value))
+	}
+	line := strings.Join(parts, ": ")
+	if len(fields) > 0 {
+		line += " " + strings.Join(fields, " ")
+	}
+	return line
+}
+
+// Routes the klog output of client-go through the logger at its verbosity, instead of klog writing log files to the
+// temporary directory
+func (l *Logger) CaptureKlog() {
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	flags.Set("logtostderr", "false")
+	flags.Set("alsologtostderr", "false")
+	flags.Set("stderrthreshold", "FATAL")
+	flags.Set("v", strconv.Itoa(l.verbosity))
+	// klog writes each message to the writers of its severity and all lower severities
+	klog.SetOutputBySeverity("INFO", klogWriter{logger: l})
+	for _, severity := range []string{"WARNING", "ERROR", "FATAL"} {
+		klog.SetOutputBySeverity(severity, ioutil.Discard)
+	}
+}
+
+var klogLevels = map[byte]string{'I': "info", 'W': "warning", 'E': "error", 'F': "fatal"}
+
+// Parses the "Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg" lines klog writes
+type klogWriter struct {
+	logger *Logger
+}
+
+func (w klogWriter) Write(data []byte) (int, error) {
+	line := strings.TrimSuffix(string(data), "\n")
+	index := strings.Index(line, "] ")
+	if index < 0 || klogLevels[line[0]] == "" {
+		w.logger.log("info", line, nil)
+		return len(data), nil
+	}
+	header := strings.Fields(line[:index])
+	w.logger.log(klogLevels[line[0]], line[index+2:], []interface{}{"source", header[len(header)-1]})
+	return len(data), nil
+}
@@ -0,0 +1,183 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"k8s.io/klog"
+)
+
+func newTestLogger(t *testing.T, format string, verbosity int) (*Logger, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	logger, err := New(out, format, verbosity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger, out
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "yaml", 0); err == nil {
+		t.Errorf("expected an invalid format error")
+	}
+	if _, err := New(&bytes.Buffer{}, TextFormat, -1); err == nil {
+		t.Errorf("expected a negative verbosity error")
+	}
+}
+
+func TestVerbosity(t *testing.T) {
+	for _, test := range []struct {
+		verbosity int
+		level     int
+		logged    bool
+	}{
+		{verbosity: 0, level: 0, logged: true},
+		{verbosity: 0, level: 1, logged: false},
+		{verbosity: 2, level: 1, logged: true},
+		{verbosity: 2, level: 2, logged: true},
+		{verbosity: 2, level: 3, logged: false},
+	} {
+		logger, out := newTestLogger(t, TextFormat, test.verbosity)
+		logger.V(test.level).Info("message")
+		logger.V(test.level).Warning("message")
+		logger.V(test.level).Error(errors.New("failed"), "message")
+		if logged := out.Len() > 0; logged != test.logged {
+			t.Errorf("level %d at verbosity %d logged %t, expected %t", test.level, test.verbosity, logged, test.logged)
+		}
+	}
+	// A nil logger discards messages
+	var logger *Logger
+	logger.V(0).Info("message")
+	logger.Warning("message")
+}
+
+func TestText(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		log      func(logger *Logger)
+		expected string
+	}{
+		{name: "info", log: func(logger *Logger) { logger.Info("listed nodes", "count", 3) }, expected: "listed nodes count=3\n"},
+		{name: "warning", log: func(logger *Logger) { logger.Warning("partial", "nodes", []string{"a", "b"}) }, expected: "warning: partial nodes=\"[a b]\"\n"},
+		{name: "error", log: func(logger *Logger) { logger.Error(errors.New("timeout"), "failed to list pods", "namespace", "app") },
+			expected: "error: failed to list pods: timeout namespace=app\n"},
+		{name: "error without message", log: func(logger *Logger) { logger.Error(errors.New("timeout"), "") }, expected: "error: timeout\n"},
+		{name: "quoted values", log: func(logger *Logger) { logger.Info("read", "path", "my file", "query", "a=b", "quote", `"`) },
+			expected: `read path="my file" query="a=b" quote="\""` + "\n"},
+		{name: "odd key/value count", log: func(logger *Logger) { logger.Info("read", "count", 1, "nodes") }, expected: "read count=1 nodes=(MISSING)\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			logger, out := newTestLogger(t, TextFormat, 0)
+			test.log(logger)
+			if out.String() != test.expected {
+				t.Errorf("logged %q, expected %q", out.String(), test.expected)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		log      func(logger *Logger)
+		expected map[string]interface{}
+	}{
+		{name: "info", log: func(logger *Logger) { logger.Info("listed nodes", "count", 3) },
+			expected: map[string]interface{}{"level": "info", "msg": "listed nodes", "count": float64(3)}},
+		{name: "warning", log: func(logger *Logger) { logger.Warning("partial", "nodes", []string{"a", "b"}) },
+			expected: map[string]interface{}{"level": "warning", "msg": "partial", "nodes": []interface{}{"a", "b"}}},
+		{name: "error", log: func(logger *Logger) { logger.Error(errors.New("timeout"), "failed to list pods", "namespace", "app") },
+			expected: map[string]interface{}{"level": "error", "msg": "failed to list pods", "error": "timeout", "namespace": "app"}},
+		{name: "error without message", log: func(logger *Logger) { logger.Error(errors.New("timeout"), "") },
+			expected: map[string]interface{}{"level": "error", "error": "timeout"}},
+		{name: "odd key/value count", log: func(logger *Logger) { logger.Info("read", "count", 1, "nodes") },
+			expected: map[string]interface{}{"level": "info", "msg": "read", "count": float64(1), "nodes": "(MISSING)"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			logger, out := newTestLogger(t, JSONFormat, 0)
+			test.log(logger)
+			entry := map[string]interface{}{}
+			if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+				t.Fatalf("logged invalid JSON %q: %v", out.String(), err)
+			}
+			if _, ok := entry["ts"]; !ok {
+				t.Errorf("logged no timestamp")
+			}
+			delete(entry, "ts")
+			if !jsonEqual(entry, test.expected) {
+				t.Errorf("logged %v, expected %v", entry, test.expected)
+			}
+		})
+	}
+}
+
+func jsonEqual(a map[string]interface{}, b map[string]interface{}) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return bytes.Equal(aJSON, bJSON)
+}
+
+func TestCaptureKlog(t *testing.T) {
+	logger, out := newTestLogger(t, TextFormat, 1)
+	logger.CaptureKlog()
+	klog.Info("cache miss")
+	klog.V(1).Info("listing nodes")
+	klog.V(2).Info("request body")
+	klog.Warning("throttled")
+	klog.Error("connection refused")
+	klog.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	expected := []string{"cache miss", "listing nodes", "warning: throttled", "error: connection refused"}
+	if len(lines) != len(expected) {
+		t.Fatalf("logged %q, expected %d lines", out.String(), len(expected))
+	}
+	for i, line := range lines {
+		if !regexp.MustCompile("^" + expected[i] + ` source=logging_test\.go:\d+$`).MatchString(line) {
+			t.Errorf("line %d is %q, expected %q with the file and line", i, line, expected[i])
+		}
+	}
+}
+
+func TestKlogWriter(t *testing.T) {
+	logger, out := newTestLogger(t, JSONFormat, 0)
+	writer := klogWriter{logger: logger}
+	writer.Write([]byte("W0102 15:04:05.000000   12345 reflector.go:302] watch closed\n"))
+	writer.Write([]byte("not a klog line\n"))
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %q, expected 2 lines", out.String())
+	}
+	for i, expected := range []map[string]interface{}{
+		{"level": "warning", "msg": "watch closed", "source": "reflector.go:302"},
+		{"level": "info", "msg": "not a klog line"},
+	} {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		delete(entry, "ts")
+		if !jsonEqual(entry, expected) {
+			t.Errorf("logged %v, expected %v", entry, expected)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/cli-runtime/pkg/kustomize"
+	"sigs.k8s.io/kustomize/pkg/fs"
+)
+
+// A workload of a manifest with the number of pods it runs at once
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Replicas  int
+	Template  corev1.PodTemplateSpec
+	// The vendored pod spec predates topology spread constraints, they are decoded separately
+	TopologySpreadConstraints []TopologySpreadConstraint
+}
+
+type TopologySpreadConstraint struct {
+	MaxSkew           int                   `json:"maxSkew"`
+	TopologyKey       string                `json:"topologyKey"`
+	WhenUnsatisfiable string                `json:"whenUnsatisfiable"`
+	LabelSelector     *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// The pod template of the workload kinds is at spec.template
+type workloadTemplate struct {
+	Spec struct {
+		Template struct {
+			Spec struct {
+				TopologySpreadConstraints []TopologySpreadConstraint `json:"topologySpreadConstraints"`
+			} `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+}
+
+// The pod template of a CronJob is at spec.jobTemplate.spec.template
+type cronJobTemplate struct {
+	Spec struct {
+		JobTemplate workloadTemplate `json:"jobTemplate"`
+	} `json:"spec"`
+}
+
+type typeMeta struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Items      []json.RawMessage `json:"items"`
+}
+
+// Decodes a Deployment, StatefulSet, Job or CronJob, nil is returned for other kinds
+func NewWorkload(group string, kind string, raw []byte) (*Workload, error) {
+	workload, err := newWorkload(group, kind, raw)
+	if err != nil || workload == nil {
+		return nil, err
+	}
+	template := workloadTemplate{}
+	if kind == "CronJob" {
+		cronJob := cronJobTemplate{}
+		if err := json.Unmarshal(raw, &cronJob); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode %s", kind)
+		}
+		template = cronJob.Spec.JobTemplate
+	} else if err := json.Unmarshal(raw, &template); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", kind)
+	}
+	workload.TopologySpreadConstraints = template.Spec.Template.Spec.TopologySpreadConstraints
+	return workload, nil
+}
+
+func newWorkload(group string, kind string, raw []byte) (*Workload, error) {
+	switch {
+	case (group == "apps" || group == "extensions") && kind == "Deployment":
+		deployment := new(appsv1.Deployment)
+		if err := json.Unmarshal(raw, deployment); err != nil {
+			return nil, errors.Wrap(err, "failed to decode Deployment")
+		}
+		return &Workload{Kind: kind, Namespace: deployment.Namespace, Name: deployment.Name, Replicas: replicas(deployment.Spec.Replicas), Template: deployment.Spec.Template}, nil
+	case group == "apps" && kind == "StatefulSet":
+		statefulSet := new(appsv1.StatefulSet)
+		if err := json.Unmarshal(raw, statefulSet); err != nil {
+			return nil, errors.Wrap(err, "failed to decode StatefulSet")
+		}
+		return &Workload{Kind: kind, Namespace: statefulSet.Namespace, Name: statefulSet.Name, Replicas: replicas(statefulSet.Spec.Replicas), Template: statefulSet.Spec.Template}, nil
+	case group == "batch" && kind == "Job":
+		job := new(batchv1.Job)
+		if err := json.Unmarshal(raw, job); err != nil {
+			return nil, errors.Wrap(err, "failed to decode Job")
+		}
+		return &Workload{Kind: kind, Namespace: job.Namespace, Name: job.Name, Replicas: parallelism(job.Spec), Template: job.Spec.Template}, nil
+	case group == "batch" && kind == "CronJob":
+		// Counted as one Job running at a time
+		cronJob := new(batchv1beta1.CronJob)
+		if err := json.Unmarshal(raw, cronJob); err != nil {
+			return nil, errors.Wrap(err, "failed to decode CronJob")
+		}
+		return &Workload{Kind: kind, Namespace: cronJob.Namespace, Name: cronJob.Name, Replicas: parallelism(cronJob.Spec.JobTemplate.Spec), Template: cronJob.Spec.JobTemplate.Spec.Template}, nil
+	}
+	return nil, nil
+}
+
+// Pods a Job runs at once, the smaller of its parallelism and completions
+func parallelism(spec batchv1.JobSpec) int {
+	parallelism := replicas(spec.Parallelism)
+	if spec.Completions != nil && int(*spec.Completions) < parallelism {
+		parallelism = int(*spec.Completions)
+	}
+	return parallelism
+}
+
+// Replicas default to 1 when unset
+func replicas(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+	return int(*replicas)
+}
+
+// Decodes the workloads of a stream of yaml documents or json objects, including the items of Lists
+func Decode(reader io.Reader, name string) ([]Workload, error) {
+	workloads := make([]Workload, 0)
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for {
+		raw := json.RawMessage{}
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return workloads, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode %s", name)
+		}
+		decoded, err := decodeObject(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode %s", name)
+		}
+		workloads = append(workloads, decoded...)
+	}
+}
+
+func decodeObject(raw []byte) ([]Workload, error) {
+	// Empty documents decode to null
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+	object := typeMeta{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(object.Kind, "List") {
+		workloads := make([]Workload, 0)
+		for _, item := range object.Items {
+			decoded, err := decodeObject(item)
+			if err != nil {
+				return nil, err
+			}
+			workloads = append(workloads, decoded...)
+		}
+		return workloads, nil
+	}
+	group := ""
+	if i := strings.Index(object.APIVersion, "/"); i >= 0 {
+		group = object.APIVersion[:i]
+	}
+	workload, err := NewWorkload(group, object.Kind, raw)
+	if err != nil || workload == nil {
+		return nil, err
+	}
+	return []Workload{*workload}, nil
+}
+
+// Reads the workloads of manifest files and of the .yaml, .yml and .json files of directories, like kubectl apply -f,
+// the path - reads stdin
+func Read(paths []string, recursive bool, stdin io.Reader) ([]Workload, error) {
+	workloads := make([]Workload, 0)
+	for _, path := range paths {
+		if path == "-" {
+			decoded, err := Decode(stdin, "stdin")
+			if err != nil {
+				return nil, err
+			}
+			workloads = append(workloads, decoded...)
+			continue
+		}
+		files, err := manifestFiles(path, recursive)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to read manifest")
+			}
+			decoded, err := Decode(bytes.NewReader(data), file)
+			if err != nil {
+				return nil, err
+			}
+			workloads = append(workloads, decoded...)
+		}
+	}
+	return workloads, nil
+}
+
+func manifestFiles(path string, recursive bool) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read manifest")
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files := make([]string, 0)
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if file != path && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(file) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read manifests")
+	}
+	return files, nil
+}
+
+// Renders a chart with helm template and decodes the workloads of the rendered manifests
+func Helm(chart string, release string, namespace string, valuesFiles []string) ([]Workload, error) {
+	args := []string{"template", release, chart}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	for _, valuesFile := range valuesFiles {
+		args = append(args, "--values", valuesFile)
+	}
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("helm", args...)
+	command.Stdout, command.Stderr = &stdout, &stderr
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("failed to render chart %s: %s", chart, message)
+		}
+		return nil, errors.Wrapf(err, "failed to render chart %s", chart)
+	}
+	return Decode(&stdout, "chart "+chart)
+}
+
+// Builds a kustomization directory, like kubectl apply -k, and decodes the workloads of the built manifests
+func Kustomize(dir string) ([]Workload, error) {
+	var out bytes.Buffer
+	if err := kustomize.RunKustomizeBuild(&out, fs.MakeRealFS(), dir); err != nil {
+		return nil, errors.Wrapf(err, "failed to build kustomization %s", dir)
+	}
+	return Decode(&out, "kustomization "+dir)
+}
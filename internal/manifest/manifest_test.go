@@ -0,0 +1,215 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const manifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web
+      topologySpreadConstraints:
+      - maxSkew: 1
+        topologyKey: topology.kubernetes.io/zone
+        whenUnsatisfiable: DoNotSchedule
+        labelSelector:
+          matchLabels:
+            app: web
+---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: legacy
+spec:
+  template:
+    spec:
+      containers:
+      - name: legacy
+        image: legacy
+---
+# Only comments
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: db
+        image: db
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  parallelism: 4
+  completions: 2
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: migrate
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      parallelism: 2
+      template:
+        spec:
+          containers:
+          - name: report
+            image: report
+          topologySpreadConstraints:
+          - maxSkew: 2
+            topologyKey: kubernetes.io/hostname
+            whenUnsatisfiable: ScheduleAnyway
+---
+{"apiVersion": "v1", "kind": "List", "items": [
+  {"apiVersion": "batch/v1", "kind": "Job", "metadata": {"name": "backup"}, "spec": {"template": {"spec": {"containers": [{"name": "backup"}]}}}},
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config"}}
+]}
+`
+
+func TestDecode(t *testing.T) {
+	workloads, err := Decode(strings.NewReader(manifests), "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		kind                      string
+		namespace                 string
+		name                      string
+		replicas                  int
+		container                 string
+		topologySpreadConstraints []TopologySpreadConstraint
+	}{
+		{kind: "Deployment", namespace: "app", name: "web", replicas: 3, container: "web", topologySpreadConstraints: []TopologySpreadConstraint{
+			{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: "DoNotSchedule", LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}}},
+		// Replicas default to 1
+		{kind: "Deployment", name: "legacy", replicas: 1, container: "legacy"},
+		{kind: "StatefulSet", name: "db", replicas: 2, container: "db"},
+		// The smaller of parallelism and completions
+		{kind: "Job", name: "migrate", replicas: 2, container: "migrate"},
+		// The parallelism of the job template
+		{kind: "CronJob", name: "report", replicas: 2, container: "report", topologySpreadConstraints: []TopologySpreadConstraint{
+			{MaxSkew: 2, TopologyKey: "kubernetes.io/hostname", WhenUnsatisfiable: "ScheduleAnyway"}}},
+		{kind: "Job", name: "backup", replicas: 1, container: "backup"},
+	}
+	if len(workloads) != len(expected) {
+		t.Fatalf("decoded %d workloads, expected %d", len(workloads), len(expected))
+	}
+	for i, workload := range workloads {
+		e := expected[i]
+		if workload.Kind != e.kind || workload.Namespace != e.namespace || workload.Name != e.name || workload.Replicas != e.replicas {
+			t.Errorf("workload %d is %s %s/%s with %d replicas, expected %s %s/%s with %d replicas", i,
+				workload.Kind, workload.Namespace, workload.Name, workload.Replicas, e.kind, e.namespace, e.name, e.replicas)
+		}
+		if len(workload.Template.Spec.Containers) != 1 || workload.Template.Spec.Containers[0].Name != e.container {
+			t.Errorf("workload %d has containers %v, expected %s", i, workload.Template.Spec.Containers, e.container)
+		}
+		if constraints := workload.TopologySpreadConstraints; len(constraints) != 0 || len(e.topologySpreadConstraints) != 0 {
+			if !reflect.DeepEqual(constraints, e.topologySpreadConstraints) {
+				t.Errorf("workload %d has topology spread constraints %v, expected %v", i, constraints, e.topologySpreadConstraints)
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for name, manifest := range map[string]string{
+		"invalid yaml":         "kind: [Deployment",
+		"invalid replicas":     "apiVersion: apps/v1\nkind: Deployment\nspec:\n  replicas: three\n",
+		"invalid job template": "apiVersion: batch/v1beta1\nkind: CronJob\nspec:\n  jobTemplate: []\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(manifest), "manifest"); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: %s\n"
+	for file, name := range map[string]string{"web.yaml": "web", "db.json": "db", "notes.txt": "notes", "nested/cache.yml": "cache"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := strings.Replace(deployment, "%s", name, 1)
+		if filepath.Ext(file) == ".json" {
+			content = `{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "` + name + `"}}`
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		recursive bool
+		names     []string
+	}{
+		{recursive: false, names: []string{"db", "web"}},
+		{recursive: true, names: []string{"db", "cache", "web"}},
+	} {
+		workloads, err := Read([]string{dir}, test.recursive, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, workload := range workloads {
+			names = append(names, workload.Name)
+		}
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("recursive %t read %v, expected %v", test.recursive, names, test.names)
+		}
+	}
+	workloads, err := Read([]string{"-"}, false, strings.NewReader(strings.Replace(deployment, "%s", "stdin", 1)))
+	if err != nil || len(workloads) != 1 || workloads[0].Name != "stdin" {
+		t.Errorf("expected the deployment of stdin, got %v, %v", workloads, err)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Assumed role credentials are refreshed this long before they expire
+const awsCredentialsRefreshWindow = 5 * time.Minute
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Zero for the static credentials of the environment
+	Expiration time.Time
+}
+
+// Returns the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN credentials of the environment, or with
+// AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN, as set for pods of service accounts with an IAM role (IRSA) on EKS,
+// those of the role assumed with the web identity token
+type awsCredentialsProvider struct {
+	mutex       *sync.Mutex
+	credentials awsCredentials
+	// Set for web identity credentials
+	roleARN     string
+	tokenFile   string
+	sessionName string
+	stsEndpoint string
+	client      *http.Client
+}
+
+func newAWSCredentialsProvider(region string, client *http.Client) (*awsCredentialsProvider, error) {
+	provider := &awsCredentialsProvider{mutex: &sync.Mutex{}, client: client}
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" && os.Getenv("AWS_SECRET_ACCESS_KEY") != "" {
+		provider.credentials = awsCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		return provider, nil
+	}
+	provider.roleARN, provider.tokenFile = os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if provider.roleARN == "" || provider.tokenFile == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE, are required to put cloudwatch metrics")
+	}
+	provider.sessionName = os.Getenv("AWS_ROLE_SESSION_NAME")
+	if provider.sessionName == "" {
+		provider.sessionName = "kubesize-" + strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	provider.stsEndpoint = "https://sts." + region + ".amazonaws.com/"
+	return provider, nil
+}
+
+// Returns the static credentials, or the assumed role credentials, assuming the role again once they are about to
+// expire. The token file is read on every assume, since the kubelet rotates the projected token.
+func (p *awsCredentialsProvider) get(now time.Time) (awsCredentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.roleARN == "" || (p.credentials.AccessKeyID != "" && now.Add(awsCredentialsRefreshWindow).Before(p.credentials.Expiration)) {
+		return p.credentials, nil
+	}
+	token, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return awsCredentials{}, errors.Wrap(err, "failed to read the web identity token")
+	}
+	credentials, err := p.assumeRoleWithWebIdentity(strings.TrimSpace(string(token)))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	p.credentials = credentials
+	return credentials, nil
+}
+
+// Only the credentials of the STS AssumeRoleWithWebIdentity response that are used
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// The web identity token authenticates the request, so it is not signed
+func (p *awsCredentialsProvider) assumeRoleWithWebIdentity(token string) (awsCredentials, error) {
+	form := url.Values{}
+	form.Set("Action", "AssumeRoleWithWebIdentity")
+	form.Set("Version", "2011-06-15")
+	form.Set("RoleArn", p.roleARN)
+	form.Set("RoleSessionName", p.sessionName)
+	form.Set("WebIdentityToken", token)
+	resp, err := p.client.PostForm(p.stsEndpoint, form)
+	if err != nil {
+		return awsCredentials{}, errors.Wrapf(err, "failed to assume role %s", p.roleARN)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return awsCredentials{}, fmt.Errorf("failed to assume role %s, sts responded %s: %s", p.roleARN, resp.Status, body)
+	}
+	response := assumeRoleWithWebIdentityResponse{}
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return awsCredentials{}, errors.Wrap(err, "failed to decode the assumed role credentials")
+	}
+	return awsCredentials{
+		AccessKeyID:     response.Credentials.AccessKeyID,
+		SecretAccessKey: response.Credentials.SecretAccessKey,
+		SessionToken:    response.Credentials.SessionToken,
+		Expiration:      response.Credentials.Expiration,
+	}, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, values map[string]string) {
+	for key, value := range values {
+		previous, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, previous)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestAWSCredentialsEnvironment(t *testing.T) {
+	setenv(t, map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_SESSION_TOKEN": "token", "AWS_ROLE_ARN": "", "AWS_WEB_IDENTITY_TOKEN_FILE": ""})
+	provider, err := newAWSCredentialsProvider("us-east-1", http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := provider.get(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials != (awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}) {
+		t.Errorf("unexpected credentials %+v", credentials)
+	}
+}
+
+func TestAWSCredentialsMissing(t *testing.T) {
+	setenv(t, map[string]string{"AWS_ACCESS_KEY_ID": "", "AWS_SECRET_ACCESS_KEY": "", "AWS_ROLE_ARN": "", "AWS_WEB_IDENTITY_TOKEN_FILE": ""})
+	if _, err := newAWSCredentialsProvider("us-east-1", http.DefaultClient); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
+
+func TestAWSCredentialsWebIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("jwt-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, map[string]string{"AWS_ACCESS_KEY_ID": "", "AWS_SECRET_ACCESS_KEY": "", "AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/kubesize",
+		"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile, "AWS_ROLE_SESSION_NAME": "test"})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var assumes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") != "AssumeRoleWithWebIdentity" || r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/kubesize" || r.Form.Get("RoleSessionName") != "test" {
+			t.Errorf("unexpected request %v", r.Form)
+		}
+		assumes++
+		fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIA%d</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>%s</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`, assumes, r.Form.Get("WebIdentityToken"), now.Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	provider, err := newAWSCredentialsProvider("us-east-1", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.stsEndpoint != "https://sts.us-east-1.amazonaws.com/" {
+		t.Errorf("sts endpoint %s", provider.stsEndpoint)
+	}
+	provider.stsEndpoint = server.URL
+
+	for _, test := range []struct {
+		now         time.Time
+		accessKeyID string
+	}{
+		{now, "ASIA1"},
+		// Cached until the refresh window before the expiration
+		{now.Add(50 * time.Minute), "ASIA1"},
+		{now.Add(56 * time.Minute), "ASIA2"},
+	} {
+		credentials, err := provider.get(test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if credentials.AccessKeyID != test.accessKeyID || credentials.SessionToken != "jwt-1" {
+			t.Errorf("at %s got credentials %+v, expected access key %s and the session token of the token file", test.now, credentials, test.accessKeyID)
+		}
+	}
+}
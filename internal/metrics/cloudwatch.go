@@ -0,0 +1,200 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric data per PutMetricData request
+const cloudWatchBatchSize = 20
+
+// Puts metrics to AWS CloudWatch with the labels as dimensions. Requests are signed with Signature Version 4 using
+// the credentials of the environment or of the IAM role of the service account.
+type CloudWatch struct {
+	Namespace   string
+	Region      string
+	Endpoint    string
+	Client      *http.Client
+	credentials *awsCredentialsProvider
+}
+
+func NewCloudWatch(namespace string, region string) (*CloudWatch, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("cloudwatch region is not set, set --cloudwatch-region or AWS_REGION")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	credentials, err := newAWSCredentialsProvider(region, client)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudWatch{
+		Namespace:   namespace,
+		Region:      region,
+		Endpoint:    "https://monitoring." + region + ".amazonaws.com/",
+		Client:      client,
+		credentials: credentials,
+	}, nil
+}
+
+func (c *CloudWatch) Export(timestamp time.Time, metrics []Metric) error {
+	for start := 0; start < len(metrics); start += cloudWatchBatchSize {
+		end := start + cloudWatchBatchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		if err := c.putMetricData(timestamp, metrics[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CloudWatch) putMetricData(timestamp time.Time, metrics []Metric) error {
+	form := url.Values{}
+	form.Set("Action", "PutMetricData")
+	form.Set("Version", "2010-08-01")
+	form.Set("Namespace", c.Namespace)
+	for i, metric := range metrics {
+		member := "MetricData.member." + strconv.Itoa(i+1) + "."
+		form.Set(member+"MetricName", metric.Name)
+		form.Set(member+"Value", strconv.FormatFloat(metric.Value, 'f', -1, 64))
+		form.Set(member+"Unit", cloudWatchUnit(metric.Name))
+		form.Set(member+"Timestamp", timestamp.UTC().Format(time.RFC3339))
+		dimension := 0
+		for _, key := range SortedLabelKeys(metric.Labels) {
+			// Dimension values can not be empty
+			if metric.Labels[key] == "" {
+				continue
+			}
+			dimension++
+			form.Set(member+"Dimensions.member."+strconv.Itoa(dimension)+".Name", key)
+			form.Set(member+"Dimensions.member."+strconv.Itoa(dimension)+".Value", metric.Labels[key])
+		}
+	}
+	body := form.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	now := time.Now().UTC()
+	credentials, err := c.credentials.get(now)
+	if err != nil {
+		return err
+	}
+	signV4(req, body, credentials, c.Region, "monitoring", now)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("cloudwatch responded %s: %s", resp.Status, message)
+	}
+	return nil
+}
+
+func cloudWatchUnit(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_bytes"):
+		return "Bytes"
+	case strings.HasSuffix(name, "_percent"):
+		return "Percent"
+	case strings.HasSuffix(name, "_cores"):
+		return "None"
+	}
+	return "Count"
+}
+
+// Signs a request with AWS Signature Version 4
+func signV4(req *http.Request, body string, credentials awsCredentials, region string, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+	canonicalRequest, signedHeaders := canonicalRequestV4(req, body)
+	scope := now.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := stringToSignV4(amzDate, scope, canonicalRequest)
+	signature := signatureV4(credentials.SecretAccessKey, now, region, service, stringToSign)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+credentials.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// Returns the canonical request and its signed headers, the content type, host, date and security token
+func canonicalRequestV4(req *http.Request, body string) (string, string) {
+	headerNames := []string{"content-type", "host", "x-amz-date"}
+	if req.Header.Get("X-Amz-Security-Token") != "" {
+		headerNames = append(headerNames, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, sha256Hex(body)}, "\n"), signedHeaders
+}
+
+func stringToSignV4(amzDate string, scope string, canonicalRequest string) string {
+	return strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+}
+
+// Derives the signing key of the day, region and service from the secret access key
+func signatureV4(secretAccessKey string, now time.Time, region string, service string, stringToSign string) string {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Requests of the AWS Signature Version 4 test suite, signed with its example credentials
+func TestSignV4TestSuite(t *testing.T) {
+	credentials := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name             string
+		contentType      string
+		canonicalRequest string
+		stringToSign     string
+		signature        string
+	}{
+		{
+			name:        "post-x-www-form-urlencoded",
+			contentType: "application/x-www-form-urlencoded",
+			canonicalRequest: "POST\n/\n\ncontent-type:application/x-www-form-urlencoded\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"content-type;host;x-amz-date\n9095672bbd1f56dfc5b65f3e153adc8731a4a654192329106275f4c7b24d0b6e",
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+				"42a5e5bb34198acb3e84da4f085bb7927f2bc277ca766e6d19c73c2154021281",
+			signature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			name:        "post-x-www-form-urlencoded-parameters",
+			contentType: "application/x-www-form-urlencoded; charset=utf8",
+			canonicalRequest: "POST\n/\n\ncontent-type:application/x-www-form-urlencoded; charset=utf8\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\n" +
+				"content-type;host;x-amz-date\n9095672bbd1f56dfc5b65f3e153adc8731a4a654192329106275f4c7b24d0b6e",
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+				"2e1cf7ed91881a30569e46552437e4156c823447bf1781b921b5d486c568dd1c",
+			signature: "1a72ec8f64bd914b0e42e42607c7fbce7fb2c7465f63e3092b3b0d39fa77a6fe",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			body := "Param1=value1"
+			req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", test.contentType)
+			signV4(req, body, credentials, "us-east-1", "service", now)
+
+			canonicalRequest, _ := canonicalRequestV4(req, body)
+			if canonicalRequest != test.canonicalRequest {
+				t.Errorf("canonical request:\n%s\nexpected:\n%s", canonicalRequest, test.canonicalRequest)
+			}
+			stringToSign := stringToSignV4("20150830T123600Z", "20150830/us-east-1/service/aws4_request", canonicalRequest)
+			if stringToSign != test.stringToSign {
+				t.Errorf("string to sign:\n%s\nexpected:\n%s", stringToSign, test.stringToSign)
+			}
+			authorization := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=" + test.signature
+			if got := req.Header.Get("Authorization"); got != authorization {
+				t.Errorf("authorization %q, expected %q", got, authorization)
+			}
+		})
+	}
+}
+
+func TestSignV4SessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://monitoring.us-east-1.amazonaws.com/", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	signV4(req, "", awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}, "us-east-1", "monitoring", time.Now().UTC())
+	if token := req.Header.Get("X-Amz-Security-Token"); token != "token" {
+		t.Errorf("security token header %q, expected %q", token, "token")
+	}
+	if authorization := req.Header.Get("Authorization"); !strings.Contains(authorization, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("security token is not signed: %s", authorization)
+	}
+}
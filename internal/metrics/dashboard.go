@@ -0,0 +1,93 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"encoding/json"
+)
+
+type dashboardPanel struct {
+	title  string
+	expr   string
+	legend string
+	unit   string
+}
+
+// Role panels select the node-role series, which have a role but no node label, node panels match
+// the comma separated roles of the node series
+var dashboardPanels = []dashboardPanel{
+	{title: "CPU requests % by role", expr: `kubesize_cpu_requests_percent{cluster="$cluster",role=~"$role",node=""}`, legend: "{{role}}", unit: "percent"},
+	{title: "Memory requests % by role", expr: `kubesize_memory_requests_percent{cluster="$cluster",role=~"$role",node=""}`, legend: "{{role}}", unit: "percent"},
+	{title: "Available CPU by role", expr: `kubesize_cpu_available_cores{cluster="$cluster",role=~"$role",node=""}`, legend: "{{role}}", unit: "none"},
+	{title: "Available memory by role", expr: `kubesize_memory_available_bytes{cluster="$cluster",role=~"$role",node=""}`, legend: "{{role}}", unit: "bytes"},
+	{title: "Non-terminated pods % of allocatable by role", expr: `100 * kubesize_pods_non_terminated{cluster="$cluster",role=~"$role",node=""} / kubesize_pods_allocatable{cluster="$cluster",role=~"$role",node=""}`, legend: "{{role}}", unit: "percent"},
+	{title: "Unready nodes by role", expr: `kubesize_nodes{cluster="$cluster",role=~"$role",node=""} - kubesize_nodes_ready{cluster="$cluster",role=~"$role",node=""}`, legend: "{{role}}", unit: "none"},
+	{title: "CPU requests % by node", expr: `kubesize_cpu_requests_percent{cluster="$cluster",role=~"(.*,)?($role)(,.*)?",node!=""}`, legend: "{{node}}", unit: "percent"},
+	{title: "Memory requests % by node", expr: `kubesize_memory_requests_percent{cluster="$cluster",role=~"(.*,)?($role)(,.*)?",node!=""}`, legend: "{{node}}", unit: "percent"},
+}
+
+// Returns a Grafana dashboard of the exported metrics with datasource, cluster and role variables
+func GrafanaDashboard(title string) ([]byte, error) {
+	panels := make([]interface{}, 0, len(dashboardPanels))
+	for i, panel := range dashboardPanels {
+		panels = append(panels, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      panel.title,
+			"datasource": "${datasource}",
+			"gridPos":    map[string]int{"h": 8, "w": 12, "x": (i % 2) * 12, "y": (i / 2) * 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": panel.unit},
+				"overrides": []interface{}{},
+			},
+			"targets": []interface{}{map[string]string{"expr": panel.expr, "legendFormat": panel.legend, "refId": "A"}},
+		})
+	}
+
+	variable := func(name string, query string, includeAll bool) map[string]interface{} {
+		return map[string]interface{}{
+			"name":       name,
+			"label":      name,
+			"type":       "query",
+			"datasource": "${datasource}",
+			"query":      query,
+			"refresh":    2,
+			"includeAll": includeAll,
+			"multi":      includeAll,
+			"allValue":   ".+",
+			"sort":       1,
+		}
+	}
+
+	dashboard := map[string]interface{}{
+		"title":         title,
+		"uid":           "kubesize",
+		"tags":          []string{"kubesize", "capacity"},
+		"schemaVersion": 27,
+		"editable":      true,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{"name": "datasource", "label": "datasource", "type": "datasource", "query": "prometheus"},
+				variable("cluster", "label_values(kubesize_pods_allocatable, cluster)", false),
+				variable("role", `label_values(kubesize_pods_allocatable{cluster="$cluster"}, role)`, true),
+			},
+		},
+		"panels": panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
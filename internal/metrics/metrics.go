@@ -0,0 +1,153 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Gauge of capacity data, names follow the Prometheus naming conventions
+type Metric struct {
+	Name   string
+	Help   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Exports the metrics of a sample
+type Sink interface {
+	Export(timestamp time.Time, metrics []Metric) error
+}
+
+// Capacity data shared by clusters, node roles and nodes
+type capacityData struct {
+	nonTermPodCount   int
+	allocatablePods   resource.Quantity
+	allocatableCPU    resource.Quantity
+	requestsCPU       resource.Quantity
+	limitsCPU         resource.Quantity
+	availableCPU      resource.Quantity
+	allocatableMemory resource.Quantity
+	requestsMemory    resource.Quantity
+	limitsMemory      resource.Quantity
+	availableMemory   resource.Quantity
+}
+
+func capacityMetrics(data capacityData, labels map[string]string) []Metric {
+	return []Metric{
+		{Name: "kubesize_pods_allocatable", Help: "Allocatable pods", Labels: labels, Value: float64(data.allocatablePods.Value())},
+		{Name: "kubesize_pods_non_terminated", Help: "Non-terminated pods", Labels: labels, Value: float64(data.nonTermPodCount)},
+		{Name: "kubesize_cpu_allocatable_cores", Help: "Allocatable cpu cores", Labels: labels, Value: capacity.ReadableCPU(data.allocatableCPU)},
+		{Name: "kubesize_cpu_requests_cores", Help: "Requested cpu cores", Labels: labels, Value: capacity.ReadableCPU(data.requestsCPU)},
+		{Name: "kubesize_cpu_limits_cores", Help: "Cpu cores limits", Labels: labels, Value: capacity.ReadableCPU(data.limitsCPU)},
+		{Name: "kubesize_cpu_available_cores", Help: "Allocatable cpu cores not requested", Labels: labels, Value: capacity.ReadableCPU(data.availableCPU)},
+		{Name: "kubesize_cpu_requests_percent", Help: "Percent of allocatable cpu requested", Labels: labels, Value: capacity.Percent(data.requestsCPU, data.allocatableCPU)},
+		{Name: "kubesize_memory_allocatable_bytes", Help: "Allocatable memory bytes", Labels: labels, Value: float64(data.allocatableMemory.Value())},
+		{Name: "kubesize_memory_requests_bytes", Help: "Requested memory bytes", Labels: labels, Value: float64(data.requestsMemory.Value())},
+		{Name: "kubesize_memory_limits_bytes", Help: "Memory bytes limits", Labels: labels, Value: float64(data.limitsMemory.Value())},
+		{Name: "kubesize_memory_available_bytes", Help: "Allocatable memory bytes not requested", Labels: labels, Value: float64(data.availableMemory.Value())},
+		{Name: "kubesize_memory_requests_percent", Help: "Percent of allocatable memory requested", Labels: labels, Value: capacity.Percent(data.requestsMemory, data.allocatableMemory)},
+	}
+}
+
+func ClusterMetrics(data *output.ClusterCapacityData, labels map[string]string) []Metric {
+	metrics := []Metric{
+		{Name: "kubesize_nodes", Help: "Nodes", Labels: labels, Value: float64(data.TotalNodeCount)},
+		{Name: "kubesize_nodes_ready", Help: "Ready nodes", Labels: labels, Value: float64(data.TotalReadyNodeCount)},
+	}
+	return append(metrics, capacityMetrics(capacityData{
+		nonTermPodCount:   data.TotalNonTermPodCount,
+		allocatablePods:   data.TotalAllocatablePods,
+		allocatableCPU:    data.TotalAllocatableCPU,
+		requestsCPU:       data.TotalRequestsCPU,
+		limitsCPU:         data.TotalLimitsCPU,
+		availableCPU:      data.TotalAvailableCPU,
+		allocatableMemory: data.TotalAllocatableMemory,
+		requestsMemory:    data.TotalRequestsMemory,
+		limitsMemory:      data.TotalLimitsMemory,
+		availableMemory:   data.TotalAvailableMemory,
+	}, labels)...)
+}
+
+// Pseudo roles other than *total* are skipped, *total* is labeled as the cluster without a role
+func NodeRoleMetrics(nodeRoleCapacityData map[string]*output.ClusterCapacityData, roleNames []string, labels map[string]string) []Metric {
+	metrics := make([]Metric, 0)
+	for _, role := range roleNames {
+		if strings.HasPrefix(role, "*") && role != "*total*" {
+			continue
+		}
+		roleLabels := copyLabels(labels)
+		if role != "*total*" {
+			roleLabels["role"] = role
+		}
+		metrics = append(metrics, ClusterMetrics(nodeRoleCapacityData[role], roleLabels)...)
+	}
+	return metrics
+}
+
+// Nodes are labeled with their name, comma separated roles and zone, pseudo nodes are skipped
+func NodeMetrics(nodesCapacityData map[string]*output.NodeCapacityData, nodeNames []string, labels map[string]string) []Metric {
+	metrics := make([]Metric, 0)
+	for _, nodeName := range nodeNames {
+		if strings.HasPrefix(nodeName, "*") {
+			continue
+		}
+		data := nodesCapacityData[nodeName]
+		nodeLabels := copyLabels(labels)
+		nodeLabels["node"] = nodeName
+		nodeLabels["role"] = strings.Join(data.Roles.List(), ",")
+		if data.Zone != "" {
+			nodeLabels["zone"] = data.Zone
+		}
+		metrics = append(metrics, capacityMetrics(capacityData{
+			nonTermPodCount:   data.TotalNonTermPodCount,
+			allocatablePods:   data.TotalAllocatablePods,
+			allocatableCPU:    data.TotalAllocatableCPU,
+			requestsCPU:       data.TotalRequestsCPU,
+			limitsCPU:         data.TotalLimitsCPU,
+			availableCPU:      data.TotalAvailableCPU,
+			allocatableMemory: data.TotalAllocatableMemory,
+			requestsMemory:    data.TotalRequestsMemory,
+			limitsMemory:      data.TotalLimitsMemory,
+			availableMemory:   data.TotalAvailableMemory,
+		}, nodeLabels)...)
+	}
+	return metrics
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels)+3)
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Label keys in sorted order so exports are stable
+func SortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
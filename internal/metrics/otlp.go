@@ -0,0 +1,109 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pushes metrics as gauges to an OpenTelemetry collector with OTLP/HTTP JSON encoding
+type OTLP struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+func NewOTLP(endpoint string, headers map[string]string) *OTLP {
+	return &OTLP{Endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/metrics", Headers: headers, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpMetric struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Gauge       struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+func (o *OTLP) Export(timestamp time.Time, metrics []Metric) error {
+	otlpMetrics := make([]*otlpMetric, 0)
+	byName := make(map[string]*otlpMetric)
+	for _, metric := range metrics {
+		if _, ok := byName[metric.Name]; !ok {
+			byName[metric.Name] = &otlpMetric{Name: metric.Name, Description: metric.Help}
+			otlpMetrics = append(otlpMetrics, byName[metric.Name])
+		}
+		dataPoint := otlpDataPoint{TimeUnixNano: strconv.FormatInt(timestamp.UnixNano(), 10), AsDouble: metric.Value, Attributes: make([]otlpAttribute, 0, len(metric.Labels))}
+		for _, key := range SortedLabelKeys(metric.Labels) {
+			dataPoint.Attributes = append(dataPoint.Attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: metric.Labels[key]}})
+		}
+		byName[metric.Name].Gauge.DataPoints = append(byName[metric.Name].Gauge.DataPoints, dataPoint)
+	}
+
+	request := map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: "kubesize"}}},
+			},
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   map[string]string{"name": "github.com/akrzos/kubeSize"},
+				"metrics": otlpMetrics,
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range o.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", o.Endpoint, resp.Status)
+	}
+	return nil
+}
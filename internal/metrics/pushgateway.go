@@ -0,0 +1,93 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pushes metrics in the Prometheus text format to a Pushgateway, replacing the previous push of the same job, cluster
+// and sub-command
+type Pushgateway struct {
+	URL     string
+	Job     string
+	Command string
+	Client  *http.Client
+}
+
+func NewPushgateway(url string, job string, command string) *Pushgateway {
+	return &Pushgateway{URL: strings.TrimSuffix(url, "/"), Job: job, Command: command, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *Pushgateway) Export(timestamp time.Time, metrics []Metric) error {
+	// The cluster label of the metrics and the sub-command are part of the grouping key, so pushes of several clusters
+	// or sub-commands do not replace each other
+	pushURL := p.URL + "/metrics/job/" + url.PathEscape(p.Job)
+	if len(metrics) > 0 && metrics[0].Labels["cluster"] != "" {
+		pushURL += "/cluster/" + url.PathEscape(metrics[0].Labels["cluster"])
+	}
+	if p.Command != "" {
+		pushURL += "/command/" + url.PathEscape(p.Command)
+	}
+
+	var body bytes.Buffer
+	WriteText(&body, metrics)
+	req, err := http.NewRequest(http.MethodPut, pushURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded %s", pushURL, resp.Status)
+	}
+	return nil
+}
+
+// Writes metrics in the Prometheus text exposition format, metrics of the same name are written together
+func WriteText(w io.Writer, metrics []Metric) {
+	names := make([]string, 0)
+	byName := make(map[string][]Metric)
+	for _, metric := range metrics {
+		if _, ok := byName[metric.Name]; !ok {
+			names = append(names, metric.Name)
+		}
+		byName[metric.Name] = append(byName[metric.Name], metric)
+	}
+	labelEscaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, name := range names {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, byName[name][0].Help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		for _, metric := range byName[name] {
+			labels := make([]string, 0, len(metric.Labels))
+			for _, key := range SortedLabelKeys(metric.Labels) {
+				labels = append(labels, key+`="`+labelEscaper.Replace(metric.Labels[key])+`"`)
+			}
+			fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(labels, ","), strconv.FormatFloat(metric.Value, 'f', -1, 64))
+		}
+	}
+}
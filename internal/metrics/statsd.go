@@ -0,0 +1,72 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datagrams are kept below the common safe UDP payload size
+const statsdMaxPacketSize = 1432
+
+// Sends metrics as DogStatsD gauges with the labels as tags
+type StatsD struct {
+	Address string
+}
+
+func (s *StatsD) Export(timestamp time.Time, metrics []Metric) error {
+	conn, err := net.Dial("udp", s.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	for _, metric := range metrics {
+		line := statsdLine(metric)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacketSize {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		_, err = conn.Write(packet.Bytes())
+	}
+	return err
+}
+
+// Commas separate tags, so commas in values such as multiple roles are replaced
+func statsdLine(metric Metric) string {
+	line := metric.Name + ":" + strconv.FormatFloat(metric.Value, 'f', -1, 64) + "|g"
+	tags := make([]string, 0, len(metric.Labels))
+	for _, key := range SortedLabelKeys(metric.Labels) {
+		tags = append(tags, key+":"+strings.NewReplacer(",", "_", "|", "_").Replace(metric.Labels[key]))
+	}
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line
+}
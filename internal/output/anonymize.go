@@ -0,0 +1,151 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// Hashes a name so the same name always maps to the same value, pseudo names such as *total* and <none> are kept
+func anonymize(prefix string, name string) string {
+	if name == "" || strings.HasPrefix(name, "*") || name == "<none>" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(prefix + "/" + name))
+	return prefix + "-" + hex.EncodeToString(sum[:])[:10]
+}
+
+// Anonymizes names and re-sorts them by their anonymized value, pseudo names stay in place at the end
+func anonymizeNames(prefix string, names []string) []string {
+	anonymized := make([]string, 0, len(names))
+	pseudo := make([]string, 0)
+	for _, name := range names {
+		if strings.HasPrefix(name, "*") {
+			pseudo = append(pseudo, name)
+		} else {
+			anonymized = append(anonymized, anonymize(prefix, name))
+		}
+	}
+	sort.Strings(anonymized)
+	return append(anonymized, pseudo...)
+}
+
+func anonymizeNodeRoleData(nodeRoleCapacityData map[string]*ClusterCapacityData) map[string]*ClusterCapacityData {
+	anonymized := make(map[string]*ClusterCapacityData, len(nodeRoleCapacityData))
+	for role, data := range nodeRoleCapacityData {
+		roleData := *data
+		if data.RequestsMinMax != nil {
+			minMax := *data.RequestsMinMax
+			minMax.MinRequestsCPUNode = anonymize("node", minMax.MinRequestsCPUNode)
+			minMax.MaxRequestsCPUNode = anonymize("node", minMax.MaxRequestsCPUNode)
+			minMax.MinRequestsMemoryNode = anonymize("node", minMax.MinRequestsMemoryNode)
+			minMax.MaxRequestsMemoryNode = anonymize("node", minMax.MaxRequestsMemoryNode)
+			roleData.RequestsMinMax = &minMax
+		}
+		anonymized[role] = &roleData
+	}
+	return anonymized
+}
+
+func anonymizeNodeData(nodesCapacityData map[string]*NodeCapacityData, sortedNodeNames []string, nodesByRole map[string][]string) (map[string]*NodeCapacityData, []string, map[string][]string) {
+	anonymized := make(map[string]*NodeCapacityData, len(nodesCapacityData))
+	for nodeName, data := range nodesCapacityData {
+		nodeData := *data
+		nodeData.InternalIP = anonymize("ip", nodeData.InternalIP)
+		nodeData.Pods = anonymizePodData(nodeData.Pods)
+		if nodeData.Labels != nil {
+			nodeData.Labels = anonymizeLabels(nodeData.Labels)
+		}
+		anonymized[anonymize("node", nodeName)] = &nodeData
+	}
+	anonymizedByRole := make(map[string][]string, len(nodesByRole))
+	for role, nodeNames := range nodesByRole {
+		anonymizedByRole[role] = anonymizeNames("node", nodeNames)
+	}
+	return anonymized, anonymizeNames("node", sortedNodeNames), anonymizedByRole
+}
+
+func anonymizeNamespaceData(namespaceCapacityData map[string]*NamespaceCapacityData, sortedNamespaceNames []string) (map[string]*NamespaceCapacityData, []string) {
+	anonymized := make(map[string]*NamespaceCapacityData, len(namespaceCapacityData))
+	for namespace, data := range namespaceCapacityData {
+		anonymized[anonymize("namespace", namespace)] = data
+	}
+	return anonymized, anonymizeNames("namespace", sortedNamespaceNames)
+}
+
+func anonymizePendingPodData(pendingPods []PendingPodData) []PendingPodData {
+	anonymized := make([]PendingPodData, 0, len(pendingPods))
+	for _, pod := range pendingPods {
+		pod.Namespace = anonymize("namespace", pod.Namespace)
+		pod.Name = anonymize("pod", pod.Name)
+		// Scheduler messages can include node names
+		pod.Message = ""
+		anonymized = append(anonymized, pod)
+	}
+	sort.Slice(anonymized, func(i, j int) bool {
+		if anonymized[i].Namespace != anonymized[j].Namespace {
+			return anonymized[i].Namespace < anonymized[j].Namespace
+		}
+		return anonymized[i].Name < anonymized[j].Name
+	})
+	return anonymized
+}
+
+func anonymizePodData(pods []PodCapacityData) []PodCapacityData {
+	if pods == nil {
+		return nil
+	}
+	anonymized := make([]PodCapacityData, 0, len(pods))
+	for _, pod := range pods {
+		pod.Namespace = anonymize("namespace", pod.Namespace)
+		pod.Name = anonymize("pod", pod.Name)
+		anonymized = append(anonymized, pod)
+	}
+	sort.Slice(anonymized, func(i, j int) bool {
+		if anonymized[i].Namespace != anonymized[j].Namespace {
+			return anonymized[i].Namespace < anonymized[j].Namespace
+		}
+		return anonymized[i].Name < anonymized[j].Name
+	})
+	return anonymized
+}
+
+// The hostname label usually matches the node name
+func anonymizeLabels(labels map[string]string) map[string]string {
+	anonymized := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if key == "kubernetes.io/hostname" {
+			value = anonymize("node", value)
+		}
+		anonymized[key] = value
+	}
+	return anonymized
+}
+
+// Only node and namespace group names are anonymized, role names are kept as in the node-role output
+func anonymizeUsageData(usageData map[string]*UsageData, sortedNames []string, groupBy string) (map[string]*UsageData, []string) {
+	if groupBy != "node" && groupBy != "namespace" {
+		return usageData, sortedNames
+	}
+	anonymized := make(map[string]*UsageData, len(usageData))
+	for name, data := range usageData {
+		anonymized[anonymize(groupBy, name)] = data
+	}
+	return anonymized, anonymizeNames(groupBy, sortedNames)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// All color codes are the same length so colored cells keep the table aligned
+const (
+	colorDefault string = "\x1b[39m"
+	colorYellow  string = "\x1b[33m"
+	colorRed     string = "\x1b[31m"
+	colorReset   string = "\x1b[0m"
+)
+
+// Color is only used when writing to a terminal
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return (fileInfo.Mode() & os.ModeCharDevice) != 0
+}
+
+// Colors a cell yellow or red if used exceeds the warning or critical threshold percent of allocatable
+func (displayOptions DisplayOptions) highlight(cell string, used float64, allocatable float64) string {
+	if !displayOptions.Color || allocatable <= 0 {
+		return cell
+	}
+	utilization := used / allocatable * 100
+	switch {
+	case utilization > displayOptions.CritThreshold:
+		return colorRed + cell + colorReset
+	case utilization > displayOptions.WarnThreshold:
+		return colorYellow + cell + colorReset
+	}
+	return cell
+}
+
+func (displayOptions DisplayOptions) highlightQuantity(cell string, used resource.Quantity, allocatable resource.Quantity) string {
+	return displayOptions.highlight(cell, float64(used.MilliValue()), float64(allocatable.MilliValue()))
+}
+
+// colorWriter wraps every uncolored cell in the default color so every cell carries the same escape code overhead,
+// otherwise tabwriter would count the escape codes of highlighted cells as width and misalign the columns
+type colorWriter struct {
+	tabWriter *tabwriter.Writer
+	line      []byte
+}
+
+func (c *colorWriter) Write(p []byte) (int, error) {
+	c.line = append(c.line, p...)
+	for {
+		i := bytes.IndexByte(c.line, '\n')
+		if i < 0 {
+			break
+		}
+		if err := c.writeLine(string(c.line[:i+1])); err != nil {
+			return 0, err
+		}
+		c.line = c.line[i+1:]
+	}
+	return len(p), nil
+}
+
+func (c *colorWriter) Flush() error {
+	if len(c.line) > 0 {
+		if err := c.writeLine(string(c.line)); err != nil {
+			return err
+		}
+		c.line = nil
+	}
+	return c.tabWriter.Flush()
+}
+
+func (c *colorWriter) writeLine(line string) error {
+	cells := strings.Split(line, "\t")
+	for i, cell := range cells {
+		if !strings.HasPrefix(cell, "\x1b[") {
+			newline := strings.HasSuffix(cell, "\n")
+			cells[i] = colorDefault + strings.TrimSuffix(cell, "\n") + colorReset
+			if newline {
+				cells[i] += "\n"
+			}
+		}
+	}
+	_, err := c.tabWriter.Write([]byte(strings.Join(cells, "\t")))
+	return err
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestColorWriterAlignment(t *testing.T) {
+	displayOptions := DisplayOptions{Color: true, WarnThreshold: 80, CritThreshold: 95}
+	var buf bytes.Buffer
+	w := newTableWriter(&buf, displayOptions)
+	fmt.Fprintf(w, "NAME\tREQUESTS\tLIMITS\t\n")
+	fmt.Fprintf(w, "node-a\t%s\t%s\t\n", displayOptions.highlight("99", 99, 100), "1")
+	fmt.Fprintf(w, "node-b\t%s\t%s\t\n", displayOptions.highlight("85", 85, 100), "2")
+	fmt.Fprintf(w, "node-c\t%s\t%s\t\n", displayOptions.highlight("10", 10, 100), "3")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, colorRed+"99"+colorReset) {
+		t.Errorf("expected critical cell to be red, got %q", output)
+	}
+	if !strings.Contains(output, colorYellow+"85"+colorReset) {
+		t.Errorf("expected warning cell to be yellow, got %q", output)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(escapeCode.ReplaceAllString(output, ""), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), lines)
+	}
+	header := lines[0]
+	for _, line := range lines[1:] {
+		for _, column := range []string{"REQUESTS", "LIMITS"} {
+			index := strings.Index(header, column)
+			if index >= len(line) || line[index] == ' ' || line[index-1] != ' ' {
+				t.Errorf("column %s misaligned:\n%s\n%s", column, header, line)
+			}
+		}
+	}
+}
+
+func TestHighlightWithoutColor(t *testing.T) {
+	displayOptions := DisplayOptions{Color: false, WarnThreshold: 80, CritThreshold: 95}
+	if cell := displayOptions.highlight("99", 99, 100); cell != "99" {
+		t.Errorf("expected uncolored cell, got %q", cell)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	deltaUp   string = "▲"
+	deltaDown string = "▼"
+)
+
+var escapeCode = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Deltas keeps the table cells of the previous watch sample to compare the current sample against
+type Deltas struct {
+	previous map[string][]string
+	current  map[string][]string
+	table    int
+}
+
+func NewDeltas() *Deltas {
+	return &Deltas{current: make(map[string][]string)}
+}
+
+// Starts a new watch sample, the cells of the finished sample become the previous sample
+func (d *Deltas) NextSample() {
+	d.previous = d.current
+	d.current = make(map[string][]string)
+	d.table = 0
+}
+
+// deltaWriter appends the change since the previous sample to every numeric cell of a table
+type deltaWriter struct {
+	out    io.Writer
+	deltas *Deltas
+	table  int
+	rows   map[string]int
+	line   []byte
+}
+
+func newDeltaWriter(out io.Writer, deltas *Deltas) *deltaWriter {
+	deltas.table++
+	return &deltaWriter{out: out, deltas: deltas, table: deltas.table, rows: make(map[string]int)}
+}
+
+func (d *deltaWriter) Write(p []byte) (int, error) {
+	d.line = append(d.line, p...)
+	for {
+		i := bytes.IndexByte(d.line, '\n')
+		if i < 0 {
+			break
+		}
+		if err := d.writeLine(string(d.line[:i]), "\n"); err != nil {
+			return 0, err
+		}
+		d.line = d.line[i+1:]
+	}
+	return len(p), nil
+}
+
+func (d *deltaWriter) Flush() error {
+	if len(d.line) > 0 {
+		if err := d.writeLine(string(d.line), ""); err != nil {
+			return err
+		}
+		d.line = nil
+	}
+	return nil
+}
+
+func (d *deltaWriter) writeLine(line string, newline string) error {
+	cells := strings.Split(line, "\t")
+	key := d.rowKey(cells)
+	d.deltas.current[key] = cells
+	if previous, ok := d.deltas.previous[key]; ok {
+		for i := range cells {
+			if i < len(previous) {
+				cells[i] += cellDelta(previous[i], cells[i])
+			}
+		}
+	}
+	_, err := io.WriteString(d.out, strings.Join(cells, "\t")+newline)
+	return err
+}
+
+// Rows are matched by their first cell, or by line number when the first cell is a value such as in the cluster table
+func (d *deltaWriter) rowKey(cells []string) string {
+	name := escapeCode.ReplaceAllString(cells[0], "")
+	if _, ok := parseCell(name); ok || name == "" {
+		name = "#"
+	}
+	d.rows[name]++
+	return fmt.Sprintf("%d/%s/%d", d.table, name, d.rows[name])
+}
+
+func cellDelta(previous string, current string) string {
+	previousValue, ok := parseCell(escapeCode.ReplaceAllString(previous, ""))
+	if !ok {
+		return ""
+	}
+	currentValue, ok := parseCell(escapeCode.ReplaceAllString(current, ""))
+	if !ok {
+		return ""
+	}
+	delta := currentValue.MilliValue() - previousValue.MilliValue()
+	if delta == 0 {
+		return ""
+	}
+	arrow := deltaUp
+	if delta < 0 {
+		arrow = deltaDown
+		delta = -delta
+	}
+	// Keep the precision of the displayed value
+	if i := strings.Index(current, "."); i >= 0 && !strings.ContainsAny(current, "KMGTPEkmun") {
+		decimals := len(escapeCode.ReplaceAllString(current[i+1:], ""))
+		return " " + arrow + strconv.FormatFloat(float64(delta)/1000, 'f', decimals, 64)
+	}
+	deltaQuantity := resource.NewMilliQuantity(delta, currentValue.Format)
+	return " " + arrow + deltaQuantity.String()
+}
+
+func parseCell(cell string) (resource.Quantity, bool) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return resource.Quantity{}, false
+	}
+	quantity, err := resource.ParseQuantity(cell)
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	return quantity, true
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Key casing of the json and yaml output, the Go field names or their camelCase
+const (
+	GoKeyCase    string = "go"
+	CamelKeyCase string = "camel"
+)
+
+var keyCases = []string{GoKeyCase, CamelKeyCase}
+
+func ValidateKeyCase(keyCase string) error {
+	for _, validKeyCase := range keyCases {
+		if keyCase == validKeyCase {
+			return nil
+		}
+	}
+	return fmt.Errorf("key case \"%s\" is invalid. Valid values are %v", keyCase, keyCases)
+}
+
+// Lowercases the leading initialism or word of a Go field name, TotalCPUCores is totalCPUCores and APIRequests is
+// apiRequests
+func camelCase(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// The last capital of an initialism followed by a word starts the word
+	if upper > 1 && upper < len(runes) && unicode.IsLower(runes[upper]) {
+		upper--
+	}
+	if upper == 0 {
+		upper = 1
+	}
+	for i := 0; i < upper && i < len(runes); i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// Fields of a struct in declaration order, marshaled as a json object
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		rawKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		rawValue, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(rawKey)
+		buf.WriteString(":")
+		buf.Write(rawValue)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// Rebuilds the data with the camelCase names of its struct fields. Map keys are names of nodes, roles, namespaces or
+// labels and are kept as is, values with their own json encoding such as quantities and times are marshaled as is.
+func camelCaseKeys(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if value.Type().Implements(marshalerType) {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return nil, nil
+		}
+		return marshalRaw(value.Interface())
+	}
+	if value.Kind() != reflect.Ptr && reflect.PtrTo(value.Type()).Implements(marshalerType) {
+		addressable := reflect.New(value.Type())
+		addressable.Elem().Set(value)
+		return marshalRaw(addressable.Interface())
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return camelCaseKeys(value.Elem())
+	case reflect.Struct:
+		object := &orderedObject{}
+		if err := addFields(object, value); err != nil {
+			return nil, err
+		}
+		return object, nil
+	case reflect.Map:
+		if value.IsNil() {
+			return nil, nil
+		}
+		object := map[string]interface{}{}
+		iter := value.MapRange()
+		for iter.Next() {
+			converted, err := camelCaseKeys(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			object[fmt.Sprint(iter.Key().Interface())] = converted
+		}
+		return object, nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil, nil
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			converted, err := camelCaseKeys(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	}
+	return marshalRaw(value.Interface())
+}
+
+// Adds the exported fields of a struct with the names and omitempty of their json tags, fields of embedded structs are
+// promoted as encoding/json does
+func addFields(object *orderedObject, value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag[0] == "" {
+			if err := addFields(object, value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		omitEmpty := len(tag) > 1 && tag[1] == "omitempty"
+		if omitEmpty && isEmpty(value.Field(i)) {
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = camelCase(field.Name)
+		}
+		converted, err := camelCaseKeys(value.Field(i))
+		if err != nil {
+			return err
+		}
+		object.keys = append(object.keys, name)
+		object.values = append(object.values, converted)
+	}
+	return nil
+}
+
+// The empty values omitted by omitempty
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	}
+	return false
+}
+
+func marshalRaw(data interface{}) (interface{}, error) {
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(rawData), nil
+}
@@ -18,23 +18,81 @@ package output
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"math"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/akrzos/kubeSize/internal/capacity"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/apimachinery/pkg/util/sets"
-	"sigs.k8s.io/yaml"
+	"k8s.io/cli-runtime/pkg/printers"
 )
 
 const (
 	tableDisplay string = "table"
 	jsonDisplay  string = "json"
 	yamlDisplay  string = "yaml"
+	nameDisplay  string = "name"
+	wideDisplay  string = "wide"
 )
 
+// Same tabwriter settings kubectl uses for table output
+const (
+	tabwriterMinWidth = 6
+	tabwriterWidth    = 4
+	tabwriterPadding  = 3
+	tabwriterPadChar  = ' '
+	tabwriterFlags    = 0
+)
+
+type DisplayOptions struct {
+	Default            bool
+	Headers            bool
+	EphemeralStorage   bool
+	Format             string
+	Color              bool
+	Plain              bool
+	WarnThreshold      float64
+	CritThreshold      float64
+	Anonymize          bool
+	Watch              bool
+	Timestamp          time.Time
+	Deltas             *Deltas
+	ExcludeTerminating bool
+	ExcludeStatic      bool
+	BestEffort         bool
+	PinnedRoles        []string
+	NoneLast           bool
+	ShowLabels         bool
+	LabelColumns       []string
+	// Includes the percent of allocatable pods, cpu and memory not requested in node table output
+	AvailablePercent bool
+	// Includes the persistent volumes attached against the attach limits in cluster and node table output
+	Volumes bool
+	// Includes the node and image filesystems of the kubelets in node table output
+	Disk       bool
+	CPUUnit    string
+	MemoryUnit string
+	Thresholds *Thresholds
+	Efficiency bool
+	// Schema version of the json and yaml output, the latest when empty
+	Schema string
+	// Key casing of the json and yaml output, the Go field names when empty
+	KeyCase string
+	// Prints json output on a single line
+	Compact bool
+	Out     io.Writer
+}
+
 // Available = allocatable - (scheduled aka non-term pod or requests.cpu/memory)
 type ClusterCapacityData struct {
 	TotalNodeCount                     int
@@ -43,6 +101,13 @@ type ClusterCapacityData struct {
 	TotalUnschedulableNodeCount        int
 	TotalPodCount                      int
 	TotalNonTermPodCount               int
+	TotalTerminatingPodCount           int
+	TotalStaticPodCount                int
+	TotalBestEffortPodCount            int
+	TotalStaticRequestsCPU             resource.Quantity
+	TotalStaticRequestsCPUCores        float64
+	TotalStaticRequestsMemory          resource.Quantity
+	TotalStaticRequestsMemoryGiB       float64
 	TotalCapacityPods                  resource.Quantity
 	TotalCapacityCPU                   resource.Quantity
 	TotalCapacityCPUCores              float64
@@ -76,6 +141,28 @@ type ClusterCapacityData struct {
 	TotalLimitsEphemeralStorageGB      float64
 	TotalAvailableEphemeralStorage     resource.Quantity
 	TotalAvailableEphemeralStorageGB   float64
+	// Persistent volumes attached to the nodes against their attach limits and the nodes at their limit or past
+	// --warn-threshold percent of it, set with --volumes
+	TotalAttachedVolumes     int                 `json:",omitempty"`
+	TotalAttachLimit         int64               `json:",omitempty"`
+	AtAttachLimitNodeCount   int                 `json:",omitempty"`
+	NearAttachLimitNodeCount int                 `json:",omitempty"`
+	RequestsMinMax           *RequestsMinMaxData `json:",omitempty"`
+}
+
+// Least and most loaded node of a node-role by percent of allocatable requested, and the standard deviation of the
+// percent across the nodes of the role to quantify how unevenly the role is packed
+type RequestsMinMaxData struct {
+	MinRequestsCPUPercent       float64
+	MinRequestsCPUNode          string
+	MaxRequestsCPUPercent       float64
+	MaxRequestsCPUNode          string
+	MinRequestsMemoryPercent    float64
+	MinRequestsMemoryNode       string
+	MaxRequestsMemoryPercent    float64
+	MaxRequestsMemoryNode       string
+	StdDevRequestsCPUPercent    float64
+	StdDevRequestsMemoryPercent float64
 }
 
 type ClusterSizeData struct {
@@ -120,9 +207,24 @@ type ClusterSizeData struct {
 type NodeCapacityData struct {
 	TotalPodCount                      int
 	TotalNonTermPodCount               int
+	TotalTerminatingPodCount           int
+	TotalStaticPodCount                int
+	TotalBestEffortPodCount            int
+	TotalStaticRequestsCPU             resource.Quantity
+	TotalStaticRequestsCPUCores        float64
+	TotalStaticRequestsMemory          resource.Quantity
+	TotalStaticRequestsMemoryGiB       float64
 	Roles                              sets.String
 	Ready                              bool
 	Schedulable                        bool
+	KubeletVersion                     string
+	InstanceType                       string
+	Zone                               string
+	TaintCount                         int
+	InternalIP                         string
+	LastHeartbeatTime                  *time.Time        `json:",omitempty"`
+	HeartbeatStale                     bool              `json:",omitempty"`
+	Labels                             map[string]string `json:",omitempty"`
 	TotalCapacityPods                  resource.Quantity
 	TotalCapacityCPU                   resource.Quantity
 	TotalCapacityCPUCores              float64
@@ -156,11 +258,59 @@ type NodeCapacityData struct {
 	TotalLimitsEphemeralStorageGB      float64
 	TotalAvailableEphemeralStorage     resource.Quantity
 	TotalAvailableEphemeralStorageGB   float64
+	// Percent of allocatable pods, cpu and memory not requested
+	AvailablePodsPercent   float64
+	AvailableCPUPercent    float64
+	AvailableMemoryPercent float64
+	// Persistent volumes attached against the attach limit, 0 when unknown, set with --volumes
+	AttachedVolumes        int               `json:",omitempty"`
+	AttachLimit            int64             `json:",omitempty"`
+	AttachedVolumesPercent float64           `json:",omitempty"`
+	Disk                   *NodeDiskData     `json:",omitempty"`
+	Pods                   []PodCapacityData `json:",omitempty"`
+}
+
+// Node filesystem (nodefs) and image filesystem (imagefs) of the kubelet summary API, set with --disk
+type NodeDiskData struct {
+	NodeFs  FsData
+	ImageFs FsData
+}
+
+type FsData struct {
+	CapacityGB  float64
+	UsedGB      float64
+	AvailableGB float64
+	UsedPercent float64
+}
+
+type PodCapacityData struct {
+	Namespace                  string
+	Name                       string
+	Phase                      string
+	RequestsCPU                resource.Quantity
+	RequestsCPUCores           float64
+	LimitsCPU                  resource.Quantity
+	LimitsCPUCores             float64
+	RequestsMemory             resource.Quantity
+	RequestsMemoryGiB          float64
+	LimitsMemory               resource.Quantity
+	LimitsMemoryGiB            float64
+	RequestsEphemeralStorage   resource.Quantity
+	RequestsEphemeralStorageGB float64
+	LimitsEphemeralStorage     resource.Quantity
+	LimitsEphemeralStorageGB   float64
 }
 
 type NamespaceCapacityData struct {
 	TotalPodCount                   int
 	TotalNonTermPodCount            int
+	TotalTerminatingPodCount        int
+	TotalStaticPodCount             int
+	TotalBestEffortPodCount         int
+	TotalStaticRequestsCPU          resource.Quantity
+	TotalStaticRequestsCPUCores     float64
+	TotalStaticRequestsMemory       resource.Quantity
+	TotalStaticRequestsMemoryGiB    float64
 	TotalUnassignedNodePodCount     int
 	TotalRequestsCPU                resource.Quantity
 	TotalRequestsCPUCores           float64
@@ -176,247 +326,874 @@ type NamespaceCapacityData struct {
 	TotalLimitsEphemeralStorageGB   float64
 }
 
-func DisplayClusterData(clusterCapacityData ClusterCapacityData, displayDefault bool, displayHeaders bool, displayEphemeralStorage bool, displayFormat string) {
-	switch displayFormat {
-	case jsonDisplay:
-		jsonClusterData, err := json.MarshalIndent(&clusterCapacityData, "", "  ")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(string(jsonClusterData))
-	case yamlDisplay:
-		yamlClusterData, err := yaml.Marshal(clusterCapacityData)
+// Capacity data of the subtree of a namespace in a hierarchical namespace tree, the namespace and all its descendants,
+// with the cpu and memory requests quota of the subtree
+type NamespaceTreeData struct {
+	Namespace          string
+	Parent             string `json:",omitempty"`
+	Depth              int
+	Capacity           *NamespaceCapacityData
+	QuotaUsedCPU       resource.Quantity
+	QuotaUsedCPUCores  float64
+	QuotaHardCPU       resource.Quantity
+	QuotaHardCPUCores  float64
+	QuotaUsedMemory    resource.Quantity
+	QuotaUsedMemoryGiB float64
+	QuotaHardMemory    resource.Quantity
+	QuotaHardMemoryGiB float64
+}
+
+type PendingPodData struct {
+	Namespace         string
+	Name              string
+	Reason            string
+	Message           string
+	RequestsCPU       resource.Quantity
+	RequestsCPUCores  float64
+	RequestsMemory    resource.Quantity
+	RequestsMemoryGiB float64
+}
+
+// Requested, used (from the metrics API) and limit of cpu and memory of a group, percents are of allocatable
+type UsageData struct {
+	AllocatableCPU        resource.Quantity
+	AllocatableCPUCores   float64
+	RequestsCPU           resource.Quantity
+	RequestsCPUCores      float64
+	RequestsCPUPercent    float64
+	UsedCPU               resource.Quantity
+	UsedCPUCores          float64
+	UsedCPUPercent        float64
+	LimitsCPU             resource.Quantity
+	LimitsCPUCores        float64
+	LimitsCPUPercent      float64
+	AllocatableMemory     resource.Quantity
+	AllocatableMemoryGiB  float64
+	RequestsMemory        resource.Quantity
+	RequestsMemoryGiB     float64
+	RequestsMemoryPercent float64
+	UsedMemory            resource.Quantity
+	UsedMemoryGiB         float64
+	UsedMemoryPercent     float64
+	LimitsMemory          resource.Quantity
+	LimitsMemoryGiB       float64
+	LimitsMemoryPercent   float64
+	// Used / requests, 0 without requests
+	CPUEfficiencyPercent    float64
+	MemoryEfficiencyPercent float64
+	// Only with the ephemeral storage usage of the summary API of the kubelets
+	EphemeralStorage *StorageUsageData `json:",omitempty"`
+}
+
+type StorageUsageData struct {
+	Allocatable     resource.Quantity
+	AllocatableGB   float64
+	Requests        resource.Quantity
+	RequestsGB      float64
+	RequestsPercent float64
+	Used            resource.Quantity
+	UsedGB          float64
+	UsedPercent     float64
+	Limits          resource.Quantity
+	LimitsGB        float64
+	LimitsPercent   float64
+}
+
+// Capacity health score out of 100 and the signals it was graded on
+type ScoreData struct {
+	Score   int
+	Grade   string
+	Signals []ScoreSignal
+}
+
+type ScoreSignal struct {
+	Name    string
+	Value   float64
+	Penalty int
+	Reason  string
+}
+
+// Node roles with the request utilization spread of their nodes before and after the suggested evictions
+type RebalanceData struct {
+	Roles     []RebalanceRoleData
+	Evictions []RebalanceEvictionData
+}
+
+// Spreads are percentage points between the most and least requested node of the role
+type RebalanceRoleData struct {
+	Role               string
+	NodeCount          int
+	Imbalanced         bool
+	CPUSpreadBefore    float64
+	CPUSpreadAfter     float64
+	MemorySpreadBefore float64
+	MemorySpreadAfter  float64
+	EvictionCount      int
+	Nodes              []RebalanceNodeData
+}
+
+// Percent of the allocatable cpu and memory of a node requested before and after the suggested evictions
+type RebalanceNodeData struct {
+	Node         string
+	CPUBefore    float64
+	CPUAfter     float64
+	MemoryBefore float64
+	MemoryAfter  float64
+}
+
+type RebalanceEvictionData struct {
+	Role           string
+	Namespace      string
+	Pod            string
+	From           string
+	To             string
+	RequestsCPU    resource.Quantity
+	RequestsMemory resource.Quantity
+}
+
+// Capacity preempting the pods of lower priority than Priority would free on each node role and node
+type PreemptionData struct {
+	Priority int32
+	Roles    []PreemptionCapacityData
+	Nodes    []PreemptionCapacityData
+}
+
+// Available capacity before and after the preemptible pods are preempted, over-committed nodes add no available
+// capacity to their roles
+type PreemptionCapacityData struct {
+	Name                    string
+	NodeCount               int
+	Roles                   []string `json:",omitempty"`
+	PodCount                int
+	PreemptiblePodCount     int
+	AvailablePods           int
+	AvailablePodsAfter      int
+	AvailableCPU            resource.Quantity
+	AvailableCPUCores       float64
+	FreedCPU                resource.Quantity
+	FreedCPUCores           float64
+	AvailableCPUAfter       resource.Quantity
+	AvailableCPUAfterCores  float64
+	AvailableMemory         resource.Quantity
+	AvailableMemoryGiB      float64
+	FreedMemory             resource.Quantity
+	FreedMemoryGiB          float64
+	AvailableMemoryAfter    resource.Quantity
+	AvailableMemoryAfterGiB float64
+}
+
+// Capacity available to the pods of each priority tier once the pods of lower priority are preempted
+type PriorityTiersData struct {
+	Tiers []PriorityTierData
+}
+
+// PodCount counts the pods at or above the priority, and the static pods that can not be preempted
+type PriorityTierData struct {
+	Priority           int32
+	Role               string
+	PodCount           int
+	AvailablePods      int
+	AvailableCPU       resource.Quantity
+	AvailableCPUCores  float64
+	AvailableMemory    resource.Quantity
+	AvailableMemoryGiB float64
+}
+
+// Node drains and zone losses the pod disruption budgets block
+type DisruptionData struct {
+	Budgets []BudgetDisruptionData
+	Nodes   []NodeDisruptionData
+	Zones   []ZoneDisruptionData
+}
+
+// A pod disruption budget with the nodes whose drain it blocks, their roles, and the zones whose loss violates it
+type BudgetDisruptionData struct {
+	Namespace              string
+	Name                   string
+	PodCount               int
+	HealthyPodCount        int
+	DesiredHealthyPodCount int
+	DisruptionsAllowed     int
+	BlockedNodes           []string
+	BlockedRoles           []string
+	ViolatedZones          []string
+}
+
+type NodeDisruptionData struct {
+	Node            string
+	Roles           []string
+	Zone            string
+	HealthyPodCount int
+	Drainable       bool
+	BlockingBudgets []string
+}
+
+type ZoneDisruptionData struct {
+	Zone            string
+	NodeCount       int
+	HealthyPodCount int
+	Survivable      bool
+	ViolatedBudgets []string
+}
+
+// Nodes ranked by their risk of memory pressure evictions and OOM kills
+type EvictionRiskData struct {
+	Nodes []NodeEvictionRiskData
+}
+
+// Percents are of the allocatable memory, the eviction headroom is the memory the node can still use before the kubelet
+// hard eviction threshold
+type NodeEvictionRiskData struct {
+	Node                      string
+	Roles                     []string
+	Risk                      string
+	AllocatableMemory         resource.Quantity
+	LimitsMemory              resource.Quantity
+	LimitsPercent             float64
+	UsageMemory               resource.Quantity
+	UsagePercent              float64
+	EvictionHeadroomMemory    resource.Quantity
+	EvictionHeadroomMemoryGiB float64
+	UnlimitedMemoryPods       int
+	Reasons                   []string
+}
+
+// Capacity not allocatable to pods of each node role, including the *total*, and of each node
+type GapData struct {
+	NodeRoles []CapacityGapData
+	Nodes     []CapacityGapData
+}
+
+// The gap is capacity minus allocatable, the share reserved for the system, the kubelet and eviction thresholds, and
+// its percent of capacity. Nodes counts the nodes of a role, Roles are those of a node and Outlier is why the gap of a
+// node is outsized for its role.
+type CapacityGapData struct {
+	Name                          string
+	Nodes                         int      `json:",omitempty"`
+	Roles                         []string `json:",omitempty"`
+	CapacityCPUCores              float64
+	AllocatableCPUCores           float64
+	GapCPUCores                   float64
+	GapCPUPercent                 float64
+	CapacityMemoryGiB             float64
+	AllocatableMemoryGiB          float64
+	GapMemoryGiB                  float64
+	GapMemoryPercent              float64
+	CapacityEphemeralStorageGB    float64
+	AllocatableEphemeralStorageGB float64
+	GapEphemeralStorageGB         float64
+	GapEphemeralStoragePercent    float64
+	Outlier                       string `json:",omitempty"`
+}
+
+// Pod density of each node role against the practical pods per core and per node limits of the kubelet
+type DensityData struct {
+	NodeRoles []NodeRoleDensityData
+}
+
+// Densities count the non-terminated pods on the nodes of the role, MaxPodsPerNode is that of its densest node. Status
+// is ok, near when a density passes --warn-threshold percent of its limit or over when it passes the limit.
+type NodeRoleDensityData struct {
+	Role                 string
+	Nodes                int
+	Pods                 int
+	AllocatableCPUCores  float64
+	AllocatableMemoryGiB float64
+	PodsPerCore          float64
+	PodsPerGiB           float64
+	PodsPerNode          float64
+	MaxPodsPerNode       int
+	DensestNode          string
+	Status               string
+	Reasons              []string
+}
+
+// Pod capacity of the nodes against the pod IPs their network can assign, Discrepancies counts the nodes whose
+// max-pods exceed a limit
+type MaxPodsData struct {
+	Nodes         []NodeMaxPodsData
+	Discrepancies int
+}
+
+// Limits are 0 when unknown
+type NodeMaxPodsData struct {
+	Node         string
+	InstanceType string
+	MaxPods      int64
+	PodCIDR      string
+	CIDRMaxPods  int64
+	ENIMaxPods   int64
+	Reasons      []string
+}
+
+// Allocatable cpu of each node split into the cores the static CPU manager pins to containers and the shared pool,
+// StaticNodes counts the nodes with the static policy
+type CPUManagerData struct {
+	Nodes       []NodeCPUManagerData
+	Total       NodeCPUManagerData
+	StaticNodes int
+}
+
+// Policy is that of the kubelet, empty when its configuration could not be read. The shared pool is allocatable minus
+// the exclusive cores and the shared requests are the cpu requests of the containers without exclusive cores.
+type NodeCPUManagerData struct {
+	Node                    string
+	Roles                   []string `json:",omitempty"`
+	Policy                  string   `json:",omitempty"`
+	AllocatableCPUCores     float64
+	ExclusiveCPUCores       float64
+	ExclusivePods           int
+	SharedPoolCPUCores      float64
+	SharedRequestsCPUCores  float64
+	SharedAvailableCPUCores float64
+	SharedRequestsPercent   float64
+}
+
+// Memory and hugepages of the NUMA zones of each node, Fragmented counts the rows whose available amount no single
+// NUMA zone can hold on nodes whose topology manager aligns resources to one NUMA zone
+type NUMAData struct {
+	Resources  []NodeNUMAData
+	Fragmented int
+}
+
+// Amounts are in GiB, the largest zone is the NUMA zone with the most available, the most a container aligned to one
+// NUMA zone can get
+type NodeNUMAData struct {
+	Node                    string
+	Policy                  string
+	Resource                string
+	AllocatableGiB          float64
+	AvailableGiB            float64
+	LargestZone             string
+	LargestZoneAvailableGiB float64
+	ZonesAvailableGiB       map[string]float64
+	Reasons                 []string
+}
+
+// Pod IPs of each IP family, summed over the nodes, and of each pod CIDR of each node
+type PodIPsData struct {
+	Families []FamilyPodIPsData
+	Nodes    []NodePodIPsData
+}
+
+// Addresses are the usable addresses of the pod CIDRs of the family, NearNodes counts the nodes past --warn-threshold
+// percent of theirs and ExhaustedNodes those without any left
+type FamilyPodIPsData struct {
+	Family         string
+	Nodes          int
+	Addresses      float64
+	Used           int
+	Available      float64
+	UsedPercent    float64
+	NearNodes      int
+	ExhaustedNodes int
+}
+
+type NodePodIPsData struct {
+	Node        string
+	Family      string
+	PodCIDR     string
+	Addresses   float64
+	Used        int
+	Available   float64
+	UsedPercent float64
+}
+
+// Pods created and deleted within the window per namespace and node role
+type ChurnData struct {
+	Window     string
+	Namespaces []GroupChurnData
+	NodeRoles  []GroupChurnData
+}
+
+type GroupChurnData struct {
+	Name               string
+	Creations          int
+	Deletions          int
+	CreationsPerMinute float64
+	DeletionsPerMinute float64
+}
+
+// Process ids of each node against its pid limit, NearNodes counts the nodes past --warn-threshold percent of theirs
+type PIDData struct {
+	Nodes     []NodePIDData
+	NearNodes int
+}
+
+// MaxPIDs is the pid limit of the node and Processes those running, PodPidsLimit is the pid limit of each pod of the
+// kubelet, 0 when unlimited. Status is ok, near past --warn-threshold percent of the limit or critical past
+// --crit-threshold.
+type NodePIDData struct {
+	Node         string
+	Roles        []string
+	MaxPIDs      int64
+	Processes    int64
+	UsedPercent  float64
+	Pods         int
+	PodPidsLimit int64 `json:",omitempty"`
+	Status       string
+	Reasons      []string
+}
+
+// Permissions of the sub-commands granted to the user, MissingRequired counts the denied permissions every capacity
+// sub-command needs
+type AuthCheckData struct {
+	Permissions     []PermissionData
+	MissingRequired int
+}
+
+type PermissionData struct {
+	Verb     string
+	Resource string
+	Allowed  bool
+	Required bool
+	UsedBy   string
+	// Why the authorizer allowed or denied the permission, when it says
+	Reason string
+}
+
+// Latency of the collection path over the iterations of a benchmark, with the objects and API requests of an iteration
+type BenchData struct {
+	Iterations  int
+	Nodes       int
+	Pods        int
+	APIRequests int
+	Stages      []BenchStageData
+}
+
+type BenchStageData struct {
+	Stage      string
+	MinSeconds float64
+	P50Seconds float64
+	P90Seconds float64
+	P99Seconds float64
+	MaxSeconds float64
+}
+
+type FitData struct {
+	Fits       bool
+	Groups     []GroupFitData
+	Workloads  []WorkloadFitData
+	Quotas     []QuotaFitData     `json:",omitempty"`
+	Placements []PlacementFitData `json:",omitempty"`
+	Spreads    []SpreadFitData    `json:",omitempty"`
+}
+
+// Replicas a workload with topology spread constraints can scale to on the cluster, with the pods placed in each domain
+// of its first constraint at that count, against the replicas resource headroom alone allows
+type SpreadFitData struct {
+	Kind             string
+	Namespace        string
+	Name             string
+	PodCount         int
+	MaxPodCount      int
+	HeadroomPodCount int
+	Fits             bool
+	TopologyKey      string
+	Domains          map[string]int
+	Reason           string `json:",omitempty"`
+}
+
+// Pods of a workload placed one at a time by the scheduler simulation, with the pods placed on each node
+type PlacementFitData struct {
+	Kind        string
+	Namespace   string
+	Name        string
+	PodCount    int
+	PlacedCount int
+	Fits        bool
+	Nodes       map[string]int
+	Reason      string `json:",omitempty"`
+}
+
+// Requests of the workloads placed on a group of ready, schedulable nodes against their available capacity
+type GroupFitData struct {
+	Group              string
+	Fits               bool
+	Reason             string `json:",omitempty"`
+	PodCount           int
+	AvailablePods      int
+	RequestsCPU        resource.Quantity
+	RequestsCPUCores   float64
+	AvailableCPU       resource.Quantity
+	AvailableCPUCores  float64
+	RequestsMemory     resource.Quantity
+	RequestsMemoryGiB  float64
+	AvailableMemory    resource.Quantity
+	AvailableMemoryGiB float64
+}
+
+// A resource of a ResourceQuota the workloads are charged to, quota admission rejects them beyond the hard limit
+type QuotaFitData struct {
+	Namespace     string
+	ResourceQuota string
+	Resource      string
+	Fits          bool
+	Used          resource.Quantity
+	Requested     resource.Quantity
+	Hard          resource.Quantity
+	Workloads     []string
+}
+
+type WorkloadFitData struct {
+	Kind              string
+	Namespace         string
+	Name              string
+	PodCount          int
+	RequestsCPU       resource.Quantity
+	RequestsCPUCores  float64
+	RequestsMemory    resource.Quantity
+	RequestsMemoryGiB float64
+	Groups            []string
+	// Pods of the workload each group can hold once required pod anti-affinity is honored, for affected workloads
+	AntiAffinityAvailablePods map[string]int `json:",omitempty"`
+}
+
+type ReportData struct {
+	Cluster     ClusterCapacityData
+	NodeRoles   map[string]*ClusterCapacityData
+	Nodes       map[string]*NodeCapacityData
+	Namespaces  map[string]*NamespaceCapacityData
+	PendingPods []PendingPodData
+}
+
+// Cluster, node-role and node data of the all sub-command, collected from the same lists
+type AllData struct {
+	Cluster   ClusterCapacityData
+	NodeRoles map[string]*ClusterCapacityData
+	Nodes     map[string]*NodeCapacityData
+}
+
+func DisplayClusterData(clusterCapacityData ClusterCapacityData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		data, err := toSchema(clusterCapacityData, displayOptions.Schema, &clusterCapacityDataV1{})
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
-		fmt.Print(string(yamlClusterData))
+		return printObject(data, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for cluster data", displayOptions.Format)
 	default:
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 0, 5, 1, ' ', 0)
-		if displayHeaders {
-			if displayDefault {
-				fmt.Fprintf(w, "NODES\t\t\t\tPODS\t\t\t\t\tCPU\t\t\t\t\tMEMORY\t\t\t\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE")
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintf(w, "NODES\t\t\t\tPODS\t\t\t\t\t%sCPU\t\t\t\t\tMEMORY\t\t\t\t\t", displayOptions.podColumnsTab())
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "EPHEMERAL STORAGE%s", displayOptions.volumesTab())
 				}
-				fmt.Fprintln(w, "")
+				fmt.Fprintln(w, displayOptions.volumesHeader())
 			} else {
-				fmt.Fprintf(w, "NODES\t\t\t\tPODS\t\t\t\t\tCPU (cores)\t\t\t\t\tMEMORY (GiB)\t\t\t\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)")
+				fmt.Fprintf(w, "NODES\t\t\t\tPODS\t\t\t\t\t%sCPU (%s)\t\t\t\t\tMEMORY (%s)\t\t\t\t\t", displayOptions.podColumnsTab(), displayOptions.cpuUnitName(), displayOptions.memUnitName())
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)%s", displayOptions.volumesTab())
 				}
-				fmt.Fprintln(w, "")
+				fmt.Fprintln(w, displayOptions.volumesHeader())
 			}
-			fmt.Fprintf(w, "Total\tReady\tUnready\tUnsch\tCapacity\tAllocatable\tTotal\tNon-Term\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\t")
-			if displayEphemeralStorage {
+			fmt.Fprintf(w, "Total\tReady\tUnready\tUnsch\tCapacity\tAllocatable\tTotal\tNon-Term\t%sAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\t", displayOptions.podColumnsHeader())
+			if displayOptions.EphemeralStorage {
 				fmt.Fprintf(w, "Capacity\tAllocatable\tRequests\tLimits\tAvail")
+				if displayOptions.Volumes {
+					fmt.Fprintf(w, "\t")
+				}
+			}
+			if displayOptions.Volumes {
+				fmt.Fprintf(w, "Attached\tLimit\tAt Limit\tNear Limit")
 			}
 			fmt.Fprintln(w, "")
 		}
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", clusterCapacityData.TotalNodeCount, clusterCapacityData.TotalReadyNodeCount, clusterCapacityData.TotalUnreadyNodeCount, clusterCapacityData.TotalUnschedulableNodeCount)
 		fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalCapacityPods, &clusterCapacityData.TotalAllocatablePods)
-		fmt.Fprintf(w, "%d\t%d\t", clusterCapacityData.TotalPodCount, clusterCapacityData.TotalNonTermPodCount)
-		fmt.Fprintf(w, "%d\t", clusterCapacityData.TotalAvailablePods)
-		if displayDefault {
+		fmt.Fprintf(w, "%d\t%s\t", clusterCapacityData.TotalPodCount, displayOptions.forGroup(ThresholdPods).highlight(strconv.Itoa(clusterCapacityData.TotalNonTermPodCount), float64(clusterCapacityData.TotalNonTermPodCount), float64(clusterCapacityData.TotalAllocatablePods.Value())))
+		fmt.Fprintf(w, "%s%d\t", displayOptions.podColumnsCells(clusterCapacityData.TotalTerminatingPodCount, clusterCapacityData.TotalStaticPodCount, clusterCapacityData.TotalBestEffortPodCount), clusterCapacityData.TotalAvailablePods)
+		if displayOptions.Default {
 			fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalCapacityCPU, &clusterCapacityData.TotalAllocatableCPU)
-			fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalRequestsCPU, &clusterCapacityData.TotalLimitsCPU)
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdCPU).highlightQuantity(clusterCapacityData.TotalRequestsCPU.String(), clusterCapacityData.TotalRequestsCPU, clusterCapacityData.TotalAllocatableCPU), &clusterCapacityData.TotalLimitsCPU)
 			fmt.Fprintf(w, "%s\t", &clusterCapacityData.TotalAvailableCPU)
 			fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalCapacityMemory, &clusterCapacityData.TotalAllocatableMemory)
-			fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalRequestsMemory, &clusterCapacityData.TotalLimitsMemory)
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdMemory).highlightQuantity(clusterCapacityData.TotalRequestsMemory.String(), clusterCapacityData.TotalRequestsMemory, clusterCapacityData.TotalAllocatableMemory), &clusterCapacityData.TotalLimitsMemory)
 			fmt.Fprintf(w, "%s\t", &clusterCapacityData.TotalAvailableMemory)
-			if displayEphemeralStorage {
+			if displayOptions.EphemeralStorage {
 				fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalCapacityEphemeralStorage, &clusterCapacityData.TotalAllocatableEphemeralStorage)
-				fmt.Fprintf(w, "%s\t%s\t", &clusterCapacityData.TotalRequestsEphemeralStorage, &clusterCapacityData.TotalLimitsEphemeralStorage)
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdEphemeralStorage).highlightQuantity(clusterCapacityData.TotalRequestsEphemeralStorage.String(), clusterCapacityData.TotalRequestsEphemeralStorage, clusterCapacityData.TotalAllocatableEphemeralStorage), &clusterCapacityData.TotalLimitsEphemeralStorage)
 				fmt.Fprintf(w, "%s\t", &clusterCapacityData.TotalAvailableEphemeralStorage)
 			}
-			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, displayOptions.clusterVolumesCells(clusterCapacityData))
 		} else {
-			fmt.Fprintf(w, "%.1f\t%.1f\t", clusterCapacityData.TotalCapacityCPUCores, clusterCapacityData.TotalAllocatableCPUCores)
-			fmt.Fprintf(w, "%.1f\t%.1f\t", clusterCapacityData.TotalRequestsCPUCores, clusterCapacityData.TotalLimitsCPUCores)
-			fmt.Fprintf(w, "%.1f\t", clusterCapacityData.TotalAvailableCPUCores)
-			fmt.Fprintf(w, "%.1f\t%.1f\t", clusterCapacityData.TotalCapacityMemoryGiB, clusterCapacityData.TotalAllocatableMemoryGiB)
-			fmt.Fprintf(w, "%.1f\t%.1f\t", clusterCapacityData.TotalRequestsMemoryGiB, clusterCapacityData.TotalLimitsMemoryGiB)
-			fmt.Fprintf(w, "%.1f\t", clusterCapacityData.TotalAvailableMemoryGiB)
-			if displayEphemeralStorage {
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(clusterCapacityData.TotalCapacityCPUCores), displayOptions.cpu(clusterCapacityData.TotalAllocatableCPUCores))
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdCPU).highlightQuantity(displayOptions.cpu(clusterCapacityData.TotalRequestsCPUCores), clusterCapacityData.TotalRequestsCPU, clusterCapacityData.TotalAllocatableCPU), displayOptions.cpu(clusterCapacityData.TotalLimitsCPUCores))
+			fmt.Fprintf(w, "%s\t", displayOptions.cpu(clusterCapacityData.TotalAvailableCPUCores))
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.mem(clusterCapacityData.TotalCapacityMemoryGiB), displayOptions.mem(clusterCapacityData.TotalAllocatableMemoryGiB))
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdMemory).highlightQuantity(displayOptions.mem(clusterCapacityData.TotalRequestsMemoryGiB), clusterCapacityData.TotalRequestsMemory, clusterCapacityData.TotalAllocatableMemory), displayOptions.mem(clusterCapacityData.TotalLimitsMemoryGiB))
+			fmt.Fprintf(w, "%s\t", displayOptions.mem(clusterCapacityData.TotalAvailableMemoryGiB))
+			if displayOptions.EphemeralStorage {
 				fmt.Fprintf(w, "%.1f\t%.1f\t", clusterCapacityData.TotalCapacityEphemeralStorageGB, clusterCapacityData.TotalAllocatableEphemeralStorageGB)
-				fmt.Fprintf(w, "%.1f\t%.1f\t", clusterCapacityData.TotalRequestsEphemeralStorageGB, clusterCapacityData.TotalLimitsEphemeralStorageGB)
+				fmt.Fprintf(w, "%s\t%.1f\t", displayOptions.forGroup(ThresholdEphemeralStorage).highlightQuantity(fmt.Sprintf("%.1f", clusterCapacityData.TotalRequestsEphemeralStorageGB), clusterCapacityData.TotalRequestsEphemeralStorage, clusterCapacityData.TotalAllocatableEphemeralStorage), clusterCapacityData.TotalLimitsEphemeralStorageGB)
 				fmt.Fprintf(w, "%.1f\t", clusterCapacityData.TotalAvailableEphemeralStorageGB)
 			}
-			fmt.Fprintln(w, "")
+			fmt.Fprintln(w, displayOptions.clusterVolumesCells(clusterCapacityData))
 		}
-		w.Flush()
+		return w.Flush()
 	}
 }
 
-func DisplayClusterSizeData(clusterSizeData ClusterSizeData, displayHeaders bool, displayFormat string) {
-	switch displayFormat {
-	case jsonDisplay:
-		jsonClusterData, err := json.MarshalIndent(&clusterSizeData, "", "  ")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(string(jsonClusterData))
-	case yamlDisplay:
-		yamlClusterData, err := yaml.Marshal(clusterSizeData)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Print(string(yamlClusterData))
+// Prints a one line summary of the cluster capacity data for quick checks and chat bots
+func DisplayClusterBrief(clusterCapacityData ClusterCapacityData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case tableDisplay, wideDisplay:
+		_, err := fmt.Fprintf(displayOptions.Out, "%d/%d nodes ready, %.0f%% CPU requested, %.0f%% memory requested, %d pods free\n",
+			clusterCapacityData.TotalReadyNodeCount, clusterCapacityData.TotalNodeCount,
+			capacity.Percent(clusterCapacityData.TotalRequestsCPU, clusterCapacityData.TotalAllocatableCPU),
+			capacity.Percent(clusterCapacityData.TotalRequestsMemory, clusterCapacityData.TotalAllocatableMemory),
+			clusterCapacityData.TotalAvailablePods)
+		return err
+	default:
+		return fmt.Errorf("output format \"%s\" is not supported with --brief", displayOptions.Format)
+	}
+}
+
+func DisplayClusterSizeData(clusterSizeData ClusterSizeData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(clusterSizeData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for cluster size data", displayOptions.Format)
 	default:
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 0, 5, 1, ' ', 0)
-		if displayHeaders {
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
 			fmt.Fprintln(w, "CLUSTER APIs")
 			fmt.Fprintln(w, "Namespaces\tNodes\tPersistentVolumes\tServiceAccounts\tClusterRoles\tClusterRoleBindings\tRoles\tRoleBindings\tResourceQuotas\tNetworkPolicies")
 		}
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", clusterSizeData.Namespace, clusterSizeData.Node, clusterSizeData.PersistentVolume, clusterSizeData.ServiceAccount)
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", clusterSizeData.ClusterRole, clusterSizeData.ClusterRoleBinding, clusterSizeData.Role, clusterSizeData.RoleBinding)
 		fmt.Fprintf(w, "%d\t%d\n", clusterSizeData.ResourceQuota, clusterSizeData.NetworkPolicy)
-		if displayHeaders {
+		if displayOptions.Headers {
 			fmt.Fprintln(w, "WORKLOAD APIs")
 			fmt.Fprintln(w, "Containers\tPods\tReplicaSets\tReplicationControllers\tDeployments\tDaemonSets\tStatefulSets\tCronJobs\tJobs")
 		}
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", clusterSizeData.Container, clusterSizeData.Pod, clusterSizeData.ReplicaSet, clusterSizeData.ReplicaController)
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", clusterSizeData.Deployment, clusterSizeData.Daemonset, clusterSizeData.StatefulSet, clusterSizeData.CronJob)
 		fmt.Fprintf(w, "%d\n", clusterSizeData.Job)
-		if displayHeaders {
+		if displayOptions.Headers {
 			fmt.Fprintln(w, "SERVICE APIs")
 			fmt.Fprintln(w, "Endpoints\tIngresses\tServices")
 		}
 		fmt.Fprintf(w, "%d\t%d\t%d\n", clusterSizeData.EndPoints, clusterSizeData.Ingress, clusterSizeData.Service)
-		if displayHeaders {
+		if displayOptions.Headers {
 			fmt.Fprintln(w, "CONFIG And STORAGE APIs")
 			fmt.Fprintln(w, "ConfigMaps\tSecrets\tPersistentVolumeClaims\tStorageClasses\tVolumes\tVolumeAttachments")
 		}
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", clusterSizeData.Configmap, clusterSizeData.Secret, clusterSizeData.PersistentVolumeClaim, clusterSizeData.StorageClass)
 		fmt.Fprintf(w, "%d\t\n", clusterSizeData.VolumeAttachment)
-		if displayHeaders {
+		if displayOptions.Headers {
 			fmt.Fprintln(w, "METADATA APIs")
 			fmt.Fprintln(w, "Events\tLimitRanges\tPodDisruptionBudgets\tPodSecurityPolicies")
 		}
 		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t\n", clusterSizeData.Event, clusterSizeData.LimitRange, clusterSizeData.PodDisruptionBudget, clusterSizeData.PodSecurityPolicy)
 
-		w.Flush()
+		return w.Flush()
 	}
 }
 
-func DisplayNodeRoleData(nodeRoleCapacityData map[string]*ClusterCapacityData, sortedRoleNames []string, displayDefault bool, displayHeaders bool, displayEphemeralStorage bool, displayFormat string) {
-	switch displayFormat {
-	case jsonDisplay:
-		jsonNodeRoleData, err := json.MarshalIndent(&nodeRoleCapacityData, "", "  ")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(string(jsonNodeRoleData))
-	case yamlDisplay:
-		yamlNodeRoleData, err := yaml.Marshal(nodeRoleCapacityData)
+func DisplayNodeRoleData(nodeRoleCapacityData map[string]*ClusterCapacityData, sortedRoleNames []string, displayOptions DisplayOptions, displayMinMax bool, displayImbalance bool) error {
+	if displayOptions.Anonymize {
+		nodeRoleCapacityData = anonymizeNodeRoleData(nodeRoleCapacityData)
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		data, err := toSchema(nodeRoleCapacityData, displayOptions.Schema, &map[string]*clusterCapacityDataV1{})
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
-		fmt.Print(string(yamlNodeRoleData))
+		return printObject(data, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for node-role data", displayOptions.Format)
 	default:
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 0, 5, 1, ' ', 0)
-		if displayHeaders {
-			if displayDefault {
-				fmt.Fprintf(w, "ROLE\tNODES\t\t\t\tPODS\t\t\t\t\tCPU\t\t\t\t\tMEMORY\t\t\t\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE")
+		sortedRoleNames = append([]string(nil), sortedRoleNames...)
+		capacity.SortRoleNames(sortedRoleNames, displayOptions.PinnedRoles, displayOptions.NoneLast)
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintf(w, "ROLE\tNODES\t\t\t\tPODS\t\t\t\t\t%sCPU\t\t\t\t\tMEMORY\t\t\t\t\t", displayOptions.podColumnsTab())
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "EPHEMERAL STORAGE\t\t\t\t\t")
 				}
-				fmt.Fprintln(w, "")
 			} else {
-				fmt.Fprintf(w, "ROLE\tNODES\t\t\t\tPODS\t\t\t\t\tCPU (cores)\t\t\t\t\tMEMORY (GiB)\t\t\t\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)")
+				fmt.Fprintf(w, "ROLE\tNODES\t\t\t\tPODS\t\t\t\t\t%sCPU (%s)\t\t\t\t\tMEMORY (%s)\t\t\t\t\t", displayOptions.podColumnsTab(), displayOptions.cpuUnitName(), displayOptions.memUnitName())
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)\t\t\t\t\t")
 				}
-				fmt.Fprintln(w, "")
 			}
-			fmt.Fprintf(w, "\tTotal\tReady\tUnready\tUnsch\tCapacity\tAllocatable\tTotal\tNon-Term\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\t")
-			if displayEphemeralStorage {
-				fmt.Fprintf(w, "Capacity\tAllocatable\tRequests\tLimits\tAvail")
+			if displayMinMax {
+				fmt.Fprintf(w, "CPU REQUESTS %%\t\tMEMORY REQUESTS %%\t\t")
+			}
+			if displayImbalance {
+				fmt.Fprintf(w, "CPU IMBALANCE\t\tMEMORY IMBALANCE\t\t")
+			}
+			fmt.Fprintln(w, "")
+			fmt.Fprintf(w, "\tTotal\tReady\tUnready\tUnsch\tCapacity\tAllocatable\tTotal\tNon-Term\t%sAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\t", displayOptions.podColumnsHeader())
+			if displayOptions.EphemeralStorage {
+				fmt.Fprintf(w, "Capacity\tAllocatable\tRequests\tLimits\tAvail\t")
+			}
+			if displayMinMax {
+				fmt.Fprintf(w, "Min\tMax\tMin\tMax\t")
+			}
+			if displayImbalance {
+				fmt.Fprintf(w, "Spread\tStdDev\tSpread\tStdDev\t")
 			}
 			fmt.Fprintln(w, "")
 		}
 		for _, k := range sortedRoleNames {
 			fmt.Fprintf(w, "%s\t", k)
-			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", nodeRoleCapacityData[k].TotalNodeCount, nodeRoleCapacityData[k].TotalReadyNodeCount, nodeRoleCapacityData[k].TotalUnreadyNodeCount, nodeRoleCapacityData[k].TotalUnschedulableNodeCount)
+			if k == "*average*" {
+				fmt.Fprintf(w, "\t\t\t\t")
+			} else {
+				fmt.Fprintf(w, "%d\t%d\t%d\t%d\t", nodeRoleCapacityData[k].TotalNodeCount, nodeRoleCapacityData[k].TotalReadyNodeCount, nodeRoleCapacityData[k].TotalUnreadyNodeCount, nodeRoleCapacityData[k].TotalUnschedulableNodeCount)
+			}
 			fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalCapacityPods, &nodeRoleCapacityData[k].TotalAllocatablePods)
-			fmt.Fprintf(w, "%d\t%d\t", nodeRoleCapacityData[k].TotalPodCount, nodeRoleCapacityData[k].TotalNonTermPodCount)
-			fmt.Fprintf(w, "%d\t", nodeRoleCapacityData[k].TotalAvailablePods)
-			if displayDefault {
+			fmt.Fprintf(w, "%d\t%s\t", nodeRoleCapacityData[k].TotalPodCount, displayOptions.forGroup(ThresholdPods, k).highlight(strconv.Itoa(nodeRoleCapacityData[k].TotalNonTermPodCount), float64(nodeRoleCapacityData[k].TotalNonTermPodCount), float64(nodeRoleCapacityData[k].TotalAllocatablePods.Value())))
+			fmt.Fprintf(w, "%s%d\t", displayOptions.podColumnsCells(nodeRoleCapacityData[k].TotalTerminatingPodCount, nodeRoleCapacityData[k].TotalStaticPodCount, nodeRoleCapacityData[k].TotalBestEffortPodCount), nodeRoleCapacityData[k].TotalAvailablePods)
+			if displayOptions.Default {
 				fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalCapacityCPU, &nodeRoleCapacityData[k].TotalAllocatableCPU)
-				fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalRequestsCPU, &nodeRoleCapacityData[k].TotalLimitsCPU)
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdCPU, k).highlightQuantity(nodeRoleCapacityData[k].TotalRequestsCPU.String(), nodeRoleCapacityData[k].TotalRequestsCPU, nodeRoleCapacityData[k].TotalAllocatableCPU), &nodeRoleCapacityData[k].TotalLimitsCPU)
 				fmt.Fprintf(w, "%s\t", &nodeRoleCapacityData[k].TotalAvailableCPU)
 				fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalCapacityMemory, &nodeRoleCapacityData[k].TotalAllocatableMemory)
-				fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalRequestsMemory, &nodeRoleCapacityData[k].TotalLimitsMemory)
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdMemory, k).highlightQuantity(nodeRoleCapacityData[k].TotalRequestsMemory.String(), nodeRoleCapacityData[k].TotalRequestsMemory, nodeRoleCapacityData[k].TotalAllocatableMemory), &nodeRoleCapacityData[k].TotalLimitsMemory)
 				fmt.Fprintf(w, "%s\t", &nodeRoleCapacityData[k].TotalAvailableMemory)
-				if displayEphemeralStorage {
+				if displayOptions.EphemeralStorage {
 					fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalCapacityEphemeralStorage, &nodeRoleCapacityData[k].TotalAllocatableEphemeralStorage)
-					fmt.Fprintf(w, "%s\t%s\t", &nodeRoleCapacityData[k].TotalRequestsEphemeralStorage, &nodeRoleCapacityData[k].TotalLimitsEphemeralStorage)
+					fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdEphemeralStorage, k).highlightQuantity(nodeRoleCapacityData[k].TotalRequestsEphemeralStorage.String(), nodeRoleCapacityData[k].TotalRequestsEphemeralStorage, nodeRoleCapacityData[k].TotalAllocatableEphemeralStorage), &nodeRoleCapacityData[k].TotalLimitsEphemeralStorage)
 					fmt.Fprintf(w, "%s\t", &nodeRoleCapacityData[k].TotalAvailableEphemeralStorage)
 				}
-				fmt.Fprintln(w, "")
 			} else {
-				fmt.Fprintf(w, "%.1f\t%.1f\t", nodeRoleCapacityData[k].TotalCapacityCPUCores, nodeRoleCapacityData[k].TotalAllocatableCPUCores)
-				fmt.Fprintf(w, "%.1f\t%.1f\t", nodeRoleCapacityData[k].TotalRequestsCPUCores, nodeRoleCapacityData[k].TotalLimitsCPUCores)
-				fmt.Fprintf(w, "%.1f\t", nodeRoleCapacityData[k].TotalAvailableCPUCores)
-				fmt.Fprintf(w, "%.1f\t%.1f\t", nodeRoleCapacityData[k].TotalCapacityMemoryGiB, nodeRoleCapacityData[k].TotalAllocatableMemoryGiB)
-				fmt.Fprintf(w, "%.1f\t%.1f\t", nodeRoleCapacityData[k].TotalRequestsMemoryGiB, nodeRoleCapacityData[k].TotalLimitsMemoryGiB)
-				fmt.Fprintf(w, "%.1f\t", nodeRoleCapacityData[k].TotalAvailableMemoryGiB)
-				if displayEphemeralStorage {
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(nodeRoleCapacityData[k].TotalCapacityCPUCores), displayOptions.cpu(nodeRoleCapacityData[k].TotalAllocatableCPUCores))
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdCPU, k).highlightQuantity(displayOptions.cpu(nodeRoleCapacityData[k].TotalRequestsCPUCores), nodeRoleCapacityData[k].TotalRequestsCPU, nodeRoleCapacityData[k].TotalAllocatableCPU), displayOptions.cpu(nodeRoleCapacityData[k].TotalLimitsCPUCores))
+				fmt.Fprintf(w, "%s\t", displayOptions.cpu(nodeRoleCapacityData[k].TotalAvailableCPUCores))
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.mem(nodeRoleCapacityData[k].TotalCapacityMemoryGiB), displayOptions.mem(nodeRoleCapacityData[k].TotalAllocatableMemoryGiB))
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdMemory, k).highlightQuantity(displayOptions.mem(nodeRoleCapacityData[k].TotalRequestsMemoryGiB), nodeRoleCapacityData[k].TotalRequestsMemory, nodeRoleCapacityData[k].TotalAllocatableMemory), displayOptions.mem(nodeRoleCapacityData[k].TotalLimitsMemoryGiB))
+				fmt.Fprintf(w, "%s\t", displayOptions.mem(nodeRoleCapacityData[k].TotalAvailableMemoryGiB))
+				if displayOptions.EphemeralStorage {
 					fmt.Fprintf(w, "%.1f\t%.1f\t", nodeRoleCapacityData[k].TotalCapacityEphemeralStorageGB, nodeRoleCapacityData[k].TotalAllocatableEphemeralStorageGB)
-					fmt.Fprintf(w, "%.1f\t%.1f\t", nodeRoleCapacityData[k].TotalRequestsEphemeralStorageGB, nodeRoleCapacityData[k].TotalLimitsEphemeralStorageGB)
+					fmt.Fprintf(w, "%s\t%.1f\t", displayOptions.forGroup(ThresholdEphemeralStorage, k).highlightQuantity(fmt.Sprintf("%.1f", nodeRoleCapacityData[k].TotalRequestsEphemeralStorageGB), nodeRoleCapacityData[k].TotalRequestsEphemeralStorage, nodeRoleCapacityData[k].TotalAllocatableEphemeralStorage), nodeRoleCapacityData[k].TotalLimitsEphemeralStorageGB)
 					fmt.Fprintf(w, "%.1f\t", nodeRoleCapacityData[k].TotalAvailableEphemeralStorageGB)
 				}
-				fmt.Fprintln(w, "")
 			}
+			if displayMinMax {
+				if minMax := nodeRoleCapacityData[k].RequestsMinMax; minMax != nil {
+					fmt.Fprintf(w, "%s\t%s\t", minMaxCell(minMax.MinRequestsCPUPercent, minMax.MinRequestsCPUNode), minMaxCell(minMax.MaxRequestsCPUPercent, minMax.MaxRequestsCPUNode))
+					fmt.Fprintf(w, "%s\t%s\t", minMaxCell(minMax.MinRequestsMemoryPercent, minMax.MinRequestsMemoryNode), minMaxCell(minMax.MaxRequestsMemoryPercent, minMax.MaxRequestsMemoryNode))
+				} else {
+					fmt.Fprintf(w, "\t\t\t\t")
+				}
+			}
+			if displayImbalance {
+				if minMax := nodeRoleCapacityData[k].RequestsMinMax; minMax != nil {
+					fmt.Fprintf(w, "%.1f\t%.1f\t", minMax.MaxRequestsCPUPercent-minMax.MinRequestsCPUPercent, minMax.StdDevRequestsCPUPercent)
+					fmt.Fprintf(w, "%.1f\t%.1f\t", minMax.MaxRequestsMemoryPercent-minMax.MinRequestsMemoryPercent, minMax.StdDevRequestsMemoryPercent)
+				} else {
+					fmt.Fprintf(w, "\t\t\t\t")
+				}
+			}
+			fmt.Fprintln(w, "")
 		}
-		w.Flush()
+		return w.Flush()
 	}
 }
 
-func DisplayNodeData(nodesCapacityData map[string]*NodeCapacityData, sortedNodeNames []string, displayDefault bool, displayHeaders bool, displayEphemeralStorage bool, displayFormat string, sortByRole bool, nodesByRole map[string][]string) {
-	switch displayFormat {
-	case jsonDisplay:
-		jsonNodeData, err := json.MarshalIndent(&nodesCapacityData, "", "  ")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(string(jsonNodeData))
-	case yamlDisplay:
-		yamlNodeData, err := yaml.Marshal(nodesCapacityData)
+func minMaxCell(percent float64, nodeName string) string {
+	if nodeName == "" {
+		return ""
+	}
+	return fmt.Sprintf("%.1f (%s)", percent, nodeName)
+}
+
+// Separates the ephemeral storage group of the cluster table from the volumes group, which follows it with --volumes
+func (displayOptions DisplayOptions) volumesTab() string {
+	if displayOptions.Volumes {
+		return "\t\t\t\t\t"
+	}
+	return ""
+}
+
+func (displayOptions DisplayOptions) volumesHeader() string {
+	if displayOptions.Volumes {
+		return "VOLUMES"
+	}
+	return ""
+}
+
+func (displayOptions DisplayOptions) clusterVolumesCells(clusterCapacityData ClusterCapacityData) string {
+	if !displayOptions.Volumes {
+		return ""
+	}
+	return fmt.Sprintf("%d\t%d\t%d\t%d\t", clusterCapacityData.TotalAttachedVolumes, clusterCapacityData.TotalAttachLimit, clusterCapacityData.AtAttachLimitNodeCount, clusterCapacityData.NearAttachLimitNodeCount)
+}
+
+func DisplayNodeData(nodesCapacityData map[string]*NodeCapacityData, sortedNodeNames []string, displayOptions DisplayOptions, sortByRole bool, nodesByRole map[string][]string) error {
+	if displayOptions.Anonymize {
+		nodesCapacityData, sortedNodeNames, nodesByRole = anonymizeNodeData(nodesCapacityData, sortedNodeNames, nodesByRole)
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		data, err := toSchema(nodesCapacityData, displayOptions.Schema, &map[string]*nodeCapacityDataV1{})
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
-		fmt.Print(string(yamlNodeData))
+		return printObject(data, displayOptions)
+	case nameDisplay:
+		return printNames("Node", sortedNodeNames, displayOptions.Out)
 	default:
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 0, 5, 1, ' ', 0)
-		if displayHeaders {
-			if displayDefault {
-				fmt.Fprintf(w, "NAME\tSTATUS\tROLES\tPODS\t\t\t\t\tCPU\t\t\t\t\tMEMORY\t\t\t\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE")
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintf(w, "NAME\tSTATUS\tROLES\tPODS\t\t\t\t\t%sCPU\t\t\t\t\tMEMORY\t\t\t\t\t", displayOptions.podColumnsTab())
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "EPHEMERAL STORAGE\t\t\t\t\t")
 				}
-				fmt.Fprintln(w, "")
 			} else {
-				fmt.Fprintf(w, "NAME\tSTATUS\tROLES\tPODS\t\t\t\t\tCPU (cores)\t\t\t\t\tMEMORY (GiB)\t\t\t\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)")
+				fmt.Fprintf(w, "NAME\tSTATUS\tROLES\tPODS\t\t\t\t\t%sCPU (%s)\t\t\t\t\tMEMORY (%s)\t\t\t\t\t", displayOptions.podColumnsTab(), displayOptions.cpuUnitName(), displayOptions.memUnitName())
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)\t\t\t\t\t")
 				}
-				fmt.Fprintln(w, "")
 			}
-			fmt.Fprintf(w, "\t\t\tCapacity\tAllocatable\tTotal\tNon-Term\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\t")
-			if displayEphemeralStorage {
-				fmt.Fprintf(w, "Capacity\tAllocatable\tRequests\tLimits\tAvail")
+			if displayOptions.AvailablePercent {
+				fmt.Fprintf(w, "AVAIL %%\t\t\t")
+			}
+			if displayOptions.Volumes {
+				fmt.Fprintf(w, "VOLUMES\t\t\t")
+			}
+			if displayOptions.Disk {
+				fmt.Fprintf(w, "NODEFS (GB)\t\t\t\tIMAGEFS (GB)\t\t\t\t")
+			}
+			if displayOptions.Format == wideDisplay {
+				fmt.Fprintf(w, "VERSION\tINSTANCE-TYPE\tZONE\tTAINTS\tINTERNAL-IP\tHEARTBEAT\t")
+			}
+			for _, labelColumn := range displayOptions.LabelColumns {
+				fmt.Fprintf(w, "%s\t", labelColumnHeader(labelColumn))
+			}
+			if displayOptions.ShowLabels {
+				fmt.Fprintf(w, "LABELS\t")
+			}
+			fmt.Fprintln(w, "")
+			fmt.Fprintf(w, "\t\t\tCapacity\tAllocatable\tTotal\tNon-Term\t%sAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\tCapacity\tAllocatable\tRequests\tLimits\tAvail\t", displayOptions.podColumnsHeader())
+			if displayOptions.EphemeralStorage {
+				fmt.Fprintf(w, "Capacity\tAllocatable\tRequests\tLimits\tAvail\t")
+			}
+			if displayOptions.AvailablePercent {
+				fmt.Fprintf(w, "Pods\tCPU\tMemory\t")
+			}
+			if displayOptions.Volumes {
+				fmt.Fprintf(w, "Attached\tLimit\tUsed %%\t")
+			}
+			if displayOptions.Disk {
+				fmt.Fprintf(w, "Capacity\tUsed\tAvail\tUsed %%\tCapacity\tUsed\tAvail\tUsed %%\t")
+			}
+			if displayOptions.Format == wideDisplay {
+				fmt.Fprintf(w, "\t\t\t\t\t\t")
+			}
+			fmt.Fprint(w, strings.Repeat("\t", len(displayOptions.LabelColumns)))
+			if displayOptions.ShowLabels {
+				fmt.Fprintf(w, "\t")
 			}
 			fmt.Fprintln(w, "")
 		}
@@ -427,27 +1204,29 @@ func DisplayNodeData(nodesCapacityData map[string]*NodeCapacityData, sortedNodeN
 			for role := range nodesByRole {
 				roles = append(roles, role)
 			}
-			sort.Strings(roles)
+			capacity.SortRoleNames(roles, displayOptions.PinnedRoles, displayOptions.NoneLast)
 
 			for _, role := range roles {
 				for _, node := range nodesByRole[role] {
-					printNodeData(w, node, nodesCapacityData[node], displayDefault, displayEphemeralStorage)
+					printNodeData(w, node, nodesCapacityData[node], displayOptions)
 				}
 			}
 		} else {
 			// Sort by Node Name
 			for _, k := range sortedNodeNames {
-				printNodeData(w, k, nodesCapacityData[k], displayDefault, displayEphemeralStorage)
+				printNodeData(w, k, nodesCapacityData[k], displayOptions)
 			}
 		}
 
-		w.Flush()
+		return w.Flush()
 	}
 }
 
-func printNodeData(w *tabwriter.Writer, nodeName string, nodeData *NodeCapacityData, displayDefault bool, displayEphemeralStorage bool) {
+func printNodeData(w io.Writer, nodeName string, nodeData *NodeCapacityData, displayOptions DisplayOptions) {
+	// Threshold rules match the node name or any of its roles
+	nodeGroups := append([]string{nodeName}, nodeData.Roles.List()...)
 	fmt.Fprintf(w, "%s\t", nodeName)
-	if nodeName != "*unassigned*" && nodeName != "*total*" {
+	if nodeName != "*unassigned*" && nodeName != "*total*" && nodeName != "*average*" {
 		if nodeData.Ready {
 			fmt.Fprint(w, "Ready")
 		} else {
@@ -460,111 +1239,1295 @@ func printNodeData(w *tabwriter.Writer, nodeName string, nodeData *NodeCapacityD
 	fmt.Fprintf(w, "\t")
 	fmt.Fprintf(w, "%s\t", strings.Join(nodeData.Roles.List(), ","))
 	fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalCapacityPods, &nodeData.TotalCapacityPods)
-	fmt.Fprintf(w, "%d\t%d\t", nodeData.TotalPodCount, nodeData.TotalNonTermPodCount)
-	fmt.Fprintf(w, "%d\t", nodeData.TotalAvailablePods)
-	if displayDefault {
+	fmt.Fprintf(w, "%d\t%s\t", nodeData.TotalPodCount, displayOptions.forGroup(ThresholdPods, nodeGroups...).highlight(strconv.Itoa(nodeData.TotalNonTermPodCount), float64(nodeData.TotalNonTermPodCount), float64(nodeData.TotalAllocatablePods.Value())))
+	fmt.Fprintf(w, "%s%d\t", displayOptions.podColumnsCells(nodeData.TotalTerminatingPodCount, nodeData.TotalStaticPodCount, nodeData.TotalBestEffortPodCount), nodeData.TotalAvailablePods)
+	if displayOptions.Default {
 		fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalCapacityCPU, &nodeData.TotalAllocatableCPU)
-		fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalRequestsCPU, &nodeData.TotalLimitsCPU)
+		fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdCPU, nodeGroups...).highlightQuantity(nodeData.TotalRequestsCPU.String(), nodeData.TotalRequestsCPU, nodeData.TotalAllocatableCPU), &nodeData.TotalLimitsCPU)
 		fmt.Fprintf(w, "%s\t", &nodeData.TotalAvailableCPU)
 		fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalCapacityMemory, &nodeData.TotalAllocatableMemory)
-		fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalRequestsMemory, &nodeData.TotalLimitsMemory)
+		fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdMemory, nodeGroups...).highlightQuantity(nodeData.TotalRequestsMemory.String(), nodeData.TotalRequestsMemory, nodeData.TotalAllocatableMemory), &nodeData.TotalLimitsMemory)
 		fmt.Fprintf(w, "%s\t", &nodeData.TotalAvailableMemory)
-		if displayEphemeralStorage {
+		if displayOptions.EphemeralStorage {
 			fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalCapacityEphemeralStorage, &nodeData.TotalAllocatableEphemeralStorage)
-			fmt.Fprintf(w, "%s\t%s\t", &nodeData.TotalRequestsEphemeralStorage, &nodeData.TotalLimitsEphemeralStorage)
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdEphemeralStorage, nodeGroups...).highlightQuantity(nodeData.TotalRequestsEphemeralStorage.String(), nodeData.TotalRequestsEphemeralStorage, nodeData.TotalAllocatableEphemeralStorage), &nodeData.TotalLimitsEphemeralStorage)
 			fmt.Fprintf(w, "%s\t", &nodeData.TotalAvailableEphemeralStorage)
 		}
-		fmt.Fprintln(w, "")
 	} else {
-		fmt.Fprintf(w, "%.1f\t%.1f\t", nodeData.TotalCapacityCPUCores, nodeData.TotalAllocatableCPUCores)
-		fmt.Fprintf(w, "%.1f\t%.1f\t", nodeData.TotalRequestsCPUCores, nodeData.TotalLimitsCPUCores)
-		fmt.Fprintf(w, "%.1f\t", nodeData.TotalAvailableCPUCores)
-		fmt.Fprintf(w, "%.1f\t%.1f\t", nodeData.TotalCapacityMemoryGiB, nodeData.TotalAllocatableMemoryGiB)
-		fmt.Fprintf(w, "%.1f\t%.1f\t", nodeData.TotalRequestsMemoryGiB, nodeData.TotalLimitsMemoryGiB)
-		fmt.Fprintf(w, "%.1f\t", nodeData.TotalAvailableMemoryGiB)
-		if displayEphemeralStorage {
+		fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(nodeData.TotalCapacityCPUCores), displayOptions.cpu(nodeData.TotalAllocatableCPUCores))
+		fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdCPU, nodeGroups...).highlightQuantity(displayOptions.cpu(nodeData.TotalRequestsCPUCores), nodeData.TotalRequestsCPU, nodeData.TotalAllocatableCPU), displayOptions.cpu(nodeData.TotalLimitsCPUCores))
+		fmt.Fprintf(w, "%s\t", displayOptions.cpu(nodeData.TotalAvailableCPUCores))
+		fmt.Fprintf(w, "%s\t%s\t", displayOptions.mem(nodeData.TotalCapacityMemoryGiB), displayOptions.mem(nodeData.TotalAllocatableMemoryGiB))
+		fmt.Fprintf(w, "%s\t%s\t", displayOptions.forGroup(ThresholdMemory, nodeGroups...).highlightQuantity(displayOptions.mem(nodeData.TotalRequestsMemoryGiB), nodeData.TotalRequestsMemory, nodeData.TotalAllocatableMemory), displayOptions.mem(nodeData.TotalLimitsMemoryGiB))
+		fmt.Fprintf(w, "%s\t", displayOptions.mem(nodeData.TotalAvailableMemoryGiB))
+		if displayOptions.EphemeralStorage {
 			fmt.Fprintf(w, "%.1f\t%.1f\t", nodeData.TotalCapacityEphemeralStorageGB, nodeData.TotalAllocatableEphemeralStorageGB)
-			fmt.Fprintf(w, "%.1f\t%.1f\t", nodeData.TotalRequestsEphemeralStorageGB, nodeData.TotalLimitsEphemeralStorageGB)
+			fmt.Fprintf(w, "%s\t%.1f\t", displayOptions.forGroup(ThresholdEphemeralStorage, nodeGroups...).highlightQuantity(fmt.Sprintf("%.1f", nodeData.TotalRequestsEphemeralStorageGB), nodeData.TotalRequestsEphemeralStorage, nodeData.TotalAllocatableEphemeralStorage), nodeData.TotalLimitsEphemeralStorageGB)
 			fmt.Fprintf(w, "%.1f\t", nodeData.TotalAvailableEphemeralStorageGB)
 		}
-		fmt.Fprintln(w, "")
 	}
-}
-
-func DisplayNamespaceData(namespaceCapacityData map[string]*NamespaceCapacityData, sortedNamespaceNames []string, displayDefault bool, displayHeaders bool, displayEphemeralStorage bool, displayFormat string, displayAllNamespaces bool) {
-	switch displayFormat {
-	case jsonDisplay:
-		jsonNamespaceData, err := json.MarshalIndent(&namespaceCapacityData, "", "  ")
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(string(jsonNamespaceData))
-	case yamlDisplay:
-		yamlNamespaceData, err := yaml.Marshal(namespaceCapacityData)
-		if err != nil {
-			fmt.Println(err)
-			return
+	if displayOptions.AvailablePercent {
+		fmt.Fprintf(w, "%.1f\t%.1f\t%.1f\t", nodeData.AvailablePodsPercent, nodeData.AvailableCPUPercent, nodeData.AvailableMemoryPercent)
+	}
+	if displayOptions.Volumes {
+		if nodeData.AttachLimit > 0 {
+			fmt.Fprintf(w, "%d\t%d\t%s\t", nodeData.AttachedVolumes, nodeData.AttachLimit, displayOptions.percent(nodeData.AttachedVolumesPercent))
+		} else {
+			fmt.Fprintf(w, "%d\t<none>\t<none>\t", nodeData.AttachedVolumes)
 		}
-		fmt.Print(string(yamlNamespaceData))
-	default:
-		w := new(tabwriter.Writer)
-		w.Init(os.Stdout, 0, 5, 1, ' ', 0)
-		if displayHeaders {
-			if displayDefault {
-				fmt.Fprintf(w, "NAMESPACE\tPODS\t\t\tCPU\t\tMEMORY\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE")
-				}
-				fmt.Fprintln(w, "")
-			} else {
-				fmt.Fprintf(w, "NAMESPACE\tPODS\t\t\tCPU (cores)\t\tMEMORY (GiB)\t\t")
-				if displayEphemeralStorage {
-					fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)")
-				}
-				fmt.Fprintln(w, "")
-			}
-			fmt.Fprintf(w, "\tTotal\tNon-Term\tUnassigned\tRequests\tLimits\tRequests\tLimits\t")
-			if displayEphemeralStorage {
-				fmt.Fprintf(w, "Requests\tLimits")
+	}
+	if displayOptions.Disk {
+		if nodeData.Disk != nil {
+			for _, fs := range []FsData{nodeData.Disk.NodeFs, nodeData.Disk.ImageFs} {
+				fmt.Fprintf(w, "%.1f\t%.1f\t%.1f\t%s\t", fs.CapacityGB, fs.UsedGB, fs.AvailableGB, displayOptions.percent(fs.UsedPercent))
 			}
-			fmt.Fprintln(w, "")
+		} else {
+			fmt.Fprintf(w, "<none>\t<none>\t<none>\t<none>\t<none>\t<none>\t<none>\t<none>\t")
 		}
-		for _, k := range sortedNamespaceNames {
-			if (namespaceCapacityData[k].TotalPodCount != 0) || displayAllNamespaces {
-				fmt.Fprintf(w, "%s\t", k)
-				fmt.Fprintf(w, "%d\t%d\t%d\t", namespaceCapacityData[k].TotalPodCount, namespaceCapacityData[k].TotalNonTermPodCount, namespaceCapacityData[k].TotalUnassignedNodePodCount)
-				if displayDefault {
-					fmt.Fprintf(w, "%s\t%s\t", &namespaceCapacityData[k].TotalRequestsCPU, &namespaceCapacityData[k].TotalLimitsCPU)
-					fmt.Fprintf(w, "%s\t%s\t", &namespaceCapacityData[k].TotalRequestsMemory, &namespaceCapacityData[k].TotalLimitsMemory)
-					if displayEphemeralStorage {
-						fmt.Fprintf(w, "%s\t%s\t", &namespaceCapacityData[k].TotalRequestsEphemeralStorage, &namespaceCapacityData[k].TotalLimitsEphemeralStorage)
-					}
-					fmt.Fprintln(w, "")
-				} else {
-					fmt.Fprintf(w, "%.1f\t%.1f\t", namespaceCapacityData[k].TotalRequestsCPUCores, namespaceCapacityData[k].TotalLimitsCPUCores)
-					fmt.Fprintf(w, "%.1f\t%.1f\t", namespaceCapacityData[k].TotalRequestsMemoryGiB, namespaceCapacityData[k].TotalLimitsMemoryGiB)
-					if displayEphemeralStorage {
-						fmt.Fprintf(w, "%.1f\t%.1f\t", namespaceCapacityData[k].TotalRequestsEphemeralStorageGB, namespaceCapacityData[k].TotalLimitsEphemeralStorageGB)
-					}
-					fmt.Fprintln(w, "")
-				}
-			}
+	}
+	if displayOptions.Format == wideDisplay {
+		if nodeName != "*unassigned*" && nodeName != "*total*" && nodeName != "*average*" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t", noneIfEmpty(nodeData.KubeletVersion), noneIfEmpty(nodeData.InstanceType), noneIfEmpty(nodeData.Zone), nodeData.TaintCount, noneIfEmpty(nodeData.InternalIP))
+			fmt.Fprintf(w, "%s\t", displayOptions.heartbeat(nodeData))
+		} else {
+			fmt.Fprintf(w, "\t\t\t\t\t\t")
+		}
+	}
+	if nodeName != "*unassigned*" && nodeName != "*total*" && nodeName != "*average*" {
+		for _, labelColumn := range displayOptions.LabelColumns {
+			fmt.Fprintf(w, "%s\t", nodeData.Labels[labelColumn])
+		}
+		if displayOptions.ShowLabels {
+			fmt.Fprintf(w, "%s\t", formatLabels(nodeData.Labels))
+		}
+	} else {
+		fmt.Fprint(w, strings.Repeat("\t", len(displayOptions.LabelColumns)))
+		if displayOptions.ShowLabels {
+			fmt.Fprintf(w, "\t")
 		}
-		w.Flush()
+	}
+	fmt.Fprintln(w, "")
+	for _, pod := range nodeData.Pods {
+		printNodePodData(w, pod, displayOptions)
 	}
 }
 
-func ValidateOutput(cmd cobra.Command) error {
-	displayFormat, err := cmd.Flags().GetString("output")
-	if err != nil {
-		return fmt.Errorf("unable to get output display format")
-	}
-	validOutputs := []string{tableDisplay, jsonDisplay, yamlDisplay}
-	for _, validOutputFormat := range validOutputs {
-		if displayFormat == validOutputFormat {
-			return nil
+// Prints a pod of a node indented below the node, only the requests and limits columns are filled
+func printNodePodData(w io.Writer, pod PodCapacityData, displayOptions DisplayOptions) {
+	fmt.Fprintf(w, "  %s/%s\t%s\t\t\t\t\t\t\t%s", pod.Namespace, pod.Name, pod.Phase, displayOptions.podColumnsTab())
+	if displayOptions.Default {
+		fmt.Fprintf(w, "\t\t%s\t%s\t\t", &pod.RequestsCPU, &pod.LimitsCPU)
+		fmt.Fprintf(w, "\t\t%s\t%s\t\t", &pod.RequestsMemory, &pod.LimitsMemory)
+		if displayOptions.EphemeralStorage {
+			fmt.Fprintf(w, "\t\t%s\t%s\t\t", &pod.RequestsEphemeralStorage, &pod.LimitsEphemeralStorage)
+		}
+	} else {
+		fmt.Fprintf(w, "\t\t%s\t%s\t\t", displayOptions.cpu(pod.RequestsCPUCores), displayOptions.cpu(pod.LimitsCPUCores))
+		fmt.Fprintf(w, "\t\t%s\t%s\t\t", displayOptions.mem(pod.RequestsMemoryGiB), displayOptions.mem(pod.LimitsMemoryGiB))
+		if displayOptions.EphemeralStorage {
+			fmt.Fprintf(w, "\t\t%.1f\t%.1f\t\t", pod.RequestsEphemeralStorageGB, pod.LimitsEphemeralStorageGB)
 		}
 	}
-	return fmt.Errorf("Display Format \"%s\" is invalid. Valid values are %v", displayFormat, validOutputs)
+	if displayOptions.Format == wideDisplay {
+		fmt.Fprintf(w, "\t\t\t\t\t\t")
+	}
+	fmt.Fprint(w, strings.Repeat("\t", len(displayOptions.LabelColumns)))
+	if displayOptions.ShowLabels {
+		fmt.Fprintf(w, "\t")
+	}
+	fmt.Fprintln(w, "")
+}
+
+// kubectl uses the upper cased last segment of the label key as the header of label columns
+func labelColumnHeader(labelKey string) string {
+	return strings.ToUpper(labelKey[strings.LastIndex(labelKey, "/")+1:])
+}
+
+// Formats labels like kubectl --show-labels, sorted by key
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Age of the last Ready heartbeat of a node, stale heartbeats are marked since the capacity data may be outdated
+func (displayOptions DisplayOptions) heartbeat(nodeData *NodeCapacityData) string {
+	cell := "<none>"
+	if nodeData.LastHeartbeatTime != nil {
+		cell = duration.ShortHumanDuration(displayOptions.Timestamp.Sub(*nodeData.LastHeartbeatTime))
+	}
+	if !nodeData.HeartbeatStale {
+		return cell
+	}
+	cell += "(stale)"
+	if displayOptions.Color {
+		return colorRed + cell + colorReset
+	}
+	return cell
+}
+
+// Terminating and static pods are displayed in their own columns when they are excluded from the pod counts, requests
+// and limits, BestEffort pods when requested with --best-effort
+func (displayOptions DisplayOptions) podColumnsTab() string {
+	return strings.Repeat("\t", strings.Count(displayOptions.podColumnsHeader(), "\t"))
+}
+
+func (displayOptions DisplayOptions) podColumnsHeader() string {
+	header := ""
+	if displayOptions.ExcludeTerminating {
+		header += "Term\t"
+	}
+	if displayOptions.ExcludeStatic {
+		header += "Static\t"
+	}
+	if displayOptions.BestEffort {
+		header += "BestEffort\t"
+	}
+	return header
+}
+
+func (displayOptions DisplayOptions) podColumnsCells(terminatingPodCount int, staticPodCount int, bestEffortPodCount int) string {
+	cells := ""
+	if displayOptions.ExcludeTerminating {
+		cells += strconv.Itoa(terminatingPodCount) + "\t"
+	}
+	if displayOptions.ExcludeStatic {
+		cells += strconv.Itoa(staticPodCount) + "\t"
+	}
+	if displayOptions.BestEffort {
+		cells += strconv.Itoa(bestEffortPodCount) + "\t"
+	}
+	return cells
+}
+
+// kubectl prints <none> for missing values in wide output
+// Formats counts as name=count pairs sorted by name
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func noneIfEmpty(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func DisplayNamespaceData(namespaceCapacityData map[string]*NamespaceCapacityData, sortedNamespaceNames []string, displayOptions DisplayOptions, displayAllNamespaces bool) error {
+	if displayOptions.Anonymize {
+		namespaceCapacityData, sortedNamespaceNames = anonymizeNamespaceData(namespaceCapacityData, sortedNamespaceNames)
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		data, err := toSchema(namespaceCapacityData, displayOptions.Schema, &map[string]*namespaceCapacityDataV1{})
+		if err != nil {
+			return err
+		}
+		return printObject(data, displayOptions)
+	case nameDisplay:
+		namespaceNames := make([]string, 0, len(sortedNamespaceNames))
+		for _, k := range sortedNamespaceNames {
+			if (namespaceCapacityData[k].TotalPodCount != 0) || displayAllNamespaces {
+				namespaceNames = append(namespaceNames, k)
+			}
+		}
+		return printNames("Namespace", namespaceNames, displayOptions.Out)
+	default:
+		return printNamespaceTable(namespaceCapacityData, sortedNamespaceNames, "NAMESPACE", displayOptions, displayAllNamespaces)
+	}
+}
+
+// Displays namespace capacity data rolled up by the values of a namespace label
+func DisplayNamespaceLabelData(groupCapacityData map[string]*NamespaceCapacityData, sortedGroupNames []string, label string, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(groupCapacityData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for namespace label data", displayOptions.Format)
+	default:
+		return printNamespaceTable(groupCapacityData, sortedGroupNames, strings.ToUpper(label), displayOptions, true)
+	}
+}
+
+// Displays the subtree capacity data of hierarchical namespaces, names are indented by their depth in the tree
+func DisplayNamespaceTreeData(treeData []NamespaceTreeData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(treeData, displayOptions)
+	case nameDisplay:
+		namespaceNames := make([]string, 0, len(treeData))
+		for _, namespace := range treeData {
+			namespaceNames = append(namespaceNames, namespace.Namespace)
+		}
+		return printNames("Namespace", namespaceNames, displayOptions.Out)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintf(w, "NAMESPACE\tPODS\t\tCPU (%[1]s)\t\tMEMORY (%[2]s)\t\tQUOTA CPU (%[1]s)\t\tQUOTA MEMORY (%[2]s)\t\t\n", displayOptions.cpuUnitName(), displayOptions.memUnitName())
+			fmt.Fprintln(w, "\tTotal\tNon-Term\tRequests\tLimits\tRequests\tLimits\tUsed\tHard\tUsed\tHard\t")
+		}
+		for _, namespace := range treeData {
+			fmt.Fprintf(w, "%s%s\t%d\t%d\t", strings.Repeat("  ", namespace.Depth), namespace.Namespace, namespace.Capacity.TotalPodCount, namespace.Capacity.TotalNonTermPodCount)
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(namespace.Capacity.TotalRequestsCPUCores), displayOptions.cpu(namespace.Capacity.TotalLimitsCPUCores))
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.mem(namespace.Capacity.TotalRequestsMemoryGiB), displayOptions.mem(namespace.Capacity.TotalLimitsMemoryGiB))
+			fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(namespace.QuotaUsedCPUCores), displayOptions.cpu(namespace.QuotaHardCPUCores))
+			fmt.Fprintf(w, "%s\t%s\t\n", displayOptions.mem(namespace.QuotaUsedMemoryGiB), displayOptions.mem(namespace.QuotaHardMemoryGiB))
+		}
+		return w.Flush()
+	}
+}
+
+// Prints the table of namespace capacity data with the title of its first column, rows without pods are skipped
+// unless displayAll is set
+func printNamespaceTable(namespaceCapacityData map[string]*NamespaceCapacityData, sortedNamespaceNames []string, title string, displayOptions DisplayOptions, displayAll bool) error {
+	w := newTableWriter(displayOptions.Out, displayOptions)
+	if displayOptions.Headers {
+		if displayOptions.Default {
+			fmt.Fprintf(w, "%s\tPODS\t\t\t%sCPU\t\tMEMORY\t\t", title, displayOptions.podColumnsTab())
+			if displayOptions.EphemeralStorage {
+				fmt.Fprintf(w, "EPHEMERAL STORAGE")
+			}
+			fmt.Fprintln(w, "")
+		} else {
+			fmt.Fprintf(w, "%s\tPODS\t\t\t%sCPU (%s)\t\tMEMORY (%s)\t\t", title, displayOptions.podColumnsTab(), displayOptions.cpuUnitName(), displayOptions.memUnitName())
+			if displayOptions.EphemeralStorage {
+				fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)")
+			}
+			fmt.Fprintln(w, "")
+		}
+		fmt.Fprintf(w, "\tTotal\tNon-Term\t%sUnassigned\tRequests\tLimits\tRequests\tLimits\t", displayOptions.podColumnsHeader())
+		if displayOptions.EphemeralStorage {
+			fmt.Fprintf(w, "Requests\tLimits")
+		}
+		fmt.Fprintln(w, "")
+	}
+	for _, k := range sortedNamespaceNames {
+		if (namespaceCapacityData[k].TotalPodCount != 0) || displayAll {
+			fmt.Fprintf(w, "%s\t", k)
+			fmt.Fprintf(w, "%d\t%d\t%s%d\t", namespaceCapacityData[k].TotalPodCount, namespaceCapacityData[k].TotalNonTermPodCount, displayOptions.podColumnsCells(namespaceCapacityData[k].TotalTerminatingPodCount, namespaceCapacityData[k].TotalStaticPodCount, namespaceCapacityData[k].TotalBestEffortPodCount), namespaceCapacityData[k].TotalUnassignedNodePodCount)
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\t%s\t", &namespaceCapacityData[k].TotalRequestsCPU, &namespaceCapacityData[k].TotalLimitsCPU)
+				fmt.Fprintf(w, "%s\t%s\t", &namespaceCapacityData[k].TotalRequestsMemory, &namespaceCapacityData[k].TotalLimitsMemory)
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "%s\t%s\t", &namespaceCapacityData[k].TotalRequestsEphemeralStorage, &namespaceCapacityData[k].TotalLimitsEphemeralStorage)
+				}
+				fmt.Fprintln(w, "")
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(namespaceCapacityData[k].TotalRequestsCPUCores), displayOptions.cpu(namespaceCapacityData[k].TotalLimitsCPUCores))
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.mem(namespaceCapacityData[k].TotalRequestsMemoryGiB), displayOptions.mem(namespaceCapacityData[k].TotalLimitsMemoryGiB))
+				if displayOptions.EphemeralStorage {
+					fmt.Fprintf(w, "%.1f\t%.1f\t", namespaceCapacityData[k].TotalRequestsEphemeralStorageGB, namespaceCapacityData[k].TotalLimitsEphemeralStorageGB)
+				}
+				fmt.Fprintln(w, "")
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// Displays usage data grouped by cluster, node-role, node or namespace
+func DisplayUsageData(usageData map[string]*UsageData, sortedNames []string, groupBy string, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		usageData, sortedNames = anonymizeUsageData(usageData, sortedNames, groupBy)
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(usageData, displayOptions)
+	case nameDisplay:
+		switch groupBy {
+		case "node":
+			return printNames("Node", sortedNames, displayOptions.Out)
+		case "namespace":
+			return printNames("Namespace", sortedNames, displayOptions.Out)
+		}
+		return fmt.Errorf("output format \"%s\" is not supported for usage data grouped by %s", displayOptions.Format, groupBy)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			subColumns := "Allocatable\tRequests\tUsed\tLimits\tReq%\tUsed%\tLim%\t"
+			if displayOptions.Efficiency {
+				subColumns += "Eff%\t"
+			}
+			// Each resource heading spans its sub-columns
+			span := strings.Repeat("\t", strings.Count(subColumns, "\t"))
+			storageHeader, storageSubColumns := "", ""
+			if displayOptions.EphemeralStorage {
+				storageHeader, storageSubColumns = "EPHEMERAL STORAGE (GB)", "Allocatable\tRequests\tUsed\tLimits\tReq%\tUsed%\tLim%\t"
+				if displayOptions.Default {
+					storageHeader = "EPHEMERAL STORAGE"
+				}
+			}
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\tCPU%sMEMORY%s%s\n", strings.ToUpper(groupBy), span, span, storageHeader)
+			} else {
+				fmt.Fprintf(w, "%s\tCPU (%s)%sMEMORY (%s)%s%s\n", strings.ToUpper(groupBy), displayOptions.cpuUnitName(), span, displayOptions.memUnitName(), span, storageHeader)
+			}
+			fmt.Fprintf(w, "\t%s%s%s\n", subColumns, subColumns, storageSubColumns)
+		}
+		for _, k := range sortedNames {
+			data := usageData[k]
+			fmt.Fprintf(w, "%s\t", k)
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", &data.AllocatableCPU, &data.RequestsCPU, &data.UsedCPU, &data.LimitsCPU)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", displayOptions.cpu(data.AllocatableCPUCores), displayOptions.cpu(data.RequestsCPUCores), displayOptions.cpu(data.UsedCPUCores), displayOptions.cpu(data.LimitsCPUCores))
+			}
+			cpuOptions := displayOptions.forGroup(ThresholdCPU, k)
+			fmt.Fprintf(w, "%s\t%s\t%s\t", cpuOptions.percent(data.RequestsCPUPercent), cpuOptions.percent(data.UsedCPUPercent), cpuOptions.percent(data.LimitsCPUPercent))
+			if displayOptions.Efficiency {
+				fmt.Fprintf(w, "%s\t", efficiencyCell(data.CPUEfficiencyPercent, data.RequestsCPU))
+			}
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", &data.AllocatableMemory, &data.RequestsMemory, &data.UsedMemory, &data.LimitsMemory)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", displayOptions.mem(data.AllocatableMemoryGiB), displayOptions.mem(data.RequestsMemoryGiB), displayOptions.mem(data.UsedMemoryGiB), displayOptions.mem(data.LimitsMemoryGiB))
+			}
+			memoryOptions := displayOptions.forGroup(ThresholdMemory, k)
+			fmt.Fprintf(w, "%s\t%s\t%s\t", memoryOptions.percent(data.RequestsMemoryPercent), memoryOptions.percent(data.UsedMemoryPercent), memoryOptions.percent(data.LimitsMemoryPercent))
+			if displayOptions.Efficiency {
+				fmt.Fprintf(w, "%s\t", efficiencyCell(data.MemoryEfficiencyPercent, data.RequestsMemory))
+			}
+			if storage := data.EphemeralStorage; displayOptions.EphemeralStorage && storage != nil {
+				if displayOptions.Default {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", &storage.Allocatable, &storage.Requests, &storage.Used, &storage.Limits)
+				} else {
+					fmt.Fprintf(w, "%.1f\t%.1f\t%.1f\t%.1f\t", storage.AllocatableGB, storage.RequestsGB, storage.UsedGB, storage.LimitsGB)
+				}
+				storageOptions := displayOptions.forGroup(ThresholdEphemeralStorage, k)
+				fmt.Fprintf(w, "%s\t%s\t%s\t", storageOptions.percent(storage.RequestsPercent), storageOptions.percent(storage.UsedPercent), storageOptions.percent(storage.LimitsPercent))
+			}
+			fmt.Fprintln(w, "")
+		}
+		return w.Flush()
+	}
+}
+
+// Percent of allocatable cell, highlighted past the thresholds
+func (displayOptions DisplayOptions) percent(percent float64) string {
+	return displayOptions.highlight(fmt.Sprintf("%.1f", percent), percent, 100)
+}
+
+// Efficiency is undefined without requests
+func efficiencyCell(percent float64, requests resource.Quantity) string {
+	if requests.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", percent)
+}
+
+func DisplayScoreData(scoreData ScoreData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(scoreData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for score data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "GRADE\tSCORE\t")
+		}
+		fmt.Fprintf(w, "%s\t%d\t\n", scoreData.Grade, scoreData.Score)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "SIGNAL\tVALUE\tPENALTY\tREASON\t")
+		}
+		for _, signal := range scoreData.Signals {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t\n", signal.Name, strconv.FormatFloat(signal.Value, 'f', -1, 64), signal.Penalty, signal.Reason)
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayRebalanceData(rebalanceData RebalanceData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(rebalanceData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for rebalance data", displayOptions.Format)
+	default:
+		formatPercent := func(value float64) string { return strconv.FormatFloat(value, 'f', 1, 64) }
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "ROLE\tNODES\tIMBALANCED\tCPU SPREAD BEFORE (%)\tCPU SPREAD AFTER (%)\tMEMORY SPREAD BEFORE (%)\tMEMORY SPREAD AFTER (%)\tEVICTIONS\t")
+		}
+		imbalancedRoles := 0
+		for _, role := range rebalanceData.Roles {
+			imbalanced := "no"
+			if role.Imbalanced {
+				imbalanced = "yes"
+				imbalancedRoles++
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%d\t\n", role.Role, role.NodeCount, imbalanced, formatPercent(role.CPUSpreadBefore), formatPercent(role.CPUSpreadAfter), formatPercent(role.MemorySpreadBefore), formatPercent(role.MemorySpreadAfter), role.EvictionCount)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if imbalancedRoles == 0 {
+			return nil
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "ROLE\tNODE\tCPU BEFORE (%)\tCPU AFTER (%)\tMEMORY BEFORE (%)\tMEMORY AFTER (%)\t")
+		}
+		for _, role := range rebalanceData.Roles {
+			if !role.Imbalanced {
+				continue
+			}
+			for _, node := range role.Nodes {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", role.Role, node.Node, formatPercent(node.CPUBefore), formatPercent(node.CPUAfter), formatPercent(node.MemoryBefore), formatPercent(node.MemoryAfter))
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(rebalanceData.Evictions) == 0 {
+			return nil
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "ROLE\tNAMESPACE\tPOD\tFROM\tTO\tCPU REQUESTS\tMEMORY REQUESTS\t")
+		}
+		for _, eviction := range rebalanceData.Evictions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", eviction.Role, eviction.Namespace, eviction.Pod, eviction.From, eviction.To, eviction.RequestsCPU.String(), eviction.RequestsMemory.String())
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayPreemptionData(preemptionData PreemptionData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(preemptionData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for preemption data", displayOptions.Format)
+	default:
+		roleNames := make([]string, 0, len(preemptionData.Roles))
+		roles := make(map[string]PreemptionCapacityData, len(preemptionData.Roles))
+		for _, roleData := range preemptionData.Roles {
+			roleNames = append(roleNames, roleData.Name)
+			roles[roleData.Name] = roleData
+		}
+		capacity.SortRoleNames(roleNames, displayOptions.PinnedRoles, displayOptions.NoneLast)
+		sortedRoles := make([]PreemptionCapacityData, 0, len(roleNames))
+		for _, roleName := range roleNames {
+			sortedRoles = append(sortedRoles, roles[roleName])
+		}
+		if err := displayPreemptionTable("ROLE\tNODES", sortedRoles, func(data PreemptionCapacityData) string {
+			return fmt.Sprintf("%s\t%d", data.Name, data.NodeCount)
+		}, displayOptions); err != nil {
+			return err
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		return displayPreemptionTable("NAME\tROLES", preemptionData.Nodes, func(data PreemptionCapacityData) string {
+			return fmt.Sprintf("%s\t%s", data.Name, noneIfEmpty(strings.Join(data.Roles, ",")))
+		}, displayOptions)
+	}
+}
+
+func DisplayPriorityTiersData(priorityTiersData PriorityTiersData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(priorityTiersData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for priority tier data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintln(w, "PRIORITY\tROLE\tPODS\tAVAIL PODS\tAVAIL CPU\tAVAIL MEMORY\t")
+			} else {
+				fmt.Fprintf(w, "PRIORITY\tROLE\tPODS\tAVAIL PODS\tAVAIL CPU (%s)\tAVAIL MEMORY (%s)\t\n", displayOptions.cpuUnitName(), displayOptions.memUnitName())
+			}
+		}
+		for start := 0; start < len(priorityTiersData.Tiers); {
+			end := start
+			tierRoles := make(map[string]PriorityTierData)
+			roleNames := make([]string, 0)
+			for ; end < len(priorityTiersData.Tiers) && priorityTiersData.Tiers[end].Priority == priorityTiersData.Tiers[start].Priority; end++ {
+				tierRoles[priorityTiersData.Tiers[end].Role] = priorityTiersData.Tiers[end]
+				roleNames = append(roleNames, priorityTiersData.Tiers[end].Role)
+			}
+			capacity.SortRoleNames(roleNames, displayOptions.PinnedRoles, displayOptions.NoneLast)
+			for _, roleName := range roleNames {
+				tier := tierRoles[roleName]
+				fmt.Fprintf(w, "%d\t%s\t%d\t%d\t", tier.Priority, tier.Role, tier.PodCount, tier.AvailablePods)
+				if displayOptions.Default {
+					fmt.Fprintf(w, "%s\t%s\t\n", &tier.AvailableCPU, &tier.AvailableMemory)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\t\n", displayOptions.cpu(tier.AvailableCPUCores), displayOptions.mem(tier.AvailableMemoryGiB))
+				}
+			}
+			start = end
+		}
+		return w.Flush()
+	}
+}
+
+func displayPreemptionTable(header string, rows []PreemptionCapacityData, name func(data PreemptionCapacityData) string, displayOptions DisplayOptions) error {
+	w := newTableWriter(displayOptions.Out, displayOptions)
+	if displayOptions.Headers {
+		if displayOptions.Default {
+			fmt.Fprintf(w, "%s\tPODS\tPREEMPTIBLE PODS\tAVAIL PODS\tAVAIL PODS AFTER\tAVAIL CPU\tFREED CPU\tAVAIL CPU AFTER\tAVAIL MEMORY\tFREED MEMORY\tAVAIL MEMORY AFTER\t\n", header)
+		} else {
+			fmt.Fprintf(w, "%[1]s\tPODS\tPREEMPTIBLE PODS\tAVAIL PODS\tAVAIL PODS AFTER\tAVAIL CPU (%[2]s)\tFREED CPU (%[2]s)\tAVAIL CPU AFTER (%[2]s)\tAVAIL MEMORY (%[3]s)\tFREED MEMORY (%[3]s)\tAVAIL MEMORY AFTER (%[3]s)\t\n", header, displayOptions.cpuUnitName(), displayOptions.memUnitName())
+		}
+	}
+	for _, data := range rows {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t", name(data), data.PodCount, data.PreemptiblePodCount, data.AvailablePods, data.AvailablePodsAfter)
+		if displayOptions.Default {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", &data.AvailableCPU, &data.FreedCPU, &data.AvailableCPUAfter, &data.AvailableMemory, &data.FreedMemory, &data.AvailableMemoryAfter)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", displayOptions.cpu(data.AvailableCPUCores), displayOptions.cpu(data.FreedCPUCores), displayOptions.cpu(data.AvailableCPUAfterCores), displayOptions.mem(data.AvailableMemoryGiB), displayOptions.mem(data.FreedMemoryGiB), displayOptions.mem(data.AvailableMemoryAfterGiB))
+		}
+	}
+	return w.Flush()
+}
+
+func DisplayDisruptionData(disruptionData DisruptionData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(disruptionData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for disruption data", displayOptions.Format)
+	default:
+		yesNo := func(value bool) string {
+			if value {
+				return "yes"
+			}
+			return "no"
+		}
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "NAMESPACE\tBUDGET\tPODS\tHEALTHY\tDESIRED HEALTHY\tALLOWED DISRUPTIONS\tBLOCKED NODES\tBLOCKED ROLES\tVIOLATED ZONES\t")
+		}
+		for _, budget := range disruptionData.Budgets {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t\n", budget.Namespace, budget.Name, budget.PodCount, budget.HealthyPodCount, budget.DesiredHealthyPodCount, budget.DisruptionsAllowed, noneIfEmpty(strings.Join(budget.BlockedNodes, ",")), noneIfEmpty(strings.Join(budget.BlockedRoles, ",")), noneIfEmpty(strings.Join(budget.ViolatedZones, ",")))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "NODE\tROLES\tZONE\tHEALTHY PODS\tDRAINABLE\tBLOCKING BUDGETS\t")
+		}
+		for _, node := range disruptionData.Nodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t\n", node.Node, noneIfEmpty(strings.Join(node.Roles, ",")), noneIfEmpty(node.Zone), node.HealthyPodCount, yesNo(node.Drainable), noneIfEmpty(strings.Join(node.BlockingBudgets, ",")))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(disruptionData.Zones) == 0 {
+			return nil
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "ZONE\tNODES\tHEALTHY PODS\tSURVIVABLE\tVIOLATED BUDGETS\t")
+		}
+		for _, zone := range disruptionData.Zones {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t\n", zone.Zone, zone.NodeCount, zone.HealthyPodCount, yesNo(zone.Survivable), noneIfEmpty(strings.Join(zone.ViolatedBudgets, ",")))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayEvictionRiskData(evictionRiskData EvictionRiskData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(evictionRiskData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for eviction risk data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintln(w, "NODE\tROLES\tRISK\tMEMORY USAGE (%)\tMEMORY LIMITS (%)\tEVICTION HEADROOM\tUNLIMITED PODS\tREASON\t")
+			} else {
+				fmt.Fprintf(w, "NODE\tROLES\tRISK\tMEMORY USAGE (%%)\tMEMORY LIMITS (%%)\tEVICTION HEADROOM (%s)\tUNLIMITED PODS\tREASON\t\n", displayOptions.memUnitName())
+			}
+		}
+		for _, node := range evictionRiskData.Nodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t", node.Node, noneIfEmpty(strings.Join(node.Roles, ",")), node.Risk, displayOptions.percent(node.UsagePercent), displayOptions.percent(node.LimitsPercent))
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\t", &node.EvictionHeadroomMemory)
+			} else {
+				fmt.Fprintf(w, "%s\t", displayOptions.mem(node.EvictionHeadroomMemoryGiB))
+			}
+			fmt.Fprintf(w, "%d\t%s\t\n", node.UnlimitedMemoryPods, noneIfEmpty(strings.Join(node.Reasons, "; ")))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayGapData(gapData GapData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		gapData.Nodes = append([]CapacityGapData(nil), gapData.Nodes...)
+		for i := range gapData.Nodes {
+			gapData.Nodes[i].Name = anonymize("node", gapData.Nodes[i].Name)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(gapData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for gap data", displayOptions.Format)
+	default:
+		return displaySections([]section{
+			{"NODE-ROLES", func() error { return printGapTable(gapData.NodeRoles, "ROLE\tNODES", displayOptions) }},
+			{"NODES", func() error { return printGapTable(gapData.Nodes, "NAME\tROLES", displayOptions) }},
+		}, displayOptions)
+	}
+}
+
+func printGapTable(rows []CapacityGapData, header string, displayOptions DisplayOptions) error {
+	w := newTableWriter(displayOptions.Out, displayOptions)
+	nodes := strings.HasPrefix(header, "NAME")
+	if displayOptions.Headers {
+		fmt.Fprintf(w, "%s\tCPU (%s)\t\t\t\tMEMORY (%s)\t\t\t\t", header, displayOptions.cpuUnitName(), displayOptions.memUnitName())
+		if displayOptions.EphemeralStorage {
+			fmt.Fprintf(w, "EPHEMERAL STORAGE (GB)\t\t\t\t")
+		}
+		if nodes {
+			fmt.Fprintf(w, "OUTLIER\t")
+		}
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "\t\tCapacity\tAllocatable\tGap\tGap %%\tCapacity\tAllocatable\tGap\tGap %%\t")
+		if displayOptions.EphemeralStorage {
+			fmt.Fprintf(w, "Capacity\tAllocatable\tGap\tGap %%\t")
+		}
+		if nodes {
+			fmt.Fprintf(w, "\t")
+		}
+		fmt.Fprintln(w, "")
+	}
+	for _, row := range rows {
+		if nodes {
+			fmt.Fprintf(w, "%s\t%s\t", row.Name, noneIfEmpty(strings.Join(row.Roles, ",")))
+		} else {
+			fmt.Fprintf(w, "%s\t%d\t", row.Name, row.Nodes)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t", displayOptions.cpu(row.CapacityCPUCores), displayOptions.cpu(row.AllocatableCPUCores), displayOptions.cpu(row.GapCPUCores), row.GapCPUPercent)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t", displayOptions.mem(row.CapacityMemoryGiB), displayOptions.mem(row.AllocatableMemoryGiB), displayOptions.mem(row.GapMemoryGiB), row.GapMemoryPercent)
+		if displayOptions.EphemeralStorage {
+			fmt.Fprintf(w, "%.1f\t%.1f\t%.1f\t%.1f\t", row.CapacityEphemeralStorageGB, row.AllocatableEphemeralStorageGB, row.GapEphemeralStorageGB, row.GapEphemeralStoragePercent)
+		}
+		if nodes {
+			fmt.Fprintf(w, "%s\t", noneIfEmpty(row.Outlier))
+		}
+		fmt.Fprintln(w, "")
+	}
+	return w.Flush()
+}
+
+func DisplayDensityData(densityData DensityData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		densityData.NodeRoles = append([]NodeRoleDensityData(nil), densityData.NodeRoles...)
+		for i := range densityData.NodeRoles {
+			densityData.NodeRoles[i].DensestNode = anonymize("node", densityData.NodeRoles[i].DensestNode)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(densityData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for density data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "ROLE\tNODES\tPODS\tPODS/CORE\tPODS/GIB\tPODS/NODE\tDENSEST NODE\t\tSTATUS\tREASON\t")
+			fmt.Fprintln(w, "\t\t\t\t\t\tName\tPods\t\t\t")
+		}
+		for _, role := range densityData.NodeRoles {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\t", role.Role, role.Nodes, role.Pods, role.PodsPerCore, role.PodsPerGiB, role.PodsPerNode)
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t\n", noneIfEmpty(role.DensestNode), role.MaxPodsPerNode, role.Status, noneIfEmpty(strings.Join(role.Reasons, "; ")))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayMaxPodsData(maxPodsData MaxPodsData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(maxPodsData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for max pods data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "NODE\tINSTANCE-TYPE\tMAX PODS\tPOD CIDR\tCIDR LIMIT\tENI LIMIT\tWARNING\t")
+		}
+		limit := func(pods int64) string {
+			if pods == 0 {
+				return "<none>"
+			}
+			return strconv.FormatInt(pods, 10)
+		}
+		for _, node := range maxPodsData.Nodes {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t\n", node.Node, noneIfEmpty(node.InstanceType), node.MaxPods, noneIfEmpty(node.PodCIDR), limit(node.CIDRMaxPods), limit(node.ENIMaxPods), noneIfEmpty(strings.Join(node.Reasons, "; ")))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayCPUManagerData(cpuManagerData CPUManagerData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		cpuManagerData.Nodes = append([]NodeCPUManagerData(nil), cpuManagerData.Nodes...)
+		for i := range cpuManagerData.Nodes {
+			cpuManagerData.Nodes[i].Node = anonymize("node", cpuManagerData.Nodes[i].Node)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(cpuManagerData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for cpu manager data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintf(w, "NODE\tROLES\tPOLICY\tALLOCATABLE (%s)\tEXCLUSIVE\t\tSHARED POOL (%s)\t\t\t\t\n", displayOptions.cpuUnitName(), displayOptions.cpuUnitName())
+			fmt.Fprintln(w, "\t\t\t\tCPU\tPods\tCPU\tRequests\tAvailable\tRequests %\t")
+		}
+		for _, node := range append(cpuManagerData.Nodes, cpuManagerData.Total) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", node.Node, noneIfEmpty(strings.Join(node.Roles, ",")), noneIfEmpty(node.Policy), displayOptions.cpu(node.AllocatableCPUCores))
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t", displayOptions.cpu(node.ExclusiveCPUCores), node.ExclusivePods, displayOptions.cpu(node.SharedPoolCPUCores), displayOptions.cpu(node.SharedRequestsCPUCores))
+			fmt.Fprintf(w, "%s\t%s\t\n", displayOptions.cpu(node.SharedAvailableCPUCores), displayOptions.percent(node.SharedRequestsPercent))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayNUMAData(numaData NUMAData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		numaData.Resources = append([]NodeNUMAData(nil), numaData.Resources...)
+		for i := range numaData.Resources {
+			numaData.Resources[i].Node = anonymize("node", numaData.Resources[i].Node)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(numaData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for numa data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintf(w, "NODE\tPOLICY\tRESOURCE\tALLOCATABLE (%s)\tAVAILABLE (%s)\tLARGEST ZONE\t\tZONES AVAILABLE\tWARNING\t\n", displayOptions.memUnitName(), displayOptions.memUnitName())
+			fmt.Fprintln(w, "\t\t\t\t\tName\tAvailable\t\t\t")
+		}
+		for _, row := range numaData.Resources {
+			zoneNames := make([]string, 0, len(row.ZonesAvailableGiB))
+			for zoneName := range row.ZonesAvailableGiB {
+				zoneNames = append(zoneNames, zoneName)
+			}
+			sort.Strings(zoneNames)
+			zones := make([]string, 0, len(zoneNames))
+			for _, zoneName := range zoneNames {
+				zones = append(zones, fmt.Sprintf("%s=%s", zoneName, displayOptions.mem(row.ZonesAvailableGiB[zoneName])))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t", row.Node, noneIfEmpty(row.Policy), row.Resource, displayOptions.mem(row.AllocatableGiB), displayOptions.mem(row.AvailableGiB))
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", noneIfEmpty(row.LargestZone), displayOptions.mem(row.LargestZoneAvailableGiB), noneIfEmpty(strings.Join(zones, ",")), noneIfEmpty(strings.Join(row.Reasons, "; ")))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayPodIPsData(podIPsData PodIPsData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		podIPsData.Nodes = append([]NodePodIPsData(nil), podIPsData.Nodes...)
+		for i := range podIPsData.Nodes {
+			podIPsData.Nodes[i].Node = anonymize("node", podIPsData.Nodes[i].Node)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(podIPsData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for pod IPs data", displayOptions.Format)
+	default:
+		return displaySections([]section{
+			{"IP FAMILIES", func() error {
+				w := newTableWriter(displayOptions.Out, displayOptions)
+				if displayOptions.Headers {
+					fmt.Fprintln(w, "FAMILY\tNODES\tADDRESSES\tUSED\tAVAILABLE\tUSED %\tNEAR NODES\tEXHAUSTED NODES\t")
+				}
+				for _, family := range podIPsData.Families {
+					fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\t%d\t%d\t\n", family.Family, family.Nodes, addresses(family.Addresses), family.Used, addresses(family.Available), displayOptions.percent(family.UsedPercent), family.NearNodes, family.ExhaustedNodes)
+				}
+				return w.Flush()
+			}},
+			{"NODES", func() error {
+				w := newTableWriter(displayOptions.Out, displayOptions)
+				if displayOptions.Headers {
+					fmt.Fprintln(w, "NODE\tFAMILY\tPOD CIDR\tADDRESSES\tUSED\tAVAILABLE\tUSED %\t")
+				}
+				for _, node := range podIPsData.Nodes {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t\n", node.Node, node.Family, node.PodCIDR, addresses(node.Addresses), node.Used, addresses(node.Available), displayOptions.percent(node.UsedPercent))
+				}
+				return w.Flush()
+			}},
+		}, displayOptions)
+	}
+}
+
+// IPv6 ranges hold more addresses than are readable, those past 2^32 are shown in powers of two
+func addresses(count float64) string {
+	if count >= math.Exp2(32) {
+		return fmt.Sprintf("~2^%.0f", math.Log2(count))
+	}
+	return fmt.Sprintf("%.0f", count)
+}
+
+func DisplayChurnData(churnData ChurnData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		churnData.Namespaces = append([]GroupChurnData(nil), churnData.Namespaces...)
+		for i := range churnData.Namespaces {
+			churnData.Namespaces[i].Name = anonymize("namespace", churnData.Namespaces[i].Name)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(churnData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for churn data", displayOptions.Format)
+	default:
+		displayGroups := func(heading string, groups []GroupChurnData) func() error {
+			return func() error {
+				w := newTableWriter(displayOptions.Out, displayOptions)
+				if displayOptions.Headers {
+					fmt.Fprintf(w, "%s\tCREATED\tDELETED\tCREATED/MIN\tDELETED/MIN\t\n", heading)
+				}
+				for _, group := range groups {
+					fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\t%.2f\t\n", group.Name, group.Creations, group.Deletions, group.CreationsPerMinute, group.DeletionsPerMinute)
+				}
+				return w.Flush()
+			}
+		}
+		return displaySections([]section{
+			{fmt.Sprintf("NAMESPACES (last %s)", churnData.Window), displayGroups("NAMESPACE", churnData.Namespaces)},
+			{fmt.Sprintf("NODE ROLES (last %s)", churnData.Window), displayGroups("NODE-ROLE", churnData.NodeRoles)},
+		}, displayOptions)
+	}
+}
+
+func DisplayPIDData(pidData PIDData, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		pidData.Nodes = append([]NodePIDData(nil), pidData.Nodes...)
+		for i := range pidData.Nodes {
+			pidData.Nodes[i].Node = anonymize("node", pidData.Nodes[i].Node)
+		}
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(pidData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for pid data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "NODE\tROLES\tMAX PIDS\tPROCESSES\tUSED %\tPODS\tPOD PIDS LIMIT\tSTATUS\tREASON\t")
+		}
+		for _, node := range pidData.Nodes {
+			podPidsLimit := "<none>"
+			if node.PodPidsLimit > 0 {
+				podPidsLimit = strconv.FormatInt(node.PodPidsLimit, 10)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t", node.Node, noneIfEmpty(strings.Join(node.Roles, ",")), node.MaxPIDs, node.Processes, displayOptions.percent(node.UsedPercent))
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t\n", node.Pods, podPidsLimit, node.Status, noneIfEmpty(strings.Join(node.Reasons, "; ")))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayAuthCheckData(authCheckData AuthCheckData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(authCheckData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for auth check data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "VERB\tRESOURCE\tALLOWED\tREQUIRED\tUSED BY\tREASON\t")
+		}
+		for _, permission := range authCheckData.Permissions {
+			allowed, required := "yes", "no"
+			if !permission.Allowed {
+				allowed = "no"
+			}
+			if permission.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", permission.Verb, permission.Resource, allowed, required, permission.UsedBy, noneIfEmpty(permission.Reason))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayBenchData(benchData BenchData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(benchData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for bench data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "STAGE\tMIN\tP50\tP90\tP99\tMAX\t")
+		}
+		for _, stage := range benchData.Stages {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", stage.Stage, benchDuration(stage.MinSeconds), benchDuration(stage.P50Seconds), benchDuration(stage.P90Seconds), benchDuration(stage.P99Seconds), benchDuration(stage.MaxSeconds))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "ITERATIONS\tNODES\tPODS\tAPI REQUESTS\t")
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t\n", benchData.Iterations, benchData.Nodes, benchData.Pods, benchData.APIRequests)
+		return w.Flush()
+	}
+}
+
+func benchDuration(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Microsecond).String()
+}
+
+func DisplayFitData(fitData FitData, displayOptions DisplayOptions) error {
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(fitData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for fit data", displayOptions.Format)
+	default:
+		w := newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintln(w, "GROUP\tFITS\tPODS\tAVAIL PODS\tCPU REQUESTS\tAVAIL CPU\tMEMORY REQUESTS\tAVAIL MEMORY\tREASON\t")
+			} else {
+				fmt.Fprintf(w, "GROUP\tFITS\tPODS\tAVAIL PODS\tCPU REQUESTS (%[1]s)\tAVAIL CPU (%[1]s)\tMEMORY REQUESTS (%[2]s)\tAVAIL MEMORY (%[2]s)\tREASON\t\n", displayOptions.cpuUnitName(), displayOptions.memUnitName())
+			}
+		}
+		for _, group := range fitData.Groups {
+			fits := "yes"
+			if !group.Fits {
+				fits = "no"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t", group.Group, fits, group.PodCount, group.AvailablePods)
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", &group.RequestsCPU, &group.AvailableCPU, &group.RequestsMemory, &group.AvailableMemory)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t", displayOptions.cpu(group.RequestsCPUCores), displayOptions.cpu(group.AvailableCPUCores), displayOptions.mem(group.RequestsMemoryGiB), displayOptions.mem(group.AvailableMemoryGiB))
+			}
+			fmt.Fprintf(w, "%s\t\n", noneIfEmpty(group.Reason))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			if displayOptions.Default {
+				fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tPODS\tCPU REQUESTS\tMEMORY REQUESTS\tGROUPS\t")
+			} else {
+				fmt.Fprintf(w, "KIND\tNAMESPACE\tNAME\tPODS\tCPU REQUESTS (%s)\tMEMORY REQUESTS (%s)\tGROUPS\t\n", displayOptions.cpuUnitName(), displayOptions.memUnitName())
+			}
+		}
+		for _, workload := range fitData.Workloads {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t", workload.Kind, workload.Namespace, workload.Name, workload.PodCount)
+			if displayOptions.Default {
+				fmt.Fprintf(w, "%s\t%s\t", &workload.RequestsCPU, &workload.RequestsMemory)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\t", displayOptions.cpu(workload.RequestsCPUCores), displayOptions.mem(workload.RequestsMemoryGiB))
+			}
+			fmt.Fprintf(w, "%s\t\n", strings.Join(workload.Groups, ","))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(fitData.Placements) > 0 {
+			fmt.Fprintln(displayOptions.Out, "")
+			w = newTableWriter(displayOptions.Out, displayOptions)
+			if displayOptions.Headers {
+				fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tPODS\tPLACED\tNODES\tREASON\t")
+			}
+			for _, placement := range fitData.Placements {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t\n", placement.Kind, placement.Namespace, placement.Name, placement.PodCount, placement.PlacedCount, noneIfEmpty(formatCounts(placement.Nodes)), noneIfEmpty(placement.Reason))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if len(fitData.Spreads) > 0 {
+			fmt.Fprintln(displayOptions.Out, "")
+			w = newTableWriter(displayOptions.Out, displayOptions)
+			if displayOptions.Headers {
+				fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tPODS\tMAX PODS\tHEADROOM PODS\tTOPOLOGY KEY\tDOMAINS\tREASON\t")
+			}
+			for _, spread := range fitData.Spreads {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t\n", spread.Kind, spread.Namespace, spread.Name, spread.PodCount, spread.MaxPodCount, spread.HeadroomPodCount, spread.TopologyKey, noneIfEmpty(formatCounts(spread.Domains)), noneIfEmpty(spread.Reason))
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if len(fitData.Quotas) == 0 {
+			return nil
+		}
+		fmt.Fprintln(displayOptions.Out, "")
+		w = newTableWriter(displayOptions.Out, displayOptions)
+		if displayOptions.Headers {
+			fmt.Fprintln(w, "NAMESPACE\tQUOTA\tRESOURCE\tFITS\tUSED\tREQUESTED\tHARD\tWORKLOADS\t")
+		}
+		for _, quota := range fitData.Quotas {
+			fits := "yes"
+			if !quota.Fits {
+				fits = "no"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", quota.Namespace, quota.ResourceQuota, quota.Resource, fits, &quota.Used, &quota.Requested, &quota.Hard, strings.Join(quota.Workloads, ","))
+		}
+		return w.Flush()
+	}
+}
+
+func DisplayReportData(reportData ReportData, sortedRoleNames []string, sortedNodeNames []string, sortedNamespaceNames []string, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		reportData.NodeRoles = anonymizeNodeRoleData(reportData.NodeRoles)
+		reportData.Nodes, sortedNodeNames, _ = anonymizeNodeData(reportData.Nodes, sortedNodeNames, nil)
+		reportData.Namespaces, sortedNamespaceNames = anonymizeNamespaceData(reportData.Namespaces, sortedNamespaceNames)
+		reportData.PendingPods = anonymizePendingPodData(reportData.PendingPods)
+		// Sections are displayed from the already anonymized data
+		displayOptions.Anonymize = false
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(reportData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for report data", displayOptions.Format)
+	default:
+		return displaySections([]section{
+			{"CLUSTER", func() error { return DisplayClusterData(reportData.Cluster, displayOptions) }},
+			{"NODE-ROLES", func() error {
+				return DisplayNodeRoleData(reportData.NodeRoles, sortedRoleNames, displayOptions, false, false)
+			}},
+			{"NODES", func() error { return DisplayNodeData(reportData.Nodes, sortedNodeNames, displayOptions, false, nil) }},
+			{"NAMESPACES", func() error {
+				return DisplayNamespaceData(reportData.Namespaces, sortedNamespaceNames, displayOptions, false)
+			}},
+			{"PENDING PODS", func() error { return displayPendingPodData(reportData.PendingPods, displayOptions) }},
+		}, displayOptions)
+	}
+}
+
+func DisplayAllData(allData AllData, sortedRoleNames []string, sortedNodeNames []string, displayOptions DisplayOptions) error {
+	if displayOptions.Anonymize {
+		allData.NodeRoles = anonymizeNodeRoleData(allData.NodeRoles)
+		allData.Nodes, sortedNodeNames, _ = anonymizeNodeData(allData.Nodes, sortedNodeNames, nil)
+		displayOptions.Anonymize = false
+	}
+	switch displayOptions.Format {
+	case jsonDisplay, yamlDisplay:
+		return printObject(allData, displayOptions)
+	case nameDisplay:
+		return fmt.Errorf("output format \"%s\" is not supported for all data", displayOptions.Format)
+	default:
+		return displaySections([]section{
+			{"CLUSTER", func() error { return DisplayClusterData(allData.Cluster, displayOptions) }},
+			{"NODE-ROLES", func() error {
+				return DisplayNodeRoleData(allData.NodeRoles, sortedRoleNames, displayOptions, false, false)
+			}},
+			{"NODES", func() error { return DisplayNodeData(allData.Nodes, sortedNodeNames, displayOptions, false, nil) }},
+		}, displayOptions)
+	}
+}
+
+// Titled table of a multi-section output
+type section struct {
+	title   string
+	display func() error
+}
+
+// Prints the sections one after another, separated by a blank line and titled when headers are displayed
+func displaySections(sections []section, displayOptions DisplayOptions) error {
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(displayOptions.Out, "")
+		}
+		if displayOptions.Headers {
+			fmt.Fprintf(displayOptions.Out, "%s\n", section.title)
+		}
+		if err := section.display(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func displayPendingPodData(pendingPods []PendingPodData, displayOptions DisplayOptions) error {
+	w := newTableWriter(displayOptions.Out, displayOptions)
+	if displayOptions.Headers {
+		if displayOptions.Default {
+			fmt.Fprintln(w, "NAMESPACE\tNAME\tREASON\tCPU REQUESTS\tMEMORY REQUESTS\t")
+		} else {
+			fmt.Fprintf(w, "NAMESPACE\tNAME\tREASON\tCPU REQUESTS (%s)\tMEMORY REQUESTS (%s)\t\n", displayOptions.cpuUnitName(), displayOptions.memUnitName())
+		}
+	}
+	for _, pod := range pendingPods {
+		fmt.Fprintf(w, "%s\t%s\t%s\t", pod.Namespace, pod.Name, noneIfEmpty(pod.Reason))
+		if displayOptions.Default {
+			fmt.Fprintf(w, "%s\t%s\t\n", &pod.RequestsCPU, &pod.RequestsMemory)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t\n", displayOptions.cpu(pod.RequestsCPUCores), displayOptions.mem(pod.RequestsMemoryGiB))
+		}
+	}
+	return w.Flush()
+}
+
+func ValidateOutput(cmd cobra.Command) error {
+	displayFormat, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("unable to get output display format")
+	}
+	validOutputs := []string{tableDisplay, wideDisplay, jsonDisplay, yamlDisplay, nameDisplay}
+	for _, validOutputFormat := range validOutputs {
+		if displayFormat == validOutputFormat {
+			return nil
+		}
+	}
+	return fmt.Errorf("Display Format \"%s\" is invalid. Valid values are %v", displayFormat, validOutputs)
+}
+
+type tableWriter interface {
+	io.Writer
+	Flush() error
+}
+
+func newTableWriter(output io.Writer, displayOptions DisplayOptions) tableWriter {
+	var table tableWriter
+	if displayOptions.Plain {
+		table = &plainWriter{out: output}
+	} else {
+		w := tabwriter.NewWriter(output, tabwriterMinWidth, tabwriterWidth, tabwriterPadding, tabwriterPadChar, tabwriterFlags)
+		table = w
+		if displayOptions.Color {
+			table = &colorWriter{tabWriter: w}
+		}
+	}
+	if displayOptions.Deltas != nil {
+		return &chainedWriter{first: newDeltaWriter(table, displayOptions.Deltas), next: table}
+	}
+	return table
+}
+
+// Writes through first and flushes first before next
+type chainedWriter struct {
+	first tableWriter
+	next  tableWriter
+}
+
+func (c *chainedWriter) Write(p []byte) (int, error) {
+	return c.first.Write(p)
+}
+
+func (c *chainedWriter) Flush() error {
+	if err := c.first.Flush(); err != nil {
+		return err
+	}
+	return c.next.Flush()
+}
+
+// Marshal the capacity data to raw json so the cli-runtime json and yaml printers can print it without a Kind
+func printObject(data interface{}, displayOptions DisplayOptions) error {
+	if displayOptions.Watch {
+		// Each watch sample is a timestamped record, json is printed as one record per line
+		data = watchRecord{Timestamp: displayOptions.Timestamp, Data: data}
+	}
+	if displayOptions.KeyCase == CamelKeyCase {
+		var err error
+		if data, err = camelCaseKeys(reflect.ValueOf(data)); err != nil {
+			return err
+		}
+	}
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	obj := &runtime.Unknown{Raw: rawData, ContentType: runtime.ContentTypeJSON}
+	var printer printers.ResourcePrinter
+	switch displayOptions.Format {
+	case jsonDisplay:
+		if displayOptions.Watch || displayOptions.Compact {
+			_, err := fmt.Fprintf(displayOptions.Out, "%s\n", rawData)
+			return err
+		}
+		printer = &printers.JSONPrinter{}
+	case yamlDisplay:
+		if displayOptions.Watch {
+			fmt.Fprintln(displayOptions.Out, "---")
+		}
+		printer = &printers.YAMLPrinter{}
+	default:
+		return fmt.Errorf("output format \"%s\" is not a structured format", displayOptions.Format)
+	}
+	return printer.PrintObj(obj, displayOptions.Out)
+}
+
+type watchRecord struct {
+	Timestamp time.Time
+	Data      interface{}
+}
+
+// Separates watch samples in table output with the time of the sample
+func PrintWatchHeader(displayOptions DisplayOptions) {
+	if displayOptions.Deltas != nil {
+		displayOptions.Deltas.NextSample()
+	}
+	switch displayOptions.Format {
+	case tableDisplay, wideDisplay:
+		fmt.Fprintf(displayOptions.Out, "\n%s\n", displayOptions.Timestamp.Format(time.RFC3339))
+	}
+}
+
+// Prints kind/name for each entry, skipping the *unassigned* and *total* pseudo entries
+func printNames(kind string, names []string, output io.Writer) error {
+	printer := &printers.NamePrinter{}
+	for _, name := range names {
+		if strings.HasPrefix(name, "*") {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetKind(kind)
+		obj.SetName(name)
+		if err := printer.PrintObj(obj, output); err != nil {
+			return err
+		}
+	}
+	return nil
 }
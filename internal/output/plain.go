@@ -0,0 +1,71 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// plainWriter writes table cells separated by a single space without alignment padding, empty cells are
+// printed as "-" and spaces within cells as "_" so every row of a table has the same number of fields
+type plainWriter struct {
+	out  io.Writer
+	line []byte
+}
+
+func (p *plainWriter) Write(b []byte) (int, error) {
+	p.line = append(p.line, b...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(string(p.line[:i]), "\n"); err != nil {
+			return 0, err
+		}
+		p.line = p.line[i+1:]
+	}
+	return len(b), nil
+}
+
+func (p *plainWriter) Flush() error {
+	if len(p.line) > 0 {
+		if err := p.writeLine(string(p.line), ""); err != nil {
+			return err
+		}
+		p.line = nil
+	}
+	return nil
+}
+
+func (p *plainWriter) writeLine(line string, newline string) error {
+	cells := strings.Split(strings.TrimSuffix(line, "\t"), "\t")
+	if len(cells) == 1 && cells[0] == "" {
+		_, err := io.WriteString(p.out, newline)
+		return err
+	}
+	for i, cell := range cells {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			cell = "-"
+		}
+		cells[i] = strings.Replace(cell, " ", "_", -1)
+	}
+	_, err := io.WriteString(p.out, strings.Join(cells, " ")+newline)
+	return err
+}
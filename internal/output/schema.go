@@ -0,0 +1,158 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Schema versions of the json and yaml output of the cluster, node-role, node and namespace data. The fields of v1 are
+// those of the first release and never change, v2 only gains fields.
+const (
+	SchemaV1 string = "v1"
+	SchemaV2 string = "v2"
+)
+
+var schemas = []string{SchemaV1, SchemaV2}
+
+func ValidateSchema(schema string) error {
+	for _, validSchema := range schemas {
+		if schema == validSchema {
+			return nil
+		}
+	}
+	return fmt.Errorf("schema \"%s\" is invalid. Valid values are %v", schema, schemas)
+}
+
+type clusterCapacityDataV1 struct {
+	TotalNodeCount                     int
+	TotalReadyNodeCount                int
+	TotalUnreadyNodeCount              int
+	TotalUnschedulableNodeCount        int
+	TotalPodCount                      int
+	TotalNonTermPodCount               int
+	TotalCapacityPods                  resource.Quantity
+	TotalCapacityCPU                   resource.Quantity
+	TotalCapacityCPUCores              float64
+	TotalCapacityMemory                resource.Quantity
+	TotalCapacityMemoryGiB             float64
+	TotalCapacityEphemeralStorage      resource.Quantity
+	TotalCapacityEphemeralStorageGB    float64
+	TotalAllocatablePods               resource.Quantity
+	TotalAllocatableCPU                resource.Quantity
+	TotalAllocatableCPUCores           float64
+	TotalAllocatableMemory             resource.Quantity
+	TotalAllocatableMemoryGiB          float64
+	TotalAllocatableEphemeralStorage   resource.Quantity
+	TotalAllocatableEphemeralStorageGB float64
+	TotalAvailablePods                 int
+	TotalRequestsCPU                   resource.Quantity
+	TotalRequestsCPUCores              float64
+	TotalLimitsCPU                     resource.Quantity
+	TotalLimitsCPUCores                float64
+	TotalAvailableCPU                  resource.Quantity
+	TotalAvailableCPUCores             float64
+	TotalRequestsMemory                resource.Quantity
+	TotalRequestsMemoryGiB             float64
+	TotalLimitsMemory                  resource.Quantity
+	TotalLimitsMemoryGiB               float64
+	TotalAvailableMemory               resource.Quantity
+	TotalAvailableMemoryGiB            float64
+	TotalRequestsEphemeralStorage      resource.Quantity
+	TotalRequestsEphemeralStorageGB    float64
+	TotalLimitsEphemeralStorage        resource.Quantity
+	TotalLimitsEphemeralStorageGB      float64
+	TotalAvailableEphemeralStorage     resource.Quantity
+	TotalAvailableEphemeralStorageGB   float64
+}
+
+type nodeCapacityDataV1 struct {
+	TotalPodCount                      int
+	TotalNonTermPodCount               int
+	Roles                              sets.String
+	Ready                              bool
+	Schedulable                        bool
+	TotalCapacityPods                  resource.Quantity
+	TotalCapacityCPU                   resource.Quantity
+	TotalCapacityCPUCores              float64
+	TotalCapacityMemory                resource.Quantity
+	TotalCapacityMemoryGiB             float64
+	TotalCapacityEphemeralStorage      resource.Quantity
+	TotalCapacityEphemeralStorageGB    float64
+	TotalAllocatablePods               resource.Quantity
+	TotalAllocatableCPU                resource.Quantity
+	TotalAllocatableCPUCores           float64
+	TotalAllocatableMemory             resource.Quantity
+	TotalAllocatableMemoryGiB          float64
+	TotalAllocatableEphemeralStorage   resource.Quantity
+	TotalAllocatableEphemeralStorageGB float64
+	TotalAvailablePods                 int
+	TotalRequestsCPU                   resource.Quantity
+	TotalRequestsCPUCores              float64
+	TotalLimitsCPU                     resource.Quantity
+	TotalLimitsCPUCores                float64
+	TotalAvailableCPU                  resource.Quantity
+	TotalAvailableCPUCores             float64
+	TotalRequestsMemory                resource.Quantity
+	TotalRequestsMemoryGiB             float64
+	TotalLimitsMemory                  resource.Quantity
+	TotalLimitsMemoryGiB               float64
+	TotalAvailableMemory               resource.Quantity
+	TotalAvailableMemoryGiB            float64
+	TotalRequestsEphemeralStorage      resource.Quantity
+	TotalRequestsEphemeralStorageGB    float64
+	TotalLimitsEphemeralStorage        resource.Quantity
+	TotalLimitsEphemeralStorageGB      float64
+	TotalAvailableEphemeralStorage     resource.Quantity
+	TotalAvailableEphemeralStorageGB   float64
+}
+
+type namespaceCapacityDataV1 struct {
+	TotalPodCount                   int
+	TotalNonTermPodCount            int
+	TotalUnassignedNodePodCount     int
+	TotalRequestsCPU                resource.Quantity
+	TotalRequestsCPUCores           float64
+	TotalLimitsCPU                  resource.Quantity
+	TotalLimitsCPUCores             float64
+	TotalRequestsMemory             resource.Quantity
+	TotalRequestsMemoryGiB          float64
+	TotalLimitsMemory               resource.Quantity
+	TotalLimitsMemoryGiB            float64
+	TotalRequestsEphemeralStorage   resource.Quantity
+	TotalRequestsEphemeralStorageGB float64
+	TotalLimitsEphemeralStorage     resource.Quantity
+	TotalLimitsEphemeralStorageGB   float64
+}
+
+// Converts the data to the fields of the schema, v1 is the data without the fields added since
+func toSchema(data interface{}, schema string, v1 interface{}) (interface{}, error) {
+	if schema != SchemaV1 {
+		return data, nil
+	}
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawData, v1); err != nil {
+		return nil, err
+	}
+	return v1, nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"sigs.k8s.io/yaml"
+)
+
+// Threshold resources
+const (
+	ThresholdPods             string = "pods"
+	ThresholdCPU              string = "cpu"
+	ThresholdMemory           string = "memory"
+	ThresholdEphemeralStorage string = "ephemeral-storage"
+)
+
+// Threshold levels of a breach
+const (
+	LevelWarning  string = "warning"
+	LevelCritical string = "critical"
+)
+
+// Warning and critical utilization percents of allocatable requested, read from --threshold-config
+type Thresholds struct {
+	Warn  *float64        `json:"warn,omitempty"`
+	Crit  *float64        `json:"crit,omitempty"`
+	Rules []ThresholdRule `json:"rules,omitempty"`
+}
+
+// A rule without a group applies to every group, a rule without a resource to every resource
+type ThresholdRule struct {
+	// Node role or node name
+	Group    string  `json:"group,omitempty"`
+	Resource string  `json:"resource,omitempty"`
+	Warn     float64 `json:"warn"`
+	Crit     float64 `json:"crit"`
+}
+
+// A group whose requests crossed a threshold
+type Breach struct {
+	Group     string
+	Resource  string
+	Percent   float64
+	Level     string
+	Threshold float64
+}
+
+func LoadThresholds(path string) (*Thresholds, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	thresholds := new(Thresholds)
+	if err := yaml.UnmarshalStrict(data, thresholds); err != nil {
+		return nil, err
+	}
+	for i, rule := range thresholds.Rules {
+		switch rule.Resource {
+		case "", ThresholdPods, ThresholdCPU, ThresholdMemory, ThresholdEphemeralStorage:
+		default:
+			return nil, fmt.Errorf("rule %d resource \"%s\" is invalid. Valid values are [%s %s %s %s]", i+1, rule.Resource, ThresholdPods, ThresholdCPU, ThresholdMemory, ThresholdEphemeralStorage)
+		}
+		if err := ValidateThresholds(rule.Warn, rule.Crit); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	return thresholds, nil
+}
+
+func ValidateThresholds(warn float64, crit float64) error {
+	if warn < 0 || warn > 100 || crit < 0 || crit > 100 {
+		return fmt.Errorf("thresholds must be between 0 and 100 percent")
+	}
+	if warn > crit {
+		return fmt.Errorf("warn threshold %.1f is greater than crit threshold %.1f", warn, crit)
+	}
+	return nil
+}
+
+// Returns display options with the thresholds of the most specific matching rule, rules naming one of the groups
+// win over rules for every group and rules naming the resource win over rules for every resource. Later rules win ties.
+func (displayOptions DisplayOptions) forGroup(resource string, groups ...string) DisplayOptions {
+	if displayOptions.Thresholds == nil {
+		return displayOptions
+	}
+	best := -1
+	for _, rule := range displayOptions.Thresholds.Rules {
+		if rule.Resource != "" && rule.Resource != resource {
+			continue
+		}
+		specificity := 0
+		if rule.Group != "" {
+			if !capacity.StringInSlice(rule.Group, groups) {
+				continue
+			}
+			specificity += 2
+		}
+		if rule.Resource != "" {
+			specificity++
+		}
+		if specificity >= best {
+			best = specificity
+			displayOptions.WarnThreshold = rule.Warn
+			displayOptions.CritThreshold = rule.Crit
+		}
+	}
+	return displayOptions
+}
+
+// Returns the breach of the thresholds of the groups by percent requested, nil when below the warning threshold
+func (displayOptions DisplayOptions) CheckThreshold(resource string, percent float64, groups ...string) *Breach {
+	displayOptions = displayOptions.forGroup(resource, groups...)
+	breach := &Breach{Resource: resource, Percent: percent}
+	if len(groups) > 0 {
+		breach.Group = groups[0]
+	}
+	switch {
+	case percent > displayOptions.CritThreshold:
+		breach.Level = LevelCritical
+		breach.Threshold = displayOptions.CritThreshold
+	case percent > displayOptions.WarnThreshold:
+		breach.Level = LevelWarning
+		breach.Threshold = displayOptions.WarnThreshold
+	default:
+		return nil
+	}
+	return breach
+}
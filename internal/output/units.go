@@ -0,0 +1,89 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CPU and memory units of human readable table output
+const (
+	CPUUnitCores      string = "cores"
+	CPUUnitMillicores string = "millicores"
+)
+
+var memoryUnitBytes = map[string]float64{
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"MB": 1e6,
+	"GB": 1e9,
+	"TB": 1e12,
+}
+
+func ValidateUnits(cpuUnit string, memoryUnit string) error {
+	if cpuUnit != CPUUnitCores && cpuUnit != CPUUnitMillicores {
+		return fmt.Errorf("CPU unit \"%s\" is invalid. Valid values are [%s %s]", cpuUnit, CPUUnitCores, CPUUnitMillicores)
+	}
+	if _, ok := memoryUnitBytes[memoryUnit]; !ok {
+		return fmt.Errorf("memory unit \"%s\" is invalid. Valid values are [Mi Gi Ti MB GB TB]", memoryUnit)
+	}
+	return nil
+}
+
+// Returns the decimal (SI) unit of the same magnitude as a binary memory unit
+func DecimalMemoryUnit(memoryUnit string) string {
+	if strings.HasSuffix(memoryUnit, "i") {
+		return strings.TrimSuffix(memoryUnit, "i") + "B"
+	}
+	return memoryUnit
+}
+
+// Formats cores in the selected CPU unit
+func (d DisplayOptions) cpu(cores float64) string {
+	if d.CPUUnit == CPUUnitMillicores {
+		return fmt.Sprintf("%.0f", cores*1000)
+	}
+	return fmt.Sprintf("%.1f", cores)
+}
+
+// Formats GiB in the selected memory unit
+func (d DisplayOptions) mem(gib float64) string {
+	unitBytes, ok := memoryUnitBytes[d.MemoryUnit]
+	if !ok {
+		return fmt.Sprintf("%.1f", gib)
+	}
+	return fmt.Sprintf("%.1f", gib*(1<<30)/unitBytes)
+}
+
+func (d DisplayOptions) cpuUnitName() string {
+	if d.CPUUnit == CPUUnitMillicores {
+		return CPUUnitMillicores
+	}
+	return CPUUnitCores
+}
+
+// Binary units are displayed as MiB, GiB and TiB
+func (d DisplayOptions) memUnitName() string {
+	if _, ok := memoryUnitBytes[d.MemoryUnit]; !ok {
+		return "GiB"
+	}
+	if strings.HasSuffix(d.MemoryUnit, "i") {
+		return d.MemoryUnit + "B"
+	}
+	return d.MemoryUnit
+}
@@ -0,0 +1,366 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package simulate
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Filters unschedulable (cordoned) nodes unless the pod tolerates the unschedulable taint
+type NodeUnschedulable struct{}
+
+func (p *NodeUnschedulable) Name() string { return "NodeUnschedulable" }
+
+func (p *NodeUnschedulable) PreFilter(cluster *Cluster, pod *Pod) {}
+
+func (p *NodeUnschedulable) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	if !node.Node.Spec.Unschedulable {
+		return nil
+	}
+	taint := corev1.Taint{Key: "node.kubernetes.io/unschedulable", Effect: corev1.TaintEffectNoSchedule}
+	for _, toleration := range pod.Spec.Tolerations {
+		if toleration.ToleratesTaint(&taint) {
+			return nil
+		}
+	}
+	return []string{"node(s) were unschedulable"}
+}
+
+// Filters nodes whose Ready condition is not true
+type NodeReady struct{}
+
+func (p *NodeReady) Name() string { return "NodeReady" }
+
+func (p *NodeReady) PreFilter(cluster *Cluster, pod *Pod) {}
+
+func (p *NodeReady) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	for _, condition := range node.Node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			return nil
+		}
+	}
+	return []string{"node(s) were not ready"}
+}
+
+// Filters nodes without the allocatable pods, cpu, memory and extended resources the pod requests
+type NodeResourcesFit struct{}
+
+func (p *NodeResourcesFit) Name() string { return "NodeResourcesFit" }
+
+func (p *NodeResourcesFit) PreFilter(cluster *Cluster, pod *Pod) {}
+
+func (p *NodeResourcesFit) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	reasons := make([]string, 0)
+	allocatablePods := node.Node.Status.Allocatable[corev1.ResourcePods]
+	if int64(len(node.Pods)+1) > allocatablePods.Value() {
+		reasons = append(reasons, "Too many pods")
+	}
+	for resourceName, requested := range podRequests(pod.Spec) {
+		if requested == 0 {
+			continue
+		}
+		allocatable := node.Node.Status.Allocatable[resourceName]
+		total := allocatable.Value()
+		if resourceName == corev1.ResourceCPU {
+			total = allocatable.MilliValue()
+		}
+		if node.Requested[resourceName]+requested > total {
+			reasons = append(reasons, fmt.Sprintf("Insufficient %s", resourceName))
+		}
+	}
+	return reasons
+}
+
+// Filters nodes that do not match the node selector and required node affinity of the pod
+type NodeAffinity struct{}
+
+func (p *NodeAffinity) Name() string { return "NodeAffinity" }
+
+func (p *NodeAffinity) PreFilter(cluster *Cluster, pod *Pod) {}
+
+func (p *NodeAffinity) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	if !nodeAffinityMatches(pod, node.Node) {
+		return []string{"node(s) didn't match Pod's node affinity/selector"}
+	}
+	return nil
+}
+
+func nodeAffinityMatches(pod *Pod, node *corev1.Node) bool {
+	for key, value := range pod.Spec.NodeSelector {
+		if nodeValue, ok := node.Labels[key]; !ok || nodeValue != value {
+			return false
+		}
+	}
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil || pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// Terms are ORed, the requirements of a term are ANDed and a term without requirements matches no node
+	for _, term := range pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		matches := true
+		for _, requirement := range term.MatchExpressions {
+			value, ok := node.Labels[requirement.Key]
+			matches = matches && requirementMatches(requirement, value, ok)
+		}
+		for _, requirement := range term.MatchFields {
+			matches = matches && requirement.Key == "metadata.name" && requirementMatches(requirement, node.Name, true)
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func requirementMatches(requirement corev1.NodeSelectorRequirement, value string, ok bool) bool {
+	in := false
+	for _, v := range requirement.Values {
+		in = in || v == value
+	}
+	switch requirement.Operator {
+	case corev1.NodeSelectorOpIn:
+		return ok && in
+	case corev1.NodeSelectorOpNotIn:
+		return !ok || !in
+	case corev1.NodeSelectorOpExists:
+		return ok
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !ok
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !ok || len(requirement.Values) != 1 {
+			return false
+		}
+		nodeValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		requirementValue, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == corev1.NodeSelectorOpGt {
+			return nodeValue > requirementValue
+		}
+		return nodeValue < requirementValue
+	}
+	return false
+}
+
+// Filters nodes with a NoSchedule or NoExecute taint the pod does not tolerate
+type TaintToleration struct{}
+
+func (p *TaintToleration) Name() string { return "TaintToleration" }
+
+func (p *TaintToleration) PreFilter(cluster *Cluster, pod *Pod) {}
+
+func (p *TaintToleration) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	for i := range node.Node.Spec.Taints {
+		taint := &node.Node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range pod.Spec.Tolerations {
+			tolerated = tolerated || toleration.ToleratesTaint(taint)
+		}
+		if !tolerated {
+			return []string{fmt.Sprintf("node(s) had untolerated taint {%s: %s}", taint.Key, taint.Value)}
+		}
+	}
+	return nil
+}
+
+// Filters nodes that would push the skew of a DoNotSchedule topology spread constraint beyond its maxSkew. Only nodes
+// matching the node affinity of the pod are domains, like the scheduler counts them.
+type PodTopologySpread struct {
+	// Matching pods of each domain of each constraint and the fewest of any domain
+	counts   []map[string]int
+	minimums []int
+}
+
+func (p *PodTopologySpread) Name() string { return "PodTopologySpread" }
+
+func (p *PodTopologySpread) PreFilter(cluster *Cluster, pod *Pod) {
+	p.counts, p.minimums = make([]map[string]int, len(pod.TopologySpreadConstraints)), make([]int, len(pod.TopologySpreadConstraints))
+	for i, constraint := range pod.TopologySpreadConstraints {
+		selector := podSelector(constraint.LabelSelector)
+		counts := make(map[string]int)
+		for _, node := range cluster.Nodes {
+			domain, ok := node.Node.Labels[constraint.TopologyKey]
+			if !ok || !nodeAffinityMatches(pod, node.Node) {
+				continue
+			}
+			counts[domain] += 0
+			for _, existing := range node.Pods {
+				if existing.Namespace == pod.Namespace && selector.Matches(labels.Set(existing.Labels)) {
+					counts[domain]++
+				}
+			}
+		}
+		minimum := -1
+		for _, count := range counts {
+			if minimum < 0 || count < minimum {
+				minimum = count
+			}
+		}
+		p.counts[i], p.minimums[i] = counts, minimum
+	}
+}
+
+func (p *PodTopologySpread) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	for i, constraint := range pod.TopologySpreadConstraints {
+		if constraint.WhenUnsatisfiable == "ScheduleAnyway" {
+			continue
+		}
+		domain, ok := node.Node.Labels[constraint.TopologyKey]
+		if !ok {
+			return []string{"node(s) didn't match pod topology spread constraints (missing required label)"}
+		}
+		selfMatch := 0
+		if podSelector(constraint.LabelSelector).Matches(labels.Set(pod.Labels)) {
+			selfMatch = 1
+		}
+		if p.counts[i][domain]+selfMatch-p.minimums[i] > constraint.MaxSkew {
+			return []string{"node(s) didn't match pod topology spread constraints"}
+		}
+	}
+	return nil
+}
+
+// Filters nodes in a topology domain holding a pod the required pod anti-affinity of the pod matches, or holding a
+// pod whose required pod anti-affinity matches the pod. Required pod affinity is not modeled.
+type InterPodAffinity struct {
+	// Label values of the topology keys of the domains the pod can not be placed in
+	podDomains      map[string]map[string]bool
+	existingDomains map[string]map[string]bool
+}
+
+func (p *InterPodAffinity) Name() string { return "InterPodAffinity" }
+
+func (p *InterPodAffinity) PreFilter(cluster *Cluster, pod *Pod) {
+	p.podDomains, p.existingDomains = make(map[string]map[string]bool), make(map[string]map[string]bool)
+	addDomain := func(domains map[string]map[string]bool, node *corev1.Node, topologyKey string) {
+		value, ok := node.Labels[topologyKey]
+		if !ok {
+			return
+		}
+		if domains[topologyKey] == nil {
+			domains[topologyKey] = make(map[string]bool)
+		}
+		domains[topologyKey][value] = true
+	}
+	terms := AntiAffinityTerms(&pod.Pod)
+	for _, node := range cluster.Nodes {
+		for _, existing := range node.Pods {
+			for _, term := range terms {
+				if termMatches(term, pod.Namespace, existing) {
+					addDomain(p.podDomains, node.Node, term.TopologyKey)
+				}
+			}
+			for _, term := range AntiAffinityTerms(existing) {
+				if termMatches(term, existing.Namespace, &pod.Pod) {
+					addDomain(p.existingDomains, node.Node, term.TopologyKey)
+				}
+			}
+		}
+	}
+}
+
+func (p *InterPodAffinity) Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string {
+	inDomain := func(domains map[string]map[string]bool) bool {
+		for topologyKey, values := range domains {
+			if value, ok := node.Node.Labels[topologyKey]; ok && values[value] {
+				return true
+			}
+		}
+		return false
+	}
+	if inDomain(p.existingDomains) {
+		return []string{"node(s) didn't satisfy existing pods anti-affinity rules"}
+	}
+	if inDomain(p.podDomains) {
+		return []string{"node(s) didn't match pod anti-affinity rules"}
+	}
+	return nil
+}
+
+// The required pod anti-affinity terms of a pod
+func AntiAffinityTerms(pod *corev1.Pod) []corev1.PodAffinityTerm {
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	return pod.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+}
+
+// Whether a term of a pod in the namespace matches the other pod, terms without namespaces match the namespace of
+// their own pod
+func termMatches(term corev1.PodAffinityTerm, namespace string, other *corev1.Pod) bool {
+	namespaces := term.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{namespace}
+	}
+	for _, termNamespace := range namespaces {
+		if termNamespace == other.Namespace {
+			return podSelector(term.LabelSelector).Matches(labels.Set(other.Labels))
+		}
+	}
+	return false
+}
+
+// Topology keys of the required pod anti-affinity terms that match the pod itself, its replicas can hold only one
+// pod in each domain of these keys
+func SelfAntiAffinityKeys(pod *corev1.Pod) []string {
+	keys := make([]string, 0)
+	for _, term := range AntiAffinityTerms(pod) {
+		if termMatches(term, pod.Namespace, pod) {
+			keys = append(keys, term.TopologyKey)
+		}
+	}
+	return keys
+}
+
+// Names of the nodes the pod can not be placed on because of the required pod anti-affinity of it or of the pods of
+// the cluster
+func (c *Cluster) BlockedByAntiAffinity(pod *Pod) map[string]bool {
+	plugin := &InterPodAffinity{}
+	plugin.PreFilter(c, pod)
+	blocked := make(map[string]bool)
+	for _, node := range c.Nodes {
+		if len(plugin.Filter(c, pod, node)) > 0 {
+			blocked[node.Node.Name] = true
+		}
+	}
+	return blocked
+}
+
+// A nil selector matches no pods
+func podSelector(labelSelector *metav1.LabelSelector) labels.Selector {
+	if labelSelector == nil {
+		return labels.Nothing()
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return labels.Nothing()
+	}
+	return selector
+}
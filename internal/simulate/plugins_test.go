@@ -0,0 +1,321 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package simulate
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/akrzos/kubeSize/internal/manifest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newNode(name string, nodeLabels map[string]string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}, Spec: corev1.NodeSpec{Taints: taints}}
+}
+
+func newPod(namespace string, name string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: podLabels}}
+}
+
+func requiredNodeAffinity(terms ...corev1.NodeSelectorTerm) *corev1.Affinity {
+	return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: terms},
+	}}
+}
+
+func expressions(requirements ...corev1.NodeSelectorRequirement) corev1.NodeSelectorTerm {
+	return corev1.NodeSelectorTerm{MatchExpressions: requirements}
+}
+
+func requirement(key string, operator corev1.NodeSelectorOperator, values ...string) corev1.NodeSelectorRequirement {
+	return corev1.NodeSelectorRequirement{Key: key, Operator: operator, Values: values}
+}
+
+func TestNodeAffinity(t *testing.T) {
+	node := newNode("node-a", map[string]string{"zone": "a", "disk": "ssd", "cores": "8"})
+	for _, test := range []struct {
+		name         string
+		nodeSelector map[string]string
+		affinity     *corev1.Affinity
+		matches      bool
+	}{
+		{name: "no affinity", matches: true},
+		{name: "node selector match", nodeSelector: map[string]string{"zone": "a"}, matches: true},
+		{name: "node selector value mismatch", nodeSelector: map[string]string{"zone": "b"}, matches: false},
+		{name: "node selector missing label", nodeSelector: map[string]string{"gpu": "true"}, matches: false},
+		{name: "In match", affinity: requiredNodeAffinity(expressions(requirement("zone", corev1.NodeSelectorOpIn, "b", "a"))), matches: true},
+		{name: "In mismatch", affinity: requiredNodeAffinity(expressions(requirement("zone", corev1.NodeSelectorOpIn, "b"))), matches: false},
+		{name: "In missing label", affinity: requiredNodeAffinity(expressions(requirement("gpu", corev1.NodeSelectorOpIn, "true"))), matches: false},
+		{name: "NotIn value listed", affinity: requiredNodeAffinity(expressions(requirement("zone", corev1.NodeSelectorOpNotIn, "a"))), matches: false},
+		{name: "NotIn value not listed", affinity: requiredNodeAffinity(expressions(requirement("zone", corev1.NodeSelectorOpNotIn, "b"))), matches: true},
+		{name: "NotIn missing label", affinity: requiredNodeAffinity(expressions(requirement("gpu", corev1.NodeSelectorOpNotIn, "true"))), matches: true},
+		{name: "Exists", affinity: requiredNodeAffinity(expressions(requirement("disk", corev1.NodeSelectorOpExists))), matches: true},
+		{name: "Exists missing label", affinity: requiredNodeAffinity(expressions(requirement("gpu", corev1.NodeSelectorOpExists))), matches: false},
+		{name: "DoesNotExist", affinity: requiredNodeAffinity(expressions(requirement("gpu", corev1.NodeSelectorOpDoesNotExist))), matches: true},
+		{name: "DoesNotExist present label", affinity: requiredNodeAffinity(expressions(requirement("disk", corev1.NodeSelectorOpDoesNotExist))), matches: false},
+		{name: "Gt greater", affinity: requiredNodeAffinity(expressions(requirement("cores", corev1.NodeSelectorOpGt, "4"))), matches: true},
+		{name: "Gt equal", affinity: requiredNodeAffinity(expressions(requirement("cores", corev1.NodeSelectorOpGt, "8"))), matches: false},
+		{name: "Gt missing label", affinity: requiredNodeAffinity(expressions(requirement("gpus", corev1.NodeSelectorOpGt, "0"))), matches: false},
+		{name: "Gt non-integer label", affinity: requiredNodeAffinity(expressions(requirement("zone", corev1.NodeSelectorOpGt, "0"))), matches: false},
+		{name: "Gt several values", affinity: requiredNodeAffinity(expressions(requirement("cores", corev1.NodeSelectorOpGt, "4", "5"))), matches: false},
+		{name: "Lt less", affinity: requiredNodeAffinity(expressions(requirement("cores", corev1.NodeSelectorOpLt, "16"))), matches: true},
+		{name: "Lt greater", affinity: requiredNodeAffinity(expressions(requirement("cores", corev1.NodeSelectorOpLt, "8"))), matches: false},
+		{name: "requirements of a term are ANDed", affinity: requiredNodeAffinity(expressions(
+			requirement("zone", corev1.NodeSelectorOpIn, "a"), requirement("disk", corev1.NodeSelectorOpIn, "hdd"))), matches: false},
+		{name: "terms are ORed", affinity: requiredNodeAffinity(
+			expressions(requirement("zone", corev1.NodeSelectorOpIn, "b")), expressions(requirement("disk", corev1.NodeSelectorOpIn, "ssd"))), matches: true},
+		{name: "empty term matches no node", affinity: requiredNodeAffinity(corev1.NodeSelectorTerm{}), matches: false},
+		{name: "node selector and affinity are ANDed", nodeSelector: map[string]string{"zone": "b"},
+			affinity: requiredNodeAffinity(expressions(requirement("zone", corev1.NodeSelectorOpExists))), matches: false},
+		{name: "match fields name", affinity: requiredNodeAffinity(corev1.NodeSelectorTerm{MatchFields: []corev1.NodeSelectorRequirement{
+			requirement("metadata.name", corev1.NodeSelectorOpIn, "node-a")}}), matches: true},
+		{name: "match fields other name", affinity: requiredNodeAffinity(corev1.NodeSelectorTerm{MatchFields: []corev1.NodeSelectorRequirement{
+			requirement("metadata.name", corev1.NodeSelectorOpIn, "node-b")}}), matches: false},
+		{name: "preferred affinity does not filter", affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+				{Weight: 100, Preference: expressions(requirement("zone", corev1.NodeSelectorOpIn, "b"))},
+			}}}, matches: true},
+		{name: "preferred affinity with unmatched required affinity", affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+				expressions(requirement("zone", corev1.NodeSelectorOpIn, "b"))}},
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+				{Weight: 100, Preference: expressions(requirement("zone", corev1.NodeSelectorOpIn, "a"))},
+			}}}, matches: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &Pod{Pod: *newPod("app", "web", nil)}
+			pod.Spec.NodeSelector, pod.Spec.Affinity = test.nodeSelector, test.affinity
+			reasons := (&NodeAffinity{}).Filter(nil, pod, &NodeInfo{Node: node})
+			if matches := len(reasons) == 0; matches != test.matches {
+				t.Errorf("matches %t, expected %t (reasons %v)", matches, test.matches, reasons)
+			}
+		})
+	}
+}
+
+func TestTaintToleration(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		taint       corev1.Taint
+		tolerations []corev1.Toleration
+		fits        bool
+	}{
+		{name: "NoSchedule untolerated",
+			taint: corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule}, fits: false},
+		{name: "NoExecute untolerated",
+			taint: corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoExecute}, fits: false},
+		{name: "PreferNoSchedule does not filter",
+			taint: corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectPreferNoSchedule}, fits: true},
+		{name: "NoSchedule tolerated by NoSchedule",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "db", Effect: corev1.TaintEffectNoSchedule}}, fits: true},
+		{name: "NoExecute not tolerated by NoSchedule",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoExecute},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "db", Effect: corev1.TaintEffectNoSchedule}}, fits: false},
+		{name: "NoSchedule not tolerated by NoExecute",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "db", Effect: corev1.TaintEffectNoExecute}}, fits: false},
+		{name: "NoExecute tolerated by NoExecute",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoExecute},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "db", Effect: corev1.TaintEffectNoExecute}}, fits: true},
+		{name: "empty effect tolerates every effect",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoExecute},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "db"}}, fits: true},
+		{name: "value mismatch",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "web", Effect: corev1.TaintEffectNoSchedule}}, fits: false},
+		{name: "Exists ignores the value",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+			tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}, fits: true},
+		{name: "Exists without key tolerates every taint",
+			taint:       corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoExecute},
+			tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}, fits: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &Pod{Pod: *newPod("app", "web", nil)}
+			pod.Spec.Tolerations = test.tolerations
+			reasons := (&TaintToleration{}).Filter(nil, pod, &NodeInfo{Node: newNode("node-a", nil, test.taint)})
+			if fits := len(reasons) == 0; fits != test.fits {
+				t.Errorf("fits %t, expected %t (reasons %v)", fits, test.fits, reasons)
+			}
+		})
+	}
+}
+
+func antiAffinity(namespaces []string, topologyKey string, matchLabels map[string]string) *corev1.Affinity {
+	return &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+			{LabelSelector: &metav1.LabelSelector{MatchLabels: matchLabels}, Namespaces: namespaces, TopologyKey: topologyKey},
+		},
+	}}
+}
+
+// Nodes a and b share zone-1, node c is in zone-2 and node d has no zone
+func newZonedCluster(plugins ...FilterPlugin) *Cluster {
+	cluster := &Cluster{Plugins: plugins}
+	for _, node := range []*corev1.Node{
+		newNode("node-a", map[string]string{"zone": "zone-1"}),
+		newNode("node-b", map[string]string{"zone": "zone-1"}),
+		newNode("node-c", map[string]string{"zone": "zone-2"}),
+		newNode("node-d", nil),
+	} {
+		cluster.Nodes = append(cluster.Nodes, &NodeInfo{Node: node, Requested: make(map[corev1.ResourceName]int64)})
+	}
+	return cluster
+}
+
+func nodeNames(nodes map[string]bool) []string {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestInterPodAffinity(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		existing *corev1.Pod
+		pod      *corev1.Pod
+		blocked  []string
+	}{
+		{
+			name:     "anti-affinity of the pod",
+			existing: newPod("app", "db", map[string]string{"app": "db"}),
+			pod: func() *corev1.Pod {
+				pod := newPod("app", "web", map[string]string{"app": "web"})
+				pod.Spec.Affinity = antiAffinity(nil, "zone", map[string]string{"app": "db"})
+				return pod
+			}(),
+			blocked: []string{"node-a", "node-b"},
+		},
+		{
+			name: "anti-affinity of the existing pod is symmetric",
+			existing: func() *corev1.Pod {
+				pod := newPod("app", "db", map[string]string{"app": "db"})
+				pod.Spec.Affinity = antiAffinity(nil, "zone", map[string]string{"app": "web"})
+				return pod
+			}(),
+			pod:     newPod("app", "web", map[string]string{"app": "web"}),
+			blocked: []string{"node-a", "node-b"},
+		},
+		{
+			name: "hostname topology only blocks the node",
+			existing: func() *corev1.Pod {
+				pod := newPod("app", "db", map[string]string{"app": "db"})
+				pod.Spec.Affinity = antiAffinity(nil, "kubernetes.io/hostname", map[string]string{"app": "web"})
+				return pod
+			}(),
+			pod:     newPod("app", "web", map[string]string{"app": "web"}),
+			blocked: []string{"node-a"},
+		},
+		{
+			name: "terms without namespaces only match their own namespace",
+			existing: func() *corev1.Pod {
+				pod := newPod("other", "db", map[string]string{"app": "db"})
+				pod.Spec.Affinity = antiAffinity(nil, "zone", map[string]string{"app": "web"})
+				return pod
+			}(),
+			pod:     newPod("app", "web", map[string]string{"app": "web"}),
+			blocked: []string{},
+		},
+		{
+			name: "terms with namespaces match those namespaces",
+			existing: func() *corev1.Pod {
+				pod := newPod("other", "db", map[string]string{"app": "db"})
+				pod.Spec.Affinity = antiAffinity([]string{"app"}, "zone", map[string]string{"app": "web"})
+				return pod
+			}(),
+			pod:     newPod("app", "web", map[string]string{"app": "web"}),
+			blocked: []string{"node-a", "node-b"},
+		},
+		{
+			name: "non-matching selector",
+			existing: func() *corev1.Pod {
+				pod := newPod("app", "db", map[string]string{"app": "db"})
+				pod.Spec.Affinity = antiAffinity(nil, "zone", map[string]string{"app": "cache"})
+				return pod
+			}(),
+			pod:     newPod("app", "web", map[string]string{"app": "web"}),
+			blocked: []string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := newZonedCluster()
+			cluster.Nodes[0].Node.Labels["kubernetes.io/hostname"] = "node-a"
+			cluster.Nodes[0].Add(test.existing)
+			blocked := nodeNames(cluster.BlockedByAntiAffinity(&Pod{Pod: *test.pod}))
+			if !reflect.DeepEqual(blocked, test.blocked) {
+				t.Errorf("blocked %v, expected %v", blocked, test.blocked)
+			}
+		})
+	}
+}
+
+func TestPodTopologySpread(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	for _, test := range []struct {
+		name              string
+		maxSkew           int
+		whenUnsatisfiable string
+		podLabels         map[string]string
+		feasible          []string
+	}{
+		// zone-1 holds 2 matching pods and zone-2 none, so the skew after placing in zone-1 is 3 and in zone-2 is 1
+		{name: "DoNotSchedule maxSkew 1", maxSkew: 1, whenUnsatisfiable: "DoNotSchedule", podLabels: web, feasible: []string{"node-c"}},
+		{name: "DoNotSchedule maxSkew 3", maxSkew: 3, whenUnsatisfiable: "DoNotSchedule", podLabels: web, feasible: []string{"node-a", "node-b", "node-c"}},
+		{name: "ScheduleAnyway does not filter", maxSkew: 1, whenUnsatisfiable: "ScheduleAnyway", podLabels: web, feasible: []string{"node-a", "node-b", "node-c", "node-d"}},
+		// Without matching itself the pod does not add to the skew of its domain
+		{name: "pod not matching the selector", maxSkew: 2, whenUnsatisfiable: "DoNotSchedule", podLabels: map[string]string{"app": "db"}, feasible: []string{"node-a", "node-b", "node-c"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := newZonedCluster(&PodTopologySpread{})
+			cluster.Nodes[0].Add(newPod("app", "web-1", web))
+			cluster.Nodes[1].Add(newPod("app", "web-2", web))
+			// Pods of other namespaces do not count
+			cluster.Nodes[2].Add(newPod("other", "web-1", web))
+			pod := &Pod{Pod: *newPod("app", "web-3", test.podLabels), TopologySpreadConstraints: []manifest.TopologySpreadConstraint{
+				{MaxSkew: test.maxSkew, TopologyKey: "zone", WhenUnsatisfiable: test.whenUnsatisfiable, LabelSelector: &metav1.LabelSelector{MatchLabels: web}},
+			}}
+			feasible := make([]string, 0)
+			for _, node := range cluster.Feasible(pod, cluster.Nodes) {
+				feasible = append(feasible, node.Node.Name)
+			}
+			if !reflect.DeepEqual(feasible, test.feasible) {
+				t.Errorf("feasible %v, expected %v", feasible, test.feasible)
+			}
+		})
+	}
+}
+
+// Domains of nodes the node affinity of the pod excludes do not count towards the minimum
+func TestPodTopologySpreadNodeAffinity(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	cluster := newZonedCluster(&NodeAffinity{}, &PodTopologySpread{})
+	cluster.Nodes[0].Add(newPod("app", "web-1", web))
+	pod := &Pod{Pod: *newPod("app", "web-2", web), TopologySpreadConstraints: []manifest.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: "zone", WhenUnsatisfiable: "DoNotSchedule", LabelSelector: &metav1.LabelSelector{MatchLabels: web}},
+	}}
+	pod.Spec.NodeSelector = map[string]string{"zone": "zone-1"}
+	feasible := cluster.Feasible(pod, cluster.Nodes)
+	if len(feasible) != 2 {
+		t.Errorf("expected both zone-1 nodes to be feasible, got %d nodes", len(feasible))
+	}
+}
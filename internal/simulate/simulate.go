@@ -0,0 +1,235 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package simulate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/manifest"
+	kubesize "github.com/akrzos/kubeSize/pkg/capacity"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// A pod to place, with the topology spread constraints the vendored pod spec can not hold
+type Pod struct {
+	corev1.Pod
+	TopologySpreadConstraints []manifest.TopologySpreadConstraint
+}
+
+// A node with the pods placed on it and the sum of their requests, cpu in millicores
+type NodeInfo struct {
+	Node      *corev1.Node
+	Pods      []*corev1.Pod
+	Requested map[corev1.ResourceName]int64
+}
+
+// Checks whether a pod can be placed on a node, modeled after the filter plugins of the scheduler framework
+type FilterPlugin interface {
+	Name() string
+	// Computes the state the filters of a pod share, such as the pod counts of topology domains
+	PreFilter(cluster *Cluster, pod *Pod)
+	// Returns the reasons the pod can not be placed on the node, none when it fits
+	Filter(cluster *Cluster, pod *Pod, node *NodeInfo) []string
+}
+
+// Cached cluster state pods are placed on one at a time, like the scheduler places them
+type Cluster struct {
+	Nodes   []*NodeInfo
+	Plugins []FilterPlugin
+}
+
+// The pods of a placement that did not fit on any node, with the number of nodes filtered for each reason
+type FitError struct {
+	NodeCount int
+	Reasons   map[string]int
+}
+
+// Formatted like the FailedScheduling events of the scheduler
+func (e *FitError) Error() string {
+	reasons := make([]string, 0, len(e.Reasons))
+	for reason, count := range e.Reasons {
+		reasons = append(reasons, fmt.Sprintf("%d %s", count, reason))
+	}
+	sort.Strings(reasons)
+	return fmt.Sprintf("0/%d nodes are available: %s.", e.NodeCount, strings.Join(reasons, ", "))
+}
+
+// The default plugins, the scheduler relies on the not-ready taint instead of NodeReady, but snapshots may lack it
+func DefaultPlugins() []FilterPlugin {
+	return []FilterPlugin{&NodeUnschedulable{}, &NodeReady{}, &NodeResourcesFit{}, &NodeAffinity{}, &TaintToleration{}, &InterPodAffinity{}, &PodTopologySpread{}}
+}
+
+// Builds the cluster state of the nodes and the pods that hold resources on them
+func NewCluster(nodes *corev1.NodeList, pods *corev1.PodList, filter kubesize.PodFilter) *Cluster {
+	cluster := &Cluster{Plugins: DefaultPlugins()}
+	nodeInfos := make(map[string]*NodeInfo, len(nodes.Items))
+	for i := range nodes.Items {
+		nodeInfo := &NodeInfo{Node: &nodes.Items[i], Requested: make(map[corev1.ResourceName]int64)}
+		nodeInfos[nodeInfo.Node.Name] = nodeInfo
+		cluster.Nodes = append(cluster.Nodes, nodeInfo)
+	}
+	sort.Slice(cluster.Nodes, func(i, j int) bool { return cluster.Nodes[i].Node.Name < cluster.Nodes[j].Node.Name })
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if nodeInfo, ok := nodeInfos[pod.Spec.NodeName]; ok && filter.HoldsResources(*pod) {
+			nodeInfo.Add(pod)
+		}
+	}
+	return cluster
+}
+
+func (n *NodeInfo) Add(pod *corev1.Pod) {
+	n.Pods = append(n.Pods, pod)
+	for resourceName, value := range podRequests(pod.Spec) {
+		n.Requested[resourceName] += value
+	}
+}
+
+// Removes a pod of the node, such as one evicted to be placed on another node
+func (n *NodeInfo) Remove(pod *corev1.Pod) {
+	for i, existing := range n.Pods {
+		if existing == pod {
+			n.Pods = append(n.Pods[:i:i], n.Pods[i+1:]...)
+			for resourceName, value := range podRequests(pod.Spec) {
+				n.Requested[resourceName] -= value
+			}
+			return
+		}
+	}
+}
+
+// Percent of the allocatable cpu and memory of the node requested
+func (n *NodeInfo) Utilization() (float64, float64) {
+	percent := func(resourceName corev1.ResourceName) float64 {
+		allocatable := n.Node.Status.Allocatable[resourceName]
+		total := allocatable.Value()
+		if resourceName == corev1.ResourceCPU {
+			total = allocatable.MilliValue()
+		}
+		if total <= 0 {
+			return 0
+		}
+		return 100 * float64(n.Requested[resourceName]) / float64(total)
+	}
+	return percent(corev1.ResourceCPU), percent(corev1.ResourceMemory)
+}
+
+// Effective requests of a pod of the spec, the larger of the sum of its containers and each of its init containers
+func podRequests(spec corev1.PodSpec) map[corev1.ResourceName]int64 {
+	value := func(resourceName corev1.ResourceName, requests corev1.ResourceList) int64 {
+		quantity := requests[resourceName]
+		if resourceName == corev1.ResourceCPU {
+			return quantity.MilliValue()
+		}
+		return quantity.Value()
+	}
+	requests := make(map[corev1.ResourceName]int64)
+	for _, container := range spec.Containers {
+		for resourceName := range container.Resources.Requests {
+			requests[resourceName] += value(resourceName, container.Resources.Requests)
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for resourceName := range container.Resources.Requests {
+			if v := value(resourceName, container.Resources.Requests); v > requests[resourceName] {
+				requests[resourceName] = v
+			}
+		}
+	}
+	return requests
+}
+
+// Removes the named filter plugin, such as PodTopologySpread to place pods by resource headroom alone
+func (c *Cluster) RemovePlugin(name string) {
+	plugins := make([]FilterPlugin, 0, len(c.Plugins))
+	for _, plugin := range c.Plugins {
+		if plugin.Name() != name {
+			plugins = append(plugins, plugin)
+		}
+	}
+	c.Plugins = plugins
+}
+
+// Places the pod on the feasible node it leaves least allocated and returns the name of the node
+func (c *Cluster) Place(pod *Pod) (string, error) {
+	for _, plugin := range c.Plugins {
+		plugin.PreFilter(c, pod)
+	}
+	fitError := &FitError{NodeCount: len(c.Nodes), Reasons: make(map[string]int)}
+	var best *NodeInfo
+	bestScore := 0.0
+	requests := podRequests(pod.Spec)
+	for _, node := range c.Nodes {
+		if reasons := c.filter(pod, node); len(reasons) > 0 {
+			for _, reason := range reasons {
+				fitError.Reasons[reason]++
+			}
+			continue
+		}
+		if score := allocatedAfter(node, requests); best == nil || score < bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best == nil {
+		return "", fitError
+	}
+	best.Add(&pod.Pod)
+	return best.Node.Name, nil
+}
+
+// The larger of the cpu and memory fraction of allocatable requested once the pod is placed
+func allocatedAfter(node *NodeInfo, requests map[corev1.ResourceName]int64) float64 {
+	allocated := 0.0
+	for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		allocatable := node.Node.Status.Allocatable[resourceName]
+		total := allocatable.Value()
+		if resourceName == corev1.ResourceCPU {
+			total = allocatable.MilliValue()
+		}
+		if total > 0 {
+			if fraction := float64(node.Requested[resourceName]+requests[resourceName]) / float64(total); fraction > allocated {
+				allocated = fraction
+			}
+		}
+	}
+	return allocated
+}
+
+// Reasons of the first plugin that filters the node, PreFilter must have run for the pod
+func (c *Cluster) filter(pod *Pod, node *NodeInfo) []string {
+	for _, plugin := range c.Plugins {
+		if reasons := plugin.Filter(c, pod, node); len(reasons) > 0 {
+			return reasons
+		}
+	}
+	return nil
+}
+
+// The nodes of the given nodes the pod can be placed on
+func (c *Cluster) Feasible(pod *Pod, nodes []*NodeInfo) []*NodeInfo {
+	for _, plugin := range c.Plugins {
+		plugin.PreFilter(c, pod)
+	}
+	feasible := make([]*NodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		if len(c.filter(pod, node)) == 0 {
+			feasible = append(feasible, node)
+		}
+	}
+	return feasible
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+)
+
+// Serves the lists of a source from the files of a directory while they are younger than the TTL, so sub-commands run
+// back-to-back reuse the objects the first one listed instead of listing them again
+type Cache struct {
+	CapacitySource
+	dir string
+	ttl time.Duration
+}
+
+func NewCache(capacitySource CapacitySource, dir string, ttl time.Duration) *Cache {
+	return &Cache{CapacitySource: capacitySource, dir: dir, ttl: ttl}
+}
+
+func (c *Cache) Nodes() (*corev1.NodeList, error) {
+	nodes := &corev1.NodeList{}
+	if c.read("nodes", "", nodes) {
+		return nodes, nil
+	}
+	nodes, err := c.CapacitySource.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	c.write("nodes", "", nodes)
+	return nodes, nil
+}
+
+func (c *Cache) Pods(namespace string) (*corev1.PodList, error) {
+	pods := &corev1.PodList{}
+	if c.read("pods", namespace, pods) {
+		return pods, nil
+	}
+	pods, err := c.CapacitySource.Pods(namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.write("pods", namespace, pods)
+	return pods, nil
+}
+
+func (c *Cache) Namespaces(name string) (*corev1.NamespaceList, error) {
+	namespaces := &corev1.NamespaceList{}
+	if c.read("namespaces", name, namespaces) {
+		return namespaces, nil
+	}
+	namespaces, err := c.CapacitySource.Namespaces(name)
+	if err != nil {
+		return nil, err
+	}
+	c.write("namespaces", name, namespaces)
+	return namespaces, nil
+}
+
+func (c *Cache) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	resourceQuotas := &corev1.ResourceQuotaList{}
+	if c.read("resourcequotas", namespace, resourceQuotas) {
+		return resourceQuotas, nil
+	}
+	resourceQuotas, err := c.CapacitySource.ResourceQuotas(namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.write("resourcequotas", namespace, resourceQuotas)
+	return resourceQuotas, nil
+}
+
+func (c *Cache) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	podDisruptionBudgets := &policyv1beta1.PodDisruptionBudgetList{}
+	if c.read("poddisruptionbudgets", namespace, podDisruptionBudgets) {
+		return podDisruptionBudgets, nil
+	}
+	podDisruptionBudgets, err := c.CapacitySource.PodDisruptionBudgets(namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.write("poddisruptionbudgets", namespace, podDisruptionBudgets)
+	return podDisruptionBudgets, nil
+}
+
+// Lists of all namespaces are saved as <resource>.json, lists of a namespace or name as <resource>-<name>.json
+func (c *Cache) path(resource string, name string) string {
+	if name == "" {
+		return filepath.Join(c.dir, resource+".json")
+	}
+	return filepath.Join(c.dir, resource+"-"+name+".json")
+}
+
+// Reads a cached list, false when it is missing, expired or unreadable so the list is listed from the source
+func (c *Cache) read(resource string, name string, list interface{}) bool {
+	path := c.path(resource, name)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.ttl {
+		return false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, list) == nil
+}
+
+// Replaces a cached list through a temporary file, so concurrent runs never read a partial list. A list that can not
+// be cached is listed again by the next run.
+func (c *Cache) write(resource string, name string, list interface{}) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return
+	}
+	file, err := ioutil.TempFile(c.dir, resource+"-*.tmp")
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err != nil || closeErr != nil {
+		return
+	}
+	os.Rename(file.Name(), c.path(resource, name))
+}
@@ -0,0 +1,207 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Counts the lists of the source, Nodes fails while err is set
+type countingSource struct {
+	Snapshot
+	nodeLists int
+	podLists  map[string]int
+	err       error
+}
+
+func newCountingSource() *countingSource {
+	return &countingSource{podLists: make(map[string]int)}
+}
+
+func (s *countingSource) Nodes() (*corev1.NodeList, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.nodeLists++
+	return &corev1.NodeList{Items: []corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}}}, nil
+}
+
+func (s *countingSource) Pods(namespace string) (*corev1.PodList, error) {
+	s.podLists[namespace]++
+	return &corev1.PodList{Items: []corev1.Pod{{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "pod"}}}}, nil
+}
+
+func TestCacheTTL(t *testing.T) {
+	dir := t.TempDir()
+	capacitySource := newCountingSource()
+	cache := NewCache(capacitySource, dir, time.Minute)
+	for i := 0; i < 2; i++ {
+		nodes, err := cache.Nodes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nodes.Items) != 1 || nodes.Items[0].Name != "worker-1" {
+			t.Errorf("got nodes %v, expected worker-1", nodes.Items)
+		}
+	}
+	if capacitySource.nodeLists != 1 {
+		t.Errorf("listed the nodes %d times within the TTL, expected once", capacitySource.nodeLists)
+	}
+
+	expired := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, "nodes.json"), expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Nodes(); err != nil {
+		t.Fatal(err)
+	}
+	if capacitySource.nodeLists != 2 {
+		t.Errorf("listed the nodes %d times after the TTL expired, expected twice", capacitySource.nodeLists)
+	}
+	// The expired list is replaced
+	if _, err := cache.Nodes(); err != nil {
+		t.Fatal(err)
+	}
+	if capacitySource.nodeLists != 2 {
+		t.Errorf("listed the nodes %d times after they were cached again, expected twice", capacitySource.nodeLists)
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	capacitySource := newCountingSource()
+	cache := NewCache(capacitySource, t.TempDir(), time.Minute)
+	for _, namespace := range []string{"", "app", "db", "app", "", "db"} {
+		pods, err := cache.Pods(namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pods.Items[0].Namespace != namespace {
+			t.Errorf("got the pods of namespace %q for namespace %q", pods.Items[0].Namespace, namespace)
+		}
+	}
+	for _, namespace := range []string{"", "app", "db"} {
+		if capacitySource.podLists[namespace] != 1 {
+			t.Errorf("listed the pods of namespace %q %d times, expected once", namespace, capacitySource.podLists[namespace])
+		}
+	}
+
+	// Caches of other directories, such as those of other clusters, are not shared
+	other := NewCache(capacitySource, t.TempDir(), time.Minute)
+	if _, err := other.Pods("app"); err != nil {
+		t.Fatal(err)
+	}
+	if capacitySource.podLists["app"] != 2 {
+		t.Errorf("listed the pods of namespace app %d times with another cache directory, expected twice", capacitySource.podLists["app"])
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	dir := t.TempDir()
+	capacitySource := newCountingSource()
+	capacitySource.err = errors.New("connection refused")
+	cache := NewCache(capacitySource, dir, time.Minute)
+	if _, err := cache.Nodes(); err == nil {
+		t.Fatalf("expected the error of the source")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nodes.json")); !os.IsNotExist(err) {
+		t.Errorf("expected a failed list not to be cached")
+	}
+
+	// Unreadable lists are listed again and replaced
+	capacitySource.err = nil
+	if err := ioutil.WriteFile(filepath.Join(dir, "nodes.json"), []byte(`{"items": [`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Nodes(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Nodes(); err != nil {
+		t.Fatal(err)
+	}
+	if capacitySource.nodeLists != 1 {
+		t.Errorf("listed the nodes %d times with an unreadable cached list, expected once", capacitySource.nodeLists)
+	}
+
+	// Lists that can not be cached are still returned
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if nodes, err := NewCache(capacitySource, filepath.Join(file, "cache"), time.Minute).Nodes(); err != nil || len(nodes.Items) != 1 {
+		t.Errorf("expected the nodes of the source without a cache directory, got %v, %v", nodes, err)
+	}
+}
+
+// Readers of the cache directory never see a partial list while it is replaced
+func TestCacheAtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(newCountingSource(), dir, time.Minute)
+	pods := &corev1.PodList{}
+	for i := 0; i < 1000; i++ {
+		pods.Items = append(pods.Items, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: strings.Repeat("p", 100)}})
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, "pods.json"))
+			if os.IsNotExist(err) {
+				continue
+			}
+			read := &corev1.PodList{}
+			if err != nil || json.Unmarshal(data, read) != nil || len(read.Items) != len(pods.Items) {
+				t.Errorf("read a partial list of %d bytes", len(data))
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		cache.write("pods", "", pods)
+	}
+	close(done)
+	wg.Wait()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "pods.json" {
+		names := make([]string, 0)
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		t.Errorf("expected only pods.json in the cache directory, got %v", names)
+	}
+}
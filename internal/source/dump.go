@@ -0,0 +1,274 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reads the objects from a kubectl cluster-info dump directory or an OpenShift must-gather directory or tar archive:
+//
+//	cluster-info dump: nodes.json, <namespace>/pods.json
+//	must-gather:       cluster-scoped-resources/core/nodes/<node>.yaml, namespaces/<namespace>/core/pods.yaml,
+//	                   namespaces/<namespace>/core/resourcequotas.yaml, namespaces/<namespace>/<namespace>.yaml,
+//	                   namespaces/<namespace>/policy/poddisruptionbudgets.yaml
+//
+// The files are found at any depth, so the directory or archive may hold several dumps, objects found more than once
+// are only kept once.
+type Dump struct {
+	path    string
+	objects *objects
+}
+
+func NewDump(path string) *Dump {
+	return &Dump{path: path}
+}
+
+// Whether the path is a directory or tar archive to read with a Dump rather than a Snapshot
+func IsDump(path string) bool {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return true
+	}
+	return strings.HasSuffix(path, ".tar") || strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// Whether a file of a dump holds nodes, pods, namespaces or resource quotas, other files such as logs are skipped
+func isDumpObjectFile(name string) bool {
+	dir, base := path.Split(name)
+	switch base {
+	case "nodes.json", "pods.json", "resourcequotas.json", "poddisruptionbudgets.json", "nodes.yaml", "pods.yaml", "resourcequotas.yaml", "poddisruptionbudgets.yaml":
+		return true
+	}
+	if strings.HasSuffix(dir, "core/nodes/") && strings.HasSuffix(base, ".yaml") {
+		return true
+	}
+	// namespaces/<namespace>/<namespace>.yaml
+	parts := strings.Split(strings.TrimSuffix(dir, "/"), "/")
+	return len(parts) >= 2 && parts[len(parts)-2] == "namespaces" && base == parts[len(parts)-1]+".yaml"
+}
+
+// The dump is only read once, it does not change
+func (d *Dump) read() error {
+	if d.objects != nil {
+		return nil
+	}
+	objects := newObjects()
+	var namespaceNames []string
+	add := func(name string, data []byte) error {
+		// A cluster-info dump has no namespace objects, but a directory of each dumped namespace
+		if dir := path.Dir(filepath.ToSlash(name)); path.Base(name) == "pods.json" && dir != "." {
+			namespaceNames = append(namespaceNames, path.Base(dir))
+		}
+		if err := objects.add(data); err != nil {
+			return errors.Wrapf(err, "failed to decode %s of dump %s", name, d.path)
+		}
+		return nil
+	}
+
+	var err error
+	if info, statErr := os.Stat(d.path); statErr == nil && info.IsDir() {
+		err = walkDumpDirectory(d.path, add)
+	} else {
+		err = walkDumpArchive(d.path, add)
+	}
+	if err != nil {
+		return err
+	}
+	if len(objects.nodes.Items) == 0 {
+		return fmt.Errorf("no nodes found in %s, expected a kubectl cluster-info dump or must-gather", d.path)
+	}
+
+	for _, pod := range objects.pods.Items {
+		namespaceNames = append(namespaceNames, pod.Namespace)
+	}
+	objects.nodes, objects.pods = uniqueNodes(objects.nodes), uniquePods(objects.pods)
+	objects.namespaces = uniqueNamespaces(objects.namespaces, namespaceNames)
+	objects.resourceQuotas = uniqueResourceQuotas(objects.resourceQuotas)
+	objects.podDisruptionBudgets = uniquePodDisruptionBudgets(objects.podDisruptionBudgets)
+	d.objects = objects
+	return nil
+}
+
+func walkDumpDirectory(root string, add func(name string, data []byte) error) error {
+	return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "failed to read dump")
+		}
+		name, _ := filepath.Rel(root, file)
+		if info.IsDir() || !isDumpObjectFile(filepath.ToSlash(name)) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return errors.Wrap(err, "failed to read dump")
+		}
+		return add(name, data)
+	})
+}
+
+func walkDumpArchive(archive string, add func(name string, data []byte) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return errors.Wrap(err, "failed to read dump")
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if !strings.HasSuffix(archive, ".tar") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read dump %s", archive)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read dump %s", archive)
+		}
+		if header.Typeflag != tar.TypeReg || !isDumpObjectFile(header.Name) {
+			continue
+		}
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read dump %s", archive)
+		}
+		if err := add(header.Name, data); err != nil {
+			return err
+		}
+	}
+}
+
+func uniqueNodes(nodes *corev1.NodeList) *corev1.NodeList {
+	unique := &corev1.NodeList{}
+	seen := make(map[string]bool)
+	for _, node := range nodes.Items {
+		if !seen[node.Name] {
+			seen[node.Name] = true
+			unique.Items = append(unique.Items, node)
+		}
+	}
+	return unique
+}
+
+func uniquePods(pods *corev1.PodList) *corev1.PodList {
+	unique := &corev1.PodList{}
+	seen := make(map[string]bool)
+	for _, pod := range pods.Items {
+		if key := pod.Namespace + "/" + pod.Name; !seen[key] {
+			seen[key] = true
+			unique.Items = append(unique.Items, pod)
+		}
+	}
+	return unique
+}
+
+func uniqueResourceQuotas(resourceQuotas *corev1.ResourceQuotaList) *corev1.ResourceQuotaList {
+	unique := &corev1.ResourceQuotaList{}
+	seen := make(map[string]bool)
+	for _, resourceQuota := range resourceQuotas.Items {
+		if key := resourceQuota.Namespace + "/" + resourceQuota.Name; !seen[key] {
+			seen[key] = true
+			unique.Items = append(unique.Items, resourceQuota)
+		}
+	}
+	return unique
+}
+
+func uniquePodDisruptionBudgets(podDisruptionBudgets *policyv1beta1.PodDisruptionBudgetList) *policyv1beta1.PodDisruptionBudgetList {
+	unique := &policyv1beta1.PodDisruptionBudgetList{}
+	seen := make(map[string]bool)
+	for _, podDisruptionBudget := range podDisruptionBudgets.Items {
+		if key := podDisruptionBudget.Namespace + "/" + podDisruptionBudget.Name; !seen[key] {
+			seen[key] = true
+			unique.Items = append(unique.Items, podDisruptionBudget)
+		}
+	}
+	return unique
+}
+
+// Adds the named namespaces that were not dumped as objects
+func uniqueNamespaces(namespaces *corev1.NamespaceList, names []string) *corev1.NamespaceList {
+	unique := &corev1.NamespaceList{}
+	seen := make(map[string]bool)
+	for _, namespace := range namespaces.Items {
+		if !seen[namespace.Name] {
+			seen[namespace.Name] = true
+			unique.Items = append(unique.Items, namespace)
+		}
+	}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique.Items = append(unique.Items, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+	}
+	return unique
+}
+
+func (d *Dump) Nodes() (*corev1.NodeList, error) {
+	if err := d.read(); err != nil {
+		return nil, err
+	}
+	return d.objects.nodes, nil
+}
+
+func (d *Dump) Pods(namespace string) (*corev1.PodList, error) {
+	if err := d.read(); err != nil {
+		return nil, err
+	}
+	return filterPods(d.objects.pods, namespace), nil
+}
+
+func (d *Dump) Namespaces(name string) (*corev1.NamespaceList, error) {
+	if err := d.read(); err != nil {
+		return nil, err
+	}
+	return filterNamespaces(d.objects.namespaces, name), nil
+}
+
+func (d *Dump) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	if err := d.read(); err != nil {
+		return nil, err
+	}
+	return filterResourceQuotas(d.objects.resourceQuotas, namespace), nil
+}
+
+func (d *Dump) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	if err := d.read(); err != nil {
+		return nil, err
+	}
+	return filterPodDisruptionBudgets(d.objects.podDisruptionBudgets, namespace), nil
+}
@@ -0,0 +1,274 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selects names by patterns, globs such as prod-* or regular expressions enclosed in slashes such as
+// /^team-[a-z]+-prod$/. A name is selected when it matches an include pattern, or there are none, and no exclude
+// pattern.
+type NameFilter struct {
+	include []func(string) bool
+	exclude []func(string) bool
+}
+
+// Selects namespaces by name patterns
+func NewNamespaceFilter(include []string, exclude []string) (*NameFilter, error) {
+	return newNameFilter("namespace", include, exclude)
+}
+
+// Selects node roles by name patterns
+func NewRoleFilter(include []string) (*NameFilter, error) {
+	return newNameFilter("role", include, nil)
+}
+
+func newNameFilter(kind string, include []string, exclude []string) (*NameFilter, error) {
+	filter := &NameFilter{}
+	var err error
+	if filter.include, err = nameMatchers(kind, include); err != nil {
+		return nil, err
+	}
+	if filter.exclude, err = nameMatchers(kind, exclude); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func nameMatchers(kind string, patterns []string) ([]func(string) bool, error) {
+	matchers := make([]func(string) bool, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expression, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%s pattern \"%s\" is invalid: %v", kind, pattern, err)
+			}
+			matchers = append(matchers, expression.MatchString)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s pattern \"%s\" is invalid: %v", kind, pattern, err)
+		}
+		glob := pattern
+		matchers = append(matchers, func(name string) bool {
+			matched, _ := path.Match(glob, name)
+			return matched
+		})
+	}
+	return matchers, nil
+}
+
+func (f *NameFilter) Matches(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, matches := range f.exclude {
+		if matches(name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, matches := range f.include {
+		if matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Nodes selected by a node filter
+type NodeFilterOptions struct {
+	// Regular expression of the node names, for node pools only distinguishable by naming convention
+	NameRegex string
+	// Leaves out cordoned nodes, their capacity is not available to new pods
+	SchedulableOnly bool
+	// Leaves out nodes that are not Ready
+	ReadyOnly bool
+	// Leaves out nodes with a NoSchedule or NoExecute taint, dedicated pools most pods can not land on
+	ExcludeTainted bool
+	// Leaves out nodes with a taint of any effect matching one of these key or key=value
+	ExcludeTaints []string
+}
+
+// Selects nodes by name, schedulability, readiness and taints
+type NodeFilter struct {
+	name            *regexp.Regexp
+	schedulableOnly bool
+	readyOnly       bool
+	excludeTainted  bool
+	excludeTaints   []corev1.Taint
+}
+
+func NewNodeFilter(options NodeFilterOptions) (*NodeFilter, error) {
+	filter := &NodeFilter{schedulableOnly: options.SchedulableOnly, readyOnly: options.ReadyOnly, excludeTainted: options.ExcludeTainted}
+	for _, taint := range options.ExcludeTaints {
+		key, value := taint, ""
+		if index := strings.Index(taint, "="); index >= 0 {
+			key, value = taint[:index], taint[index+1:]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("taint filter \"%s\" is invalid, expected key or key=value", taint)
+		}
+		filter.excludeTaints = append(filter.excludeTaints, corev1.Taint{Key: key, Value: value})
+	}
+	if options.NameRegex != "" {
+		expression, err := regexp.Compile(options.NameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("node name regex \"%s\" is invalid: %v", options.NameRegex, err)
+		}
+		filter.name = expression
+	}
+	return filter, nil
+}
+
+func (f *NodeFilter) Matches(node corev1.Node) bool {
+	if f == nil {
+		return true
+	}
+	if f.name != nil && !f.name.MatchString(node.Name) {
+		return false
+	}
+	if f.schedulableOnly && node.Spec.Unschedulable {
+		return false
+	}
+	if f.readyOnly && !isReady(node) {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if f.excludeTainted && (taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute) {
+			return false
+		}
+		for _, excluded := range f.excludeTaints {
+			if taint.Key == excluded.Key && (excluded.Value == "" || taint.Value == excluded.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// Only reads the nodes selected by the node filter, the namespaced objects of the namespaces selected by the namespace
+// filter and the pods matching the pod selector from a source. Pods bound to nodes that are not selected are left out,
+// pods not yet scheduled are kept. A nil filter or selector selects everything.
+type Filtered struct {
+	CapacitySource
+	filter      *NameFilter
+	nodeFilter  *NodeFilter
+	podSelector labels.Selector
+	// Names of the nodes left out by the node filter, set once the nodes are listed
+	excludedNodes map[string]bool
+}
+
+func NewFiltered(capacitySource CapacitySource, filter *NameFilter, nodeFilter *NodeFilter, podSelector labels.Selector) *Filtered {
+	return &Filtered{CapacitySource: capacitySource, filter: filter, nodeFilter: nodeFilter, podSelector: podSelector}
+}
+
+func (f *Filtered) Nodes() (*corev1.NodeList, error) {
+	nodes, err := f.CapacitySource.Nodes()
+	if err != nil || f.nodeFilter == nil {
+		return nodes, err
+	}
+	filtered := &corev1.NodeList{ListMeta: nodes.ListMeta}
+	f.excludedNodes = map[string]bool{}
+	for _, node := range nodes.Items {
+		if f.nodeFilter.Matches(node) {
+			filtered.Items = append(filtered.Items, node)
+		} else {
+			f.excludedNodes[node.Name] = true
+		}
+	}
+	return filtered, nil
+}
+
+func (f *Filtered) Pods(namespace string) (*corev1.PodList, error) {
+	if f.nodeFilter != nil && f.excludedNodes == nil {
+		if _, err := f.Nodes(); err != nil {
+			return nil, err
+		}
+	}
+	pods, err := f.CapacitySource.Pods(namespace)
+	if err != nil {
+		return nil, err
+	}
+	filtered := &corev1.PodList{ListMeta: pods.ListMeta}
+	for _, pod := range pods.Items {
+		if f.filter.Matches(pod.Namespace) && !f.excludedNodes[pod.Spec.NodeName] && (f.podSelector == nil || f.podSelector.Matches(labels.Set(pod.Labels))) {
+			filtered.Items = append(filtered.Items, pod)
+		}
+	}
+	return filtered, nil
+}
+
+func (f *Filtered) Namespaces(name string) (*corev1.NamespaceList, error) {
+	namespaces, err := f.CapacitySource.Namespaces(name)
+	if err != nil {
+		return nil, err
+	}
+	filtered := &corev1.NamespaceList{ListMeta: namespaces.ListMeta}
+	for _, namespace := range namespaces.Items {
+		if f.filter.Matches(namespace.Name) {
+			filtered.Items = append(filtered.Items, namespace)
+		}
+	}
+	return filtered, nil
+}
+
+func (f *Filtered) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	resourceQuotas, err := f.CapacitySource.ResourceQuotas(namespace)
+	if err != nil {
+		return nil, err
+	}
+	filtered := &corev1.ResourceQuotaList{ListMeta: resourceQuotas.ListMeta}
+	for _, resourceQuota := range resourceQuotas.Items {
+		if f.filter.Matches(resourceQuota.Namespace) {
+			filtered.Items = append(filtered.Items, resourceQuota)
+		}
+	}
+	return filtered, nil
+}
+
+func (f *Filtered) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	podDisruptionBudgets, err := f.CapacitySource.PodDisruptionBudgets(namespace)
+	if err != nil {
+		return nil, err
+	}
+	filtered := &policyv1beta1.PodDisruptionBudgetList{ListMeta: podDisruptionBudgets.ListMeta}
+	for _, podDisruptionBudget := range podDisruptionBudgets.Items {
+		if f.filter.Matches(podDisruptionBudget.Namespace) {
+			filtered.Items = append(filtered.Items, podDisruptionBudget)
+		}
+	}
+	return filtered, nil
+}
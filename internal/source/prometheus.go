@@ -0,0 +1,375 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Rebuilds the objects from the kube-state-metrics v2 series of a Prometheus server, only the fields the capacity data
+// is aggregated from are set
+type Prometheus struct {
+	url    string
+	client *http.Client
+}
+
+func NewPrometheus(url string) *Prometheus {
+	return &Prometheus{url: strings.TrimSuffix(url, "/"), client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// kube-state-metrics only exports allowlisted node labels, with their names sanitized
+var nodeLabels = map[string]string{
+	"label_topology_kubernetes_io_zone":                "topology.kubernetes.io/zone",
+	"label_failure_domain_beta_kubernetes_io_zone":     "failure-domain.beta.kubernetes.io/zone",
+	"label_node_kubernetes_io_instance_type":           "node.kubernetes.io/instance-type",
+	"label_beta_kubernetes_io_instance_type":           "beta.kubernetes.io/instance-type",
+	"label_kubernetes_io_role":                         "kubernetes.io/role",
+	"label_topology_kubernetes_io_region":              "topology.kubernetes.io/region",
+	"label_failure_domain_beta_kubernetes_io_region":   "failure-domain.beta.kubernetes.io/region",
+	"label_node_kubernetes_io_exclude_from_scheduling": "node.kubernetes.io/exclude-from-scheduling",
+}
+
+// Runs an instant query
+func (p *Prometheus) query(query string) ([]sample, error) {
+	response, err := p.client.Get(p.url + "/api/v1/query?query=" + url.QueryEscape(query))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query prometheus")
+	}
+	defer response.Body.Close()
+	result := struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  []interface{}     `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode prometheus response of %s", response.Status)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus query %s failed: %s", query, result.Error)
+	}
+	samples := make([]sample, 0, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		if len(r.Value) != 2 {
+			continue
+		}
+		value, _ := r.Value[1].(string)
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample{labels: r.Metric, value: v})
+	}
+	return samples, nil
+}
+
+// Restricts a series to a namespace, all namespaces when empty
+func inNamespace(metric string, namespace string) string {
+	if namespace == "" {
+		return metric
+	}
+	return fmt.Sprintf("%s{namespace=%q}", metric, namespace)
+}
+
+// Quantity of a kube-state-metrics resource series in the unit of its unit label
+func quantity(value float64, unit string) resource.Quantity {
+	switch unit {
+	case "core":
+		return *resource.NewMilliQuantity(int64(value*1000), resource.DecimalSI)
+	case "byte":
+		return *resource.NewQuantity(int64(value), resource.BinarySI)
+	}
+	return *resource.NewQuantity(int64(value), resource.DecimalSI)
+}
+
+// Resource names are sanitized, ephemeral-storage is exported as ephemeral_storage
+func resourceName(name string) corev1.ResourceName {
+	return corev1.ResourceName(strings.Replace(name, "_", "-", -1))
+}
+
+func (p *Prometheus) Nodes() (*corev1.NodeList, error) {
+	info, err := p.query("kube_node_info")
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*corev1.Node, len(info))
+	names := make([]string, 0, len(info))
+	for _, s := range info {
+		name := s.labels["node"]
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: make(map[string]string)},
+			Status: corev1.NodeStatus{
+				Capacity:    make(corev1.ResourceList),
+				Allocatable: make(corev1.ResourceList),
+				Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}},
+				NodeInfo:    corev1.NodeSystemInfo{KubeletVersion: s.labels["kubelet_version"]},
+			},
+		}
+		if internalIP := s.labels["internal_ip"]; internalIP != "" {
+			node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: internalIP}}
+		}
+		nodes[name] = node
+		names = append(names, name)
+	}
+
+	for _, resources := range []struct {
+		metric string
+		list   func(node *corev1.Node) corev1.ResourceList
+	}{
+		{"kube_node_status_capacity", func(node *corev1.Node) corev1.ResourceList { return node.Status.Capacity }},
+		{"kube_node_status_allocatable", func(node *corev1.Node) corev1.ResourceList { return node.Status.Allocatable }},
+	} {
+		samples, err := p.query(resources.metric)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			if node, ok := nodes[s.labels["node"]]; ok {
+				resources.list(node)[resourceName(s.labels["resource"])] = quantity(s.value, s.labels["unit"])
+			}
+		}
+	}
+
+	ready, err := p.query(`kube_node_status_condition{condition="Ready",status="true"} == 1`)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ready {
+		if node, ok := nodes[s.labels["node"]]; ok {
+			node.Status.Conditions[0].Status = corev1.ConditionTrue
+		}
+	}
+
+	unschedulable, err := p.query("kube_node_spec_unschedulable == 1")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range unschedulable {
+		if node, ok := nodes[s.labels["node"]]; ok {
+			node.Spec.Unschedulable = true
+		}
+	}
+
+	roles, err := p.query("kube_node_role")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range roles {
+		if node, ok := nodes[s.labels["node"]]; ok {
+			node.Labels["node-role.kubernetes.io/"+s.labels["role"]] = ""
+		}
+	}
+
+	labels, err := p.query("kube_node_labels")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range labels {
+		if node, ok := nodes[s.labels["node"]]; ok {
+			for label, value := range s.labels {
+				if name, ok := nodeLabels[label]; ok {
+					node.Labels[name] = value
+				}
+			}
+		}
+	}
+
+	taints, err := p.query("kube_node_spec_taint")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range taints {
+		if node, ok := nodes[s.labels["node"]]; ok {
+			node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: s.labels["key"], Value: s.labels["value"], Effect: corev1.TaintEffect(s.labels["effect"])})
+		}
+	}
+
+	nodeList := &corev1.NodeList{}
+	for _, name := range names {
+		nodeList.Items = append(nodeList.Items, *nodes[name])
+	}
+	return nodeList, nil
+}
+
+func (p *Prometheus) Pods(namespace string) (*corev1.PodList, error) {
+	info, err := p.query(inNamespace("kube_pod_info", namespace))
+	if err != nil {
+		return nil, err
+	}
+	pods := make(map[string]*corev1.Pod, len(info))
+	keys := make([]string, 0, len(info))
+	for _, s := range info {
+		key := s.labels["namespace"] + "/" + s.labels["pod"]
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.labels["namespace"], Name: s.labels["pod"]},
+			Spec:       corev1.PodSpec{NodeName: s.labels["node"]},
+		}
+		// Mirror pods of static pods are owned by their node
+		if s.labels["created_by_kind"] == "Node" {
+			pod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: ""}
+		}
+		pods[key] = pod
+		keys = append(keys, key)
+	}
+
+	phases, err := p.query(inNamespace("kube_pod_status_phase", namespace) + " == 1")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range phases {
+		if pod, ok := pods[s.labels["namespace"]+"/"+s.labels["pod"]]; ok {
+			pod.Status.Phase = corev1.PodPhase(s.labels["phase"])
+		}
+	}
+
+	deletions, err := p.query(inNamespace("kube_pod_deletion_timestamp", namespace))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range deletions {
+		if pod, ok := pods[s.labels["namespace"]+"/"+s.labels["pod"]]; ok {
+			deletionTimestamp := metav1.NewTime(time.Unix(int64(s.value), 0))
+			pod.DeletionTimestamp = &deletionTimestamp
+		}
+	}
+
+	for _, resources := range []struct {
+		metric string
+		list   func(container *corev1.Container) *corev1.ResourceList
+	}{
+		{"kube_pod_container_resource_requests", func(container *corev1.Container) *corev1.ResourceList { return &container.Resources.Requests }},
+		{"kube_pod_container_resource_limits", func(container *corev1.Container) *corev1.ResourceList { return &container.Resources.Limits }},
+	} {
+		samples, err := p.query(inNamespace(resources.metric, namespace))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			pod, ok := pods[s.labels["namespace"]+"/"+s.labels["pod"]]
+			if !ok {
+				continue
+			}
+			container := podContainer(pod, s.labels["container"])
+			list := resources.list(container)
+			if *list == nil {
+				*list = make(corev1.ResourceList)
+			}
+			(*list)[resourceName(s.labels["resource"])] = quantity(s.value, s.labels["unit"])
+		}
+	}
+
+	podList := &corev1.PodList{}
+	for _, key := range keys {
+		podList.Items = append(podList.Items, *pods[key])
+	}
+	return podList, nil
+}
+
+// Returns the named container of a pod, adding it if needed
+func podContainer(pod *corev1.Pod, name string) *corev1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name})
+	return &pod.Spec.Containers[len(pod.Spec.Containers)-1]
+}
+
+func (p *Prometheus) Namespaces(name string) (*corev1.NamespaceList, error) {
+	query := "kube_namespace_created"
+	if name != "" {
+		query = fmt.Sprintf("kube_namespace_created{namespace=%q}", name)
+	}
+	samples, err := p.query(query)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := &corev1.NamespaceList{}
+	for _, s := range samples {
+		namespaces.Items = append(namespaces.Items, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: s.labels["namespace"]}})
+	}
+	return namespaces, nil
+}
+
+func (p *Prometheus) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	samples, err := p.query(inNamespace("kube_resourcequota", namespace))
+	if err != nil {
+		return nil, err
+	}
+	resourceQuotas := make(map[string]*corev1.ResourceQuota)
+	keys := make([]string, 0)
+	for _, s := range samples {
+		key := s.labels["namespace"] + "/" + s.labels["resourcequota"]
+		resourceQuota, ok := resourceQuotas[key]
+		if !ok {
+			resourceQuota = &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Namespace: s.labels["namespace"], Name: s.labels["resourcequota"]},
+				Spec:       corev1.ResourceQuotaSpec{Hard: make(corev1.ResourceList)},
+				Status:     corev1.ResourceQuotaStatus{Hard: make(corev1.ResourceList), Used: make(corev1.ResourceList)},
+			}
+			resourceQuotas[key] = resourceQuota
+			keys = append(keys, key)
+		}
+		// Quota series have no unit label, cpu is exported in cores and memory and storage in bytes
+		name := corev1.ResourceName(s.labels["resource"])
+		unit := "integer"
+		switch {
+		case strings.HasSuffix(string(name), "cpu"):
+			unit = "core"
+		case strings.HasSuffix(string(name), "memory") || strings.HasSuffix(string(name), "storage"):
+			unit = "byte"
+		}
+		switch s.labels["type"] {
+		case "hard":
+			resourceQuota.Spec.Hard[name] = quantity(s.value, unit)
+			resourceQuota.Status.Hard[name] = quantity(s.value, unit)
+		case "used":
+			resourceQuota.Status.Used[name] = quantity(s.value, unit)
+		}
+	}
+	resourceQuotaList := &corev1.ResourceQuotaList{}
+	for _, key := range keys {
+		resourceQuotaList.Items = append(resourceQuotaList.Items, *resourceQuotas[key])
+	}
+	return resourceQuotaList, nil
+}
+
+// kube-state-metrics exports the status of pod disruption budgets, but not the selectors their pods are matched with
+func (p *Prometheus) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	return nil, fmt.Errorf("pod disruption budgets are not supported with prometheus, kube-state-metrics does not export their selectors")
+}
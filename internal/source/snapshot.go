@@ -0,0 +1,174 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// Reads the objects from a json or yaml file saved with, for example,
+// kubectl get nodes,pods,namespaces --all-namespaces -o json > snapshot.json
+type Snapshot struct {
+	path string
+}
+
+func NewSnapshot(path string) *Snapshot {
+	return &Snapshot{path: path}
+}
+
+// A List of mixed kinds or a NodeList, PodList or NamespaceList, the items of typed lists have no kind
+type objectList struct {
+	Kind  string            `json:"kind"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// The objects of a snapshot or dump
+type objects struct {
+	nodes                *corev1.NodeList
+	pods                 *corev1.PodList
+	namespaces           *corev1.NamespaceList
+	resourceQuotas       *corev1.ResourceQuotaList
+	podDisruptionBudgets *policyv1beta1.PodDisruptionBudgetList
+}
+
+func newObjects() *objects {
+	return &objects{nodes: &corev1.NodeList{}, pods: &corev1.PodList{}, namespaces: &corev1.NamespaceList{}, resourceQuotas: &corev1.ResourceQuotaList{}, podDisruptionBudgets: &policyv1beta1.PodDisruptionBudgetList{}}
+}
+
+// The file is read on every call so watch mode picks up a replaced snapshot
+func (s *Snapshot) read() (*objects, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+	objects := newObjects()
+	if err := objects.add(data); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode snapshot %s", s.path)
+	}
+	return objects, nil
+}
+
+// Adds the nodes, pods, namespaces, resource quotas and pod disruption budgets of a json or yaml object or list, other
+// kinds are skipped
+func (o *objects) add(data []byte) error {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+	list := objectList{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	itemKind := ""
+	switch list.Kind {
+	case "List":
+	case "NodeList", "PodList", "NamespaceList", "ResourceQuotaList", "PodDisruptionBudgetList":
+		itemKind = list.Kind[:len(list.Kind)-len("List")]
+	default:
+		// A single object
+		list.Items = []json.RawMessage{data}
+	}
+	for _, item := range list.Items {
+		kind := itemKind
+		if kind == "" {
+			object := objectList{}
+			if err := json.Unmarshal(item, &object); err != nil {
+				return err
+			}
+			kind = object.Kind
+		}
+		switch kind {
+		case "Node":
+			node := corev1.Node{}
+			if err := json.Unmarshal(item, &node); err != nil {
+				return err
+			}
+			o.nodes.Items = append(o.nodes.Items, node)
+		case "Pod":
+			pod := corev1.Pod{}
+			if err := json.Unmarshal(item, &pod); err != nil {
+				return err
+			}
+			o.pods.Items = append(o.pods.Items, pod)
+		case "Namespace":
+			namespace := corev1.Namespace{}
+			if err := json.Unmarshal(item, &namespace); err != nil {
+				return err
+			}
+			o.namespaces.Items = append(o.namespaces.Items, namespace)
+		case "ResourceQuota":
+			resourceQuota := corev1.ResourceQuota{}
+			if err := json.Unmarshal(item, &resourceQuota); err != nil {
+				return err
+			}
+			o.resourceQuotas.Items = append(o.resourceQuotas.Items, resourceQuota)
+		case "PodDisruptionBudget":
+			// policy/v1 and policy/v1beta1 have the same fields
+			podDisruptionBudget := policyv1beta1.PodDisruptionBudget{}
+			if err := json.Unmarshal(item, &podDisruptionBudget); err != nil {
+				return err
+			}
+			o.podDisruptionBudgets.Items = append(o.podDisruptionBudgets.Items, podDisruptionBudget)
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) Nodes() (*corev1.NodeList, error) {
+	objects, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return objects.nodes, nil
+}
+
+func (s *Snapshot) Pods(namespace string) (*corev1.PodList, error) {
+	objects, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return filterPods(objects.pods, namespace), nil
+}
+
+func (s *Snapshot) Namespaces(name string) (*corev1.NamespaceList, error) {
+	objects, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return filterNamespaces(objects.namespaces, name), nil
+}
+
+func (s *Snapshot) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	objects, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return filterResourceQuotas(objects.resourceQuotas, namespace), nil
+}
+
+func (s *Snapshot) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	objects, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return filterPodDisruptionBudgets(objects.podDisruptionBudgets, namespace), nil
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"encoding/json"
+
+	"github.com/akrzos/kubeSize/internal/kube"
+	"github.com/akrzos/kubeSize/internal/logging"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The objects the capacity sub-commands aggregate, so every sub-command runs against a live cluster, a saved snapshot or
+// Prometheus with the same aggregation and output code
+type CapacitySource interface {
+	Nodes() (*corev1.NodeList, error)
+	// Pods of the namespace, of all namespaces when empty
+	Pods(namespace string) (*corev1.PodList, error)
+	// The named namespace, all namespaces when empty
+	Namespaces(name string) (*corev1.NamespaceList, error)
+	// Resource quotas of the namespace, of all namespaces when empty
+	ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error)
+	// Pod disruption budgets of the namespace, of all namespaces when empty
+	PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error)
+}
+
+// Bounds the streaming list of the nodes or pods, which ends as soon as the initial events are sent
+const watchListTimeoutSeconds = 300
+
+// Lists the objects from the API server
+type Live struct {
+	clientset kubernetes.Interface
+	// Streams the nodes and pods as watch events, cleared once the server rejects a streaming list
+	watchList bool
+	// Partial results, such as pods of namespaces that may not be listed, are logged as warnings
+	logger *logging.Logger
+	// Field selector of the pods listed, all pods when empty
+	podFieldSelector string
+}
+
+// With watchList the nodes and pods are streamed from servers that support it and listed from the others. A non-nil
+// podFieldSelector is sent to the server as is. Terminated pods are listed with or without it, like the other sources
+// list them, and are left out of requests by PodFilter.HoldsResources.
+func NewLive(clientset kubernetes.Interface, watchList bool, podFieldSelector fields.Selector, logger *logging.Logger) *Live {
+	live := &Live{
+		clientset: clientset,
+		watchList: watchList && kube.SupportsWatchList(clientset.Discovery()),
+		logger:    logger,
+	}
+	if podFieldSelector != nil {
+		live.podFieldSelector = podFieldSelector.String()
+	}
+	return live
+}
+
+// Streams the resource into the items of a list, false when the server does not support streaming lists so the
+// caller lists it instead
+func (l *Live) streamList(resource string, namespace string, fieldSelector string, add func(object []byte) error) bool {
+	if !l.watchList {
+		return false
+	}
+	if err := kube.WatchList(l.clientset.CoreV1().RESTClient(), resource, namespace, fieldSelector, watchListTimeoutSeconds, add); err != nil {
+		l.watchList = false
+		return false
+	}
+	return true
+}
+
+func (l *Live) Nodes() (*corev1.NodeList, error) {
+	streamed := &corev1.NodeList{}
+	if l.streamList("nodes", "", "", func(object []byte) error {
+		node := corev1.Node{}
+		if err := json.Unmarshal(object, &node); err != nil {
+			return err
+		}
+		streamed.Items = append(streamed.Items, node)
+		return nil
+	}) {
+		return streamed, nil
+	}
+	nodes, err := l.clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list nodes")
+	}
+	return nodes, nil
+}
+
+func (l *Live) Pods(namespace string) (*corev1.PodList, error) {
+	streamed := &corev1.PodList{}
+	if l.streamList("pods", namespace, l.podFieldSelector, func(object []byte) error {
+		pod := corev1.Pod{}
+		if err := json.Unmarshal(object, &pod); err != nil {
+			return err
+		}
+		streamed.Items = append(streamed.Items, pod)
+		return nil
+	}) {
+		return streamed, nil
+	}
+	pods, err := l.clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{FieldSelector: l.podFieldSelector})
+	if namespace == "" && apierrors.IsForbidden(err) {
+		return l.permittedPods()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pods")
+	}
+	return pods, nil
+}
+
+// Most namespaces named in the warning of partial pods
+const maxWarnedNamespaces = 5
+
+// Lists the pods of each namespace that may be listed when the pods of all namespaces may not, so restricted users
+// still get the figures of their namespaces. Without permission to list namespaces the pods are left out entirely.
+// Either way the figures derived from pods are partial, which is logged as a warning.
+func (l *Live) permittedPods() (*corev1.PodList, error) {
+	pods := &corev1.PodList{}
+	namespaces, err := l.clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		l.logger.Warning("not permitted to list pods or namespaces, pod counts, requests and limits are missing")
+		return pods, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list namespaces")
+	}
+	var skipped []string
+	for _, namespace := range namespaces.Items {
+		namespacePods, err := l.clientset.CoreV1().Pods(namespace.Name).List(metav1.ListOptions{FieldSelector: l.podFieldSelector})
+		if apierrors.IsForbidden(err) {
+			skipped = append(skipped, namespace.Name)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list pods")
+		}
+		pods.Items = append(pods.Items, namespacePods.Items...)
+	}
+	switch {
+	case len(skipped) == 0:
+	case len(skipped) == len(namespaces.Items):
+		l.logger.Warning("not permitted to list the pods of any namespace, pod counts, requests and limits are missing")
+	default:
+		names := skipped
+		if len(names) > maxWarnedNamespaces {
+			names = append(names[:maxWarnedNamespaces:maxWarnedNamespaces], "...")
+		}
+		l.logger.Warning("not permitted to list the pods of some namespaces, pod counts, requests and limits are partial",
+			"namespaces", names, "count", len(skipped), "total", len(namespaces.Items))
+	}
+	return pods, nil
+}
+
+func (l *Live) Namespaces(name string) (*corev1.NamespaceList, error) {
+	listOptions := metav1.ListOptions{}
+	if name != "" {
+		listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	}
+	namespaces, err := l.clientset.CoreV1().Namespaces().List(listOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list namespaces")
+	}
+	return namespaces, nil
+}
+
+func (l *Live) ResourceQuotas(namespace string) (*corev1.ResourceQuotaList, error) {
+	resourceQuotas, err := l.clientset.CoreV1().ResourceQuotas(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list resource quotas")
+	}
+	return resourceQuotas, nil
+}
+
+// Lists policy/v1 first, servers since 1.25 no longer serve policy/v1beta1. Both versions have the same fields, so
+// policy/v1 is decoded into the policy/v1beta1 types of the client.
+func (l *Live) PodDisruptionBudgets(namespace string) (*policyv1beta1.PodDisruptionBudgetList, error) {
+	path := []string{"/apis/policy/v1"}
+	if namespace != "" {
+		path = append(path, "namespaces", namespace)
+	}
+	data, err := l.clientset.PolicyV1beta1().RESTClient().Get().AbsPath(append(path, "poddisruptionbudgets")...).DoRaw()
+	if apierrors.IsNotFound(err) {
+		podDisruptionBudgets, err := l.clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list pod disruption budgets")
+		}
+		return podDisruptionBudgets, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pod disruption budgets")
+	}
+	podDisruptionBudgets := &policyv1beta1.PodDisruptionBudgetList{}
+	if err := json.Unmarshal(data, podDisruptionBudgets); err != nil {
+		return nil, errors.Wrap(err, "failed to decode pod disruption budgets")
+	}
+	return podDisruptionBudgets, nil
+}
+
+// Keeps the pods of the namespace, all pods when empty
+func filterPods(pods *corev1.PodList, namespace string) *corev1.PodList {
+	if namespace == "" {
+		return pods
+	}
+	filtered := &corev1.PodList{}
+	for _, pod := range pods.Items {
+		if pod.Namespace == namespace {
+			filtered.Items = append(filtered.Items, pod)
+		}
+	}
+	return filtered
+}
+
+// Keeps the named namespace, all namespaces when empty
+func filterNamespaces(namespaces *corev1.NamespaceList, name string) *corev1.NamespaceList {
+	if name == "" {
+		return namespaces
+	}
+	filtered := &corev1.NamespaceList{}
+	for _, namespace := range namespaces.Items {
+		if namespace.Name == name {
+			filtered.Items = append(filtered.Items, namespace)
+		}
+	}
+	return filtered
+}
+
+// Keeps the resource quotas of the namespace, all resource quotas when empty
+func filterResourceQuotas(resourceQuotas *corev1.ResourceQuotaList, namespace string) *corev1.ResourceQuotaList {
+	if namespace == "" {
+		return resourceQuotas
+	}
+	filtered := &corev1.ResourceQuotaList{}
+	for _, resourceQuota := range resourceQuotas.Items {
+		if resourceQuota.Namespace == namespace {
+			filtered.Items = append(filtered.Items, resourceQuota)
+		}
+	}
+	return filtered
+}
+
+// Keeps the pod disruption budgets of the namespace, all pod disruption budgets when empty
+func filterPodDisruptionBudgets(podDisruptionBudgets *policyv1beta1.PodDisruptionBudgetList, namespace string) *policyv1beta1.PodDisruptionBudgetList {
+	if namespace == "" {
+		return podDisruptionBudgets
+	}
+	filtered := &policyv1beta1.PodDisruptionBudgetList{}
+	for _, podDisruptionBudget := range podDisruptionBudgets.Items {
+		if podDisruptionBudget.Namespace == namespace {
+			filtered.Items = append(filtered.Items, podDisruptionBudget)
+		}
+	}
+	return filtered
+}
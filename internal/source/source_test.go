@@ -0,0 +1,61 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package source
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/akrzos/kubeSize/internal/logging"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// The pod field selector is sent as given, terminated pods are listed with and without one
+func TestLivePodFieldSelector(t *testing.T) {
+	for _, test := range []struct {
+		podFieldSelector fields.Selector
+		expected         string
+	}{
+		{podFieldSelector: nil, expected: ""},
+		{podFieldSelector: fields.OneTermEqualSelector("spec.nodeName", "worker-1"), expected: "spec.nodeName=worker-1"},
+	} {
+		clientset := fake.NewSimpleClientset(
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "running"}, Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "completed"}, Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+		)
+		sent := "unset"
+		clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			sent = action.(k8stesting.ListAction).GetListRestrictions().Fields.String()
+			return false, nil, nil
+		})
+		logger, _ := logging.New(&bytes.Buffer{}, logging.TextFormat, 0)
+		pods, err := NewLive(clientset, false, test.podFieldSelector, logger).Pods("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sent != test.expected {
+			t.Errorf("listed pods with field selector %q, expected %q", sent, test.expected)
+		}
+		if len(pods.Items) != 2 {
+			t.Errorf("listed %d pods, expected the running and the completed pod", len(pods.Items))
+		}
+	}
+}
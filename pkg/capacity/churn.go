@@ -0,0 +1,174 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pod churn of a namespace or node role
+type churn struct {
+	creations map[string]bool
+	deletions map[string]bool
+}
+
+// Reports the pods created and deleted within the window before now per namespace and node role, sorted by churn with
+// *total* last. Creations are the pods with a creation timestamp within the window and those created by controllers
+// (SuccessfulCreate events), deletions are the pods with a deletion timestamp within the window and those deleted by
+// controllers (SuccessfulDelete events) or whose containers were stopped (Killing events). Events only cover the event
+// TTL of the API server, one hour by default, so pods gone before then are not counted. Pods count towards the roles of
+// the node they were assigned to, pods whose node is not known to *unassigned*.
+func PodChurn(nodes *corev1.NodeList, pods *corev1.PodList, events *corev1.EventList, window time.Duration, now time.Time, pinnedRoles []string, noneLast bool) output.ChurnData {
+	since := now.Add(-window)
+	created, deleted := make(map[string]bool), make(map[string]bool)
+	podNodes := make(map[string]string)
+	for _, pod := range pods.Items {
+		key := pod.Namespace + "/" + pod.Name
+		podNodes[key] = pod.Spec.NodeName
+		if pod.CreationTimestamp.Time.After(since) {
+			created[key] = true
+		}
+		if pod.DeletionTimestamp != nil && pod.DeletionTimestamp.Time.After(since) {
+			deleted[key] = true
+		}
+	}
+	eventNodes := make(map[string]string)
+	for _, event := range events.Items {
+		if !eventTime(event).After(since) {
+			continue
+		}
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		switch {
+		case event.InvolvedObject.Kind == "Pod" && event.Reason == "Scheduled":
+			// Successfully assigned <namespace>/<name> to <node>
+			if i := strings.LastIndex(event.Message, " to "); i >= 0 {
+				eventNodes[key] = event.Message[i+len(" to "):]
+			}
+		case event.InvolvedObject.Kind == "Pod" && event.Reason == "Killing" && strings.HasPrefix(event.Message, "Stopping container"):
+			deleted[key] = true
+			if _, ok := eventNodes[key]; !ok && event.Source.Host != "" {
+				eventNodes[key] = event.Source.Host
+			}
+		case event.Reason == "SuccessfulCreate" && strings.HasPrefix(event.Message, "Created pod: "):
+			created[event.Namespace+"/"+strings.TrimPrefix(event.Message, "Created pod: ")] = true
+		case event.Reason == "SuccessfulDelete" && strings.HasPrefix(event.Message, "Deleted pod: "):
+			deleted[event.Namespace+"/"+strings.TrimPrefix(event.Message, "Deleted pod: ")] = true
+		}
+	}
+
+	nodeRoles := make(map[string][]string)
+	roleChurn := map[string]*churn{"*total*": newChurn()}
+	for _, node := range nodes.Items {
+		nodeRoles[node.Name] = NodeRoles(node).List()
+		for _, role := range nodeRoles[node.Name] {
+			roleChurn[role] = newChurn()
+		}
+	}
+	namespaceChurn := map[string]*churn{"*total*": roleChurn["*total*"]}
+	groups := func(key string) []*churn {
+		namespace := strings.SplitN(key, "/", 2)[0]
+		if _, ok := namespaceChurn[namespace]; !ok {
+			namespaceChurn[namespace] = newChurn()
+		}
+		nodeName := podNodes[key]
+		if nodeName == "" {
+			nodeName = eventNodes[key]
+		}
+		roles, ok := nodeRoles[nodeName]
+		if !ok {
+			roles = []string{"*unassigned*"}
+			if _, ok := roleChurn["*unassigned*"]; !ok {
+				roleChurn["*unassigned*"] = newChurn()
+			}
+		}
+		groups := []*churn{namespaceChurn[namespace], roleChurn["*total*"]}
+		for _, role := range roles {
+			groups = append(groups, roleChurn[role])
+		}
+		return groups
+	}
+	for key := range created {
+		for _, churn := range groups(key) {
+			churn.creations[key] = true
+		}
+	}
+	for key := range deleted {
+		for _, churn := range groups(key) {
+			churn.deletions[key] = true
+		}
+	}
+
+	churnData := output.ChurnData{Window: window.String(), Namespaces: make([]output.GroupChurnData, 0, len(namespaceChurn))}
+	for namespace, churn := range namespaceChurn {
+		churnData.Namespaces = append(churnData.Namespaces, groupChurnData(namespace, churn, window))
+	}
+	sort.Slice(churnData.Namespaces, func(i, j int) bool {
+		iData, jData := churnData.Namespaces[i], churnData.Namespaces[j]
+		if iPseudo, jPseudo := strings.HasPrefix(iData.Name, "*"), strings.HasPrefix(jData.Name, "*"); iPseudo != jPseudo {
+			return jPseudo
+		}
+		if iData.Creations+iData.Deletions != jData.Creations+jData.Deletions {
+			return iData.Creations+iData.Deletions > jData.Creations+jData.Deletions
+		}
+		return iData.Name < jData.Name
+	})
+	roleNames := make([]string, 0, len(roleChurn))
+	for role := range roleChurn {
+		if role != "*total*" {
+			roleNames = append(roleNames, role)
+		}
+	}
+	sort.Strings(roleNames)
+	capacity.SortRoleNames(roleNames, pinnedRoles, noneLast)
+	roleNames = append(roleNames, "*total*")
+	churnData.NodeRoles = make([]output.GroupChurnData, 0, len(roleNames))
+	for _, role := range roleNames {
+		churnData.NodeRoles = append(churnData.NodeRoles, groupChurnData(role, roleChurn[role], window))
+	}
+	return churnData
+}
+
+func newChurn() *churn {
+	return &churn{creations: make(map[string]bool), deletions: make(map[string]bool)}
+}
+
+// Rates are per minute of the window
+func groupChurnData(name string, churn *churn, window time.Duration) output.GroupChurnData {
+	return output.GroupChurnData{
+		Name:               name,
+		Creations:          len(churn.creations),
+		Deletions:          len(churn.deletions),
+		CreationsPerMinute: float64(len(churn.creations)) / window.Minutes(),
+		DeletionsPerMinute: float64(len(churn.deletions)) / window.Minutes(),
+	}
+}
+
+// Aggregated events carry the time of their last occurrence, events of the events.k8s.io API only an event time
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.FirstTimestamp.Time
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Aggregates the capacity data of all nodes and pods
+func ClusterCapacity(nodes *corev1.NodeList, pods *corev1.PodList, filter PodFilter) *output.ClusterCapacityData {
+	clusterCapacityData := new(output.ClusterCapacityData)
+
+	for _, node := range nodes.Items {
+		clusterCapacityData.TotalNodeCount++
+		for _, condition := range node.Status.Conditions {
+			if (condition.Type == "Ready") && condition.Status == corev1.ConditionTrue {
+				clusterCapacityData.TotalReadyNodeCount++
+			}
+		}
+		if node.Spec.Unschedulable {
+			clusterCapacityData.TotalUnschedulableNodeCount++
+		}
+		clusterCapacityData.TotalCapacityPods.Add(*node.Status.Capacity.Pods())
+		clusterCapacityData.TotalCapacityCPU.Add(*node.Status.Capacity.Cpu())
+		clusterCapacityData.TotalCapacityMemory.Add(*node.Status.Capacity.Memory())
+		clusterCapacityData.TotalCapacityEphemeralStorage.Add(*node.Status.Capacity.StorageEphemeral())
+		clusterCapacityData.TotalAllocatablePods.Add(*node.Status.Allocatable.Pods())
+		clusterCapacityData.TotalAllocatableCPU.Add(*node.Status.Allocatable.Cpu())
+		clusterCapacityData.TotalAllocatableMemory.Add(*node.Status.Allocatable.Memory())
+		clusterCapacityData.TotalAllocatableEphemeralStorage.Add(*node.Status.Allocatable.StorageEphemeral())
+	}
+	clusterCapacityData.TotalUnreadyNodeCount = clusterCapacityData.TotalNodeCount - clusterCapacityData.TotalReadyNodeCount
+
+	// Note you can have non-terminated pod not assigned to a node (Ex Pending) thus cluster vs node/node-role counts can differ
+	for _, pod := range pods.Items {
+		clusterCapacityData.TotalPodCount++
+		if IsTerminating(pod) {
+			clusterCapacityData.TotalTerminatingPodCount++
+		}
+		if IsStatic(pod) {
+			requestsCPU, requestsMemory := PodRequests(pod)
+			clusterCapacityData.TotalStaticPodCount++
+			clusterCapacityData.TotalStaticRequestsCPU.Add(requestsCPU)
+			clusterCapacityData.TotalStaticRequestsMemory.Add(requestsMemory)
+		}
+		if !filter.HoldsResources(pod) {
+			continue
+		}
+		clusterCapacityData.TotalNonTermPodCount++
+		if IsBestEffort(pod) {
+			clusterCapacityData.TotalBestEffortPodCount++
+		}
+		for _, container := range pod.Spec.Containers {
+			clusterCapacityData.TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
+			clusterCapacityData.TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
+			clusterCapacityData.TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
+			clusterCapacityData.TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
+			clusterCapacityData.TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
+			clusterCapacityData.TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
+		}
+	}
+
+	// Populate derived capacity data values
+	clusterCapacityData.TotalAvailablePods = int(clusterCapacityData.TotalAllocatablePods.Value()) - clusterCapacityData.TotalNonTermPodCount
+	clusterCapacityData.TotalAvailableCPU = clusterCapacityData.TotalAllocatableCPU
+	clusterCapacityData.TotalAvailableCPU.Sub(clusterCapacityData.TotalRequestsCPU)
+	clusterCapacityData.TotalAvailableMemory = clusterCapacityData.TotalAllocatableMemory
+	clusterCapacityData.TotalAvailableMemory.Sub(clusterCapacityData.TotalRequestsMemory)
+	clusterCapacityData.TotalAvailableEphemeralStorage = clusterCapacityData.TotalAllocatableEphemeralStorage
+	clusterCapacityData.TotalAvailableEphemeralStorage.Sub(clusterCapacityData.TotalRequestsEphemeralStorage)
+
+	// Populate "Human" readable capacity data values
+	clusterCapacityData.TotalCapacityCPUCores = capacity.ReadableCPU(clusterCapacityData.TotalCapacityCPU)
+	clusterCapacityData.TotalCapacityMemoryGiB = capacity.ReadableMem(clusterCapacityData.TotalCapacityMemory)
+	clusterCapacityData.TotalCapacityEphemeralStorageGB = capacity.ReadableStorage(clusterCapacityData.TotalCapacityEphemeralStorage)
+	clusterCapacityData.TotalAllocatableCPUCores = capacity.ReadableCPU(clusterCapacityData.TotalAllocatableCPU)
+	clusterCapacityData.TotalAllocatableMemoryGiB = capacity.ReadableMem(clusterCapacityData.TotalAllocatableMemory)
+	clusterCapacityData.TotalAllocatableEphemeralStorageGB = capacity.ReadableStorage(clusterCapacityData.TotalAllocatableEphemeralStorage)
+	clusterCapacityData.TotalAvailableCPUCores = capacity.ReadableCPU(clusterCapacityData.TotalAvailableCPU)
+	clusterCapacityData.TotalAvailableMemoryGiB = capacity.ReadableMem(clusterCapacityData.TotalAvailableMemory)
+	clusterCapacityData.TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(clusterCapacityData.TotalAvailableEphemeralStorage)
+	clusterCapacityData.TotalRequestsCPUCores = capacity.ReadableCPU(clusterCapacityData.TotalRequestsCPU)
+	clusterCapacityData.TotalLimitsCPUCores = capacity.ReadableCPU(clusterCapacityData.TotalLimitsCPU)
+	clusterCapacityData.TotalRequestsMemoryGiB = capacity.ReadableMem(clusterCapacityData.TotalRequestsMemory)
+	clusterCapacityData.TotalLimitsMemoryGiB = capacity.ReadableMem(clusterCapacityData.TotalLimitsMemory)
+	clusterCapacityData.TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(clusterCapacityData.TotalRequestsEphemeralStorage)
+	clusterCapacityData.TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(clusterCapacityData.TotalLimitsEphemeralStorage)
+	clusterCapacityData.TotalStaticRequestsCPUCores = capacity.ReadableCPU(clusterCapacityData.TotalStaticRequestsCPU)
+	clusterCapacityData.TotalStaticRequestsMemoryGiB = capacity.ReadableMem(clusterCapacityData.TotalStaticRequestsMemory)
+
+	return clusterCapacityData
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package capacity aggregates the capacity data of a Kubernetes cluster, grouped by node role, node or namespace, so Go
+// programs can reuse the calculations of the kubectl capacity plugin without shelling out.
+package capacity
+
+import (
+	"context"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The capacity data types are aliases of the types the plugin displays, so they can be named outside of this module
+type (
+	ClusterCapacityData   = output.ClusterCapacityData
+	RequestsMinMaxData    = output.RequestsMinMaxData
+	NodeCapacityData      = output.NodeCapacityData
+	PodCapacityData       = output.PodCapacityData
+	NamespaceCapacityData = output.NamespaceCapacityData
+)
+
+// Lists the nodes and pods of the cluster. The vendored client-go does not take a context, it is checked before each
+// request instead.
+func listNodesAndPods(ctx context.Context, client kubernetes.Interface) (*corev1.NodeList, *corev1.PodList, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list nodes")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	pods, err := client.CoreV1().Pods("").List(metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list pods")
+	}
+	return nodes, pods, nil
+}
+
+// Collects the capacity data of the whole cluster
+func CollectCluster(ctx context.Context, client kubernetes.Interface, filter PodFilter) (ClusterCapacityData, error) {
+	nodes, pods, err := listNodesAndPods(ctx, client)
+	if err != nil {
+		return ClusterCapacityData{}, err
+	}
+	return *ClusterCapacity(nodes, pods, filter), nil
+}
+
+// Collects the capacity data of each node role, returns the data and the sorted role names. The *unassigned*, *total*
+// and *average* pseudo roles are included on request.
+func CollectByNodeRole(ctx context.Context, client kubernetes.Interface, includeUnassigned bool, includeTotal bool, includeAverage bool, filter PodFilter) (map[string]*ClusterCapacityData, []string, error) {
+	nodes, pods, err := listNodesAndPods(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodeRoleCapacityData, roleNames := NodeRoleCapacity(nodes, pods, includeUnassigned, includeTotal, includeAverage, filter)
+	return nodeRoleCapacityData, roleNames, nil
+}
+
+// Collects the capacity data of each node, returns the data and the sorted node names. The *unassigned*, *total* and
+// *average* pseudo nodes are included on request.
+func CollectByNode(ctx context.Context, client kubernetes.Interface, includeUnassigned bool, includeTotal bool, includeAverage bool, filter PodFilter) (map[string]*NodeCapacityData, []string, error) {
+	nodes, pods, err := listNodesAndPods(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodesCapacityData, nodeNames, _ := NodeCapacity(nodes, pods, includeUnassigned, includeTotal, includeAverage, filter)
+	return nodesCapacityData, nodeNames, nil
+}
+
+// Collects the pod capacity data of each namespace, returns the data and the sorted namespace names. The *total*
+// pseudo namespace is included on request.
+func CollectByNamespace(ctx context.Context, client kubernetes.Interface, includeTotal bool, filter PodFilter) (map[string]*NamespaceCapacityData, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	namespaces, err := client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list namespaces")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	pods, err := client.CoreV1().Pods("").List(metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list pods")
+	}
+
+	namespaceCapacityData, namespaceNames := NamespaceCapacity(namespaces, pods, includeTotal, filter)
+	return namespaceCapacityData, namespaceNames, nil
+}
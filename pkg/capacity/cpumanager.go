@@ -0,0 +1,123 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// CPU manager policy of a kubelet that pins cores to containers, the default policy "none" shares every core
+const StaticCPUManagerPolicy = "static"
+
+// Guaranteed pods have cpu and memory limits in every container equal to their requests, which default to the limits
+func IsGuaranteed(pod corev1.Pod) bool {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass == corev1.PodQOSGuaranteed
+	}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			limit, ok := container.Resources.Limits[resourceName]
+			if !ok || limit.IsZero() {
+				return false
+			}
+			if request, ok := container.Resources.Requests[resourceName]; ok && request.Cmp(limit) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Splits the allocatable cpu of each node into the cores pinned to containers and the shared pool left to every other
+// container, by the CPU manager policy of its kubelet from policies. Only nodes with the static policy pin cores, the
+// shared requests are the cpu requests of the containers without pinned cores, and the shared pool headroom is what
+// burstable and best effort pods actually compete for. The *total* sums the nodes.
+func CPUManagerCapacity(nodes *corev1.NodeList, pods *corev1.PodList, policies map[string]string, filter PodFilter) output.CPUManagerData {
+	nodeCPU := make(map[string]*cpuManagerNode, len(nodes.Items))
+	for _, node := range nodes.Items {
+		nodeCPU[node.Name] = &cpuManagerNode{static: policies[node.Name] == StaticCPUManagerPolicy}
+	}
+	for _, pod := range pods.Items {
+		cpu, ok := nodeCPU[pod.Spec.NodeName]
+		if !ok || !filter.HoldsResources(pod) {
+			continue
+		}
+		// The static policy pins the cores of the containers of Guaranteed pods with integral cpu
+		pinned := cpu.static && IsGuaranteed(pod)
+		exclusive := false
+		for _, container := range pod.Spec.Containers {
+			if limit := container.Resources.Limits.Cpu(); pinned && limit.MilliValue()%1000 == 0 {
+				cpu.exclusive.Add(*limit)
+				exclusive = true
+			} else {
+				cpu.sharedRequests.Add(*container.Resources.Requests.Cpu())
+			}
+		}
+		if exclusive {
+			cpu.exclusivePods++
+		}
+	}
+
+	cpuManagerData := output.CPUManagerData{Nodes: make([]output.NodeCPUManagerData, 0, len(nodes.Items))}
+	var total cpuManagerNode
+	var totalAllocatable resource.Quantity
+	for _, node := range nodes.Items {
+		cpu := nodeCPU[node.Name]
+		policy := policies[node.Name]
+		if cpu.static {
+			cpuManagerData.StaticNodes++
+		}
+		cpuManagerData.Nodes = append(cpuManagerData.Nodes, cpuManagerNodeData(node.Name, NodeRoles(node).List(), policy, *node.Status.Allocatable.Cpu(), cpu))
+		totalAllocatable.Add(*node.Status.Allocatable.Cpu())
+		total.exclusive.Add(cpu.exclusive)
+		total.sharedRequests.Add(cpu.sharedRequests)
+		total.exclusivePods += cpu.exclusivePods
+	}
+	sort.Slice(cpuManagerData.Nodes, func(i, j int) bool { return cpuManagerData.Nodes[i].Node < cpuManagerData.Nodes[j].Node })
+	cpuManagerData.Total = cpuManagerNodeData("*total*", nil, "", totalAllocatable, &total)
+	return cpuManagerData
+}
+
+type cpuManagerNode struct {
+	static         bool
+	exclusive      resource.Quantity
+	exclusivePods  int
+	sharedRequests resource.Quantity
+}
+
+func cpuManagerNodeData(name string, roles []string, policy string, allocatable resource.Quantity, cpu *cpuManagerNode) output.NodeCPUManagerData {
+	sharedPool := allocatable.DeepCopy()
+	sharedPool.Sub(cpu.exclusive)
+	sharedAvailable := sharedPool.DeepCopy()
+	sharedAvailable.Sub(cpu.sharedRequests)
+	return output.NodeCPUManagerData{
+		Node:                    name,
+		Roles:                   roles,
+		Policy:                  policy,
+		AllocatableCPUCores:     capacity.ReadableCPU(allocatable),
+		ExclusiveCPUCores:       capacity.ReadableCPU(cpu.exclusive),
+		ExclusivePods:           cpu.exclusivePods,
+		SharedPoolCPUCores:      capacity.ReadableCPU(sharedPool),
+		SharedRequestsCPUCores:  capacity.ReadableCPU(cpu.sharedRequests),
+		SharedAvailableCPUCores: capacity.ReadableCPU(sharedAvailable),
+		SharedRequestsPercent:   capacity.Percent(cpu.sharedRequests, sharedPool),
+	}
+}
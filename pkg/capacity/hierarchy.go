@@ -0,0 +1,148 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// The Hierarchical Namespace Controller (HNC) labels each namespace of a hierarchy with
+// <ancestor>.tree.hnc.x-k8s.io/depth for itself and each of its ancestors, so the tree of the HierarchyConfigurations
+// is known from the namespaces alone
+const hncTreeLabelSuffix = ".tree.hnc.x-k8s.io/depth"
+
+// Whether any namespace is part of an HNC hierarchy
+func HasNamespaceHierarchy(namespaces *corev1.NamespaceList) bool {
+	for _, namespace := range namespaces.Items {
+		if len(namespaceAncestors(namespace)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Ancestors of a namespace by their depth above it, the namespace itself is at depth 0
+func namespaceAncestors(namespace corev1.Namespace) map[string]int {
+	ancestors := make(map[string]int)
+	for key, value := range namespace.Labels {
+		if !strings.HasSuffix(key, hncTreeLabelSuffix) {
+			continue
+		}
+		depth, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		ancestors[strings.TrimSuffix(key, hncTreeLabelSuffix)] = depth
+	}
+	return ancestors
+}
+
+// Rolls up the capacity data and the cpu and memory requests quota of each namespace into each of its HNC ancestors,
+// so every namespace holds the totals of its subtree. The namespaces are returned in tree order, each parent followed
+// by its children sorted by name.
+func NamespaceHierarchyCapacity(namespaces *corev1.NamespaceList, namespaceCapacityData map[string]*output.NamespaceCapacityData, resourceQuotas *corev1.ResourceQuotaList) []output.NamespaceTreeData {
+	treeData := make(map[string]*output.NamespaceTreeData, len(namespaces.Items))
+	ancestorsByNamespace := make(map[string]map[string]int, len(namespaces.Items))
+	children := make(map[string][]string)
+	roots := make([]string, 0)
+	for _, namespace := range namespaces.Items {
+		ancestors := namespaceAncestors(namespace)
+		ancestors[namespace.Name] = 0
+		ancestorsByNamespace[namespace.Name] = ancestors
+		data := &output.NamespaceTreeData{Namespace: namespace.Name, Capacity: new(output.NamespaceCapacityData)}
+		for ancestor, depth := range ancestors {
+			if depth == 1 {
+				data.Parent = ancestor
+			}
+			if depth > data.Depth {
+				data.Depth = depth
+			}
+		}
+		treeData[namespace.Name] = data
+	}
+	for _, namespace := range namespaces.Items {
+		parent := treeData[namespace.Name].Parent
+		if _, ok := treeData[parent]; ok {
+			children[parent] = append(children[parent], namespace.Name)
+		} else {
+			roots = append(roots, namespace.Name)
+		}
+	}
+
+	for _, namespace := range namespaces.Items {
+		data, ok := namespaceCapacityData[namespace.Name]
+		if !ok {
+			continue
+		}
+		for ancestor := range ancestorsByNamespace[namespace.Name] {
+			if subtree, ok := treeData[ancestor]; ok {
+				addNamespaceCapacity(subtree.Capacity, data)
+			}
+		}
+	}
+
+	for _, resourceQuota := range resourceQuotas.Items {
+		usedCPU, hardCPU := quotaRequests(resourceQuota, corev1.ResourceRequestsCPU, corev1.ResourceCPU)
+		usedMemory, hardMemory := quotaRequests(resourceQuota, corev1.ResourceRequestsMemory, corev1.ResourceMemory)
+		for ancestor := range ancestorsByNamespace[resourceQuota.Namespace] {
+			if subtree, ok := treeData[ancestor]; ok {
+				subtree.QuotaUsedCPU.Add(usedCPU)
+				subtree.QuotaHardCPU.Add(hardCPU)
+				subtree.QuotaUsedMemory.Add(usedMemory)
+				subtree.QuotaHardMemory.Add(hardMemory)
+			}
+		}
+	}
+
+	for _, data := range treeData {
+		data.QuotaUsedCPUCores = capacity.ReadableCPU(data.QuotaUsedCPU)
+		data.QuotaHardCPUCores = capacity.ReadableCPU(data.QuotaHardCPU)
+		data.QuotaUsedMemoryGiB = capacity.ReadableMem(data.QuotaUsedMemory)
+		data.QuotaHardMemoryGiB = capacity.ReadableMem(data.QuotaHardMemory)
+	}
+
+	ordered := make([]output.NamespaceTreeData, 0, len(treeData))
+	var visit func(names []string)
+	visit = func(names []string) {
+		sort.Strings(names)
+		for _, name := range names {
+			ordered = append(ordered, *treeData[name])
+			visit(children[name])
+		}
+	}
+	visit(roots)
+	return ordered
+}
+
+// Used and hard requests of a quota, quotas limit requests as requests.<resource> or as the bare resource name
+func quotaRequests(resourceQuota corev1.ResourceQuota, names ...corev1.ResourceName) (used, hard resource.Quantity) {
+	for _, name := range names {
+		if hardQuantity, ok := resourceQuota.Status.Hard[name]; ok {
+			return resourceQuota.Status.Used[name], hardQuantity
+		}
+		if hardQuantity, ok := resourceQuota.Spec.Hard[name]; ok {
+			return resourceQuota.Status.Used[name], hardQuantity
+		}
+	}
+	return used, hard
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pods of the AWS VPC CNI per instance type without prefix delegation, each ENI but the first of its IPs assigns pod
+// IPs: ENIs * (IPs per ENI - 1) + 2 for the host network pods
+var ENIMaxPods = map[string]int64{
+	"t3.nano": 4, "t3.micro": 4, "t3.small": 11, "t3.medium": 17, "t3.large": 35, "t3.xlarge": 58, "t3.2xlarge": 58,
+	"m5.large": 29, "m5.xlarge": 58, "m5.2xlarge": 58, "m5.4xlarge": 234, "m5.8xlarge": 234, "m5.12xlarge": 234, "m5.16xlarge": 737, "m5.24xlarge": 737,
+	"c5.large": 29, "c5.xlarge": 58, "c5.2xlarge": 58, "c5.4xlarge": 234, "c5.9xlarge": 234, "c5.12xlarge": 234, "c5.18xlarge": 737, "c5.24xlarge": 737,
+	"r5.large": 29, "r5.xlarge": 58, "r5.2xlarge": 58, "r5.4xlarge": 234, "r5.8xlarge": 234, "r5.12xlarge": 234, "r5.16xlarge": 737, "r5.24xlarge": 737,
+}
+
+// Compares the pod capacity (kubelet max-pods) of each node against the pod IPs its network can assign, the usable
+// addresses of its IPv4 pod CIDR and, on AWS, the ENI limit of its instance type from eniMaxPods. Nodes whose max-pods
+// exceed either limit are flagged, pods scheduled past the limit never get an IP and are stuck in ContainerCreating.
+func NetworkMaxPods(nodes *corev1.NodeList, eniMaxPods map[string]int64) output.MaxPodsData {
+	maxPodsData := output.MaxPodsData{Nodes: make([]output.NodeMaxPodsData, 0, len(nodes.Items))}
+	for _, node := range nodes.Items {
+		nodeData := output.NodeMaxPodsData{
+			Node:         node.Name,
+			InstanceType: node.Labels["node.kubernetes.io/instance-type"],
+			MaxPods:      node.Status.Capacity.Pods().Value(),
+			PodCIDR:      node.Spec.PodCIDR,
+			Reasons:      make([]string, 0),
+		}
+		if nodeData.InstanceType == "" {
+			nodeData.InstanceType = node.Labels["beta.kubernetes.io/instance-type"]
+		}
+		nodeData.CIDRMaxPods = podCIDRMaxPods(node.Spec.PodCIDR)
+		if strings.HasPrefix(node.Spec.ProviderID, "aws://") {
+			nodeData.ENIMaxPods = eniMaxPods[nodeData.InstanceType]
+		}
+		if nodeData.CIDRMaxPods > 0 && nodeData.MaxPods > nodeData.CIDRMaxPods {
+			nodeData.Reasons = append(nodeData.Reasons, fmt.Sprintf("max-pods %d exceeds the %d addresses of pod CIDR %s", nodeData.MaxPods, nodeData.CIDRMaxPods, nodeData.PodCIDR))
+		}
+		if nodeData.ENIMaxPods > 0 && nodeData.MaxPods > nodeData.ENIMaxPods {
+			nodeData.Reasons = append(nodeData.Reasons, fmt.Sprintf("max-pods %d exceeds the ENI limit %d of %s", nodeData.MaxPods, nodeData.ENIMaxPods, nodeData.InstanceType))
+		}
+		if len(nodeData.Reasons) > 0 {
+			maxPodsData.Discrepancies++
+		}
+		maxPodsData.Nodes = append(maxPodsData.Nodes, nodeData)
+	}
+	sort.Slice(maxPodsData.Nodes, func(i, j int) bool { return maxPodsData.Nodes[i].Node < maxPodsData.Nodes[j].Node })
+	return maxPodsData
+}
+
+// Usable addresses of an IPv4 pod CIDR without its network and broadcast addresses, 0 when unset or IPv6, whose
+// ranges are never the limit
+func podCIDRMaxPods(podCIDR string) int64 {
+	_, network, err := net.ParseCIDR(podCIDR)
+	if err != nil || network.IP.To4() == nil {
+		return 0
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones < 2 {
+		return 0
+	}
+	return int64(1)<<uint(bits-ones) - 2
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Aggregates pod capacity data per namespace, returns the data and the sorted namespace names to display
+func NamespaceCapacity(namespaces *corev1.NamespaceList, pods *corev1.PodList, includeTotal bool, filter PodFilter) (map[string]*output.NamespaceCapacityData, []string) {
+	namespaceCapacityData := make(map[string]*output.NamespaceCapacityData)
+	namespaceNames := make([]string, 0, len(namespaces.Items))
+
+	for _, namespace := range namespaces.Items {
+		namespaceNames = append(namespaceNames, namespace.Name)
+		namespaceCapacityData[namespace.Name] = new(output.NamespaceCapacityData)
+	}
+
+	for _, pod := range pods.Items {
+		if !capacity.StringInSlice(pod.Namespace, namespaceNames) {
+			namespaceNames = append(namespaceNames, pod.Namespace)
+			namespaceCapacityData[pod.Namespace] = new(output.NamespaceCapacityData)
+		}
+		if pod.Spec.NodeName == "" {
+			namespaceCapacityData[pod.Namespace].TotalUnassignedNodePodCount++
+		}
+		namespaceCapacityData[pod.Namespace].TotalPodCount++
+		if IsTerminating(pod) {
+			namespaceCapacityData[pod.Namespace].TotalTerminatingPodCount++
+		}
+		if IsStatic(pod) {
+			requestsCPU, requestsMemory := PodRequests(pod)
+			namespaceCapacityData[pod.Namespace].TotalStaticPodCount++
+			namespaceCapacityData[pod.Namespace].TotalStaticRequestsCPU.Add(requestsCPU)
+			namespaceCapacityData[pod.Namespace].TotalStaticRequestsMemory.Add(requestsMemory)
+		}
+		if filter.HoldsResources(pod) {
+			namespaceCapacityData[pod.Namespace].TotalNonTermPodCount++
+			if IsBestEffort(pod) {
+				namespaceCapacityData[pod.Namespace].TotalBestEffortPodCount++
+			}
+			for _, container := range pod.Spec.Containers {
+				namespaceCapacityData[pod.Namespace].TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
+				namespaceCapacityData[pod.Namespace].TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
+				namespaceCapacityData[pod.Namespace].TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
+				namespaceCapacityData[pod.Namespace].TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
+				namespaceCapacityData[pod.Namespace].TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
+				namespaceCapacityData[pod.Namespace].TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
+			}
+		}
+	}
+
+	namespaceCapacityData["*total*"] = new(output.NamespaceCapacityData)
+
+	// Populate "Human" readable capacity data values and the *total* "namespace"
+	for _, namespace := range namespaceNames {
+		namespaceCapacityData[namespace].TotalRequestsCPUCores = capacity.ReadableCPU(namespaceCapacityData[namespace].TotalRequestsCPU)
+		namespaceCapacityData[namespace].TotalLimitsCPUCores = capacity.ReadableCPU(namespaceCapacityData[namespace].TotalLimitsCPU)
+		namespaceCapacityData[namespace].TotalRequestsMemoryGiB = capacity.ReadableMem(namespaceCapacityData[namespace].TotalRequestsMemory)
+		namespaceCapacityData[namespace].TotalLimitsMemoryGiB = capacity.ReadableMem(namespaceCapacityData[namespace].TotalLimitsMemory)
+		namespaceCapacityData[namespace].TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(namespaceCapacityData[namespace].TotalRequestsEphemeralStorage)
+		namespaceCapacityData[namespace].TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(namespaceCapacityData[namespace].TotalLimitsEphemeralStorage)
+		namespaceCapacityData[namespace].TotalStaticRequestsCPUCores = capacity.ReadableCPU(namespaceCapacityData[namespace].TotalStaticRequestsCPU)
+		namespaceCapacityData[namespace].TotalStaticRequestsMemoryGiB = capacity.ReadableMem(namespaceCapacityData[namespace].TotalStaticRequestsMemory)
+		addNamespaceCapacity(namespaceCapacityData["*total*"], namespaceCapacityData[namespace])
+	}
+
+	sort.Strings(namespaceNames)
+
+	if includeTotal {
+		namespaceNames = append(namespaceNames, "*total*")
+	}
+
+	return namespaceCapacityData, namespaceNames
+}
+
+// Rolls up the capacity data of the namespaces by the value of a namespace label, such as team or tenant, namespaces
+// without the label are grouped as <none>. Returns the data and the sorted label values to display.
+func NamespaceLabelCapacity(namespaces *corev1.NamespaceList, namespaceCapacityData map[string]*output.NamespaceCapacityData, label string, includeTotal bool) (map[string]*output.NamespaceCapacityData, []string) {
+	namespaceLabels := make(map[string]map[string]string, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		namespaceLabels[namespace.Name] = namespace.Labels
+	}
+
+	groupCapacityData := make(map[string]*output.NamespaceCapacityData)
+	groupNames := make([]string, 0)
+	for namespace, data := range namespaceCapacityData {
+		if namespace == "*total*" {
+			continue
+		}
+		group, ok := namespaceLabels[namespace][label]
+		if !ok {
+			group = "<none>"
+		}
+		if _, ok := groupCapacityData[group]; !ok {
+			groupNames = append(groupNames, group)
+			groupCapacityData[group] = new(output.NamespaceCapacityData)
+		}
+		addNamespaceCapacity(groupCapacityData[group], data)
+	}
+
+	sort.Strings(groupNames)
+
+	if total, ok := namespaceCapacityData["*total*"]; ok {
+		groupCapacityData["*total*"] = total
+		if includeTotal {
+			groupNames = append(groupNames, "*total*")
+		}
+	}
+
+	return groupCapacityData, groupNames
+}
+
+// Adds the capacity data of a namespace to a sum of namespaces
+func addNamespaceCapacity(sum *output.NamespaceCapacityData, data *output.NamespaceCapacityData) {
+	sum.TotalPodCount += data.TotalPodCount
+	sum.TotalNonTermPodCount += data.TotalNonTermPodCount
+	sum.TotalUnassignedNodePodCount += data.TotalUnassignedNodePodCount
+	sum.TotalTerminatingPodCount += data.TotalTerminatingPodCount
+	sum.TotalStaticPodCount += data.TotalStaticPodCount
+	sum.TotalBestEffortPodCount += data.TotalBestEffortPodCount
+	sum.TotalStaticRequestsCPU.Add(data.TotalStaticRequestsCPU)
+	sum.TotalStaticRequestsCPUCores += data.TotalStaticRequestsCPUCores
+	sum.TotalStaticRequestsMemory.Add(data.TotalStaticRequestsMemory)
+	sum.TotalStaticRequestsMemoryGiB += data.TotalStaticRequestsMemoryGiB
+	sum.TotalRequestsCPU.Add(data.TotalRequestsCPU)
+	sum.TotalRequestsCPUCores += data.TotalRequestsCPUCores
+	sum.TotalLimitsCPU.Add(data.TotalLimitsCPU)
+	sum.TotalLimitsCPUCores += data.TotalLimitsCPUCores
+	sum.TotalRequestsMemory.Add(data.TotalRequestsMemory)
+	sum.TotalRequestsMemoryGiB += data.TotalRequestsMemoryGiB
+	sum.TotalLimitsMemory.Add(data.TotalLimitsMemory)
+	sum.TotalLimitsMemoryGiB += data.TotalLimitsMemoryGiB
+	sum.TotalRequestsEphemeralStorage.Add(data.TotalRequestsEphemeralStorage)
+	sum.TotalRequestsEphemeralStorageGB += data.TotalRequestsEphemeralStorageGB
+	sum.TotalLimitsEphemeralStorage.Add(data.TotalLimitsEphemeralStorage)
+	sum.TotalLimitsEphemeralStorageGB += data.TotalLimitsEphemeralStorageGB
+}
@@ -0,0 +1,336 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Aggregates capacity data per node, returns the data, the sorted node names to display and the node names grouped by role
+func NodeCapacity(nodes *corev1.NodeList, pods *corev1.PodList, includeUnassigned bool, includeTotal bool, includeAverage bool, filter PodFilter) (map[string]*output.NodeCapacityData, []string, map[string][]string) {
+	nodesCapacityData := make(map[string]*output.NodeCapacityData)
+	nodeNames := make([]string, 0, len(nodes.Items))
+	nodesByRole := make(map[string][]string)
+
+	for _, node := range nodes.Items {
+		nodeNames = append(nodeNames, node.Name)
+		nodesCapacityData[node.Name] = new(output.NodeCapacityData)
+
+		roles := NodeRoles(node)
+
+		nodesCapacityData[node.Name].Ready = false
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" {
+				nodesCapacityData[node.Name].Ready = condition.Status == corev1.ConditionTrue
+				if !condition.LastHeartbeatTime.IsZero() {
+					lastHeartbeatTime := condition.LastHeartbeatTime.Time
+					nodesCapacityData[node.Name].LastHeartbeatTime = &lastHeartbeatTime
+				}
+				break
+			}
+		}
+
+		nodesCapacityData[node.Name].Schedulable = !node.Spec.Unschedulable
+		nodesCapacityData[node.Name].KubeletVersion = node.Status.NodeInfo.KubeletVersion
+		nodesCapacityData[node.Name].InstanceType = node.Labels["node.kubernetes.io/instance-type"]
+		if nodesCapacityData[node.Name].InstanceType == "" {
+			nodesCapacityData[node.Name].InstanceType = node.Labels["beta.kubernetes.io/instance-type"]
+		}
+		nodesCapacityData[node.Name].Zone = node.Labels["topology.kubernetes.io/zone"]
+		if nodesCapacityData[node.Name].Zone == "" {
+			nodesCapacityData[node.Name].Zone = node.Labels["failure-domain.beta.kubernetes.io/zone"]
+		}
+		nodesCapacityData[node.Name].TaintCount = len(node.Spec.Taints)
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				nodesCapacityData[node.Name].InternalIP = address.Address
+				break
+			}
+		}
+		nodesCapacityData[node.Name].Roles = roles
+		nodesCapacityData[node.Name].TotalCapacityPods.Add(*node.Status.Capacity.Pods())
+		nodesCapacityData[node.Name].TotalCapacityCPU.Add(*node.Status.Capacity.Cpu())
+		nodesCapacityData[node.Name].TotalCapacityMemory.Add(*node.Status.Capacity.Memory())
+		nodesCapacityData[node.Name].TotalCapacityEphemeralStorage.Add(*node.Status.Capacity.StorageEphemeral())
+		nodesCapacityData[node.Name].TotalAllocatablePods.Add(*node.Status.Allocatable.Pods())
+		nodesCapacityData[node.Name].TotalAllocatableCPU.Add(*node.Status.Allocatable.Cpu())
+		nodesCapacityData[node.Name].TotalAllocatableMemory.Add(*node.Status.Allocatable.Memory())
+		nodesCapacityData[node.Name].TotalAllocatableEphemeralStorage.Add(*node.Status.Allocatable.StorageEphemeral())
+		rolesIndex := strings.Join(roles.List(), ",")
+		nodesByRole[rolesIndex] = append(nodesByRole[rolesIndex], node.Name)
+	}
+	nodesCapacityData["*unassigned*"] = new(output.NodeCapacityData)
+	nodesCapacityData["*total*"] = new(output.NodeCapacityData)
+
+	for _, pod := range pods.Items {
+		podNode := pod.Spec.NodeName
+		if pod.Spec.NodeName == "" {
+			podNode = "*unassigned*"
+		}
+		nodesCapacityData[podNode].TotalPodCount++
+		if IsTerminating(pod) {
+			nodesCapacityData[podNode].TotalTerminatingPodCount++
+		}
+		if IsStatic(pod) {
+			requestsCPU, requestsMemory := PodRequests(pod)
+			nodesCapacityData[podNode].TotalStaticPodCount++
+			nodesCapacityData[podNode].TotalStaticRequestsCPU.Add(requestsCPU)
+			nodesCapacityData[podNode].TotalStaticRequestsMemory.Add(requestsMemory)
+		}
+
+		if filter.HoldsResources(pod) {
+			nodesCapacityData[podNode].TotalNonTermPodCount++
+			if IsBestEffort(pod) {
+				nodesCapacityData[podNode].TotalBestEffortPodCount++
+			}
+			for _, container := range pod.Spec.Containers {
+				nodesCapacityData[podNode].TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
+				nodesCapacityData[podNode].TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
+				nodesCapacityData[podNode].TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
+				nodesCapacityData[podNode].TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
+				nodesCapacityData[podNode].TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
+				nodesCapacityData[podNode].TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
+			}
+		}
+	}
+
+	for _, node := range nodeNames {
+		nodesCapacityData[node].TotalAvailablePods = int(nodesCapacityData[node].TotalAllocatablePods.Value()) - nodesCapacityData[node].TotalNonTermPodCount
+		nodesCapacityData[node].TotalAvailableCPU = nodesCapacityData[node].TotalAllocatableCPU
+		nodesCapacityData[node].TotalAvailableCPU.Sub(nodesCapacityData[node].TotalRequestsCPU)
+		nodesCapacityData[node].TotalAvailableMemory = nodesCapacityData[node].TotalAllocatableMemory
+		nodesCapacityData[node].TotalAvailableMemory.Sub(nodesCapacityData[node].TotalRequestsMemory)
+		nodesCapacityData[node].TotalAvailableEphemeralStorage = nodesCapacityData[node].TotalAllocatableEphemeralStorage
+		nodesCapacityData[node].TotalAvailableEphemeralStorage.Sub(nodesCapacityData[node].TotalRequestsEphemeralStorage)
+	}
+
+	sort.Strings(nodeNames)
+	for _, roleNodeNames := range nodesByRole {
+		sort.Strings(roleNodeNames)
+	}
+	if includeUnassigned {
+		nodeNames = append(nodeNames, "*unassigned*")
+		nodesByRole["~"] = append(nodesByRole["~"], "*unassigned*")
+	}
+
+	// Populate "Human" readable capacity data values and the *total* "node"
+	for _, node := range nodeNames {
+		nodesCapacityData[node].TotalCapacityCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalCapacityCPU)
+		nodesCapacityData[node].TotalCapacityMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalCapacityMemory)
+		nodesCapacityData[node].TotalCapacityEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalCapacityEphemeralStorage)
+		nodesCapacityData[node].TotalAllocatableCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalAllocatableCPU)
+		nodesCapacityData[node].TotalAllocatableMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalAllocatableMemory)
+		nodesCapacityData[node].TotalAllocatableEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalAllocatableEphemeralStorage)
+		nodesCapacityData[node].TotalRequestsCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalRequestsCPU)
+		nodesCapacityData[node].TotalLimitsCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalLimitsCPU)
+		nodesCapacityData[node].TotalAvailableCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalAvailableCPU)
+		nodesCapacityData[node].TotalRequestsMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalRequestsMemory)
+		nodesCapacityData[node].TotalLimitsMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalLimitsMemory)
+		nodesCapacityData[node].TotalAvailableMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalAvailableMemory)
+		nodesCapacityData[node].TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalRequestsEphemeralStorage)
+		nodesCapacityData[node].TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalLimitsEphemeralStorage)
+		nodesCapacityData[node].TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(nodesCapacityData[node].TotalAvailableEphemeralStorage)
+		nodesCapacityData[node].TotalStaticRequestsCPUCores = capacity.ReadableCPU(nodesCapacityData[node].TotalStaticRequestsCPU)
+		nodesCapacityData[node].TotalStaticRequestsMemoryGiB = capacity.ReadableMem(nodesCapacityData[node].TotalStaticRequestsMemory)
+		setAvailablePercents(nodesCapacityData[node])
+		addNodeCapacityData(nodesCapacityData["*total*"], nodesCapacityData[node])
+	}
+
+	// Unassigned pods have no allocatable capacity, their requests reduce the available capacity of the *total* "node" so it
+	// reconciles with the cluster data
+	total := nodesCapacityData["*total*"]
+	total.TotalAvailablePods = int(total.TotalAllocatablePods.Value()) - total.TotalNonTermPodCount
+	total.TotalAvailableCPU = total.TotalAllocatableCPU
+	total.TotalAvailableCPU.Sub(total.TotalRequestsCPU)
+	total.TotalAvailableCPUCores = capacity.ReadableCPU(total.TotalAvailableCPU)
+	total.TotalAvailableMemory = total.TotalAllocatableMemory
+	total.TotalAvailableMemory.Sub(total.TotalRequestsMemory)
+	total.TotalAvailableMemoryGiB = capacity.ReadableMem(total.TotalAvailableMemory)
+	total.TotalAvailableEphemeralStorage = total.TotalAllocatableEphemeralStorage
+	total.TotalAvailableEphemeralStorage.Sub(total.TotalRequestsEphemeralStorage)
+	total.TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(total.TotalAvailableEphemeralStorage)
+	setAvailablePercents(total)
+
+	if includeTotal {
+		nodeNames = append(nodeNames, "*total*")
+		nodesByRole["~"] = append(nodesByRole["~"], "*total*")
+	}
+
+	if includeAverage {
+		// Sum nodes only, the *total* "node" includes unassigned pods with -u
+		nodesTotal := new(output.NodeCapacityData)
+		for _, node := range nodes.Items {
+			addNodeCapacityData(nodesTotal, nodesCapacityData[node.Name])
+		}
+		nodesCapacityData["*average*"] = averageNodeCapacityData(nodesTotal, len(nodes.Items))
+		setAvailablePercents(nodesCapacityData["*average*"])
+		nodeNames = append(nodeNames, "*average*")
+		nodesByRole["~"] = append(nodesByRole["~"], "*average*")
+	}
+
+	return nodesCapacityData, nodeNames, nodesByRole
+}
+
+// Percent of allocatable not requested, negative when requests exceed allocatable
+func setAvailablePercents(data *output.NodeCapacityData) {
+	data.AvailablePodsPercent = capacity.Percent(*resource.NewQuantity(int64(data.TotalAvailablePods), resource.DecimalSI), data.TotalAllocatablePods)
+	data.AvailableCPUPercent = capacity.Percent(data.TotalAvailableCPU, data.TotalAllocatableCPU)
+	data.AvailableMemoryPercent = capacity.Percent(data.TotalAvailableMemory, data.TotalAllocatableMemory)
+}
+
+// Lists the non-terminated pods of each node, pods without a node are listed under *unassigned*
+func AddNodePods(nodesCapacityData map[string]*output.NodeCapacityData, pods *corev1.PodList, filter PodFilter) {
+	for _, pod := range pods.Items {
+		if !filter.HoldsResources(pod) {
+			continue
+		}
+		podNode := pod.Spec.NodeName
+		if pod.Spec.NodeName == "" {
+			podNode = "*unassigned*"
+		}
+		nodeData, ok := nodesCapacityData[podNode]
+		if !ok {
+			continue
+		}
+		podData := output.PodCapacityData{Namespace: pod.Namespace, Name: pod.Name, Phase: string(pod.Status.Phase)}
+		for _, container := range pod.Spec.Containers {
+			podData.RequestsCPU.Add(*container.Resources.Requests.Cpu())
+			podData.LimitsCPU.Add(*container.Resources.Limits.Cpu())
+			podData.RequestsMemory.Add(*container.Resources.Requests.Memory())
+			podData.LimitsMemory.Add(*container.Resources.Limits.Memory())
+			podData.RequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
+			podData.LimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
+		}
+		podData.RequestsCPUCores = capacity.ReadableCPU(podData.RequestsCPU)
+		podData.LimitsCPUCores = capacity.ReadableCPU(podData.LimitsCPU)
+		podData.RequestsMemoryGiB = capacity.ReadableMem(podData.RequestsMemory)
+		podData.LimitsMemoryGiB = capacity.ReadableMem(podData.LimitsMemory)
+		podData.RequestsEphemeralStorageGB = capacity.ReadableStorage(podData.RequestsEphemeralStorage)
+		podData.LimitsEphemeralStorageGB = capacity.ReadableStorage(podData.LimitsEphemeralStorage)
+		nodeData.Pods = append(nodeData.Pods, podData)
+	}
+	for _, nodeData := range nodesCapacityData {
+		sort.Slice(nodeData.Pods, func(i, j int) bool {
+			if nodeData.Pods[i].Namespace != nodeData.Pods[j].Namespace {
+				return nodeData.Pods[i].Namespace < nodeData.Pods[j].Namespace
+			}
+			return nodeData.Pods[i].Name < nodeData.Pods[j].Name
+		})
+	}
+}
+
+// Adds the capacity data of a node to the total
+func addNodeCapacityData(total *output.NodeCapacityData, data *output.NodeCapacityData) {
+	total.TotalPodCount += data.TotalPodCount
+	total.TotalNonTermPodCount += data.TotalNonTermPodCount
+	total.TotalTerminatingPodCount += data.TotalTerminatingPodCount
+	total.TotalStaticPodCount += data.TotalStaticPodCount
+	total.TotalBestEffortPodCount += data.TotalBestEffortPodCount
+	total.TotalStaticRequestsCPU.Add(data.TotalStaticRequestsCPU)
+	total.TotalStaticRequestsCPUCores += data.TotalStaticRequestsCPUCores
+	total.TotalStaticRequestsMemory.Add(data.TotalStaticRequestsMemory)
+	total.TotalStaticRequestsMemoryGiB += data.TotalStaticRequestsMemoryGiB
+	total.TotalCapacityPods.Add(data.TotalCapacityPods)
+	total.TotalCapacityCPU.Add(data.TotalCapacityCPU)
+	total.TotalCapacityCPUCores += data.TotalCapacityCPUCores
+	total.TotalCapacityMemory.Add(data.TotalCapacityMemory)
+	total.TotalCapacityMemoryGiB += data.TotalCapacityMemoryGiB
+	total.TotalCapacityEphemeralStorage.Add(data.TotalCapacityEphemeralStorage)
+	total.TotalCapacityEphemeralStorageGB += data.TotalCapacityEphemeralStorageGB
+	total.TotalAllocatablePods.Add(data.TotalAllocatablePods)
+	total.TotalAllocatableCPU.Add(data.TotalAllocatableCPU)
+	total.TotalAllocatableCPUCores += data.TotalAllocatableCPUCores
+	total.TotalAllocatableMemory.Add(data.TotalAllocatableMemory)
+	total.TotalAllocatableMemoryGiB += data.TotalAllocatableMemoryGiB
+	total.TotalAllocatableEphemeralStorage.Add(data.TotalAllocatableEphemeralStorage)
+	total.TotalAllocatableEphemeralStorageGB += data.TotalAllocatableEphemeralStorageGB
+	total.TotalAvailablePods += data.TotalAvailablePods
+	total.TotalRequestsCPU.Add(data.TotalRequestsCPU)
+	total.TotalRequestsCPUCores += data.TotalRequestsCPUCores
+	total.TotalLimitsCPU.Add(data.TotalLimitsCPU)
+	total.TotalLimitsCPUCores += data.TotalLimitsCPUCores
+	total.TotalAvailableCPU.Add(data.TotalAvailableCPU)
+	total.TotalAvailableCPUCores += data.TotalAvailableCPUCores
+	total.TotalRequestsMemory.Add(data.TotalRequestsMemory)
+	total.TotalRequestsMemoryGiB += data.TotalRequestsMemoryGiB
+	total.TotalLimitsMemory.Add(data.TotalLimitsMemory)
+	total.TotalLimitsMemoryGiB += data.TotalLimitsMemoryGiB
+	total.TotalAvailableMemory.Add(data.TotalAvailableMemory)
+	total.TotalAvailableMemoryGiB += data.TotalAvailableMemoryGiB
+	total.TotalRequestsEphemeralStorage.Add(data.TotalRequestsEphemeralStorage)
+	total.TotalRequestsEphemeralStorageGB += data.TotalRequestsEphemeralStorageGB
+	total.TotalLimitsEphemeralStorage.Add(data.TotalLimitsEphemeralStorage)
+	total.TotalLimitsEphemeralStorageGB += data.TotalLimitsEphemeralStorageGB
+	total.TotalAvailableEphemeralStorage.Add(data.TotalAvailableEphemeralStorage)
+	total.TotalAvailableEphemeralStorageGB += data.TotalAvailableEphemeralStorageGB
+}
+
+// Per node average of the summed node capacity data, pod counts are rounded down
+func averageNodeCapacityData(total *output.NodeCapacityData, nodeCount int) *output.NodeCapacityData {
+	average := new(output.NodeCapacityData)
+	if nodeCount == 0 {
+		return average
+	}
+	average.TotalPodCount = total.TotalPodCount / nodeCount
+	average.TotalNonTermPodCount = total.TotalNonTermPodCount / nodeCount
+	average.TotalTerminatingPodCount = total.TotalTerminatingPodCount / nodeCount
+	average.TotalStaticPodCount = total.TotalStaticPodCount / nodeCount
+	average.TotalBestEffortPodCount = total.TotalBestEffortPodCount / nodeCount
+	average.TotalStaticRequestsCPU = capacity.AverageCPU(total.TotalStaticRequestsCPU, nodeCount)
+	average.TotalStaticRequestsMemory = capacity.AverageQuantity(total.TotalStaticRequestsMemory, nodeCount)
+	average.TotalStaticRequestsCPUCores = capacity.ReadableCPU(average.TotalStaticRequestsCPU)
+	average.TotalStaticRequestsMemoryGiB = capacity.ReadableMem(average.TotalStaticRequestsMemory)
+	average.TotalAvailablePods = total.TotalAvailablePods / nodeCount
+	average.TotalCapacityPods = capacity.AverageQuantity(total.TotalCapacityPods, nodeCount)
+	average.TotalCapacityCPU = capacity.AverageCPU(total.TotalCapacityCPU, nodeCount)
+	average.TotalCapacityMemory = capacity.AverageQuantity(total.TotalCapacityMemory, nodeCount)
+	average.TotalCapacityEphemeralStorage = capacity.AverageQuantity(total.TotalCapacityEphemeralStorage, nodeCount)
+	average.TotalAllocatablePods = capacity.AverageQuantity(total.TotalAllocatablePods, nodeCount)
+	average.TotalAllocatableCPU = capacity.AverageCPU(total.TotalAllocatableCPU, nodeCount)
+	average.TotalAllocatableMemory = capacity.AverageQuantity(total.TotalAllocatableMemory, nodeCount)
+	average.TotalAllocatableEphemeralStorage = capacity.AverageQuantity(total.TotalAllocatableEphemeralStorage, nodeCount)
+	average.TotalRequestsCPU = capacity.AverageCPU(total.TotalRequestsCPU, nodeCount)
+	average.TotalLimitsCPU = capacity.AverageCPU(total.TotalLimitsCPU, nodeCount)
+	average.TotalAvailableCPU = capacity.AverageCPU(total.TotalAvailableCPU, nodeCount)
+	average.TotalRequestsMemory = capacity.AverageQuantity(total.TotalRequestsMemory, nodeCount)
+	average.TotalLimitsMemory = capacity.AverageQuantity(total.TotalLimitsMemory, nodeCount)
+	average.TotalAvailableMemory = capacity.AverageQuantity(total.TotalAvailableMemory, nodeCount)
+	average.TotalRequestsEphemeralStorage = capacity.AverageQuantity(total.TotalRequestsEphemeralStorage, nodeCount)
+	average.TotalLimitsEphemeralStorage = capacity.AverageQuantity(total.TotalLimitsEphemeralStorage, nodeCount)
+	average.TotalAvailableEphemeralStorage = capacity.AverageQuantity(total.TotalAvailableEphemeralStorage, nodeCount)
+	average.TotalCapacityCPUCores = capacity.ReadableCPU(average.TotalCapacityCPU)
+	average.TotalCapacityMemoryGiB = capacity.ReadableMem(average.TotalCapacityMemory)
+	average.TotalCapacityEphemeralStorageGB = capacity.ReadableStorage(average.TotalCapacityEphemeralStorage)
+	average.TotalAllocatableCPUCores = capacity.ReadableCPU(average.TotalAllocatableCPU)
+	average.TotalAllocatableMemoryGiB = capacity.ReadableMem(average.TotalAllocatableMemory)
+	average.TotalAllocatableEphemeralStorageGB = capacity.ReadableStorage(average.TotalAllocatableEphemeralStorage)
+	average.TotalRequestsCPUCores = capacity.ReadableCPU(average.TotalRequestsCPU)
+	average.TotalLimitsCPUCores = capacity.ReadableCPU(average.TotalLimitsCPU)
+	average.TotalAvailableCPUCores = capacity.ReadableCPU(average.TotalAvailableCPU)
+	average.TotalRequestsMemoryGiB = capacity.ReadableMem(average.TotalRequestsMemory)
+	average.TotalLimitsMemoryGiB = capacity.ReadableMem(average.TotalLimitsMemory)
+	average.TotalAvailableMemoryGiB = capacity.ReadableMem(average.TotalAvailableMemory)
+	average.TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(average.TotalRequestsEphemeralStorage)
+	average.TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(average.TotalLimitsEphemeralStorage)
+	average.TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(average.TotalAvailableEphemeralStorage)
+	return average
+}
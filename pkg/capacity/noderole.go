@@ -0,0 +1,284 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/capacity"
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Roles of a node from its node-role.kubernetes.io/<role> and kubernetes.io/role labels, <none> without any
+func NodeRoles(node corev1.Node) sets.String {
+	roles := sets.NewString()
+	for labelKey, labelValue := range node.Labels {
+		switch {
+		case strings.HasPrefix(labelKey, "node-role.kubernetes.io/"):
+			if role := strings.TrimPrefix(labelKey, "node-role.kubernetes.io/"); len(role) > 0 {
+				roles.Insert(role)
+			}
+		case labelKey == "kubernetes.io/role" && labelValue != "":
+			roles.Insert(labelValue)
+		}
+	}
+	if len(roles) == 0 {
+		roles.Insert("<none>")
+	}
+	return roles
+}
+
+// Aggregates capacity data grouped by node role, returns the data and the sorted role names to display
+func NodeRoleCapacity(nodes *corev1.NodeList, pods *corev1.PodList, includeUnassigned bool, includeTotal bool, includeAverage bool, filter PodFilter) (map[string]*output.ClusterCapacityData, []string) {
+	nodeRoleCapacityData := make(map[string]*output.ClusterCapacityData)
+	nodeRoles := make(map[string][]string)
+	roleNames := make([]string, 0)
+	nodesRequests := make(map[string]*output.NodeCapacityData)
+	nodeRoleCapacityData["*total*"] = new(output.ClusterCapacityData)
+
+	for _, node := range nodes.Items {
+		roles := NodeRoles(node)
+		// Every node is also part of the *total* "role"
+		for _, role := range append(roles.List(), "*total*") {
+			if _, ok := nodeRoleCapacityData[role]; !ok {
+				roleNames = append(roleNames, role)
+				nodeRoleCapacityData[role] = new(output.ClusterCapacityData)
+			}
+			nodeRoleCapacityData[role].TotalNodeCount++
+			for _, condition := range node.Status.Conditions {
+				if (condition.Type == "Ready") && condition.Status == corev1.ConditionTrue {
+					nodeRoleCapacityData[role].TotalReadyNodeCount++
+				}
+			}
+			if node.Spec.Unschedulable {
+				nodeRoleCapacityData[role].TotalUnschedulableNodeCount++
+			}
+			nodeRoleCapacityData[role].TotalCapacityPods.Add(*node.Status.Capacity.Pods())
+			nodeRoleCapacityData[role].TotalCapacityCPU.Add(*node.Status.Capacity.Cpu())
+			nodeRoleCapacityData[role].TotalCapacityMemory.Add(*node.Status.Capacity.Memory())
+			nodeRoleCapacityData[role].TotalCapacityEphemeralStorage.Add(*node.Status.Capacity.StorageEphemeral())
+			nodeRoleCapacityData[role].TotalAllocatablePods.Add(*node.Status.Allocatable.Pods())
+			nodeRoleCapacityData[role].TotalAllocatableCPU.Add(*node.Status.Allocatable.Cpu())
+			nodeRoleCapacityData[role].TotalAllocatableMemory.Add(*node.Status.Allocatable.Memory())
+			nodeRoleCapacityData[role].TotalAllocatableEphemeralStorage.Add(*node.Status.Allocatable.StorageEphemeral())
+		}
+		nodeRoles[node.Name] = append(roles.List(), "*total*")
+		nodesRequests[node.Name] = new(output.NodeCapacityData)
+		nodesRequests[node.Name].TotalAllocatableCPU.Add(*node.Status.Allocatable.Cpu())
+		nodesRequests[node.Name].TotalAllocatableMemory.Add(*node.Status.Allocatable.Memory())
+	}
+
+	nodeRoleCapacityData["*unassigned*"] = new(output.ClusterCapacityData)
+	nodeRoles["*unassigned*"] = []string{"*unassigned*"}
+
+	for _, pod := range pods.Items {
+		podNode := pod.Spec.NodeName
+		if pod.Spec.NodeName == "" {
+			podNode = "*unassigned*"
+		}
+		for _, role := range nodeRoles[podNode] {
+			nodeRoleCapacityData[role].TotalPodCount++
+			if IsTerminating(pod) {
+				nodeRoleCapacityData[role].TotalTerminatingPodCount++
+			}
+			if IsStatic(pod) {
+				requestsCPU, requestsMemory := PodRequests(pod)
+				nodeRoleCapacityData[role].TotalStaticPodCount++
+				nodeRoleCapacityData[role].TotalStaticRequestsCPU.Add(requestsCPU)
+				nodeRoleCapacityData[role].TotalStaticRequestsMemory.Add(requestsMemory)
+			}
+			if filter.HoldsResources(pod) {
+				nodeRoleCapacityData[role].TotalNonTermPodCount++
+				if IsBestEffort(pod) {
+					nodeRoleCapacityData[role].TotalBestEffortPodCount++
+				}
+				for _, container := range pod.Spec.Containers {
+					nodeRoleCapacityData[role].TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
+					nodeRoleCapacityData[role].TotalLimitsCPU.Add(*container.Resources.Limits.Cpu())
+					nodeRoleCapacityData[role].TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
+					nodeRoleCapacityData[role].TotalLimitsMemory.Add(*container.Resources.Limits.Memory())
+					nodeRoleCapacityData[role].TotalRequestsEphemeralStorage.Add(*container.Resources.Requests.StorageEphemeral())
+					nodeRoleCapacityData[role].TotalLimitsEphemeralStorage.Add(*container.Resources.Limits.StorageEphemeral())
+				}
+			}
+		}
+		if nodeRequests, ok := nodesRequests[podNode]; ok && filter.HoldsResources(pod) {
+			for _, container := range pod.Spec.Containers {
+				nodeRequests.TotalRequestsCPU.Add(*container.Resources.Requests.Cpu())
+				nodeRequests.TotalRequestsMemory.Add(*container.Resources.Requests.Memory())
+			}
+		}
+	}
+
+	// Least and most loaded node of each role by percent of allocatable requested, nodes are visited by name so ties
+	// resolve to the same node on every run
+	nodeNames := make([]string, 0, len(nodesRequests))
+	for nodeName := range nodesRequests {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	rolesCPUPercents := make(map[string][]float64)
+	rolesMemoryPercents := make(map[string][]float64)
+	for _, nodeName := range nodeNames {
+		requestsCPUPercent := capacity.Percent(nodesRequests[nodeName].TotalRequestsCPU, nodesRequests[nodeName].TotalAllocatableCPU)
+		requestsMemoryPercent := capacity.Percent(nodesRequests[nodeName].TotalRequestsMemory, nodesRequests[nodeName].TotalAllocatableMemory)
+		for _, role := range nodeRoles[nodeName] {
+			rolesCPUPercents[role] = append(rolesCPUPercents[role], requestsCPUPercent)
+			rolesMemoryPercents[role] = append(rolesMemoryPercents[role], requestsMemoryPercent)
+			if nodeRoleCapacityData[role].RequestsMinMax == nil {
+				nodeRoleCapacityData[role].RequestsMinMax = new(output.RequestsMinMaxData)
+			}
+			minMax := nodeRoleCapacityData[role].RequestsMinMax
+			if minMax.MinRequestsCPUNode == "" || requestsCPUPercent < minMax.MinRequestsCPUPercent {
+				minMax.MinRequestsCPUPercent = requestsCPUPercent
+				minMax.MinRequestsCPUNode = nodeName
+			}
+			if minMax.MaxRequestsCPUNode == "" || requestsCPUPercent > minMax.MaxRequestsCPUPercent {
+				minMax.MaxRequestsCPUPercent = requestsCPUPercent
+				minMax.MaxRequestsCPUNode = nodeName
+			}
+			if minMax.MinRequestsMemoryNode == "" || requestsMemoryPercent < minMax.MinRequestsMemoryPercent {
+				minMax.MinRequestsMemoryPercent = requestsMemoryPercent
+				minMax.MinRequestsMemoryNode = nodeName
+			}
+			if minMax.MaxRequestsMemoryNode == "" || requestsMemoryPercent > minMax.MaxRequestsMemoryPercent {
+				minMax.MaxRequestsMemoryPercent = requestsMemoryPercent
+				minMax.MaxRequestsMemoryNode = nodeName
+			}
+		}
+	}
+
+	for role, percents := range rolesCPUPercents {
+		nodeRoleCapacityData[role].RequestsMinMax.StdDevRequestsCPUPercent = capacity.StdDev(percents)
+		nodeRoleCapacityData[role].RequestsMinMax.StdDevRequestsMemoryPercent = capacity.StdDev(rolesMemoryPercents[role])
+	}
+
+	for _, role := range append(roleNames, "*total*") {
+		nodeRoleCapacityData[role].TotalUnreadyNodeCount = nodeRoleCapacityData[role].TotalNodeCount - nodeRoleCapacityData[role].TotalReadyNodeCount
+		calculateAvailable(nodeRoleCapacityData[role])
+	}
+
+	// The average only covers pods on nodes, so it is taken before unassigned pods are added to the total
+	if includeAverage {
+		nodeRoleCapacityData["*average*"] = averageClusterCapacityData(nodeRoleCapacityData["*total*"])
+	}
+
+	sort.Strings(roleNames)
+	if includeUnassigned {
+		roleNames = append(roleNames, "*unassigned*")
+		addPodCapacityData(nodeRoleCapacityData["*total*"], nodeRoleCapacityData["*unassigned*"])
+		calculateAvailable(nodeRoleCapacityData["*total*"])
+	}
+
+	if includeTotal {
+		roleNames = append(roleNames, "*total*")
+	} else {
+		delete(nodeRoleCapacityData, "*total*")
+	}
+
+	if _, ok := nodeRoleCapacityData["*average*"]; ok {
+		roleNames = append(roleNames, "*average*")
+	}
+
+	// Populate "Human" readable capacity data values
+	for _, role := range roleNames {
+		nodeRoleCapacityData[role].TotalCapacityCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalCapacityCPU)
+		nodeRoleCapacityData[role].TotalCapacityMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalCapacityMemory)
+		nodeRoleCapacityData[role].TotalCapacityEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalCapacityEphemeralStorage)
+		nodeRoleCapacityData[role].TotalAllocatableCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalAllocatableCPU)
+		nodeRoleCapacityData[role].TotalAllocatableMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalAllocatableMemory)
+		nodeRoleCapacityData[role].TotalAllocatableEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalAllocatableEphemeralStorage)
+		nodeRoleCapacityData[role].TotalRequestsCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalRequestsCPU)
+		nodeRoleCapacityData[role].TotalLimitsCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalLimitsCPU)
+		nodeRoleCapacityData[role].TotalAvailableCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalAvailableCPU)
+		nodeRoleCapacityData[role].TotalRequestsMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalRequestsMemory)
+		nodeRoleCapacityData[role].TotalLimitsMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalLimitsMemory)
+		nodeRoleCapacityData[role].TotalAvailableMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalAvailableMemory)
+		nodeRoleCapacityData[role].TotalRequestsEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalRequestsEphemeralStorage)
+		nodeRoleCapacityData[role].TotalLimitsEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalLimitsEphemeralStorage)
+		nodeRoleCapacityData[role].TotalAvailableEphemeralStorageGB = capacity.ReadableStorage(nodeRoleCapacityData[role].TotalAvailableEphemeralStorage)
+		nodeRoleCapacityData[role].TotalStaticRequestsCPUCores = capacity.ReadableCPU(nodeRoleCapacityData[role].TotalStaticRequestsCPU)
+		nodeRoleCapacityData[role].TotalStaticRequestsMemoryGiB = capacity.ReadableMem(nodeRoleCapacityData[role].TotalStaticRequestsMemory)
+	}
+
+	return nodeRoleCapacityData, roleNames
+}
+
+// Available = allocatable - (scheduled aka non-term pod or requests.cpu/memory)
+func calculateAvailable(data *output.ClusterCapacityData) {
+	data.TotalAvailablePods = int(data.TotalAllocatablePods.Value()) - data.TotalNonTermPodCount
+	data.TotalAvailableCPU = data.TotalAllocatableCPU
+	data.TotalAvailableCPU.Sub(data.TotalRequestsCPU)
+	data.TotalAvailableMemory = data.TotalAllocatableMemory
+	data.TotalAvailableMemory.Sub(data.TotalRequestsMemory)
+	data.TotalAvailableEphemeralStorage = data.TotalAllocatableEphemeralStorage
+	data.TotalAvailableEphemeralStorage.Sub(data.TotalRequestsEphemeralStorage)
+}
+
+// Adds the pod counts, requests and limits of src to dst
+func addPodCapacityData(dst *output.ClusterCapacityData, src *output.ClusterCapacityData) {
+	dst.TotalPodCount += src.TotalPodCount
+	dst.TotalNonTermPodCount += src.TotalNonTermPodCount
+	dst.TotalTerminatingPodCount += src.TotalTerminatingPodCount
+	dst.TotalStaticPodCount += src.TotalStaticPodCount
+	dst.TotalBestEffortPodCount += src.TotalBestEffortPodCount
+	dst.TotalStaticRequestsCPU.Add(src.TotalStaticRequestsCPU)
+	dst.TotalStaticRequestsMemory.Add(src.TotalStaticRequestsMemory)
+	dst.TotalRequestsCPU.Add(src.TotalRequestsCPU)
+	dst.TotalLimitsCPU.Add(src.TotalLimitsCPU)
+	dst.TotalRequestsMemory.Add(src.TotalRequestsMemory)
+	dst.TotalLimitsMemory.Add(src.TotalLimitsMemory)
+	dst.TotalRequestsEphemeralStorage.Add(src.TotalRequestsEphemeralStorage)
+	dst.TotalLimitsEphemeralStorage.Add(src.TotalLimitsEphemeralStorage)
+}
+
+// Per node average of the *total* capacity data, node counts are left empty and pod counts are rounded down
+func averageClusterCapacityData(total *output.ClusterCapacityData) *output.ClusterCapacityData {
+	average := new(output.ClusterCapacityData)
+	nodeCount := total.TotalNodeCount
+	if nodeCount == 0 {
+		return average
+	}
+	average.TotalPodCount = total.TotalPodCount / nodeCount
+	average.TotalNonTermPodCount = total.TotalNonTermPodCount / nodeCount
+	average.TotalTerminatingPodCount = total.TotalTerminatingPodCount / nodeCount
+	average.TotalStaticPodCount = total.TotalStaticPodCount / nodeCount
+	average.TotalBestEffortPodCount = total.TotalBestEffortPodCount / nodeCount
+	average.TotalStaticRequestsCPU = capacity.AverageCPU(total.TotalStaticRequestsCPU, nodeCount)
+	average.TotalStaticRequestsMemory = capacity.AverageQuantity(total.TotalStaticRequestsMemory, nodeCount)
+	average.TotalAvailablePods = total.TotalAvailablePods / nodeCount
+	average.TotalCapacityPods = capacity.AverageQuantity(total.TotalCapacityPods, nodeCount)
+	average.TotalCapacityCPU = capacity.AverageCPU(total.TotalCapacityCPU, nodeCount)
+	average.TotalCapacityMemory = capacity.AverageQuantity(total.TotalCapacityMemory, nodeCount)
+	average.TotalCapacityEphemeralStorage = capacity.AverageQuantity(total.TotalCapacityEphemeralStorage, nodeCount)
+	average.TotalAllocatablePods = capacity.AverageQuantity(total.TotalAllocatablePods, nodeCount)
+	average.TotalAllocatableCPU = capacity.AverageCPU(total.TotalAllocatableCPU, nodeCount)
+	average.TotalAllocatableMemory = capacity.AverageQuantity(total.TotalAllocatableMemory, nodeCount)
+	average.TotalAllocatableEphemeralStorage = capacity.AverageQuantity(total.TotalAllocatableEphemeralStorage, nodeCount)
+	average.TotalRequestsCPU = capacity.AverageCPU(total.TotalRequestsCPU, nodeCount)
+	average.TotalLimitsCPU = capacity.AverageCPU(total.TotalLimitsCPU, nodeCount)
+	average.TotalAvailableCPU = capacity.AverageCPU(total.TotalAvailableCPU, nodeCount)
+	average.TotalRequestsMemory = capacity.AverageQuantity(total.TotalRequestsMemory, nodeCount)
+	average.TotalLimitsMemory = capacity.AverageQuantity(total.TotalLimitsMemory, nodeCount)
+	average.TotalAvailableMemory = capacity.AverageQuantity(total.TotalAvailableMemory, nodeCount)
+	average.TotalRequestsEphemeralStorage = capacity.AverageQuantity(total.TotalRequestsEphemeralStorage, nodeCount)
+	average.TotalLimitsEphemeralStorage = capacity.AverageQuantity(total.TotalLimitsEphemeralStorage, nodeCount)
+	average.TotalAvailableEphemeralStorage = capacity.AverageQuantity(total.TotalAvailableEphemeralStorage, nodeCount)
+	return average
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Groups of the planes capacity data
+const (
+	ControlPlane = "control-plane"
+	WorkerPlane  = "worker"
+)
+
+// Aggregates capacity data into the control-plane nodes and all other nodes, returns the data and the sorted plane
+// names to display. Control-plane nodes are found by their control-plane or master role or taint, or a static
+// kube-apiserver pod, so clusters without the usual role labels are split the same way.
+func PlaneCapacity(nodes *corev1.NodeList, pods *corev1.PodList, includeUnassigned bool, includeTotal bool, filter PodFilter) (map[string]*output.ClusterCapacityData, []string) {
+	apiServerNodes := sets.NewString()
+	for _, pod := range pods.Items {
+		if IsStatic(pod) && (pod.Labels["component"] == "kube-apiserver" || pod.Labels["app"] == "openshift-kube-apiserver") {
+			apiServerNodes.Insert(pod.Spec.NodeName)
+		}
+	}
+	planeNodes := &corev1.NodeList{ListMeta: nodes.ListMeta}
+	for _, node := range nodes.Items {
+		plane := WorkerPlane
+		if IsControlPlane(node) || apiServerNodes.Has(node.Name) {
+			plane = ControlPlane
+		}
+		planeNode := *node.DeepCopy()
+		planeNode.Labels = map[string]string{"node-role.kubernetes.io/" + plane: ""}
+		planeNodes.Items = append(planeNodes.Items, planeNode)
+	}
+	return NodeRoleCapacity(planeNodes, pods, includeUnassigned, includeTotal, false, filter)
+}
+
+// Whether the node has a control-plane or master role or taint
+func IsControlPlane(node corev1.Node) bool {
+	roles := NodeRoles(node)
+	if roles.Has("control-plane") || roles.Has("master") {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == "node-role.kubernetes.io/control-plane" || taint.Key == "node-role.kubernetes.io/master" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"math"
+	"net"
+	"sort"
+
+	"github.com/akrzos/kubeSize/internal/output"
+)
+
+const (
+	IPv4 = "IPv4"
+	IPv6 = "IPv6"
+)
+
+// Reports the pod IPs available in each pod CIDR of each node and summed per IP family, since on dual-stack clusters one
+// family can exhaust while the other looks fine. Used counts the pod IPs of the node within the CIDR. Nodes past
+// warnThreshold percent of the addresses of a family are counted as near exhaustion and nodes without any left as
+// exhausted.
+func PodIPCapacity(nodePodCIDRs map[string][]string, nodePodIPs map[string][]string, warnThreshold float64) output.PodIPsData {
+	podIPsData := output.PodIPsData{Families: make([]output.FamilyPodIPsData, 0), Nodes: make([]output.NodePodIPsData, 0)}
+	families := make(map[string]*output.FamilyPodIPsData)
+	nodeNames := make([]string, 0, len(nodePodCIDRs))
+	for nodeName := range nodePodCIDRs {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		for _, podCIDR := range nodePodCIDRs[nodeName] {
+			_, network, err := net.ParseCIDR(podCIDR)
+			if err != nil {
+				continue
+			}
+			nodeData := output.NodePodIPsData{Node: nodeName, Family: IPv6, PodCIDR: podCIDR}
+			if network.IP.To4() != nil {
+				nodeData.Family = IPv4
+			}
+			nodeData.Addresses = podCIDRAddresses(network)
+			for _, podIP := range nodePodIPs[nodeName] {
+				if ip := net.ParseIP(podIP); ip != nil && network.Contains(ip) {
+					nodeData.Used++
+				}
+			}
+			nodeData.Available = nodeData.Addresses - float64(nodeData.Used)
+			nodeData.UsedPercent = ipPercent(nodeData.Used, nodeData.Addresses)
+			podIPsData.Nodes = append(podIPsData.Nodes, nodeData)
+
+			family, ok := families[nodeData.Family]
+			if !ok {
+				family = &output.FamilyPodIPsData{Family: nodeData.Family}
+				families[nodeData.Family] = family
+			}
+			family.Nodes++
+			family.Addresses += nodeData.Addresses
+			family.Used += nodeData.Used
+			family.Available += nodeData.Available
+			if nodeData.Available <= 0 {
+				family.ExhaustedNodes++
+			} else if nodeData.UsedPercent > warnThreshold {
+				family.NearNodes++
+			}
+		}
+	}
+	for _, familyName := range []string{IPv4, IPv6} {
+		if family, ok := families[familyName]; ok {
+			family.UsedPercent = ipPercent(family.Used, family.Addresses)
+			podIPsData.Families = append(podIPsData.Families, *family)
+		}
+	}
+	return podIPsData
+}
+
+// Usable addresses of a pod CIDR, without the network and broadcast addresses of IPv4 and the subnet-router anycast
+// address of IPv6. A float since an IPv6 /64 has more than an int64 holds.
+func podCIDRAddresses(network *net.IPNet) float64 {
+	ones, bits := network.Mask.Size()
+	if network.IP.To4() != nil {
+		return math.Max(math.Exp2(float64(bits-ones))-2, 0)
+	}
+	return math.Exp2(float64(bits-ones)) - 1
+}
+
+func ipPercent(used int, addresses float64) float64 {
+	if addresses <= 0 {
+		return 0
+	}
+	return float64(used) / addresses * 100
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Selects the non-terminated pods that count towards the pod counts, requests and limits
+type PodFilter struct {
+	ExcludeTerminating bool
+	ExcludeStatic      bool
+}
+
+// Succeeded and failed pods no longer hold their requested resources. With ExcludeTerminating pods being deleted are
+// not counted either, since their resources are freed shortly, and with ExcludeStatic static pods are not counted.
+func (f PodFilter) HoldsResources(pod corev1.Pod) bool {
+	if (pod.Status.Phase == corev1.PodSucceeded) || (pod.Status.Phase == corev1.PodFailed) {
+		return false
+	}
+	if f.ExcludeTerminating && pod.DeletionTimestamp != nil {
+		return false
+	}
+	return !f.ExcludeStatic || !IsStatic(pod)
+}
+
+// Non-terminated pods with a deletion timestamp
+func IsTerminating(pod corev1.Pod) bool {
+	return pod.DeletionTimestamp != nil && (pod.Status.Phase != corev1.PodSucceeded) && (pod.Status.Phase != corev1.PodFailed)
+}
+
+// Static pods are run by the kubelet from manifest files and represented by a mirror pod in the API, on self-hosted
+// clusters these are typically the control-plane components
+func IsStatic(pod corev1.Pod) bool {
+	_, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]
+	return ok && (pod.Status.Phase != corev1.PodSucceeded) && (pod.Status.Phase != corev1.PodFailed)
+}
+
+// BestEffort pods have no cpu or memory requests or limits, they still use a pod slot and real resources
+func IsBestEffort(pod corev1.Pod) bool {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass == corev1.PodQOSBestEffort
+	}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if _, ok := container.Resources.Requests[resourceName]; ok {
+				return false
+			}
+			if _, ok := container.Resources.Limits[resourceName]; ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Priority admission resolved from the priority class of a pod, pods without one have the default priority 0
+func PodPriority(pod corev1.Pod) int32 {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority
+	}
+	return 0
+}
+
+// Sum of the cpu and memory requests of the containers of a pod
+func PodRequests(pod corev1.Pod) (resource.Quantity, resource.Quantity) {
+	var requestsCPU, requestsMemory resource.Quantity
+	for _, container := range pod.Spec.Containers {
+		requestsCPU.Add(*container.Resources.Requests.Cpu())
+		requestsMemory.Add(*container.Resources.Requests.Memory())
+	}
+	return requestsCPU, requestsMemory
+}
+
+// Requests the scheduler reserves for a pod of the spec, the larger of the sum of its containers and each of its init
+// containers, which run one at a time before the containers
+func EffectivePodRequests(spec corev1.PodSpec) (resource.Quantity, resource.Quantity) {
+	return effective(spec, func(container corev1.Container) corev1.ResourceList { return container.Resources.Requests })
+}
+
+// Limits of a pod of the spec, computed like the effective requests
+func EffectivePodLimits(spec corev1.PodSpec) (resource.Quantity, resource.Quantity) {
+	return effective(spec, func(container corev1.Container) corev1.ResourceList { return container.Resources.Limits })
+}
+
+func effective(spec corev1.PodSpec, resources func(container corev1.Container) corev1.ResourceList) (resource.Quantity, resource.Quantity) {
+	var cpu, memory resource.Quantity
+	for _, container := range spec.Containers {
+		list := resources(container)
+		cpu.Add(*list.Cpu())
+		memory.Add(*list.Memory())
+	}
+	for _, container := range spec.InitContainers {
+		list := resources(container)
+		if list.Cpu().Cmp(cpu) > 0 {
+			cpu = list.Cpu().DeepCopy()
+		}
+		if list.Memory().Cmp(memory) > 0 {
+			memory = list.Memory().DeepCopy()
+		}
+	}
+	return cpu, memory
+}
@@ -0,0 +1,92 @@
+/*
+Copyright © 2021 Alex Krzos akrzos@redhat.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capacity
+
+import (
+	"strings"
+
+	"github.com/akrzos/kubeSize/internal/output"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Prefix of the allocatable resources of in-tree volume plugins limiting the volumes attached to a node, such as
+// attachable-volumes-aws-ebs
+const attachableVolumesPrefix = "attachable-volumes-"
+
+// Volumes that may be attached to a node, the allocatable of its in-tree volume plugins and the limit of its CSI drivers
+// from csiLimits, 0 when unknown
+func AttachLimit(node corev1.Node, csiLimits map[string]int64) int64 {
+	attachLimit := csiLimits[node.Name]
+	for resourceName, quantity := range node.Status.Allocatable {
+		if strings.HasPrefix(string(resourceName), attachableVolumesPrefix) {
+			attachLimit += quantity.Value()
+		}
+	}
+	return attachLimit
+}
+
+// Sets the volumes attached to each node against its attach limit, summed in the *total* and averaged in the *average*
+// node when present
+func AddAttachedVolumes(nodesCapacityData map[string]*output.NodeCapacityData, nodes *corev1.NodeList, csiLimits map[string]int64) {
+	var attachedVolumes int
+	var attachLimit int64
+	for _, node := range nodes.Items {
+		nodeData, ok := nodesCapacityData[node.Name]
+		if !ok {
+			continue
+		}
+		nodeData.AttachedVolumes = len(node.Status.VolumesAttached)
+		nodeData.AttachLimit = AttachLimit(node, csiLimits)
+		nodeData.AttachedVolumesPercent = attachedPercent(nodeData.AttachedVolumes, nodeData.AttachLimit)
+		attachedVolumes += nodeData.AttachedVolumes
+		attachLimit += nodeData.AttachLimit
+	}
+	if total, ok := nodesCapacityData["*total*"]; ok {
+		total.AttachedVolumes = attachedVolumes
+		total.AttachLimit = attachLimit
+		total.AttachedVolumesPercent = attachedPercent(attachedVolumes, attachLimit)
+	}
+	if average, ok := nodesCapacityData["*average*"]; ok && len(nodes.Items) > 0 {
+		average.AttachedVolumes = attachedVolumes / len(nodes.Items)
+		average.AttachLimit = attachLimit / int64(len(nodes.Items))
+		average.AttachedVolumesPercent = attachedPercent(average.AttachedVolumes, average.AttachLimit)
+	}
+}
+
+// Sums the volumes attached to the nodes and their attach limits into the cluster data, and counts the nodes at their
+// limit and those past warnThreshold percent of it. Nodes without a known limit are not counted.
+func AddClusterAttachedVolumes(clusterCapacityData *output.ClusterCapacityData, nodes *corev1.NodeList, csiLimits map[string]int64, warnThreshold float64) {
+	for _, node := range nodes.Items {
+		attachedVolumes := len(node.Status.VolumesAttached)
+		attachLimit := AttachLimit(node, csiLimits)
+		clusterCapacityData.TotalAttachedVolumes += attachedVolumes
+		clusterCapacityData.TotalAttachLimit += attachLimit
+		switch {
+		case attachLimit == 0:
+		case int64(attachedVolumes) >= attachLimit:
+			clusterCapacityData.AtAttachLimitNodeCount++
+		case attachedPercent(attachedVolumes, attachLimit) > warnThreshold:
+			clusterCapacityData.NearAttachLimitNodeCount++
+		}
+	}
+}
+
+func attachedPercent(attachedVolumes int, attachLimit int64) float64 {
+	if attachLimit == 0 {
+		return 0
+	}
+	return float64(attachedVolumes) / float64(attachLimit) * 100
+}